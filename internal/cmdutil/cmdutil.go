@@ -0,0 +1,59 @@
+// Package cmdutil provides POSIX shell quoting helpers for building remote
+// command lines.
+package cmdutil
+
+import "strings"
+
+// Quote returns arg quoted for safe use as a single word in a POSIX sh
+// command line. Arguments made up entirely of safe characters are returned
+// unchanged; anything else is wrapped in single quotes, with each embedded
+// single quote closed, backslash-escaped, and reopened.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if isSafe(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Join quotes each argument and joins them with spaces.
+func Join(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = Quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Wrap returns a command line that runs command under `sh -c`, preceded by
+// the given prefix argv (e.g. []string{"sudo", "-S"}). The command is passed
+// as a single quoted argument, so pipes, redirects, and && chains are all
+// evaluated inside the wrapped shell rather than split by the prefix.
+func Wrap(prefix []string, command string) string {
+	return WrapShell(prefix, "sh", command)
+}
+
+// WrapShell is like Wrap but runs command under shell instead of sh. An
+// empty shell means sh.
+func WrapShell(prefix []string, shell, command string) string {
+	if shell == "" {
+		shell = "sh"
+	}
+	args := append(append([]string{}, prefix...), shell, "-c")
+	return Join(args) + " " + Quote(command)
+}
+
+// isSafe reports whether s contains only characters that never need quoting.
+func isSafe(s string) bool {
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("@%+=:,./_-", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
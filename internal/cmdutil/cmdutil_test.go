@@ -0,0 +1,79 @@
+package cmdutil
+
+import "testing"
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", "''"},
+		{"plain word", "uptime", "uptime"},
+		{"path", "/var/log/syslog", "/var/log/syslog"},
+		{"flag with value", "--since=1h", "--since=1h"},
+		{"space", "my file", "'my file'"},
+		{"single quote", "it's", `'it'\''s'`},
+		{"glob", "*.log", "'*.log'"},
+		{"dollar", "$HOME", "'$HOME'"},
+		{"pipe and semicolon", "a|b;c", "'a|b;c'"},
+		{"double quote", `say "hi"`, `'say "hi"'`},
+		{"newline", "a\nb", "'a\nb'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Quote(tc.input); got != tc.want {
+				t.Errorf("Quote(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	got := Join([]string{"grep", "-r", "foo bar", "/etc"})
+	want := "grep -r 'foo bar' /etc"
+	if got != want {
+		t.Errorf("Join() = %q, want %q", got, want)
+	}
+
+	if got := Join(nil); got != "" {
+		t.Errorf("Join(nil) = %q, want empty", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  []string
+		command string
+		want    string
+	}{
+		{"simple", []string{"sudo"}, "whoami", "sudo sh -c whoami"},
+		{"with flags", []string{"sudo", "-S"}, "whoami", "sudo -S sh -c whoami"},
+		{"pipeline", []string{"sudo"}, "cat /etc/shadow | wc -l", "sudo sh -c 'cat /etc/shadow | wc -l'"},
+		{"redirect", []string{"sudo"}, "echo hi > /root/f", "sudo sh -c 'echo hi > /root/f'"},
+		{"embedded quote", []string{"sudo"}, "echo 'x'", `sudo sh -c 'echo '\''x'\'''`},
+		{"no prefix", nil, "ls && pwd", "sh -c 'ls && pwd'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Wrap(tc.prefix, tc.command); got != tc.want {
+				t.Errorf("Wrap(%v, %q) = %q, want %q", tc.prefix, tc.command, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWrapShell(t *testing.T) {
+	if got, want := WrapShell([]string{"sudo"}, "bash", "echo {a,b}"), "sudo bash -c 'echo {a,b}'"; got != want {
+		t.Errorf("WrapShell = %q, want %q", got, want)
+	}
+	if got, want := WrapShell(nil, "/usr/local/bin/bash", "ls"), "/usr/local/bin/bash -c ls"; got != want {
+		t.Errorf("WrapShell = %q, want %q", got, want)
+	}
+	if got, want := WrapShell(nil, "", "ls | wc -l"), Wrap(nil, "ls | wc -l"); got != want {
+		t.Errorf("WrapShell with no shell = %q, want %q", got, want)
+	}
+}
@@ -2,9 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -12,16 +18,100 @@ import (
 
 // Config represents the top-level herd configuration.
 type Config struct {
-	Groups   map[string]Group   `yaml:"groups"`
-	Defaults Defaults           `yaml:"defaults"`
-	Recipes  map[string]Recipe  `yaml:"recipes,omitempty"`
-	Parsers  map[string]Parser  `yaml:"parsers,omitempty"`
+	Groups   map[string]Group  `yaml:"groups"`
+	Defaults Defaults          `yaml:"defaults"`
+	Recipes  map[string]Recipe `yaml:"recipes,omitempty"`
+	Parsers  map[string]Parser `yaml:"parsers,omitempty"`
+
+	// IncludeIgnored disables Defaults.Ignore for this session, as an
+	// explicit override (--include-ignored). It is never read from YAML.
+	IncludeIgnored bool `yaml:"-"`
+
+	// Dedup drops hosts that share a connection target with an earlier
+	// host (see DuplicateTargets), as an explicit override (--dedup). It is
+	// never read from YAML.
+	Dedup bool `yaml:"-"`
+
+	// Warn receives warnings found while resolving hosts, such as hosts
+	// that share a connection target. If nil, they are written to stderr.
+	// It is never read from YAML.
+	Warn func(msg string) `yaml:"-"`
 }
 
 // Recipe defines a named multi-step command sequence.
 type Recipe struct {
-	Description string   `yaml:"description,omitempty"`
-	Steps       []string `yaml:"steps"`
+	Description string       `yaml:"description,omitempty"`
+	Steps       []RecipeStep `yaml:"steps"`
+}
+
+// RecipeStep is one step of a recipe. It supports two YAML forms:
+//   - A bare string: "@ok apt-get -y upgrade", a command with an optional
+//     leading selector
+//   - A map: {command: "apt-get -y upgrade", selector: "@ok", timeout: 10m,
+//     on_failure: abort}
+type RecipeStep struct {
+	Command  string `yaml:"command"`
+	Selector string `yaml:"selector,omitempty"`
+
+	// Timeout replaces the per-host command timeout for this step only.
+	Timeout Duration `yaml:"timeout,omitempty"`
+
+	// OnFailure is "continue" (the default) to run the next step whatever
+	// happened, or "abort" to stop the recipe if any host failed this step.
+	OnFailure string `yaml:"on_failure,omitempty"`
+
+	// RetryExitCodes are exit codes that mark a transient failure; hosts
+	// exiting with one are rerun up to Retries more times.
+	RetryExitCodes []int `yaml:"retry_exit_codes,omitempty"`
+	Retries        int   `yaml:"retries,omitempty"`
+
+	// ExitStatus maps exit codes to ok, warn or fail for this step. It
+	// overrides Defaults.ExitStatus.
+	ExitStatus map[int]string `yaml:"exit_status,omitempty"`
+}
+
+// PlainSteps returns recipe steps for raw step strings, each a command with
+// an optional leading selector, as in the bare string YAML form.
+func PlainSteps(raw ...string) []RecipeStep {
+	steps := make([]RecipeStep, len(raw))
+	for i, r := range raw {
+		steps[i] = RecipeStep{Command: r}
+	}
+	return steps
+}
+
+// String returns the step as a line of REPL input: its selector, if any,
+// followed by its command.
+func (s RecipeStep) String() string {
+	if s.Selector == "" {
+		return s.Command
+	}
+	return s.Selector + " " + s.Command
+}
+
+// UnmarshalYAML handles both bare string and map forms of recipe steps.
+func (s *RecipeStep) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = RecipeStep{Command: value.Value}
+		return nil
+	}
+	type raw RecipeStep // avoid infinite recursion
+	var r raw
+	if err := value.Decode(&r); err != nil {
+		return fmt.Errorf("invalid recipe step: %w", err)
+	}
+	*s = RecipeStep(r)
+	return nil
+}
+
+// MarshalYAML serializes as a bare string when only the command and
+// selector are set, preserving the compact format for existing configs.
+func (s RecipeStep) MarshalYAML() (interface{}, error) {
+	if !s.Timeout.IsSet() && s.OnFailure == "" && len(s.RetryExitCodes) == 0 && s.Retries == 0 && len(s.ExitStatus) == 0 {
+		return s.String(), nil
+	}
+	type raw RecipeStep
+	return raw(s), nil
 }
 
 // Parser defines named field-extraction rules for structured output parsing.
@@ -31,18 +121,65 @@ type Parser struct {
 }
 
 // ExtractRule defines how to extract a single field from command output.
+// A rule uses either Pattern, or one of Column and ColumnRange, optionally
+// with a Delimiter.
 type ExtractRule struct {
-	Field   string `yaml:"field"`
-	Pattern string `yaml:"pattern,omitempty"` // regex with capture group
-	Column  int    `yaml:"column,omitempty"`  // extract column by index (1-based)
+	Field       string `yaml:"field"`
+	Pattern     string `yaml:"pattern,omitempty"`      // regex with capture group
+	Column      int    `yaml:"column,omitempty"`       // extract column by index (1-based)
+	ColumnRange string `yaml:"column_range,omitempty"` // columns to join, e.g. "2-4" or "3-" (1-based)
+	Delimiter   string `yaml:"delimiter,omitempty"`    // split columns on this string instead of whitespace
+	NoHeader    bool   `yaml:"no_header,omitempty"`    // read columns from the first line rather than skipping it as a header
+}
+
+// ParseColumnRange parses an ExtractRule.ColumnRange of the form "N-M", or
+// "N-" for column N to the last. An open range returns an end of 0.
+func ParseColumnRange(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("column range %q must have the form N-M or N-", s)
+	}
+	start, err = strconv.Atoi(from)
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("column range %q: start must be a column number from 1", s)
+	}
+	if to == "" {
+		return start, 0, nil
+	}
+	end, err = strconv.Atoi(to)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("column range %q: end must be a column number no less than the start", s)
+	}
+	return start, end, nil
+}
+
+// Rewrite changes commands before they run on matching hosts, for fleets
+// that need different spellings of the same command. Replace is applied
+// first, then Prefix.
+type Rewrite struct {
+	Hosts   string `yaml:"hosts"`             // glob matched against host names, e.g. "pi-*"
+	Prefix  string `yaml:"prefix,omitempty"`  // prepended to the command, e.g. "sudo"
+	Replace string `yaml:"replace,omitempty"` // regular expression to replace in the command
+	With    string `yaml:"with,omitempty"`    // replacement for Replace; may use $1
 }
 
 // HostEntry represents a host in a group config. It supports two YAML forms:
 //   - A bare string: "pi-garage" (no tags)
-//   - A map: {host: "pi-garage", tags: [debian12, arm64]}
+//   - A map: {host: "pi-garage", tags: [debian12, arm64], priority: 10}
+//
+// In the map form, name is accepted in place of host.
 type HostEntry struct {
 	Host string   `yaml:"host"`
 	Tags []string `yaml:"tags,omitempty"`
+
+	// Priority orders resolved hosts: higher priorities come first, so
+	// canaries can be given a positive priority to run ahead of the rest.
+	// Hosts of equal priority keep their listed order.
+	Priority int `yaml:"priority,omitempty"`
+
+	// ProxyJump tunnels connections to the host through jump hosts, in
+	// place of any ProxyJump in ssh_config.
+	ProxyJump JumpChain `yaml:"proxy_jump,omitempty"`
 }
 
 // UnmarshalYAML handles both bare string and map forms of host entries.
@@ -53,44 +190,239 @@ func (h *HostEntry) UnmarshalYAML(value *yaml.Node) error {
 		return nil
 	}
 	type raw HostEntry // avoid infinite recursion
-	var r raw
+	var r struct {
+		raw  `yaml:",inline"`
+		Name string `yaml:"name"`
+	}
 	if err := value.Decode(&r); err != nil {
 		return fmt.Errorf("invalid host entry: %w", err)
 	}
-	if r.Host == "" {
+	switch {
+	case r.Host == "" && r.Name == "":
 		return fmt.Errorf("host entry missing required 'host' field")
+	case r.Host != "" && r.Name != "" && r.Host != r.Name:
+		return fmt.Errorf("host entry has both host %q and name %q", r.Host, r.Name)
+	case r.Host == "":
+		r.Host = r.Name
 	}
-	*h = HostEntry(r)
+	*h = HostEntry(r.raw)
 	return nil
 }
 
-// MarshalYAML serializes as a bare string when there are no tags,
-// preserving the compact format for existing configs.
+// MarshalYAML serializes as a bare string when there are no tags, priority
+// or proxy_jump, preserving the compact format for existing configs.
 func (h HostEntry) MarshalYAML() (interface{}, error) {
-	if len(h.Tags) == 0 {
+	if len(h.Tags) == 0 && h.Priority == 0 && h.ProxyJump.IsZero() {
 		return h.Host, nil
 	}
 	type raw HostEntry
 	return raw(h), nil
 }
 
+// JumpChain is a host entry's proxy_jump. It supports two YAML forms:
+//   - A ProxyJump string: "ops@bastion:2222,inner" (hops share the host's keys)
+//   - A list of hops: [{host: bastion, user: ops, port: 2222, identity_file: ~/.ssh/bastion}, {host: inner}]
+type JumpChain struct {
+	Spec string    // the string form; empty when Hops is set
+	Hops []JumpHop // the list form
+}
+
+// JumpHop is one hop of a JumpChain in list form. Host may be an ssh_config
+// alias; the other fields override ssh_config for the hop when set.
+type JumpHop struct {
+	Host         string `yaml:"host"`
+	User         string `yaml:"user,omitempty"`
+	Port         int    `yaml:"port,omitempty"`
+	IdentityFile string `yaml:"identity_file,omitempty"`
+}
+
+// UnmarshalYAML handles both the string and list forms of proxy_jump.
+func (j *JumpChain) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*j = JumpChain{Spec: value.Value}
+		return nil
+	}
+	var hops []JumpHop
+	if err := value.Decode(&hops); err != nil {
+		return fmt.Errorf("invalid proxy_jump: %w", err)
+	}
+	for i, hop := range hops {
+		if hop.Host == "" {
+			return fmt.Errorf("proxy_jump hop %d missing required 'host' field", i+1)
+		}
+		if hop.Port < 0 {
+			return fmt.Errorf("proxy_jump hop %d: port must be non-negative, got %d", i+1, hop.Port)
+		}
+	}
+	*j = JumpChain{Hops: hops}
+	return nil
+}
+
+// MarshalYAML serializes the chain in the form it was given.
+func (j JumpChain) MarshalYAML() (interface{}, error) {
+	if len(j.Hops) > 0 {
+		return j.Hops, nil
+	}
+	return j.Spec, nil
+}
+
+// IsZero reports whether no jump hosts are set, so omitempty drops the field.
+func (j JumpChain) IsZero() bool {
+	return j.Spec == "" && len(j.Hops) == 0
+}
+
+// String returns the chain as a ProxyJump spec, for display.
+func (j JumpChain) String() string {
+	if len(j.Hops) == 0 {
+		return j.Spec
+	}
+	specs := make([]string, len(j.Hops))
+	for i, hop := range j.Hops {
+		specs[i] = hop.String()
+	}
+	return strings.Join(specs, ",")
+}
+
+// String returns the hop in ProxyJump form, [user@]host[:port].
+func (h JumpHop) String() string {
+	spec := h.Host
+	if h.Port > 0 {
+		spec = net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+	}
+	if h.User != "" {
+		spec = h.User + "@" + spec
+	}
+	return spec
+}
+
 // Group defines a named set of hosts with optional overrides.
 type Group struct {
 	Hosts   []HostEntry `yaml:"hosts"`
 	User    string      `yaml:"user,omitempty"`
 	Timeout Duration    `yaml:"timeout,omitempty"`
+
+	// ConnectTimeout bounds connecting to each host in the group,
+	// separately from Timeout, which covers the whole command.
+	ConnectTimeout Duration `yaml:"connect_timeout,omitempty"`
+
+	// Shell runs commands on the group's hosts under this shell instead of
+	// the defaults' shell.
+	Shell string `yaml:"shell,omitempty"`
+
+	// Extends names a group whose settings this group inherits. Every field
+	// left unset is taken from that group (which may itself extend another);
+	// hosts are inherited only when the group lists none of its own.
+	Extends string `yaml:"extends,omitempty"`
 }
 
 // Defaults holds default settings.
 type Defaults struct {
 	Concurrency int      `yaml:"concurrency"`
 	Timeout     Duration `yaml:"timeout"`
-	Output      string   `yaml:"output"` // "grouped" or "json"
+	Output      string   `yaml:"output"`          // "grouped", "json", "jsonl" or "flat"
+	Color       string   `yaml:"color,omitempty"` // "auto", "always", or "never"
+
+	// ConnectTimeout bounds the TCP connection and SSH handshake with each
+	// host, so unreachable hosts fail fast while commands keep the longer
+	// Timeout. Zero leaves connecting bounded only by Timeout.
+	ConnectTimeout Duration `yaml:"connect_timeout,omitempty"`
+
+	// Shell runs every command as `<shell> -c '<command>'` rather than
+	// through each remote user's login shell, which may be csh, fish or a
+	// restricted shell. Empty uses the login shell.
+	Shell string `yaml:"shell,omitempty"`
+
+	// Rewrites change commands on matching hosts before they run, in order,
+	// e.g. to prefix them with sudo on hosts where that is needed.
+	Rewrites []Rewrite `yaml:"rewrites,omitempty"`
+
+	// WarnPatterns are regular expressions matched against REPL commands.
+	// A match requires confirmation before running on more than one host.
+	WarnPatterns []string `yaml:"warn_patterns,omitempty"`
+
+	// KnownHostsFile overrides ~/.ssh/known_hosts for host key verification.
+	// Several files may be given separated by spaces.
+	KnownHostsFile string `yaml:"known_hosts_file,omitempty"`
+
+	// DialProxy routes connections through a SOCKS5 or HTTP CONNECT proxy,
+	// e.g. "socks5://proxy:1080". See ssh.ClientConfig.DialProxy.
+	DialProxy string `yaml:"dial_proxy,omitempty"`
+
+	// SSHConfig reads ssh_config directives from this file, following its
+	// Include directives, instead of ~/.ssh/config and
+	// /etc/ssh/ssh_config.
+	SSHConfig string `yaml:"ssh_config,omitempty"`
+
+	// PasswordFile names a 0600 file of "host: password" lines used for
+	// password auth before prompting, for devices without key support.
+	PasswordFile string `yaml:"password_file,omitempty"`
+
+	// Ignore lists glob patterns for hosts that are never targeted, such
+	// as decommissioned machines still named in a group. Matching hosts are
+	// dropped from every host resolution unless IncludeIgnored is set.
+	Ignore []string `yaml:"ignore,omitempty"`
+
+	// ExitStatus maps a command name (its first word, e.g. "diff") to the
+	// meaning of its exit codes: ok, warn or fail. Unmapped codes fail
+	// unless they are 0.
+	ExitStatus map[string]map[int]string `yaml:"exit_status,omitempty"`
+
+	// StderrAsOutput shows the stderr of hosts that exit 0 without any
+	// stdout as their output, for tools that log only to stderr.
+	StderrAsOutput bool `yaml:"stderr_as_output,omitempty"`
+
+	// TrimWhitespace ignores leading and trailing whitespace on each line
+	// of output when grouping hosts; CollapseSpaces also treats each run of
+	// spaces and tabs as a single space.
+	TrimWhitespace bool `yaml:"trim_whitespace,omitempty"`
+	CollapseSpaces bool `yaml:"collapse_spaces,omitempty"`
+
+	// Masks are regular expressions for per-host details, such as
+	// timestamps and PIDs, that are ignored when grouping hosts.
+	Masks []string `yaml:"masks,omitempty"`
+
+	// Facts adds or overrides host fact probes: fact name -> shell command
+	// whose first line of output is the fact's value.
+	Facts map[string]string `yaml:"facts,omitempty"`
+
+	// FactsTTL is how long probed facts stay cached; 0 keeps them for the
+	// whole session.
+	FactsTTL Duration `yaml:"facts_ttl,omitempty"`
+
+	// KeepAlive is how often interactive sessions ping their pooled
+	// connections so NAT and firewalls don't drop them; 0 disables.
+	KeepAlive Duration `yaml:"keepalive,omitempty"`
+
+	// DialConcurrency limits how many hosts interactive sessions connect
+	// to at once; 0 is unlimited. See ssh.WithDialConcurrency.
+	DialConcurrency int `yaml:"dial_concurrency,omitempty"`
+
+	// SummaryTemplate replaces the summary line after each command with a
+	// Go text/template over its counts, e.g. "{{.Succeeded}} ok, {{.Failed}}
+	// failed in {{.Elapsed}}". Empty keeps the built-in summary.
+	SummaryTemplate string `yaml:"summary_template,omitempty"`
+}
+
+// DefaultWarnPatterns returns the built-in set of destructive-command
+// patterns that trigger a confirmation prompt in the REPL.
+func DefaultWarnPatterns() []string {
+	return []string{
+		`\brm\b`,
+		`\bdd\b`,
+		`\bmkfs(\.\w+)?\b`,
+		`\bshutdown\b`,
+		`\breboot\b`,
+	}
 }
 
 // Duration wraps time.Duration to support YAML unmarshaling from strings like "30s".
 type Duration struct {
 	time.Duration
+
+	// Unbounded is set when the duration is given explicitly as zero, such
+	// as "0" or "0s", to tell it apart from an unset duration. For timeouts
+	// it means no timeout at all; see Timeout.
+	Unbounded bool
 }
 
 func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
@@ -103,6 +435,7 @@ func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
 		return fmt.Errorf("invalid duration %q: %w", s, err)
 	}
 	d.Duration = dur
+	d.Unbounded = dur == 0
 	return nil
 }
 
@@ -110,14 +443,36 @@ func (d Duration) MarshalYAML() (interface{}, error) {
 	return d.Duration.String(), nil
 }
 
+// IsSet reports whether the duration was given, including as zero.
+func (d Duration) IsSet() bool {
+	return d.Duration != 0 || d.Unbounded
+}
+
+// NoTimeout is the timeout of a host or step configured with an explicit
+// zero timeout. It has the value of executor.NoTimeout, which callers pass
+// it to.
+const NoTimeout time.Duration = -1
+
+// Timeout interprets d as a command timeout. An unset duration is 0, so
+// the executor's default applies; an explicit zero is NoTimeout; anything
+// else is the duration itself.
+func (d Duration) Timeout() time.Duration {
+	if d.Unbounded {
+		return NoTimeout
+	}
+	return d.Duration
+}
+
 // DefaultConfig returns a Config with sensible default values.
 func DefaultConfig() *Config {
 	return &Config{
 		Groups: make(map[string]Group),
 		Defaults: Defaults{
-			Concurrency: 20,
-			Timeout:     Duration{30 * time.Second},
-			Output:      "grouped",
+			Concurrency:  20,
+			Timeout:      Duration{Duration: 30 * time.Second},
+			Output:       "grouped",
+			Color:        "auto",
+			WarnPatterns: DefaultWarnPatterns(),
 		},
 	}
 }
@@ -136,6 +491,16 @@ func DefaultConfigPath() string {
 	return filepath.Join(home, ".config", "herd", "config.yaml")
 }
 
+// DefaultHistoryPath returns the default REPL history file path, next to
+// the default config file.
+func DefaultHistoryPath() string {
+	path := DefaultConfigPath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "history")
+}
+
 // Load reads and parses a config YAML file from the given path.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -148,6 +513,10 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
 
+	if err := cfg.resolveExtends(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
@@ -190,6 +559,69 @@ func Save(path string, cfg *Config) error {
 	return nil
 }
 
+// resolveExtends fills in each group's inherited settings from the group it
+// extends, resolving chains of extends and rejecting unknown groups and
+// cycles.
+func (c *Config) resolveExtends() error {
+	resolved := make(map[string]bool, len(c.Groups))
+
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if resolved[name] {
+			return nil
+		}
+		g := c.Groups[name]
+		if g.Extends == "" {
+			resolved[name] = true
+			return nil
+		}
+		chain = append(chain, name)
+		for _, seen := range chain[:len(chain)-1] {
+			if seen == name {
+				return fmt.Errorf("group %q: extends cycle %s", name, strings.Join(chain, " -> "))
+			}
+		}
+		if _, ok := c.Groups[g.Extends]; !ok {
+			return fmt.Errorf("group %q extends unknown group %q", name, g.Extends)
+		}
+		if err := resolve(g.Extends, chain); err != nil {
+			return err
+		}
+
+		parent := c.Groups[g.Extends]
+		if len(g.Hosts) == 0 {
+			g.Hosts = append([]HostEntry(nil), parent.Hosts...)
+		}
+		if g.User == "" {
+			g.User = parent.User
+		}
+		if !g.Timeout.IsSet() {
+			g.Timeout = parent.Timeout
+		}
+		if !g.ConnectTimeout.IsSet() {
+			g.ConnectTimeout = parent.ConnectTimeout
+		}
+		if g.Shell == "" {
+			g.Shell = parent.Shell
+		}
+		c.Groups[name] = g
+		resolved[name] = true
+		return nil
+	}
+
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Validate checks the config for logical errors.
 func (c *Config) Validate() error {
 	if c.Defaults.Concurrency < 0 {
@@ -198,16 +630,81 @@ func (c *Config) Validate() error {
 	if c.Defaults.Timeout.Duration < 0 {
 		return fmt.Errorf("default timeout must be non-negative, got %s", c.Defaults.Timeout)
 	}
+	if c.Defaults.ConnectTimeout.Duration < 0 {
+		return fmt.Errorf("connect_timeout must be non-negative, got %s", c.Defaults.ConnectTimeout)
+	}
+	if c.Defaults.KeepAlive.Duration < 0 {
+		return fmt.Errorf("keepalive must be non-negative, got %s", c.Defaults.KeepAlive)
+	}
+	if c.Defaults.DialConcurrency < 0 {
+		return fmt.Errorf("dial_concurrency must be non-negative, got %d", c.Defaults.DialConcurrency)
+	}
 
-	validOutputModes := map[string]bool{"grouped": true, "json": true}
+	validOutputModes := map[string]bool{"grouped": true, "json": true, "jsonl": true, "flat": true}
 	if c.Defaults.Output != "" && !validOutputModes[c.Defaults.Output] {
-		return fmt.Errorf("invalid output mode %q, must be one of: grouped, json", c.Defaults.Output)
+		return fmt.Errorf("invalid output mode %q, must be one of: grouped, json, jsonl, flat", c.Defaults.Output)
+	}
+
+	validColorModes := map[string]bool{"auto": true, "always": true, "never": true}
+	if c.Defaults.Color != "" && !validColorModes[c.Defaults.Color] {
+		return fmt.Errorf("invalid color mode %q, must be one of: auto, always, never", c.Defaults.Color)
+	}
+
+	for _, pat := range c.Defaults.WarnPatterns {
+		if _, err := regexp.Compile(pat); err != nil {
+			return fmt.Errorf("invalid warn pattern %q: %w", pat, err)
+		}
+	}
+
+	for _, pat := range c.Defaults.Masks {
+		if _, err := regexp.Compile(pat); err != nil {
+			return fmt.Errorf("invalid mask %q: %w", pat, err)
+		}
+	}
+
+	for i, rw := range c.Defaults.Rewrites {
+		if err := validateRewrite(rw); err != nil {
+			return fmt.Errorf("rewrite %d: %w", i+1, err)
+		}
+	}
+
+	if c.Defaults.SummaryTemplate != "" {
+		if _, err := template.New("summary").Parse(c.Defaults.SummaryTemplate); err != nil {
+			return fmt.Errorf("invalid summary template: %w", err)
+		}
+	}
+
+	for name, codes := range c.Defaults.ExitStatus {
+		if err := validateExitStatus(codes); err != nil {
+			return fmt.Errorf("exit_status for %q: %w", name, err)
+		}
+	}
+
+	for _, pat := range c.Defaults.Ignore {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", pat, err)
+		}
 	}
 
 	nameRe := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
+	// Fact names are spliced into the probe command, so they must be plain.
+	for name := range c.Defaults.Facts {
+		if !nameRe.MatchString(name) {
+			return fmt.Errorf("fact name %q must match [a-zA-Z0-9_-]+", name)
+		}
+	}
+
+	// A group that only serves as a base for others may omit hosts.
+	extended := make(map[string]bool)
+	for _, group := range c.Groups {
+		if group.Extends != "" {
+			extended[group.Extends] = true
+		}
+	}
+
 	for name, group := range c.Groups {
-		if len(group.Hosts) == 0 {
+		if len(group.Hosts) == 0 && !extended[name] {
 			return fmt.Errorf("group %q has no hosts", name)
 		}
 		for i, entry := range group.Hosts {
@@ -223,6 +720,9 @@ func (c *Config) Validate() error {
 		if group.Timeout.Duration < 0 {
 			return fmt.Errorf("group %q has negative timeout: %s", name, group.Timeout)
 		}
+		if group.ConnectTimeout.Duration < 0 {
+			return fmt.Errorf("group %q has negative connect_timeout: %s", name, group.ConnectTimeout)
+		}
 	}
 	for name, recipe := range c.Recipes {
 		if !nameRe.MatchString(name) {
@@ -231,6 +731,11 @@ func (c *Config) Validate() error {
 		if len(recipe.Steps) == 0 {
 			return fmt.Errorf("recipe %q has no steps", name)
 		}
+		for i, step := range recipe.Steps {
+			if err := validateStep(step); err != nil {
+				return fmt.Errorf("recipe %q step %d: %w", name, i+1, err)
+			}
+		}
 	}
 
 	for name, parser := range c.Parsers {
@@ -244,11 +749,168 @@ func (c *Config) Validate() error {
 			if rule.Field == "" {
 				return fmt.Errorf("parser %q rule %d has empty field name", name, i)
 			}
-			if rule.Pattern == "" && rule.Column == 0 {
-				return fmt.Errorf("parser %q rule %d (%s) must have pattern or column", name, i, rule.Field)
+			if err := validateExtractRule(rule); err != nil {
+				return fmt.Errorf("parser %q rule %d (%s): %w", name, i, rule.Field, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateExtractRule checks that rule uses exactly one extraction mode.
+func validateExtractRule(rule ExtractRule) error {
+	switch {
+	case rule.Pattern != "" && (rule.Column != 0 || rule.ColumnRange != "" || rule.Delimiter != "" || rule.NoHeader):
+		return fmt.Errorf("pattern cannot be combined with column, column_range, delimiter or no_header")
+	case rule.Column != 0 && rule.ColumnRange != "":
+		return fmt.Errorf("column and column_range are mutually exclusive")
+	case rule.Pattern == "" && rule.Column == 0 && rule.ColumnRange == "":
+		return fmt.Errorf("must have pattern, column or column_range")
+	case rule.Column < 0:
+		return fmt.Errorf("column must be at least 1")
+	}
+	if rule.ColumnRange != "" {
+		if _, _, err := ParseColumnRange(rule.ColumnRange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateExitStatus checks an exit code to status mapping.
+func validateExitStatus(codes map[int]string) error {
+	for code, status := range codes {
+		if code < 0 || code > 255 {
+			return fmt.Errorf("exit code %d must be between 0 and 255", code)
+		}
+		switch status {
+		case "ok", "warn", "fail":
+		default:
+			return fmt.Errorf("exit code %d: invalid exit status %q (want ok, warn or fail)", code, status)
+		}
+	}
+	return nil
+}
+
+// ExitMap returns the exit code mapping configured in Defaults.ExitStatus
+// for command, looked up by its first word. It returns nil if c is nil or
+// nothing is configured.
+func (c *Config) ExitMap(command string) map[int]string {
+	if c == nil {
+		return nil
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+	return c.Defaults.ExitStatus[fields[0]]
+}
+
+// MaskPatterns compiles Defaults.Masks, skipping any that fail to compile
+// (Validate reports those at load time). It returns nil if c is nil.
+func (c *Config) MaskPatterns() []*regexp.Regexp {
+	if c == nil || len(c.Defaults.Masks) == 0 {
+		return nil
+	}
+	res := make([]*regexp.Regexp, 0, len(c.Defaults.Masks))
+	for _, pat := range c.Defaults.Masks {
+		if re, err := regexp.Compile(pat); err == nil {
+			res = append(res, re)
+		}
+	}
+	return res
+}
+
+// validateRewrite checks that a rewrite rule names its hosts and changes the
+// command in some way.
+func validateRewrite(rw Rewrite) error {
+	if rw.Hosts == "" {
+		return fmt.Errorf("hosts pattern required")
+	}
+	if _, err := path.Match(rw.Hosts, ""); err != nil {
+		return fmt.Errorf("invalid hosts pattern %q: %w", rw.Hosts, err)
+	}
+	if rw.Prefix == "" && rw.Replace == "" {
+		return fmt.Errorf("prefix or replace required")
+	}
+	if rw.With != "" && rw.Replace == "" {
+		return fmt.Errorf("with requires replace")
+	}
+	if _, err := regexp.Compile(rw.Replace); err != nil {
+		return fmt.Errorf("invalid replace pattern %q: %w", rw.Replace, err)
+	}
+	return nil
+}
+
+// CommandRewriter returns a function applying Defaults.Rewrites to a command
+// for one host, for use with executor.WithRewrite. Every rule whose Hosts
+// glob matches the host applies, in order, each to the previous rule's
+// result. Rules that fail to compile are skipped (Validate reports those at
+// load time). It returns nil if c is nil or has no rules.
+func (c *Config) CommandRewriter() func(host, command string) string {
+	if c == nil || len(c.Defaults.Rewrites) == 0 {
+		return nil
+	}
+	type rule struct {
+		Rewrite
+		re *regexp.Regexp
+	}
+	var rules []rule
+	for _, rw := range c.Defaults.Rewrites {
+		r := rule{Rewrite: rw}
+		if rw.Replace != "" {
+			re, err := regexp.Compile(rw.Replace)
+			if err != nil {
+				continue
+			}
+			r.re = re
+		}
+		rules = append(rules, r)
+	}
+
+	return func(host, command string) string {
+		for _, r := range rules {
+			if ok, _ := path.Match(r.Hosts, host); !ok {
+				continue
+			}
+			if r.re != nil {
+				command = r.re.ReplaceAllString(command, r.With)
+			}
+			if r.Prefix != "" {
+				command = r.Prefix + " " + command
 			}
 		}
+		return command
 	}
+}
 
+// validateStep checks a recipe step's timeout, failure policy, retry rule
+// and exit status mapping. Its selector is checked by the recipe package,
+// which can parse selectors.
+func validateStep(step RecipeStep) error {
+	if strings.TrimSpace(step.String()) == "" {
+		return fmt.Errorf("step has no command")
+	}
+	if step.Timeout.Duration < 0 {
+		return fmt.Errorf("negative timeout: %s", step.Timeout)
+	}
+	if step.OnFailure != "" && step.OnFailure != "continue" && step.OnFailure != "abort" {
+		return fmt.Errorf("invalid on_failure %q, must be continue or abort", step.OnFailure)
+	}
+	if len(step.RetryExitCodes) > 0 && step.Retries < 1 {
+		return fmt.Errorf("retries must be at least 1, got %d", step.Retries)
+	}
+	if step.Retries != 0 && len(step.RetryExitCodes) == 0 {
+		return fmt.Errorf("retries set with no retry_exit_codes")
+	}
+	for _, code := range step.RetryExitCodes {
+		if code < 1 || code > 255 {
+			return fmt.Errorf("retry exit code %d must be between 1 and 255", code)
+		}
+	}
+	if err := validateExitStatus(step.ExitStatus); err != nil {
+		return fmt.Errorf("exit_status: %w", err)
+	}
 	return nil
 }
@@ -3,8 +3,12 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -19,6 +23,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.Defaults.Output != "grouped" {
 		t.Errorf("default output = %q, want \"grouped\"", cfg.Defaults.Output)
 	}
+	if cfg.Defaults.Color != "auto" {
+		t.Errorf("default color = %q, want \"auto\"", cfg.Defaults.Color)
+	}
 	if cfg.Groups == nil {
 		t.Error("default groups map should not be nil")
 	}
@@ -167,6 +174,190 @@ func TestValidateStreamOutputRejected(t *testing.T) {
 	}
 }
 
+func TestValidateFlatOutput(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.Output = "flat"
+	cfg.Groups["test"] = Group{Hosts: strHosts("host1")}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("flat output mode rejected: %v", err)
+	}
+}
+
+func TestValidateKeepAlive(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.KeepAlive = Duration{Duration: 30 * time.Second}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid keepalive rejected: %v", err)
+	}
+
+	cfg.Defaults.KeepAlive = Duration{Duration: -time.Second}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for negative keepalive")
+	}
+
+	cfg.Defaults.KeepAlive = Duration{}
+	cfg.Defaults.DialConcurrency = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for negative dial_concurrency")
+	}
+}
+
+func TestValidateColorMode(t *testing.T) {
+	for _, mode := range []string{"", "auto", "always", "never"} {
+		cfg := DefaultConfig()
+		cfg.Defaults.Color = mode
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("color mode %q: unexpected error: %v", mode, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Defaults.Color = "sometimes"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for invalid color mode")
+	}
+}
+
+func TestValidateWarnPatterns(t *testing.T) {
+	cfg := DefaultConfig()
+	if len(cfg.Defaults.WarnPatterns) == 0 {
+		t.Fatal("expected default warn patterns")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("default warn patterns should validate: %v", err)
+	}
+
+	cfg.Defaults.WarnPatterns = []string{`([unclosed`}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for invalid warn pattern")
+	}
+}
+
+func TestValidateMasks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.Masks = []string{`\d{2}:\d{2}:\d{2}`, `PID: \d+`}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid masks rejected: %v", err)
+	}
+	if got := len(cfg.MaskPatterns()); got != 2 {
+		t.Errorf("MaskPatterns() returned %d patterns, want 2", got)
+	}
+
+	cfg.Defaults.Masks = []string{`([unclosed`}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for invalid mask")
+	}
+
+	var nilCfg *Config
+	if nilCfg.MaskPatterns() != nil {
+		t.Error("nil config should have no masks")
+	}
+}
+
+func TestCommandRewriter(t *testing.T) {
+	content := `
+defaults:
+  rewrites:
+    - hosts: "old-*"
+      replace: '\bapt\b'
+      with: apt-get
+    - hosts: "*"
+      replace: '^reboot$'
+      with: systemctl reboot
+    - hosts: "pi-*"
+      prefix: sudo
+`
+	cfg := loadFromString(t, content)
+	rewrite := cfg.CommandRewriter()
+	if rewrite == nil {
+		t.Fatal("CommandRewriter() = nil, want rules")
+	}
+
+	tests := []struct {
+		host, command, want string
+	}{
+		{"web-01", "apt update", "apt update"},
+		{"old-01", "apt update && apt upgrade", "apt-get update && apt-get upgrade"},
+		{"old-01", "aptitude", "aptitude"},
+		{"pi-01", "apt update", "sudo apt update"},
+		{"pi-01", "reboot", "sudo systemctl reboot"},
+	}
+	for _, tc := range tests {
+		if got := rewrite(tc.host, tc.command); got != tc.want {
+			t.Errorf("rewrite(%q, %q) = %q, want %q", tc.host, tc.command, got, tc.want)
+		}
+	}
+
+	var nilCfg *Config
+	if nilCfg.CommandRewriter() != nil || DefaultConfig().CommandRewriter() != nil {
+		t.Error("a config without rules should have no rewriter")
+	}
+}
+
+func TestValidateRewrites(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite Rewrite
+		wantErr string
+	}{
+		{"no hosts", Rewrite{Prefix: "sudo"}, "hosts pattern required"},
+		{"bad hosts", Rewrite{Hosts: "pi-[", Prefix: "sudo"}, "invalid hosts pattern"},
+		{"no change", Rewrite{Hosts: "pi-*"}, "prefix or replace required"},
+		{"with alone", Rewrite{Hosts: "pi-*", Prefix: "sudo", With: "x"}, "with requires replace"},
+		{"bad regex", Rewrite{Hosts: "pi-*", Replace: "(["}, "invalid replace pattern"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Defaults.Rewrites = []Rewrite{{Hosts: "*", Prefix: "nice"}, tc.rewrite}
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), "rewrite 2: "+tc.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateIgnorePatterns(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.Ignore = []string{"*-old", "decom-[0-9]*"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid ignore patterns rejected: %v", err)
+	}
+
+	cfg.Defaults.Ignore = []string{"web-["}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for malformed ignore pattern")
+	}
+}
+
+func TestValidateFactNames(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.Facts = map[string]string{"role": "cat /etc/role", "docker_version": "docker --version"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid fact names rejected: %v", err)
+	}
+
+	cfg.Defaults.Facts = map[string]string{`x"; rm -rf /; echo "`: "true"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for a fact name with shell syntax")
+	}
+}
+
+func TestValidateSummaryTemplate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.SummaryTemplate = "{{.Succeeded}} ok, {{.Failed}} failed in {{.Elapsed}}"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid summary template rejected: %v", err)
+	}
+
+	cfg.Defaults.SummaryTemplate = "{{.Succeeded"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for malformed summary template")
+	}
+}
+
 func TestValidateEmptyGroup(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Groups["empty"] = Group{Hosts: []HostEntry{}}
@@ -176,6 +367,238 @@ func TestValidateEmptyGroup(t *testing.T) {
 	}
 }
 
+func TestGroupExtends(t *testing.T) {
+	content := `
+groups:
+  base:
+    user: deploy
+    timeout: 10s
+  web:
+    extends: base
+    hosts: [web-01, web-02]
+  web-canary:
+    extends: web
+    hosts: [web-03]
+    user: canary
+  web-all:
+    extends: web
+`
+	cfg := loadFromString(t, content)
+
+	web := cfg.Groups["web"]
+	if web.User != "deploy" || web.Timeout.Duration != 10*time.Second {
+		t.Errorf("web: expected inherited user and timeout, got %q %s", web.User, web.Timeout)
+	}
+
+	canary := cfg.Groups["web-canary"]
+	if canary.User != "canary" {
+		t.Errorf("web-canary: expected own user to win, got %q", canary.User)
+	}
+	if canary.Timeout.Duration != 10*time.Second {
+		t.Errorf("web-canary: expected timeout inherited through web, got %s", canary.Timeout)
+	}
+	if len(canary.Hosts) != 1 || canary.Hosts[0].Host != "web-03" {
+		t.Errorf("web-canary: expected own hosts to replace inherited ones, got %v", canary.Hosts)
+	}
+
+	all := cfg.Groups["web-all"]
+	if len(all.Hosts) != 2 || all.Hosts[0].Host != "web-01" {
+		t.Errorf("web-all: expected hosts inherited from web, got %v", all.Hosts)
+	}
+}
+
+func TestConnectTimeoutConfig(t *testing.T) {
+	content := `
+defaults:
+  connect_timeout: 5s
+groups:
+  base:
+    connect_timeout: 20s
+  wan:
+    extends: base
+    hosts: [remote-01]
+  lan:
+    extends: base
+    connect_timeout: 0
+    hosts: [local-01]
+`
+	cfg := loadFromString(t, content)
+	if lan := cfg.Groups["lan"].ConnectTimeout; !lan.Unbounded || lan.Duration != 0 {
+		t.Errorf("lan connect_timeout = %+v, want its own explicit 0 kept over extends", lan)
+	}
+	if cfg.Defaults.ConnectTimeout.Duration != 5*time.Second {
+		t.Errorf("defaults.connect_timeout = %s, want 5s", cfg.Defaults.ConnectTimeout)
+	}
+	hosts, err := ResolveHosts(cfg, "wan", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts: %v", err)
+	}
+	if hosts[0].ConnectTimeout != 20*time.Second {
+		t.Errorf("host connect timeout = %s, want 20s inherited through extends", hosts[0].ConnectTimeout)
+	}
+
+	cfg.Defaults.ConnectTimeout = Duration{Duration: -time.Second}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected validation error for negative connect_timeout")
+	}
+}
+
+func TestTimeoutStates(t *testing.T) {
+	content := `
+defaults:
+  timeout: 0
+groups:
+  base:
+    timeout: 0s
+    hosts: [backup-01]
+  child:
+    extends: base
+    hosts: [backup-02]
+  web:
+    timeout: 10s
+    hosts: [web-01]
+  plain:
+    hosts: [db-01]
+`
+	cfg := loadFromString(t, content)
+	if !cfg.Defaults.Timeout.Unbounded || cfg.Defaults.Timeout.Timeout() != NoTimeout {
+		t.Errorf("defaults.timeout 0 = %+v, want unbounded", cfg.Defaults.Timeout)
+	}
+
+	tests := []struct {
+		group string
+		want  time.Duration
+	}{
+		{"base", NoTimeout},
+		{"child", NoTimeout}, // explicit zero is inherited through extends
+		{"web", 10 * time.Second},
+		{"plain", 0}, // unset: the executor's default applies
+	}
+	for _, tt := range tests {
+		hosts, err := ResolveHosts(cfg, tt.group, nil)
+		if err != nil {
+			t.Fatalf("ResolveHosts(%s): %v", tt.group, err)
+		}
+		if hosts[0].Timeout != tt.want {
+			t.Errorf("group %s: host timeout = %v, want %v", tt.group, hosts[0].Timeout, tt.want)
+		}
+	}
+
+	var unset Duration
+	if unset.IsSet() || unset.Timeout() != 0 {
+		t.Errorf("zero Duration: IsSet = %v, Timeout = %v; want unset", unset.IsSet(), unset.Timeout())
+	}
+}
+
+func TestHostTimeouts(t *testing.T) {
+	hosts := []Host{
+		{Name: "a", Timeout: 10 * time.Second},
+		{Name: "b"},
+		{Name: "c", Timeout: NoTimeout},
+	}
+	got := HostTimeouts(hosts)
+	want := map[string]time.Duration{"a": 10 * time.Second, "c": NoTimeout}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HostTimeouts = %v, want %v", got, want)
+	}
+	if got := HostTimeouts([]Host{{Name: "b"}}); got != nil {
+		t.Errorf("HostTimeouts without timeouts = %v, want nil", got)
+	}
+}
+
+func TestShellConfig(t *testing.T) {
+	content := `
+defaults:
+  shell: bash
+groups:
+  base:
+    shell: /usr/local/bin/bash
+  bsd:
+    extends: base
+    hosts: [freebsd-01]
+  linux:
+    hosts: [web-01]
+`
+	cfg := loadFromString(t, content)
+	if cfg.Defaults.Shell != "bash" {
+		t.Errorf("defaults.shell = %q, want bash", cfg.Defaults.Shell)
+	}
+	hosts, err := ResolveHosts(cfg, "bsd", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts: %v", err)
+	}
+	if hosts[0].Shell != "/usr/local/bin/bash" {
+		t.Errorf("host shell = %q, want /usr/local/bin/bash inherited through extends", hosts[0].Shell)
+	}
+	hosts, err = ResolveHosts(cfg, "linux", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts: %v", err)
+	}
+	if hosts[0].Shell != "" {
+		t.Errorf("host shell = %q, want empty to use the default", hosts[0].Shell)
+	}
+}
+
+func TestGroupExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "unknown group",
+			content: `
+groups:
+  web:
+    extends: nope
+    hosts: [web-01]
+`,
+			wantErr: `group "web" extends unknown group "nope"`,
+		},
+		{
+			name: "cycle",
+			content: `
+groups:
+  a:
+    extends: b
+    hosts: [h1]
+  b:
+    extends: c
+  c:
+    extends: a
+`,
+			wantErr: "extends cycle a -> b -> c -> a",
+		},
+		{
+			name: "self",
+			content: `
+groups:
+  a:
+    extends: a
+    hosts: [h1]
+`,
+			wantErr: "extends cycle a -> a",
+		},
+		{
+			name: "base without hosts is only allowed when extended",
+			content: `
+groups:
+  base:
+    user: deploy
+`,
+			wantErr: `group "base" has no hosts`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadStringRaw(tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestValidateNegativeConcurrency(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Defaults.Concurrency = -1
@@ -234,8 +657,8 @@ recipes:
 	if len(deploy.Steps) != 2 {
 		t.Errorf("deploy steps = %d, want 2", len(deploy.Steps))
 	}
-	if deploy.Steps[0] != "git pull" {
-		t.Errorf("deploy.Steps[0] = %q, want %q", deploy.Steps[0], "git pull")
+	if deploy.Steps[0].Command != "git pull" {
+		t.Errorf("deploy.Steps[0] = %q, want %q", deploy.Steps[0].Command, "git pull")
 	}
 }
 
@@ -245,7 +668,7 @@ func TestRecipeValidation(t *testing.T) {
 
 	// Empty steps should fail.
 	cfg.Recipes = map[string]Recipe{
-		"empty": {Steps: []string{}},
+		"empty": {Steps: PlainSteps()},
 	}
 	if err := cfg.Validate(); err == nil {
 		t.Error("expected validation error for recipe with no steps")
@@ -253,13 +676,182 @@ func TestRecipeValidation(t *testing.T) {
 
 	// Invalid name should fail.
 	cfg.Recipes = map[string]Recipe{
-		"bad name!": {Steps: []string{"echo hi"}},
+		"bad name!": {Steps: PlainSteps("echo hi")},
 	}
 	if err := cfg.Validate(); err == nil {
 		t.Error("expected validation error for invalid recipe name")
 	}
 }
 
+func TestRecipeStructuredSteps(t *testing.T) {
+	content := `
+groups:
+  test:
+    hosts:
+      - host1
+
+recipes:
+  deploy:
+    steps:
+      - "apt-get update"
+      - command: "apt-get -y upgrade"
+        selector: "@ok"
+        timeout: 10m
+        on_failure: abort
+`
+	cfg := loadFromString(t, content)
+
+	steps := cfg.Recipes["deploy"].Steps
+	if len(steps) != 2 {
+		t.Fatalf("deploy steps = %d, want 2", len(steps))
+	}
+	if !reflect.DeepEqual(steps[0], RecipeStep{Command: "apt-get update"}) {
+		t.Errorf("plain step = %+v", steps[0])
+	}
+	want := RecipeStep{Command: "apt-get -y upgrade", Selector: "@ok", Timeout: Duration{Duration: 10 * time.Minute}, OnFailure: "abort"}
+	if !reflect.DeepEqual(steps[1], want) {
+		t.Errorf("structured step = %+v, want %+v", steps[1], want)
+	}
+	if steps[1].String() != "@ok apt-get -y upgrade" {
+		t.Errorf("String() = %q", steps[1].String())
+	}
+
+	out, err := yaml.Marshal(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "- apt-get update\n- command: apt-get -y upgrade\n") {
+		t.Errorf("plain steps should marshal as strings, got:\n%s", out)
+	}
+
+	// An explicit zero timeout must survive a round trip.
+	unbounded := RecipeStep{Command: "backup", Timeout: Duration{Unbounded: true}}
+	out, err = yaml.Marshal(unbounded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back RecipeStep
+	if err := yaml.Unmarshal(out, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.Timeout.Unbounded {
+		t.Errorf("step with timeout 0 marshalled as:\n%s", out)
+	}
+}
+
+func TestRecipeStepOptionsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    RecipeStep
+		wantErr string
+	}{
+		{"abort", RecipeStep{Command: "uptime", OnFailure: "abort"}, ""},
+		{"continue with timeout", RecipeStep{Command: "uptime", Timeout: Duration{Duration: time.Minute}, OnFailure: "continue"}, ""},
+		{"bad on_failure", RecipeStep{Command: "uptime", OnFailure: "stop"}, `invalid on_failure "stop"`},
+		{"negative timeout", RecipeStep{Command: "uptime", Timeout: Duration{Duration: -time.Second}}, "negative timeout"},
+		{"missing command", RecipeStep{}, "has no command"},
+		{"retry", RecipeStep{Command: "apt-get update", RetryExitCodes: []int{100}, Retries: 2}, ""},
+		{"retry without retries", RecipeStep{Command: "apt-get update", RetryExitCodes: []int{100}}, "retries must be at least 1"},
+		{"retries without codes", RecipeStep{Command: "apt-get update", Retries: 1}, "no retry_exit_codes"},
+		{"zero retry code", RecipeStep{Command: "apt-get update", RetryExitCodes: []int{0}, Retries: 1}, "between 1 and 255"},
+		{"exit status", RecipeStep{Command: "diff a b", ExitStatus: map[int]string{1: "fail"}}, ""},
+		{"bad exit status", RecipeStep{Command: "diff a b", ExitStatus: map[int]string{1: "maybe"}}, "exit_status: exit code 1: invalid exit status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Groups["test"] = Group{Hosts: strHosts("host1")}
+			cfg.Recipes = map[string]Recipe{"r": {Steps: []RecipeStep{tt.step}}}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecipeRetryConfig(t *testing.T) {
+	content := `
+groups:
+  test:
+    hosts:
+      - host1
+
+recipes:
+  update:
+    steps:
+      - command: "apt-get update"
+        retry_exit_codes: [100]
+        retries: 3
+        exit_status: {1: warn}
+      - "apt-get -y upgrade"
+`
+	cfg := loadFromString(t, content)
+	got := cfg.Recipes["update"].Steps[0]
+	if !reflect.DeepEqual(got.RetryExitCodes, []int{100}) || got.Retries != 3 {
+		t.Errorf("step 1 = %+v, want retry_exit_codes [100], retries 3", got)
+	}
+	if got.ExitStatus[1] != "warn" {
+		t.Errorf("step 1 exit_status = %v, want {1: warn}", got.ExitStatus)
+	}
+}
+
+func TestValidateExitStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(c *Config) {
+			c.Defaults.ExitStatus = map[string]map[int]string{"diff": {1: "ok"}, "grep": {1: "warn"}}
+		}},
+		{name: "bad status", modify: func(c *Config) {
+			c.Defaults.ExitStatus = map[string]map[int]string{"diff": {1: "maybe"}}
+		}, wantErr: "invalid exit status"},
+		{name: "code out of range", modify: func(c *Config) {
+			c.Defaults.ExitStatus = map[string]map[int]string{"diff": {256: "ok"}}
+		}, wantErr: "between 0 and 255"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExitMap(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Defaults.ExitStatus = map[string]map[int]string{"diff": {1: "ok"}}
+
+	if got := cfg.ExitMap("diff -u /etc/a /etc/b"); got[1] != "ok" {
+		t.Errorf("ExitMap(diff) = %v, want {1: ok}", got)
+	}
+	if got := cfg.ExitMap("grep foo"); got != nil {
+		t.Errorf("ExitMap(grep) = %v, want nil", got)
+	}
+	var nilCfg *Config
+	if got := nilCfg.ExitMap("diff"); got != nil {
+		t.Errorf("nil config ExitMap = %v, want nil", got)
+	}
+}
+
 func TestParserConfig(t *testing.T) {
 	content := `
 groups:
@@ -313,6 +905,33 @@ func TestParserValidation(t *testing.T) {
 	if err := cfg.Validate(); err == nil {
 		t.Error("expected validation error for rule without pattern or column")
 	}
+
+	for name, rule := range map[string]ExtractRule{
+		"pattern and column":      {Field: "x", Pattern: `(\d+)`, Column: 1},
+		"pattern and delimiter":   {Field: "x", Pattern: `(\d+)`, Delimiter: ":"},
+		"pattern and no_header":   {Field: "x", Pattern: `(\d+)`, NoHeader: true},
+		"column and column_range": {Field: "x", Column: 1, ColumnRange: "2-3"},
+		"delimiter alone":         {Field: "x", Delimiter: ":"},
+		"malformed column_range":  {Field: "x", ColumnRange: "2"},
+		"backwards column_range":  {Field: "x", ColumnRange: "4-2"},
+		"zero start column_range": {Field: "x", ColumnRange: "0-2"},
+	} {
+		cfg.Parsers = map[string]Parser{"bad": {Extract: []ExtractRule{rule}}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	for _, rule := range []ExtractRule{
+		{Field: "x", ColumnRange: "2-4", Delimiter: ":"},
+		{Field: "x", ColumnRange: "3-"},
+		{Field: "x", Column: 6, Delimiter: ",", NoHeader: true},
+	} {
+		cfg.Parsers = map[string]Parser{"good": {Extract: []ExtractRule{rule}}}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error: %v", rule, err)
+		}
+	}
 }
 
 func TestSaveConfig(t *testing.T) {
@@ -400,6 +1019,87 @@ groups:
 	}
 }
 
+func TestHostEntryPriority(t *testing.T) {
+	content := `
+groups:
+  web:
+    hosts:
+      - host: canary
+        priority: 10
+      - web-01
+`
+	cfg := loadFromString(t, content)
+	hosts := cfg.Groups["web"].Hosts
+	if hosts[0].Priority != 10 || hosts[1].Priority != 0 {
+		t.Errorf("priorities = %d, %d, want 10, 0", hosts[0].Priority, hosts[1].Priority)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Groups["web"].Hosts[0].Priority; got != 10 {
+		t.Errorf("priority after round trip = %d, want 10", got)
+	}
+}
+
+func TestHostEntryProxyJump(t *testing.T) {
+	content := `
+groups:
+  web:
+    hosts:
+      - host: web-01
+        proxy_jump: ops@bastion:2222,inner
+      - host: web-02
+        proxy_jump:
+          - host: bastion
+            user: ops
+            port: 2222
+            identity_file: ~/.ssh/bastion
+          - host: inner
+      - web-03
+`
+	cfg := loadFromString(t, content)
+	hosts := cfg.Groups["web"].Hosts
+	if got := hosts[0].ProxyJump; got.Spec != "ops@bastion:2222,inner" || len(got.Hops) != 0 {
+		t.Errorf("string proxy_jump = %+v", got)
+	}
+	want := []JumpHop{{Host: "bastion", User: "ops", Port: 2222, IdentityFile: "~/.ssh/bastion"}, {Host: "inner"}}
+	if got := hosts[1].ProxyJump; got.Spec != "" || !reflect.DeepEqual(got.Hops, want) {
+		t.Errorf("list proxy_jump = %+v, want hops %+v", got, want)
+	}
+	if got := hosts[1].ProxyJump.String(); got != "ops@bastion:2222,inner" {
+		t.Errorf("String() = %q", got)
+	}
+	if !hosts[2].ProxyJump.IsZero() {
+		t.Errorf("bare host has proxy_jump %+v", hosts[2].ProxyJump)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := Save(path, cfg); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.Groups["web"].Hosts; !reflect.DeepEqual(got, hosts) {
+		t.Errorf("hosts after round trip = %+v, want %+v", got, hosts)
+	}
+}
+
+func TestHostEntryProxyJumpInvalid(t *testing.T) {
+	for _, hops := range []string{"[{user: ops}]", "[{host: bastion, port: -1}]"} {
+		content := "groups:\n  web:\n    hosts:\n      - host: web-01\n        proxy_jump: " + hops + "\n"
+		if _, err := loadStringRaw(content); err == nil {
+			t.Errorf("proxy_jump %s: expected an error", hops)
+		}
+	}
+}
 func TestHostEntryMixed(t *testing.T) {
 	content := `
 groups:
@@ -454,6 +1154,34 @@ func TestHostEntryMarshalRoundTrip(t *testing.T) {
 	}
 }
 
+func TestHostEntryNameAlias(t *testing.T) {
+	content := `
+groups:
+  test:
+    hosts:
+      - name: web-01
+        tags: [prod, web]
+      - host: web-02
+        name: web-02
+`
+	cfg := loadFromString(t, content)
+	hosts := cfg.Groups["test"].Hosts
+	if len(hosts) != 2 || hosts[0].Host != "web-01" || len(hosts[0].Tags) != 2 || hosts[1].Host != "web-02" {
+		t.Errorf("hosts = %+v, want name accepted as host", hosts)
+	}
+
+	_, err := loadStringRaw(`
+groups:
+  test:
+    hosts:
+      - host: web-01
+        name: web-02
+`)
+	if err == nil || !strings.Contains(err.Error(), "both host") {
+		t.Errorf("error = %v, want a conflict between host and name", err)
+	}
+}
+
 func TestHostEntryMissingHost(t *testing.T) {
 	content := `
 groups:
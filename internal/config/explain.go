@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Explain resolves a single host through ResolveHosts' per-host code path
+// and returns it along with a trace of where each connection setting came
+// from: the host entry, a group override, ssh_config, or a default. Settings
+// come from the first group, in name order, that lists the host; a host in
+// no group is resolved as if given on the command line. Each trace line has
+// the form "user: deploy (group web)".
+func Explain(cfg *Config, name string) (Host, []string) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	var groups []string
+	for gn, group := range cfg.Groups {
+		for _, entry := range group.Hosts {
+			if entry.Host == name {
+				groups = append(groups, gn)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+
+	var trace []string
+	entry := HostEntry{Host: name}
+	var group Group
+	var groupName string
+	if len(groups) == 0 {
+		trace = append(trace, "group: none (not in any group; resolved as a command-line host)")
+	} else {
+		groupName = groups[0]
+		group = cfg.Groups[groupName]
+		line := "group: " + groupName
+		if len(groups) > 1 {
+			line += fmt.Sprintf(" (also in %s; settings from %s)", strings.Join(groups[1:], ", "), groupName)
+		}
+		trace = append(trace, line)
+		for _, e := range group.Hosts {
+			if e.Host == name {
+				entry = e
+				break
+			}
+		}
+	}
+
+	get, err := cfg.sshConfigLookup()
+	if err != nil {
+		trace = append(trace, fmt.Sprintf("ssh_config: not read (%v)", err))
+		get = func(string, string) string { return "" }
+	}
+	source := make(map[string]string)
+	host := resolveEntry(get, entry, group, groupName, func(field, src string) { source[field] = src })
+
+	explain := func(field, value, src string) {
+		trace = append(trace, fmt.Sprintf("%s: %s (%s)", field, value, src))
+	}
+	explain("hostname", host.Hostname, source["hostname"])
+	if host.User != "" {
+		explain("user", host.User, source["user"])
+	} else {
+		trace = append(trace, "user: unset (the SSH client's default user)")
+	}
+	explain("port", strconv.Itoa(host.Port), source["port"])
+	if host.IdentityFile != "" {
+		explain("identity_file", host.IdentityFile, source["identity_file"])
+	} else {
+		trace = append(trace, "identity_file: unset (default keys and agent)")
+	}
+	if len(host.JumpHosts) > 0 {
+		hops := make([]string, len(host.JumpHosts))
+		for i, hop := range host.JumpHosts {
+			hops[i] = hop.String()
+			if hop.IdentityFile != "" {
+				hops[i] += " [" + hop.IdentityFile + "]"
+			}
+		}
+		explain("proxy_jump", strings.Join(hops, ", "), source["proxy_jump"])
+	} else if host.ProxyJump != "" {
+		explain("proxy_jump", host.ProxyJump, source["proxy_jump"])
+	} else {
+		trace = append(trace, "proxy_jump: none")
+	}
+	switch {
+	case host.Timeout == NoTimeout:
+		explain("timeout", "none", source["timeout"])
+	case host.Timeout > 0:
+		explain("timeout", host.Timeout.String(), source["timeout"])
+	case cfg.Defaults.Timeout.Unbounded:
+		explain("timeout", "none", "defaults.timeout")
+	default:
+		explain("timeout", cfg.Defaults.Timeout.Duration.String(), "defaults.timeout")
+	}
+	switch {
+	case host.ConnectTimeout > 0:
+		explain("connect_timeout", host.ConnectTimeout.String(), source["connect_timeout"])
+	case cfg.Defaults.ConnectTimeout.Duration > 0:
+		explain("connect_timeout", cfg.Defaults.ConnectTimeout.Duration.String(), "defaults.connect_timeout")
+	default:
+		trace = append(trace, "connect_timeout: unset (bounded by timeout)")
+	}
+	switch {
+	case host.Shell != "":
+		explain("shell", host.Shell, source["shell"])
+	case cfg.Defaults.Shell != "":
+		explain("shell", cfg.Defaults.Shell, "defaults.shell")
+	default:
+		trace = append(trace, "shell: unset (the remote user's login shell)")
+	}
+	if len(host.Tags) > 0 {
+		explain("tags", strings.Join(host.Tags, ", "), "group "+groupName)
+	}
+	if host.Priority != 0 {
+		explain("priority", strconv.Itoa(host.Priority), "group "+groupName)
+	}
+	for _, pat := range cfg.Defaults.Ignore {
+		byName, _ := path.Match(pat, host.Name)
+		byHostname, _ := path.Match(pat, host.Hostname)
+		if byName || byHostname {
+			trace = append(trace, fmt.Sprintf("ignored: matches %q in defaults.ignore", pat))
+			break
+		}
+	}
+	return host, trace
+}
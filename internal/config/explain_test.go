@@ -0,0 +1,99 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplain(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Groups = map[string]Group{
+		"web":        {Hosts: []HostEntry{{Host: "web-01", Tags: []string{"prod"}, Priority: 5}}, User: "deploy", Timeout: Duration{Duration: 10 * time.Second}},
+		"web-canary": {Hosts: []HostEntry{{Host: "web-01"}}},
+	}
+	cfg.Defaults.Ignore = []string{"web-0*"}
+
+	host, trace := Explain(cfg, "web-01")
+	if host.User != "deploy" || host.Timeout != 10*time.Second || host.Priority != 5 {
+		t.Errorf("host = %+v, want user deploy, timeout 10s, priority 5", host)
+	}
+	for _, want := range []string{
+		"group: web (also in web-canary; settings from web)",
+		"hostname: web-01 (host name)",
+		"port: 22 (default)",
+		"tags: prod (group web)",
+		"priority: 5 (group web)",
+		`ignored: matches "web-0*" in defaults.ignore`,
+	} {
+		if !containsLine(trace, want) {
+			t.Errorf("trace missing %q:\n%s", want, strings.Join(trace, "\n"))
+		}
+	}
+}
+
+func TestExplainGroupOverrides(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Groups = map[string]Group{
+		"web": {Hosts: []HostEntry{{Host: "admin@web-01"}}, User: "deploy", Timeout: Duration{Duration: 10 * time.Second}, Shell: "bash"},
+	}
+
+	host, trace := Explain(cfg, "admin@web-01")
+	if host.Hostname != "web-01" || host.User != "deploy" {
+		t.Errorf("host = %+v, want hostname web-01 with the group user", host)
+	}
+	for _, want := range []string{"user: deploy (group web)", "timeout: 10s (group web)", "shell: bash (group web)"} {
+		if !containsLine(trace, want) {
+			t.Errorf("trace missing %q:\n%s", want, strings.Join(trace, "\n"))
+		}
+	}
+}
+
+func TestExplainMatchesResolveHosts(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Groups = map[string]Group{
+		"web": {
+			Hosts:          []HostEntry{{Host: "admin@web-01", Tags: []string{"prod"}, ProxyJump: JumpChain{Spec: "bastion"}}},
+			User:           "deploy",
+			ConnectTimeout: Duration{Duration: 3 * time.Second},
+			Shell:          "bash",
+		},
+	}
+
+	resolved, err := ResolveHosts(cfg, "web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := Explain(cfg, "admin@web-01")
+	if !reflect.DeepEqual(host, resolved[0]) {
+		t.Errorf("Explain = %+v, ResolveHosts = %+v; want the same host", host, resolved[0])
+	}
+}
+
+func TestExplainUnknownHost(t *testing.T) {
+	host, trace := Explain(nil, "ops@bastion")
+	if host.User != "ops" || host.Hostname != "bastion" {
+		t.Errorf("host = %+v, want ops@bastion split", host)
+	}
+	if !containsLine(trace, "user: ops (user@host)") || !strings.HasPrefix(trace[0], "group: none") {
+		t.Errorf("unexpected trace:\n%s", strings.Join(trace, "\n"))
+	}
+	if _, trace := Explain(DefaultConfig(), "bastion"); !containsLine(trace, "timeout: 30s (defaults.timeout)") {
+		t.Errorf("expected default timeout line:\n%s", strings.Join(trace, "\n"))
+	}
+	cfg := DefaultConfig()
+	cfg.Defaults.Shell = "bash"
+	if _, trace := Explain(cfg, "bastion"); !containsLine(trace, "shell: bash (defaults.shell)") {
+		t.Errorf("expected default shell line:\n%s", strings.Join(trace, "\n"))
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, l := range lines {
+		if l == want {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,6 +13,7 @@ import (
 	"github.com/kevinburke/ssh_config"
 
 	"github.com/agent462/herd/internal/pathutil"
+	"github.com/agent462/herd/internal/sshconfig"
 )
 
 // Host represents a resolved SSH host with connection details.
@@ -21,25 +24,40 @@ type Host struct {
 	Port         int
 	IdentityFile string
 	ProxyJump    string
-	Timeout      time.Duration
+	JumpHosts    []JumpHop     // from a proxy_jump list; overrides ProxyJump
+	Timeout      time.Duration // group timeout; 0 uses the default, NoTimeout disables it
 	Tags         []string // tags from config HostEntry
+	Priority     int      // from config HostEntry; higher runs first
+
+	// ConnectTimeout is the group's connect_timeout; zero uses the default.
+	ConnectTimeout time.Duration
+
+	// Shell is the group's shell; empty uses the default.
+	Shell string
 }
 
 // ResolveHosts resolves a list of hosts from a combination of a config group
 // and CLI-provided host names. If groupName is specified, hosts are loaded from
 // the config group. If cliHosts are provided, they are used. If both are given,
-// the results are merged (deduplicated, CLI hosts appended after group hosts).
+// the results are merged (deduplicated, CLI hosts appended after group hosts),
+// then ordered by priority (see SortByPriority). Hosts matching
+// Defaults.Ignore are dropped (see FilterIgnored), as are hosts sharing a
+// connection target with an earlier host when cfg.Dedup is set.
 func ResolveHosts(cfg *Config, groupName string, cliHosts []string) ([]Host, error) {
 	if groupName == "" && len(cliHosts) == 0 {
 		return nil, fmt.Errorf("no hosts specified: provide a group (-g) or host names as arguments")
 	}
+	get, err := cfg.sshConfigLookup()
+	if err != nil {
+		return nil, err
+	}
 
 	var entries []HostEntry
-	var groupUser string
-	var groupTimeout Duration
+	var group Group
 
 	if groupName != "" {
-		group, ok := cfg.Groups[groupName]
+		var ok bool
+		group, ok = cfg.Groups[groupName]
 		if !ok {
 			available := make([]string, 0, len(cfg.Groups))
 			for name := range cfg.Groups {
@@ -51,8 +69,6 @@ func ResolveHosts(cfg *Config, groupName string, cliHosts []string) ([]Host, err
 			return nil, fmt.Errorf("group %q not found (available: %v)", groupName, available)
 		}
 		entries = append(entries, group.Hosts...)
-		groupUser = group.User
-		groupTimeout = group.Timeout
 	}
 
 	// Append CLI hosts as tag-less entries, deduplicating against group hosts.
@@ -69,42 +85,240 @@ func ResolveHosts(cfg *Config, groupName string, cliHosts []string) ([]Host, err
 		}
 	}
 
+	// Group-level overrides apply to command-line hosts too.
 	hosts := make([]Host, 0, len(entries))
 	for _, entry := range entries {
-		host := Host{Name: entry.Host, Hostname: entry.Host, Port: 22, Tags: entry.Tags}
+		hosts = append(hosts, resolveEntry(get, entry, group, groupName, nil))
+	}
 
-		// Parse user@host syntax.
-		if user, hostname, ok := parseUserAtHost(entry.Host); ok {
-			host.Hostname = hostname
-			host.User = user
-			// Name stays as the original "user@host" for display and dedup.
+	SortByPriority(hosts)
+	return filterHosts(cfg, hosts)
+}
+
+// resolveEntry builds the Host for entry: its user@host syntax and
+// proxy_jump, then group's user, timeout, connect timeout and shell
+// overrides (group is the zero Group for hosts resolved outside a group),
+// then ssh_config for settings still unset. If note is not nil, it is told
+// where each setting came from, e.g. ("user", "group web"), which is how
+// Explain traces a host's resolution.
+func resolveEntry(get func(alias, key string) string, entry HostEntry, group Group, groupName string, note func(field, source string)) Host {
+	if note == nil {
+		note = func(string, string) {}
+	}
+	host := Host{Name: entry.Host, Hostname: entry.Host, Port: 22, Tags: entry.Tags, Priority: entry.Priority}
+	note("hostname", "host name")
+	note("port", "default")
+
+	// Parse user@host syntax. Name stays as the original "user@host" for
+	// display and dedup.
+	if user, hostname, ok := parseUserAtHost(entry.Host); ok {
+		host.Hostname = hostname
+		host.User = user
+		note("user", "user@host")
+	}
+
+	if !entry.ProxyJump.IsZero() {
+		applyProxyJump(&host, entry.ProxyJump)
+		note("proxy_jump", "host entry")
+	}
+
+	src := "group " + groupName
+	if group.User != "" {
+		host.User = group.User
+		note("user", src)
+	}
+	host.Timeout = group.Timeout.Timeout()
+	if group.Timeout.IsSet() {
+		note("timeout", src)
+	}
+	host.ConnectTimeout = group.ConnectTimeout.Duration
+	if group.ConnectTimeout.Duration > 0 {
+		note("connect_timeout", src)
+	}
+	host.Shell = group.Shell
+	if group.Shell != "" {
+		note("shell", src)
+	}
+
+	// Merge SSH config values (fills in missing fields).
+	mergeSSHConfig(get, &host, func(field string) { note(field, "ssh_config") })
+	return host
+}
+
+// filterHosts applies FilterIgnored and, when cfg.Dedup is set, DedupHosts.
+// Otherwise it warns about each connection target that several of the
+// remaining hosts share, on which every command would run more than once.
+func filterHosts(cfg *Config, hosts []Host) ([]Host, error) {
+	hosts, err := FilterIgnored(cfg, hosts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil && cfg.Dedup {
+		return DedupHosts(hosts), nil
+	}
+	warn := func(msg string) { fmt.Fprintln(os.Stderr, msg) }
+	if cfg != nil && cfg.Warn != nil {
+		warn = cfg.Warn
+	}
+	for _, d := range DuplicateTargets(hosts) {
+		warn(fmt.Sprintf("warning: %s all connect to %s; commands will run there %d times (use --dedup to skip the repeats)",
+			strings.Join(d.Hosts, ", "), d.Target, len(d.Hosts)))
+	}
+	return hosts, nil
+}
+
+// DuplicateTarget is a connection target reached by more than one host.
+type DuplicateTarget struct {
+	Target string   // user@hostname:port, with the jump chain if any
+	Hosts  []string // host names, in resolution order
+}
+
+// connectionTarget returns the key under which h is dialed: its user,
+// hostname and port, and the jump hosts it is reached through, since the
+// same private address behind two bastions can be two different machines.
+// Hostnames compare case-insensitively, and a host without a user is keyed
+// under the user SSH would connect as ($USER, or root).
+func connectionTarget(h Host) string {
+	user := h.User
+	if user == "" {
+		user = defaultUser()
+	}
+	target := user + "@" + net.JoinHostPort(strings.ToLower(h.Hostname), strconv.Itoa(h.Port))
+	if len(h.JumpHosts) > 0 {
+		hops := make([]string, len(h.JumpHosts))
+		for i, j := range h.JumpHosts {
+			hops[i] = j.String()
+		}
+		target += " via " + strings.Join(hops, ",")
+	} else if h.ProxyJump != "" && h.ProxyJump != "none" {
+		target += " via " + h.ProxyJump
+	}
+	return target
+}
+
+// defaultUser returns the user the SSH client connects as when neither the
+// config nor ssh_config names one.
+func defaultUser() string {
+	if u := os.Getenv("USER"); u != "" {
+		return u
+	}
+	return "root"
+}
+
+// DuplicateTargets finds hosts that resolve to the same connection target,
+// such as two names whose ssh_config Hostname is the same address, so a
+// command would run twice on one machine. Targets are compared after
+// ssh_config is applied, without DNS lookups. Duplicates are returned in
+// the order their first host was resolved.
+func DuplicateTargets(hosts []Host) []DuplicateTarget {
+	byTarget := make(map[string]int, len(hosts))
+	var targets []DuplicateTarget
+	for _, h := range hosts {
+		key := connectionTarget(h)
+		if i, ok := byTarget[key]; ok {
+			targets[i].Hosts = append(targets[i].Hosts, h.Name)
+			continue
 		}
+		byTarget[key] = len(targets)
+		targets = append(targets, DuplicateTarget{Target: key, Hosts: []string{h.Name}})
+	}
 
-		// Apply group-level user override.
-		if groupUser != "" {
-			host.User = groupUser
+	dups := targets[:0]
+	for _, t := range targets {
+		if len(t.Hosts) > 1 {
+			dups = append(dups, t)
 		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	return dups
+}
 
-		// Apply group-level timeout override.
-		if groupTimeout.Duration > 0 {
-			host.Timeout = groupTimeout.Duration
+// DedupHosts returns hosts without those sharing a connection target with
+// an earlier host (see DuplicateTargets). Hosts keep their order.
+func DedupHosts(hosts []Host) []Host {
+	seen := make(map[string]bool, len(hosts))
+	kept := hosts[:0:0]
+	for _, h := range hosts {
+		key := connectionTarget(h)
+		if seen[key] {
+			continue
 		}
+		seen[key] = true
+		kept = append(kept, h)
+	}
+	return kept
+}
 
-		// Merge SSH config values (fills in missing fields).
-		MergeSSHConfig(&host)
+// SortByPriority orders hosts by descending Priority, keeping the existing
+// order of hosts with equal priority.
+func SortByPriority(hosts []Host) {
+	sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].Priority > hosts[j].Priority })
+}
 
-		hosts = append(hosts, host)
+// HostTimeouts returns the command timeout of each host that has one from
+// its group, for executor.WithHostTimeouts. Hosts without one are omitted.
+func HostTimeouts(hosts []Host) map[string]time.Duration {
+	var timeouts map[string]time.Duration
+	for _, h := range hosts {
+		if h.Timeout == 0 {
+			continue
+		}
+		if timeouts == nil {
+			timeouts = make(map[string]time.Duration)
+		}
+		timeouts[h.Name] = h.Timeout
 	}
+	return timeouts
+}
 
-	return hosts, nil
+// FilterIgnored drops hosts whose name or hostname matches a glob in
+// cfg.Defaults.Ignore, unless cfg.IncludeIgnored is set. It is an error for
+// every host to be ignored, so a stale group cannot silently resolve to
+// nothing.
+func FilterIgnored(cfg *Config, hosts []Host) ([]Host, error) {
+	if cfg == nil || cfg.IncludeIgnored || len(cfg.Defaults.Ignore) == 0 {
+		return hosts, nil
+	}
+	kept := hosts[:0:0]
+	for _, h := range hosts {
+		if !cfg.IsIgnored(h) {
+			kept = append(kept, h)
+		}
+	}
+	if len(kept) == 0 && len(hosts) > 0 {
+		return nil, fmt.Errorf("all %d hosts match defaults.ignore (use --include-ignored to target them anyway)", len(hosts))
+	}
+	return kept, nil
+}
+
+// IsIgnored reports whether h matches a pattern in Defaults.Ignore.
+func (c *Config) IsIgnored(h Host) bool {
+	for _, pat := range c.Defaults.Ignore {
+		if ok, _ := path.Match(pat, h.Name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, h.Hostname); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // ResolveHostsByTag resolves hosts from ALL groups that match the given tag
 // expression. Tags are AND-ed (comma-separated), and a leading "!" negates.
-// Returns deduplicated hosts. Group-level User/Timeout overrides are NOT applied
-// because a host may appear in multiple groups with different settings.
+// Returns deduplicated hosts ordered by priority; a host listed in several
+// groups takes its highest priority. Group-level User/Timeout overrides are NOT
+// applied because a host may appear in multiple groups with different settings.
+// Hosts matching Defaults.Ignore are dropped, as are duplicate connection
+// targets when cfg.Dedup is set.
 func ResolveHostsByTag(cfg *Config, tagExpr string) ([]Host, error) {
 	required, negated := ParseTagExpr(tagExpr)
+	get, err := cfg.sshConfigLookup()
+	if err != nil {
+		return nil, err
+	}
 
 	// First pass: collect and merge tags across all groups for each host.
 	type hostInfo struct {
@@ -127,6 +341,7 @@ func ResolveHostsByTag(cfg *Config, tagExpr string) ([]Host, error) {
 		group := cfg.Groups[gn]
 		for _, entry := range group.Hosts {
 			if existing, ok := merged[entry.Host]; ok {
+				existing.entry.Priority = max(existing.entry.Priority, entry.Priority)
 				// Merge new tags (union, deduplicated).
 				tagSet := make(map[string]bool, len(existing.entry.Tags))
 				for _, t := range existing.entry.Tags {
@@ -141,7 +356,7 @@ func ResolveHostsByTag(cfg *Config, tagExpr string) ([]Host, error) {
 				tags := make([]string, len(entry.Tags))
 				copy(tags, entry.Tags)
 				merged[entry.Host] = &hostInfo{
-					entry: HostEntry{Host: entry.Host, Tags: tags},
+					entry: HostEntry{Host: entry.Host, Tags: tags, Priority: entry.Priority, ProxyJump: entry.ProxyJump},
 					order: order,
 				}
 				order++
@@ -159,20 +374,15 @@ func ResolveHostsByTag(cfg *Config, tagExpr string) ([]Host, error) {
 
 	for _, info := range ordered {
 		if MatchesTags(info.entry.Tags, required, negated) {
-			host := Host{Name: info.entry.Host, Hostname: info.entry.Host, Port: 22, Tags: info.entry.Tags}
-			if user, hostname, ok := parseUserAtHost(info.entry.Host); ok {
-				host.Hostname = hostname
-				host.User = user
-			}
-			MergeSSHConfig(&host)
-			hosts = append(hosts, host)
+			hosts = append(hosts, resolveEntry(get, info.entry, Group{}, "", nil))
 		}
 	}
 
 	if len(hosts) == 0 {
 		return nil, fmt.Errorf("no hosts match tag expression %q", tagExpr)
 	}
-	return hosts, nil
+	SortByPriority(hosts)
+	return filterHosts(cfg, hosts)
 }
 
 // ParseTagExpr splits a comma-separated tag expression into required and negated tags.
@@ -238,11 +448,23 @@ func MatchesTags(hostTags []string, required, negated []string) bool {
 	return true
 }
 
+// applyProxyJump sets host's jump hosts from its entry's proxy_jump.
+func applyProxyJump(host *Host, chain JumpChain) {
+	host.ProxyJump = chain.Spec
+	host.JumpHosts = chain.Hops
+}
+
 // MergeSSHConfig reads ~/.ssh/config and fills in Hostname, User, Port,
 // IdentityFile, and ProxyJump for the host if they are not already set.
 // Lookups use the original host Name (the SSH config alias), not the
 // resolved Hostname, so that Host directives match correctly.
 func MergeSSHConfig(host *Host) {
+	mergeSSHConfig(sshConfigGet, host, func(string) {})
+}
+
+// mergeSSHConfig implements MergeSSHConfig, calling applied with the name of
+// each field (e.g. "user") taken from ssh_config.
+func mergeSSHConfig(get func(alias, key string) string, host *Host, applied func(field string)) {
 	// Use the original Name for ssh_config lookups so Host aliases match.
 	lookup := host.Name
 	// If the name was "user@host", use just the host part for lookup.
@@ -251,40 +473,55 @@ func MergeSSHConfig(host *Host) {
 	}
 
 	// Resolve Hostname (ssh_config may map a Host alias to a different address).
-	if hn := sshConfigGet(lookup, "Hostname"); hn != "" {
+	if hn := get(lookup, "Hostname"); hn != "" {
 		host.Hostname = hn
+		applied("hostname")
 	}
 
 	if host.User == "" {
-		if user := sshConfigGet(lookup, "User"); user != "" {
+		if user := get(lookup, "User"); user != "" {
 			host.User = user
+			applied("user")
 		}
 	}
 
 	if host.Port == 22 {
-		if portStr := sshConfigGet(lookup, "Port"); portStr != "" {
-			if port, err := strconv.Atoi(portStr); err == nil && port > 0 {
+		if portStr := get(lookup, "Port"); portStr != "" {
+			if port, err := strconv.Atoi(portStr); err == nil && port > 0 && port != 22 {
 				host.Port = port
+				applied("port")
 			}
 		}
 	}
 
 	if host.IdentityFile == "" {
-		if identity := sshConfigGet(lookup, "IdentityFile"); identity != "" {
+		if identity := get(lookup, "IdentityFile"); identity != "" {
 			expanded := pathutil.ExpandHome(identity)
 			if _, err := os.Stat(expanded); err == nil {
 				host.IdentityFile = expanded
+				applied("identity_file")
 			}
 		}
 	}
 
-	if host.ProxyJump == "" {
-		if proxy := sshConfigGet(lookup, "ProxyJump"); proxy != "" {
+	if host.ProxyJump == "" && len(host.JumpHosts) == 0 {
+		if proxy := get(lookup, "ProxyJump"); proxy != "" {
 			host.ProxyJump = proxy
+			applied("proxy_jump")
 		}
 	}
 }
 
+// sshConfigLookup returns the ssh_config lookup for c: the file named by
+// Defaults.SSHConfig, following its Include directives, or the user's
+// ~/.ssh/config when that is unset.
+func (c *Config) sshConfigLookup() (func(alias, key string) string, error) {
+	if c == nil || c.Defaults.SSHConfig == "" {
+		return sshConfigGet, nil
+	}
+	return sshconfig.File(c.Defaults.SSHConfig)
+}
+
 // sshConfigGet looks up a key for a host in the user's SSH config.
 func sshConfigGet(hostname, key string) string {
 	val, err := ssh_config.GetStrict(hostname, key)
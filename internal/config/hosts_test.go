@@ -1,6 +1,10 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -283,7 +287,7 @@ func TestDurationFieldInGroup(t *testing.T) {
 			"web": {
 				Hosts:   strHosts("web-01"),
 				User:    "deploy",
-				Timeout: Duration{10 * time.Second},
+				Timeout: Duration{Duration: 10 * time.Second},
 			},
 		},
 		Defaults: DefaultConfig().Defaults,
@@ -294,6 +298,73 @@ func TestDurationFieldInGroup(t *testing.T) {
 	}
 }
 
+func TestResolveHostsCustomSSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "web.conf"), []byte("Host web-01\n  HostName 10.0.0.5\n  User deploy\n  Port 2222\n  ProxyJump bastion\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ssh_config")
+	if err := os.WriteFile(path, []byte("Include "+filepath.Join(dir, "*.conf")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		Groups:   map[string]Group{"web": {Hosts: strHosts("web-01")}},
+		Defaults: DefaultConfig().Defaults,
+	}
+	cfg.Defaults.SSHConfig = path
+
+	hosts, err := ResolveHosts(cfg, "web", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts: %v", err)
+	}
+	h := hosts[0]
+	if h.Hostname != "10.0.0.5" || h.User != "deploy" || h.Port != 2222 || h.ProxyJump != "bastion" {
+		t.Errorf("host = %+v, want values from the included ssh_config file", h)
+	}
+
+	cfg.Defaults.SSHConfig = filepath.Join(dir, "missing")
+	if _, err := ResolveHosts(cfg, "web", nil); err == nil {
+		t.Error("expected an error for a missing ssh_config file")
+	}
+}
+
+
+func TestResolveHostsJumpHosts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ssh_config")
+	if err := os.WriteFile(path, []byte("Host *\n  ProxyJump from-ssh-config\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		Groups: map[string]Group{"web": {Hosts: []HostEntry{
+			{Host: "web-01", ProxyJump: JumpChain{Hops: []JumpHop{{Host: "bastion", User: "ops"}, {Host: "inner", Port: 2222}}}},
+			{Host: "web-02", ProxyJump: JumpChain{Spec: "edge"}},
+			{Host: "web-03"},
+		}}},
+		Defaults: DefaultConfig().Defaults,
+	}
+	cfg.Defaults.SSHConfig = path
+
+	hosts, err := ResolveHosts(cfg, "web", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts: %v", err)
+	}
+	want := []JumpHop{{Host: "bastion", User: "ops"}, {Host: "inner", Port: 2222}}
+	if h := hosts[0]; !reflect.DeepEqual(h.JumpHosts, want) || h.ProxyJump != "" {
+		t.Errorf("web-01 jump hosts = %+v, proxy jump %q; want %+v and no ssh_config ProxyJump", h.JumpHosts, h.ProxyJump, want)
+	}
+	if h := hosts[1]; h.ProxyJump != "edge" || h.JumpHosts != nil {
+		t.Errorf("web-02 proxy jump = %q, %+v; want edge", h.ProxyJump, h.JumpHosts)
+	}
+	if h := hosts[2]; h.ProxyJump != "from-ssh-config" {
+		t.Errorf("web-03 proxy jump = %q, want from-ssh-config", h.ProxyJump)
+	}
+
+	_, trace := Explain(cfg, "web-01")
+	if !slices.Contains(trace, "proxy_jump: ops@bastion, inner:2222 (host entry)") {
+		t.Errorf("trace = %q, want the host entry's jump hosts", trace)
+	}
+}
 func TestResolveHostsPreservesTags(t *testing.T) {
 	cfg := &Config{
 		Groups: map[string]Group{
@@ -319,6 +390,58 @@ func TestResolveHostsPreservesTags(t *testing.T) {
 	}
 }
 
+func TestResolveHostsPriorityOrder(t *testing.T) {
+	cfg := &Config{
+		Groups: map[string]Group{
+			"web": {
+				Hosts: []HostEntry{
+					{Host: "web-01"},
+					{Host: "web-02", Priority: -1},
+					{Host: "web-03"},
+					{Host: "canary", Priority: 10},
+				},
+			},
+		},
+		Defaults: DefaultConfig().Defaults,
+	}
+
+	hosts, err := ResolveHosts(cfg, "web", []string{"extra"})
+	if err != nil {
+		t.Fatalf("ResolveHosts error: %v", err)
+	}
+	var names []string
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	want := []string{"canary", "web-01", "web-03", "extra", "web-02"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("order = %v, want %v", names, want)
+	}
+}
+
+func TestResolveHostsByTag_Priority(t *testing.T) {
+	cfg := &Config{
+		Groups: map[string]Group{
+			"apps":    {Hosts: []HostEntry{{Host: "app-01", Tags: []string{"prod"}}, {Host: "app-02", Tags: []string{"prod"}}}},
+			"canary":  {Hosts: []HostEntry{{Host: "app-02", Priority: 5}}},
+			"zz-last": {Hosts: []HostEntry{{Host: "app-03", Tags: []string{"prod"}, Priority: 1}}},
+		},
+		Defaults: DefaultConfig().Defaults,
+	}
+
+	hosts, err := ResolveHostsByTag(cfg, "prod")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var names []string
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	if got := strings.Join(names, ","); got != "app-02,app-03,app-01" {
+		t.Errorf("order = %s, want app-02,app-03,app-01", got)
+	}
+}
+
 func TestResolveHostsByTag_Single(t *testing.T) {
 	cfg := &Config{
 		Groups: map[string]Group{
@@ -530,3 +653,152 @@ func slicesEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestResolveHostsIgnore(t *testing.T) {
+	cfg := &Config{
+		Groups: map[string]Group{
+			"web": {Hosts: []HostEntry{
+				{Host: "web-01", Tags: []string{"prod"}},
+				{Host: "web-02-old", Tags: []string{"prod"}},
+				{Host: "admin@web-03", Tags: []string{"prod"}},
+			}},
+		},
+		Defaults: DefaultConfig().Defaults,
+	}
+	cfg.Defaults.Ignore = []string{"*-old", "web-03"}
+
+	names := func(hosts []Host) string {
+		var out []string
+		for _, h := range hosts {
+			out = append(out, h.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	hosts, err := ResolveHosts(cfg, "web", []string{"db-01-old", "db-02"})
+	if err != nil {
+		t.Fatalf("ResolveHosts error: %v", err)
+	}
+	if got := names(hosts); got != "web-01,db-02" {
+		t.Errorf("ResolveHosts = %s, want web-01,db-02", got)
+	}
+
+	hosts, err = ResolveHostsByTag(cfg, "prod")
+	if err != nil {
+		t.Fatalf("ResolveHostsByTag error: %v", err)
+	}
+	if got := names(hosts); got != "web-01" {
+		t.Errorf("ResolveHostsByTag = %s, want web-01", got)
+	}
+
+	if _, err := ResolveHosts(cfg, "", []string{"web-02-old"}); err == nil || !strings.Contains(err.Error(), "--include-ignored") {
+		t.Errorf("expected all-ignored error, got %v", err)
+	}
+
+	cfg.IncludeIgnored = true
+	hosts, err = ResolveHosts(cfg, "web", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts with IncludeIgnored error: %v", err)
+	}
+	if len(hosts) != 3 {
+		t.Errorf("IncludeIgnored: got %d hosts, want 3", len(hosts))
+	}
+}
+
+func TestDuplicateTargets(t *testing.T) {
+	t.Setenv("USER", "alice")
+	hosts := []Host{
+		{Name: "pi-garage", Hostname: "192.168.1.10", User: "pi", Port: 22},
+		{Name: "pi-old", Hostname: "192.168.1.10", User: "pi", Port: 22},
+		{Name: "root@192.168.1.10", Hostname: "192.168.1.10", User: "root", Port: 22},
+		{Name: "pi-ssh2", Hostname: "192.168.1.10", User: "pi", Port: 2222},
+		{Name: "web-a", Hostname: "10.0.0.5", Port: 22, ProxyJump: "bastion-a"},
+		{Name: "web-b", Hostname: "10.0.0.5", Port: 22, ProxyJump: "bastion-b"},
+		{Name: "Shed", Hostname: "SHED.lan", Port: 22},
+		{Name: "shed", Hostname: "shed.lan", Port: 22},
+		{Name: "pi-garage-2", Hostname: "192.168.1.10", User: "pi", Port: 22},
+		{Name: "alice@shed.lan", Hostname: "shed.lan", User: "alice", Port: 22},
+	}
+
+	got := DuplicateTargets(hosts)
+	want := []DuplicateTarget{
+		{Target: "pi@192.168.1.10:22", Hosts: []string{"pi-garage", "pi-old", "pi-garage-2"}},
+		{Target: "alice@shed.lan:22", Hosts: []string{"Shed", "shed", "alice@shed.lan"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DuplicateTargets = %+v, want %+v", got, want)
+	}
+
+	if got := hostNames(DedupHosts(hosts)); got != "pi-garage,root@192.168.1.10,pi-ssh2,web-a,web-b,Shed" {
+		t.Errorf("DedupHosts = %s", got)
+	}
+
+	if got := DuplicateTargets(DedupHosts(hosts)); got != nil {
+		t.Errorf("DuplicateTargets after DedupHosts = %+v, want none", got)
+	}
+}
+
+func TestResolveHostsDedup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ssh_config")
+	if err := os.WriteFile(path, []byte("Host pi-garage pi-old\n  HostName 192.168.1.10\n  User pi\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		Groups: map[string]Group{"pis": {Hosts: []HostEntry{
+			{Host: "pi-garage", Tags: []string{"pi"}},
+			{Host: "pi-shed", Tags: []string{"pi"}},
+			{Host: "pi-old", Tags: []string{"pi"}},
+		}}},
+		Defaults: DefaultConfig().Defaults,
+	}
+	cfg.Defaults.SSHConfig = path
+	var warnings []string
+	cfg.Warn = func(msg string) { warnings = append(warnings, msg) }
+
+	hosts, err := ResolveHosts(cfg, "pis", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts: %v", err)
+	}
+	if got := hostNames(hosts); got != "pi-garage,pi-shed,pi-old" {
+		t.Errorf("without Dedup: hosts = %s, want all three", got)
+	}
+	dups := DuplicateTargets(hosts)
+	if len(dups) != 1 || !reflect.DeepEqual(dups[0].Hosts, []string{"pi-garage", "pi-old"}) {
+		t.Errorf("DuplicateTargets = %+v, want pi-garage and pi-old", dups)
+	}
+	want := "warning: pi-garage, pi-old all connect to pi@192.168.1.10:22; commands will run there 2 times (use --dedup to skip the repeats)"
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("ResolveHosts warnings = %q, want [%q]", warnings, want)
+	}
+
+	warnings = nil
+	if _, err := ResolveHostsByTag(cfg, "pi"); err != nil {
+		t.Fatalf("ResolveHostsByTag: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("ResolveHostsByTag warnings = %q, want [%q]", warnings, want)
+	}
+
+	warnings = nil
+	cfg.Dedup = true
+	hosts, err = ResolveHosts(cfg, "pis", nil)
+	if err != nil {
+		t.Fatalf("ResolveHosts with Dedup: %v", err)
+	}
+	if got := hostNames(hosts); got != "pi-garage,pi-shed" {
+		t.Errorf("with Dedup: hosts = %s, want pi-garage,pi-shed", got)
+	}
+	if warnings != nil {
+		t.Errorf("with Dedup: warnings = %q, want none", warnings)
+	}
+}
+
+// hostNames joins the names of hosts with commas.
+func hostNames(hosts []Host) string {
+	out := make([]string, len(hosts))
+	for i, h := range hosts {
+		out[i] = h.Name
+	}
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// schemaID is the $id of the generated config schema.
+const schemaID = "https://github.com/agent462/herd/config.schema.json"
+
+// namePattern matches recipe, parser and tag names, as enforced by Validate.
+const namePattern = `^[a-zA-Z0-9_-]+$`
+
+// durationPattern matches non-negative Go durations such as "30s" or "1m30s".
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+// exitStatusSchema describes an exit code to status mapping.
+var exitStatusSchema = map[string]any{
+	"type":                 "object",
+	"propertyNames":        map[string]any{"pattern": "^[0-9]+$"},
+	"additionalProperties": map[string]any{"enum": []string{"ok", "warn", "fail"}},
+}
+
+// schemaOverrides adds the constraints Validate enforces, and descriptions,
+// to individual fields, keyed by "Type.Field".
+var schemaOverrides = map[string]map[string]any{
+	"Config.Groups":             {"description": "Named host groups.", "propertyNames": map[string]any{"minLength": 1}},
+	"Config.Recipes":            {"description": "Named multi-step command sequences.", "propertyNames": map[string]any{"pattern": namePattern}},
+	"Config.Parsers":            {"description": "Named field-extraction rules for command output.", "propertyNames": map[string]any{"pattern": namePattern}},
+	"Defaults.Concurrency":      {"description": "Maximum number of hosts contacted in parallel.", "minimum": 0},
+	"Defaults.Timeout":          {"description": "Per-host command timeout."},
+	"Defaults.Output":           {"description": "Output format.", "enum": []string{"grouped", "json", "jsonl", "flat"}},
+	"Defaults.Color":            {"description": "When to color output.", "enum": []string{"auto", "always", "never"}},
+	"Defaults.Shell":            {"description": "Shell that runs every command (as <shell> -c '<command>') instead of the remote login shell."},
+	"Defaults.Rewrites":         {"description": "Rules that change commands on matching hosts before they run, applied in order."},
+	"Defaults.WarnPatterns":     {"description": "Regular expressions for commands that need confirmation before running on more than one host."},
+	"Defaults.KnownHostsFile":   {"description": "known_hosts file(s) for host key verification, separated by spaces."},
+	"Defaults.PasswordFile":     {"description": "File of \"host: password\" lines (mode 0600) tried before prompting for a password."},
+	"Defaults.Ignore":           {"description": "Glob patterns for hosts that are never targeted, even when listed in a group."},
+	"Defaults.ExitStatus":       {"description": "Exit code meanings per command name (first word), e.g. diff: {1: ok}.", "additionalProperties": exitStatusSchema},
+	"Defaults.StderrAsOutput":   {"description": "Show stderr as the output of hosts that exit 0 with no stdout."},
+	"Defaults.TrimWhitespace":   {"description": "Ignore leading and trailing whitespace on each output line when grouping hosts."},
+	"Defaults.CollapseSpaces":   {"description": "Treat each run of spaces and tabs in output as a single space when grouping hosts."},
+	"Defaults.Masks":            {"description": "Regular expressions for per-host details, such as timestamps, ignored when grouping hosts."},
+	"Defaults.Facts":            {"description": "Host fact probes: fact name to shell command whose first output line is the value.", "propertyNames": map[string]any{"pattern": namePattern}},
+	"Defaults.FactsTTL":         {"description": "How long probed facts stay cached; 0 keeps them for the session."},
+	"Defaults.SummaryTemplate":  {"description": "Go text/template for the summary line, over .Hosts, .Succeeded, .NonZero, .Failed, .Timeout, .Groups and .Elapsed."},
+	"Group.Hosts":               {"description": "Hosts in the group; may be omitted when the group extends another or is extended."},
+	"Group.User":                {"description": "SSH user for the group's hosts."},
+	"Group.Timeout":             {"description": "Command timeout for the group's hosts."},
+	"Group.Shell":               {"description": "Shell that runs commands on the group's hosts."},
+	"Group.Extends":             {"description": "Group whose unset settings this group inherits."},
+	"HostEntry.Host":            {"minLength": 1},
+	"HostEntry.ProxyJump":       {"description": "Jump hosts, as a ProxyJump string (\"user@bastion:2222,inner\") or a list of hops."},
+	"JumpHop.Host":              {"description": "Jump host address or ssh_config alias.", "minLength": 1},
+	"JumpHop.Port":              {"minimum": 0},
+	"HostEntry.Tags":            {"items": map[string]any{"type": "string", "pattern": namePattern}},
+	"Recipe.Steps":              {"description": "Commands run in order; selectors refer to the previous step's results.", "minItems": 1},
+	"RecipeStep.Command":        {"description": "Command to run, optionally preceded by a selector.", "minLength": 1},
+	"RecipeStep.Selector":       {"description": "Selector choosing the hosts; refers to the previous step's results."},
+	"RecipeStep.Timeout":        {"description": "Per-host command timeout for this step."},
+	"RecipeStep.OnFailure":      {"description": "Whether to continue or abort the recipe when a host fails this step.", "enum": []string{"continue", "abort"}},
+	"RecipeStep.RetryExitCodes": {"description": "Exit codes that mark a transient failure worth retrying.", "items": map[string]any{"type": "integer", "minimum": 1, "maximum": 255}},
+	"RecipeStep.Retries":        {"description": "Maximum number of reruns per host for retry_exit_codes.", "minimum": 0},
+	"RecipeStep.ExitStatus":     {"description": "Exit code meanings for this step, e.g. {1: ok}.", "propertyNames": exitStatusSchema["propertyNames"], "additionalProperties": exitStatusSchema["additionalProperties"]},
+	"Parser.Extract":            {"minItems": 1},
+	"Rewrite.Hosts":             {"description": "Glob matched against host names.", "minLength": 1},
+	"Rewrite.Prefix":            {"description": "Prepended to the command, e.g. sudo."},
+	"Rewrite.Replace":           {"description": "Regular expression replaced in the command."},
+	"Rewrite.With":              {"description": "Replacement for replace; may refer to capture groups as $1."},
+	"ExtractRule.Field":         {"minLength": 1},
+	"ExtractRule.Pattern":       {"description": "Regular expression whose first capture group is the value."},
+	"ExtractRule.Column":        {"description": "Column to extract (1-based), split on whitespace or delimiter.", "minimum": 1},
+	"ExtractRule.ColumnRange":   {"description": "Columns to extract and join, as N-M or N- (1-based).", "pattern": "^[1-9][0-9]*-([1-9][0-9]*)?$"},
+	"ExtractRule.Delimiter":     {"description": "Split columns on this string instead of whitespace.", "minLength": 1},
+	"ExtractRule.NoHeader":      {"description": "Read columns from the first line instead of skipping it as a header."},
+}
+
+// schemaRequired lists required properties per type, by YAML name.
+var schemaRequired = map[string][]string{
+	"Recipe":      {"steps"},
+	"RecipeStep":  {"command"},
+	"Parser":      {"extract"},
+	"ExtractRule": {"field"},
+	"Rewrite":     {"hosts"},
+	"JumpHop":     {"host"},
+}
+
+var (
+	durationType   = reflect.TypeFor[Duration]()
+	hostEntryType  = reflect.TypeFor[HostEntry]()
+	recipeStepType = reflect.TypeFor[RecipeStep]()
+	jumpChainType  = reflect.TypeFor[JumpChain]()
+)
+
+// Schema returns a JSON Schema (draft 2020-12) describing the config file,
+// for editor completion and validation. It is derived from the Config
+// struct's YAML fields and mirrors the rules checked by Validate.
+func Schema() []byte {
+	s := schemaFor(reflect.TypeFor[Config]())
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["$id"] = schemaID
+	s["title"] = "herd configuration"
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		panic("config: marshal schema: " + err.Error()) // static input; cannot fail
+	}
+	return append(data, '\n')
+}
+
+// schemaFor returns the schema for a Go type used in Config.
+func schemaFor(t reflect.Type) map[string]any {
+	switch {
+	case t == durationType:
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	case t == hostEntryType:
+		// A host entry is either a bare hostname or a map with tags, whose
+		// host may be given as name instead.
+		entry := structSchema(t)
+		entry["properties"].(map[string]any)["name"] = map[string]any{"type": "string", "minLength": 1, "description": "Alias for host."}
+		entry["anyOf"] = []any{
+			map[string]any{"required": []string{"host"}},
+			map[string]any{"required": []string{"name"}},
+		}
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "minLength": 1},
+			entry,
+		}}
+	case t == recipeStepType:
+		// A recipe step is either a command line or a map with options.
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "minLength": 1},
+			structSchema(t),
+		}}
+	case t == jumpChainType:
+		// proxy_jump is either a ProxyJump string or a list of hops.
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "minLength": 1},
+			map[string]any{"type": "array", "minItems": 1, "items": structSchema(reflect.TypeFor[JumpHop]())},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	panic("config: no schema for " + t.String())
+}
+
+// structSchema returns an object schema with one property per YAML field.
+func structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		prop := schemaFor(f.Type)
+		for k, v := range schemaOverrides[t.Name()+"."+f.Name] {
+			prop[k] = v
+		}
+		props[name] = prop
+	}
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if req := schemaRequired[t.Name()]; len(req) > 0 {
+		s["required"] = req
+	}
+	if t.Name() == "ExtractRule" {
+		s["anyOf"] = []any{
+			map[string]any{"required": []string{"pattern"}},
+			map[string]any{"required": []string{"column"}},
+			map[string]any{"required": []string{"column_range"}},
+		}
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	var s map[string]any
+	if err := json.Unmarshal(Schema(), &s); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if s["$schema"] != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("$schema = %v", s["$schema"])
+	}
+
+	props := s["properties"].(map[string]any)
+	for _, key := range []string{"groups", "defaults", "recipes", "parsers"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("missing top-level property %q", key)
+		}
+	}
+
+	defaults := props["defaults"].(map[string]any)["properties"].(map[string]any)
+	for _, key := range []string{"concurrency", "timeout", "output", "color", "warn_patterns", "known_hosts_file", "facts", "facts_ttl"} {
+		if _, ok := defaults[key]; !ok {
+			t.Errorf("missing defaults property %q", key)
+		}
+	}
+
+	// Enums must agree with Validate.
+	output := defaults["output"].(map[string]any)["enum"].([]any)
+	for _, v := range output {
+		cfg := DefaultConfig()
+		cfg.Defaults.Output = v.(string)
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("schema allows output %q but Validate rejects it: %v", v, err)
+		}
+	}
+
+	group := props["groups"].(map[string]any)["additionalProperties"].(map[string]any)
+	hosts := group["properties"].(map[string]any)["hosts"].(map[string]any)
+	if _, ok := hosts["items"].(map[string]any)["oneOf"]; !ok {
+		t.Error("host entries should accept a bare string or a map")
+	}
+}
+
+func TestSchema_DurationPattern(t *testing.T) {
+	re := regexp.MustCompile(durationPattern)
+	for _, d := range []string{"30s", "1m30s", "1.5h", "250ms", "0"} {
+		if !re.MatchString(d) {
+			t.Errorf("pattern rejects valid duration %q", d)
+		}
+	}
+	for _, d := range []string{"", "30", "-5s", "soon"} {
+		if re.MatchString(d) {
+			t.Errorf("pattern accepts invalid duration %q", d)
+		}
+	}
+}
+
+func TestSchema_ProxyJump(t *testing.T) {
+	var s map[string]any
+	if err := json.Unmarshal(Schema(), &s); err != nil {
+		t.Fatal(err)
+	}
+	group := s["properties"].(map[string]any)["groups"].(map[string]any)["additionalProperties"].(map[string]any)
+	hosts := group["properties"].(map[string]any)["hosts"].(map[string]any)
+	entry := hosts["items"].(map[string]any)["oneOf"].([]any)[1].(map[string]any)
+	jump := entry["properties"].(map[string]any)["proxy_jump"].(map[string]any)
+
+	forms, ok := jump["oneOf"].([]any)
+	if !ok || len(forms) != 2 {
+		t.Fatalf("proxy_jump should accept a string or a list of hops, got %v", jump)
+	}
+	if forms[0].(map[string]any)["type"] != "string" {
+		t.Errorf("first form = %v, want a string", forms[0])
+	}
+	list := forms[1].(map[string]any)
+	if list["type"] != "array" {
+		t.Fatalf("second form = %v, want an array", list)
+	}
+	hop := list["items"].(map[string]any)
+	for _, key := range []string{"host", "user", "port", "identity_file"} {
+		if _, ok := hop["properties"].(map[string]any)[key]; !ok {
+			t.Errorf("hop missing property %q", key)
+		}
+	}
+	if req, _ := hop["required"].([]any); len(req) != 1 || req[0] != "host" {
+		t.Errorf("hop required = %v, want [host]", hop["required"])
+	}
+}
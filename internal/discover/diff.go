@@ -0,0 +1,102 @@
+package discover
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agent462/herd/internal/config"
+)
+
+// Diff reconciles configured hosts against discovered ones. A configured
+// host matches a discovered host when its resolved Hostname, or failing
+// that its Name, equals the discovered address, and its Port is unset or
+// equal to the discovered port. Names are compared as written, without DNS
+// lookups, so a host configured by DNS name matches only when its Hostname
+// (for example from ~/.ssh/config) is the address. onlyConfigured lists the
+// names of configured hosts with no discovered match (configured but gone),
+// onlyDiscovered lists addresses of discovered hosts no configured host
+// matches (up but not in config), and both lists the names of configured
+// hosts that were found. Each list is sorted and free of duplicates.
+func Diff(configured []config.Host, discovered []Host) (onlyConfigured, onlyDiscovered, both []string) {
+	byAddr := make(map[string][]int, len(discovered))
+	for i, h := range discovered {
+		byAddr[h.Address] = append(byAddr[h.Address], i)
+	}
+	match := func(c config.Host) (int, bool) {
+		for _, addr := range []string{c.Hostname, c.Name} {
+			for _, i := range byAddr[addr] {
+				if c.Port == 0 || c.Port == discovered[i].Port {
+					return i, true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	matched := make([]bool, len(discovered))
+	seen := make(map[string]bool, len(configured))
+	for _, c := range configured {
+		if seen[c.Name] {
+			continue
+		}
+		seen[c.Name] = true
+		if i, ok := match(c); ok {
+			matched[i] = true
+			both = append(both, c.Name)
+		} else {
+			onlyConfigured = append(onlyConfigured, c.Name)
+		}
+	}
+
+	found := make(map[string]bool, len(discovered))
+	for i, h := range discovered {
+		if matched[i] || found[h.Address] {
+			continue
+		}
+		found[h.Address] = true
+		onlyDiscovered = append(onlyDiscovered, h.Address)
+	}
+
+	sort.Strings(onlyConfigured)
+	sort.Strings(onlyDiscovered)
+	sort.Strings(both)
+	return onlyConfigured, onlyDiscovered, both
+}
+
+// FormatDiff renders the result of Diff as a short report: a summary line
+// followed by the hosts missing from the network and those missing from the
+// config. Matched hosts are only counted. If color is true, missing hosts
+// are shown in red, unknown hosts in yellow and the in-sync line in green.
+func FormatDiff(onlyConfigured, onlyDiscovered, both []string, color bool) string {
+	paint := func(s, code string) string {
+		if !color {
+			return s
+		}
+		return code + s + "\033[0m"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %d matched, %d configured but not found, %d found but not configured\n",
+		len(both), len(onlyConfigured), len(onlyDiscovered))
+	if len(onlyConfigured) == 0 && len(onlyDiscovered) == 0 {
+		sb.WriteString(paint(" config is in sync with the network", "\033[32m"))
+		sb.WriteString("\n")
+		return sb.String()
+	}
+	if len(onlyConfigured) > 0 {
+		sb.WriteString(paint(" configured but not found:", "\033[1;31m"))
+		sb.WriteString("\n")
+		for _, h := range onlyConfigured {
+			fmt.Fprintf(&sb, "   - %s\n", paint(h, "\033[31m"))
+		}
+	}
+	if len(onlyDiscovered) > 0 {
+		sb.WriteString(paint(" found but not configured:", "\033[1;33m"))
+		sb.WriteString("\n")
+		for _, h := range onlyDiscovered {
+			fmt.Fprintf(&sb, "   + %s\n", paint(h, "\033[33m"))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,67 @@
+package discover
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/config"
+)
+
+func TestDiff(t *testing.T) {
+	configured := []config.Host{
+		{Name: "10.0.0.3", Hostname: "10.0.0.3"},
+		{Name: "10.0.0.1", Hostname: "10.0.0.1"},
+		{Name: "10.0.0.9", Hostname: "10.0.0.9"},
+		{Name: "10.0.0.5:2222", Hostname: "10.0.0.5", Port: 2222},
+		{Name: "10.0.0.1", Hostname: "10.0.0.1"},
+		{Name: "pi-garage", Hostname: "10.0.0.7"},
+		{Name: "pi-shed", Hostname: "pi-shed.lan"},
+		{Name: "10.0.0.8:2200", Hostname: "10.0.0.8", Port: 2200},
+	}
+	discovered := []Host{
+		{Address: "10.0.0.1", Port: 22},
+		{Address: "10.0.0.2", Port: 22},
+		{Address: "10.0.0.3", Port: 22},
+		{Address: "10.0.0.5", Port: 2222},
+		{Address: "10.0.0.7", Port: 22},
+		{Address: "10.0.0.8", Port: 22},
+	}
+
+	onlyConfigured, onlyDiscovered, both := Diff(configured, discovered)
+
+	if want := []string{"10.0.0.8:2200", "10.0.0.9", "pi-shed"}; !reflect.DeepEqual(onlyConfigured, want) {
+		t.Errorf("onlyConfigured = %v, want %v", onlyConfigured, want)
+	}
+	if want := []string{"10.0.0.2", "10.0.0.8"}; !reflect.DeepEqual(onlyDiscovered, want) {
+		t.Errorf("onlyDiscovered = %v, want %v", onlyDiscovered, want)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.3", "10.0.0.5:2222", "pi-garage"}; !reflect.DeepEqual(both, want) {
+		t.Errorf("both = %v, want %v", both, want)
+	}
+}
+
+func TestDiff_Empty(t *testing.T) {
+	onlyConfigured, onlyDiscovered, both := Diff(nil, nil)
+	if onlyConfigured != nil || onlyDiscovered != nil || both != nil {
+		t.Errorf("expected all nil, got %v %v %v", onlyConfigured, onlyDiscovered, both)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	out := FormatDiff([]string{"10.0.0.9"}, []string{"10.0.0.2"}, []string{"10.0.0.1"}, false)
+	for _, want := range []string{
+		"1 matched, 1 configured but not found, 1 found but not configured",
+		"   - 10.0.0.9\n",
+		"   + 10.0.0.2\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out = FormatDiff(nil, nil, []string{"10.0.0.1"}, false)
+	if !strings.Contains(out, "in sync") {
+		t.Errorf("expected in-sync line, got:\n%s", out)
+	}
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"sync"
+	"time"
+)
+
+// resultCache stores successful results keyed by host and command for a
+// fixed TTL.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time // overridable for tests
+	entries map[cacheKey]cacheEntry
+}
+
+type cacheKey struct {
+	host    string
+	command string
+}
+
+type cacheEntry struct {
+	result  HostResult
+	expires time.Time
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[cacheKey]cacheEntry),
+	}
+}
+
+// get returns a copy of the cached result for host and command, if present
+// and not expired.
+func (c *resultCache) get(host, command string) (*HostResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := cacheKey{host, command}
+	e, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, k)
+		return nil, false
+	}
+	r := e.result
+	r.Cached = true
+	return &r, true
+}
+
+// put stores r if it completed without a connection or timeout error.
+func (c *resultCache) put(command string, r *HostResult) {
+	if r.Err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cacheKey{r.Host, command}] = cacheEntry{result: *r, expires: c.now().Add(c.ttl)}
+}
+
+func (c *resultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[cacheKey]cacheEntry)
+}
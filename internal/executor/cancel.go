@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrCancelled is recorded as the error of a host stopped with CancelHost.
+var ErrCancelled = errors.New("cancelled")
+
+// Run identifies the commands started under a context from NewRun, so
+// CancelHost can stop one host of one run while other runs on the same host
+// carry on.
+type Run struct {
+	id uint64
+}
+
+// runSeq numbers runs so each Run is distinct.
+var runSeq atomic.Uint64
+
+// runContextKey is the context key under which NewRun stores its Run.
+type runContextKey struct{}
+
+// NewRun returns a copy of ctx that tags the hosts of any Execute,
+// ExecuteStream or WaitFor call made with it as belonging to the returned
+// run. Commands run without a tagged context cannot be cancelled per host.
+func NewRun(ctx context.Context) (context.Context, *Run) {
+	run := &Run{id: runSeq.Add(1)}
+	return context.WithValue(ctx, runContextKey{}, run), run
+}
+
+// cancelKey identifies a host within a run.
+type cancelKey struct {
+	run  uint64
+	host string
+}
+
+// cancelRegistry holds the cancel function of each host with a command in
+// flight, by run, so one host can be stopped without stopping the rest.
+type cancelRegistry struct {
+	mu      sync.Mutex
+	running map[cancelKey]context.CancelCauseFunc
+}
+
+func newCancelRegistry() *cancelRegistry {
+	return &cancelRegistry{running: make(map[cancelKey]context.CancelCauseFunc)}
+}
+
+// hostContext returns the context to run a command on host under: derived
+// from ctx with the host's timeout, unless it is NoTimeout, and, if ctx
+// comes from NewRun, registered so CancelHost can stop it. The returned
+// func must be called when the host finishes.
+func (e *Executor) hostContext(ctx context.Context, host string) (context.Context, func()) {
+	cancelCtx, cancelCause := context.WithCancelCause(ctx)
+	hostCtx, cancelTimeout := cancelCtx, context.CancelFunc(func() {})
+	if timeout := e.hostTimeout(host); timeout != NoTimeout {
+		hostCtx, cancelTimeout = context.WithTimeout(cancelCtx, timeout)
+	}
+
+	run, ok := ctx.Value(runContextKey{}).(*Run)
+	if !ok {
+		return hostCtx, func() {
+			cancelTimeout()
+			cancelCause(nil)
+		}
+	}
+
+	key := cancelKey{run: run.id, host: host}
+	r := e.cancels
+	r.mu.Lock()
+	r.running[key] = cancelCause
+	r.mu.Unlock()
+
+	return hostCtx, func() {
+		r.mu.Lock()
+		delete(r.running, key)
+		r.mu.Unlock()
+		cancelTimeout()
+		cancelCause(nil)
+	}
+}
+
+// CancelHost stops the command running on host in run, leaving other hosts
+// and other runs untouched. The host's result records ErrCancelled. It
+// reports whether host had a command in flight in run.
+func (e *Executor) CancelHost(run *Run, host string) bool {
+	if run == nil {
+		return false
+	}
+	r := e.cancels
+	r.mu.Lock()
+	cancel, ok := r.running[cancelKey{run: run.id, host: host}]
+	r.mu.Unlock()
+	if ok {
+		cancel(ErrCancelled)
+	}
+	return ok
+}
+
+// Running reports whether host has a command in flight in run.
+func (e *Executor) Running(run *Run, host string) bool {
+	if run == nil {
+		return false
+	}
+	r := e.cancels
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.running[cancelKey{run: run.id, host: host}]
+	return ok
+}
+
+// recordContextErr sets result's error when hostCtx ended before the runner
+// reported one: ErrCancelled for CancelHost, or the deadline for a timeout.
+func recordContextErr(hostCtx context.Context, result *HostResult) {
+	if errors.Is(context.Cause(hostCtx), ErrCancelled) {
+		result.Err = ErrCancelled
+		result.ExitCode = -1
+		return
+	}
+	if hostCtx.Err() == context.DeadlineExceeded && result.Err == nil {
+		result.Err = context.DeadlineExceeded
+	}
+}
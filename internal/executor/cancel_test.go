@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// hangingRunner returns at once for every host except hung, which blocks
+// until its context ends. started is closed once hung is running.
+func hangingRunner(hung string, started chan struct{}) *mockRunner {
+	return &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			if host != hung {
+				return &HostResult{Stdout: []byte("ok\n")}
+			}
+			close(started)
+			<-ctx.Done()
+			return &HostResult{ExitCode: -1, Err: ctx.Err()}
+		},
+	}
+}
+
+// cancelWhenStarted cancels host in run on e once started is closed.
+func cancelWhenStarted(t *testing.T, e *Executor, run *Run, host string, started chan struct{}) {
+	t.Helper()
+	go func() {
+		<-started
+		if !e.CancelHost(run, host) {
+			t.Errorf("CancelHost(%q) = false, want true while running", host)
+		}
+	}()
+}
+
+func TestCancelHost(t *testing.T) {
+	started := make(chan struct{})
+	e := New(hangingRunner("b", started))
+	ctx, run := NewRun(context.Background())
+	cancelWhenStarted(t, e, run, "b", started)
+
+	results := e.Execute(ctx, []string{"a", "b", "c"}, "cmd")
+
+	for _, r := range []*HostResult{results[0], results[2]} {
+		if r.Err != nil || string(r.Stdout) != "ok\n" {
+			t.Errorf("%s: other hosts should finish normally, got %+v", r.Host, r)
+		}
+	}
+	if !errors.Is(results[1].Err, ErrCancelled) || results[1].ExitCode != -1 {
+		t.Errorf("b: err = %v, exit = %d; want ErrCancelled, -1", results[1].Err, results[1].ExitCode)
+	}
+	if e.Running(run, "b") {
+		t.Error("b should not be running after Execute returns")
+	}
+}
+
+func TestCancelHost_Stream(t *testing.T) {
+	started := make(chan struct{})
+	e := New(hangingRunner("b", started))
+	ctx, run := NewRun(context.Background())
+	cancelWhenStarted(t, e, run, "b", started)
+
+	_, final := collect(t, e.ExecuteStream(ctx, []string{"a", "b"}, "cmd"))
+
+	if final["a"].Err != nil {
+		t.Errorf("a: err = %v, want nil", final["a"].Err)
+	}
+	if !errors.Is(final["b"].Err, ErrCancelled) {
+		t.Errorf("b: err = %v, want ErrCancelled", final["b"].Err)
+	}
+}
+
+func TestCancelHost_NotRunning(t *testing.T) {
+	e := New(&mockRunner{handler: func(context.Context, string, string) *HostResult { return &HostResult{} }})
+	ctx, run := NewRun(context.Background())
+	if e.CancelHost(run, "a") {
+		t.Error("CancelHost should report false for a host with nothing in flight")
+	}
+
+	e.Execute(ctx, []string{"a"}, "cmd")
+	if e.CancelHost(run, "a") {
+		t.Error("CancelHost should report false once the host has finished")
+	}
+}
+
+func TestCancelHost_OtherRunUntouched(t *testing.T) {
+	started := make(chan string, 2)
+	e := New(&mockRunner{handler: func(ctx context.Context, host string, _ string) *HostResult {
+		started <- host
+		<-ctx.Done()
+		return &HostResult{ExitCode: -1, Err: ctx.Err()}
+	}})
+
+	ctx1, run1 := NewRun(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	ctx2, run2 := NewRun(ctx2)
+	first := make(chan []*HostResult)
+	second := make(chan []*HostResult)
+	go func() { first <- e.Execute(ctx1, []string{"a"}, "cmd") }()
+	go func() { second <- e.Execute(ctx2, []string{"a"}, "cmd") }()
+	<-started
+	<-started
+
+	if !e.CancelHost(run1, "a") {
+		t.Fatal("CancelHost(run1, a) = false, want true while running")
+	}
+	if results := <-first; !errors.Is(results[0].Err, ErrCancelled) {
+		t.Errorf("run1: err = %v, want ErrCancelled", results[0].Err)
+	}
+	if !e.Running(run2, "a") {
+		t.Error("cancelling a in run1 should leave run2 running")
+	}
+	cancel2()
+	if results := <-second; errors.Is(results[0].Err, ErrCancelled) {
+		t.Errorf("run2: err = %v, want the run's own cancellation", results[0].Err)
+	}
+}
+
+func TestCancelHost_TimeoutStillReported(t *testing.T) {
+	e := New(hangingRunner("a", make(chan struct{})), WithTimeout(20*time.Millisecond))
+	results := e.Execute(context.Background(), []string{"a"}, "cmd")
+	if !errors.Is(results[0].Err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", results[0].Err)
+	}
+}
@@ -2,20 +2,72 @@ package executor
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"sync"
 	"time"
+
+	"github.com/agent462/herd/internal/cmdutil"
 )
 
+// NoTimeout, given to WithTimeout or WithHostTimeouts, runs commands
+// without a timeout, for long jobs such as backups. They still stop when
+// the context passed to Execute ends or CancelHost is called.
+const NoTimeout time.Duration = -1
+
+// RunIDEnv is the environment variable that carries the run ID to remote
+// commands when WithRunID is used.
+const RunIDEnv = "HERD_RUN_ID"
+
 // Runner is the interface that the SSH layer implements to execute a command on a single host.
 type Runner interface {
 	Run(ctx context.Context, host string, command string) *HostResult
 }
 
+// CombinedRunner is optionally implemented by Runners that can capture
+// stdout and stderr as a single interleaved stream. See WithCombinedOutput.
+type CombinedRunner interface {
+	RunCombined(ctx context.Context, host string, command string) *HostResult
+}
+
 // Executor fans out command execution across multiple hosts with bounded concurrency.
 type Executor struct {
 	runner      Runner
 	concurrency int
 	timeout     time.Duration
+	cache       *resultCache    // nil unless WithResultCache is used
+	runIDs      bool            // tag each run with a correlation ID
+	runID       string          // fixed run ID; empty generates one per run
+	combined    bool            // capture stdout and stderr interleaved
+	latency     *latencyHistory // nil unless WithAdaptiveTimeout is used
+	cancels     *cancelRegistry // hosts with a command in flight; see CancelHost
+
+	// rewrite transforms the command for each host; nil unless WithRewrite
+	// is used.
+	rewrite func(host, command string) string
+
+	// sink receives output as it arrives; nil unless WithOutputSink is used.
+	sink OutputSink
+
+	// hostTimeouts overrides timeout for individual hosts; nil unless
+	// WithHostTimeouts is used.
+	hostTimeouts map[string]time.Duration
+
+	// requireReachable makes ExecuteRun fail unless every host connects;
+	// see WithRequireAllReachable.
+	requireReachable bool
+
+	// limiter bounds concurrency across executors; nil unless WithLimiter
+	// is used.
+	limiter *Limiter
+
+	// attempts and backoff retry connection errors; see WithRetry.
+	attempts int
+	backoff  time.Duration
+
+	// progress is told as each host finishes; nil unless WithProgress is
+	// used.
+	progress ProgressFunc
 }
 
 // Option configures an Executor.
@@ -30,21 +82,99 @@ func WithConcurrency(n int) Option {
 	}
 }
 
-// WithTimeout sets the per-host command timeout.
+// WithTimeout sets the per-host command timeout. NoTimeout disables it;
+// other values that are not positive are ignored.
 func WithTimeout(d time.Duration) Option {
 	return func(e *Executor) {
-		if d > 0 {
+		if d > 0 || d == NoTimeout {
 			e.timeout = d
 		}
 	}
 }
 
+// WithHostTimeouts sets the command timeout of individual hosts, such as
+// those of a group with its own timeout, in place of the one set by
+// WithTimeout or WithAdaptiveTimeout. NoTimeout disables a host's timeout;
+// other values that are not positive are ignored.
+func WithHostTimeouts(timeouts map[string]time.Duration) Option {
+	return func(e *Executor) {
+		for host, d := range timeouts {
+			if d <= 0 && d != NoTimeout {
+				continue
+			}
+			if e.hostTimeouts == nil {
+				e.hostTimeouts = make(map[string]time.Duration)
+			}
+			e.hostTimeouts[host] = d
+		}
+	}
+}
+
+// WithResultCache enables caching of successful results by host and command
+// for ttl. Repeated identical commands within the TTL are answered from the
+// cache without contacting the host. Use ExecuteNoCache for commands that
+// must always run.
+func WithResultCache(ttl time.Duration) Option {
+	return func(e *Executor) {
+		if ttl > 0 {
+			e.cache = newResultCache(ttl)
+		}
+	}
+}
+
+// WithRunID tags every command with a correlation ID, exported to the remote
+// command as HERD_RUN_ID and recorded in each HostResult. An empty id
+// generates a fresh random ID for every Execute call, so each run can be
+// matched against remote logs on its own.
+func WithRunID(id string) Option {
+	return func(e *Executor) {
+		e.runIDs = true
+		e.runID = id
+	}
+}
+
+// WithCombinedOutput captures stdout and stderr as one interleaved stream in
+// HostResult.Stdout, so grouping compares output in the order it was written.
+// It has no effect if the Runner does not implement CombinedRunner.
+func WithCombinedOutput() Option {
+	return func(e *Executor) {
+		e.combined = true
+	}
+}
+
+// WithRewrite transforms the command before it is sent to each host, e.g. to
+// prefix it with sudo on some hosts only. fn receives the host name and the
+// command as given, and returns the command to run there. The result cache is
+// still keyed by the command as given.
+func WithRewrite(fn func(host, command string) string) Option {
+	return func(e *Executor) {
+		e.rewrite = fn
+	}
+}
+
+// WithAdaptiveTimeout derives each host's timeout from its recent latency:
+// the slowest of its last few successful runs plus margin. Hosts with no
+// history, or whose last run timed out, use base.
+func WithAdaptiveTimeout(base, margin time.Duration) Option {
+	return func(e *Executor) {
+		if base > 0 {
+			e.timeout = base
+		}
+		e.latency = newLatencyHistory(max(margin, 0))
+	}
+}
+
+// defaultConcurrency is how many hosts an Executor runs at once unless
+// WithConcurrency says otherwise.
+const defaultConcurrency = 20
+
 // New creates an Executor with the given Runner and options.
 func New(runner Runner, opts ...Option) *Executor {
 	e := &Executor{
 		runner:      runner,
-		concurrency: 20,
+		concurrency: defaultConcurrency,
 		timeout:     30 * time.Second,
+		cancels:     newCancelRegistry(),
 	}
 	for _, opt := range opts {
 		opt(e)
@@ -52,52 +182,163 @@ func New(runner Runner, opts ...Option) *Executor {
 	return e
 }
 
-// Execute runs command on all hosts in parallel, bounded by the concurrency limit.
-// Results are returned in the same order as the input hosts slice.
+// WithFixedTimeout returns a copy of e whose commands time out after d on
+// every host, ignoring adaptive and per-host timeouts, for a single command
+// that is known to take longer or shorter than usual. d may be NoTimeout.
+// The copy shares e's runner, result cache and cancel registry.
+func (e *Executor) WithFixedTimeout(d time.Duration) *Executor {
+	c := *e
+	c.timeout = d
+	c.latency = nil
+	c.hostTimeouts = nil
+	return &c
+}
+
+// Execute runs command on all hosts in parallel, bounded by the concurrency
+// limit. Hosts start in the order given, so with a limit of 1 they run one
+// at a time in that order. Results are returned in the same order as the
+// input hosts slice. When a result cache is configured, unexpired cached
+// results are returned instead of running the command again.
 func (e *Executor) Execute(ctx context.Context, hosts []string, command string) []*HostResult {
+	return e.execute(ctx, hosts, command, e.cache)
+}
+
+// ExecuteNoCache is like Execute but always runs the command, neither
+// reading nor populating the result cache. Use it for commands with side
+// effects.
+func (e *Executor) ExecuteNoCache(ctx context.Context, hosts []string, command string) []*HostResult {
+	return e.execute(ctx, hosts, command, nil)
+}
+
+// ClearCache discards all cached results. It is a no-op without a cache.
+func (e *Executor) ClearCache() {
+	if e.cache != nil {
+		e.cache.clear()
+	}
+}
+
+func (e *Executor) execute(ctx context.Context, hosts []string, command string, cache *resultCache) []*HostResult {
 	results := make([]*HostResult, len(hosts))
 	if len(hosts) == 0 {
 		return results
 	}
 
-	sem := make(chan struct{}, e.concurrency)
+	runID := e.newRunID()
+	progress := e.newProgress(len(hosts))
+
+	sem := e.semaphore()
 	var wg sync.WaitGroup
 
 	for i, host := range hosts {
+		if cache != nil {
+			if cached, ok := cache.get(host, command); ok {
+				e.emit(host, Stdout, cached.Stdout)
+				e.emit(host, Stderr, cached.Stderr)
+				results[i] = cached
+				progress.finish(cached)
+				continue
+			}
+		}
+
+		// Acquire the semaphore before starting the goroutine, respecting
+		// parent context cancellation, so hosts start in the order given.
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = &HostResult{
+				Host:  host,
+				Err:   ctx.Err(),
+				RunID: runID,
+			}
+			progress.finish(results[i])
+			continue
+		}
+
 		wg.Add(1)
 		go func(idx int, h string) {
 			defer wg.Done()
+			defer func() { <-sem }()
 
-			// Acquire semaphore, respecting parent context cancellation.
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-ctx.Done():
-				results[idx] = &HostResult{
-					Host: h,
-					Err:  ctx.Err(),
-				}
-				return
-			}
-
-			// Create a per-host timeout context derived from the parent.
-			hostCtx, cancel := context.WithTimeout(ctx, e.timeout)
-			defer cancel()
+			// Create a per-host timeout context derived from the parent,
+			// cancellable on its own with CancelHost.
+			hostCtx, done := e.hostContext(ctx, h)
+			defer done()
 
 			start := time.Now()
-			result := e.runner.Run(hostCtx, h, command)
+			result := e.retry(hostCtx, func(attemptCtx context.Context) (*HostResult, bool) {
+				r := e.runToSink(attemptCtx, h, e.remoteCommand(h, command, runID))
+				return r, len(r.Stdout) > 0 || len(r.Stderr) > 0
+			})
 			result.Duration = time.Since(start)
 			result.Host = h
-
-			// If the per-host context timed out but the runner didn't set an error, record it.
-			if hostCtx.Err() == context.DeadlineExceeded && result.Err == nil {
-				result.Err = context.DeadlineExceeded
+			if e.runIDs {
+				result.RunID = runID
 			}
 
+			// If the per-host context timed out or was cancelled, record why.
+			recordContextErr(hostCtx, result)
+
+			if e.latency != nil {
+				e.latency.record(result)
+			}
+			if cache != nil {
+				cache.put(command, result)
+			}
 			results[idx] = result
+			progress.finish(result)
 		}(i, host)
 	}
 
 	wg.Wait()
 	return results
 }
+
+// newRunID returns the run ID for one Execute call: empty unless WithRunID
+// is used.
+func (e *Executor) newRunID() string {
+	if !e.runIDs || e.runID != "" {
+		return e.runID
+	}
+	return NewRunID()
+}
+
+// remoteCommand returns command as it should be sent to host: rewritten for
+// the host when WithRewrite is used, and exporting runID when it is set.
+func (e *Executor) remoteCommand(host, command, runID string) string {
+	if e.rewrite != nil {
+		command = e.rewrite(host, command)
+	}
+	if runID == "" {
+		return command
+	}
+	return "export " + RunIDEnv + "=" + cmdutil.Quote(runID) + "; " + command
+}
+
+// hostTimeout returns the command timeout for host.
+func (e *Executor) hostTimeout(host string) time.Duration {
+	if d, ok := e.hostTimeouts[host]; ok {
+		return d
+	}
+	if e.latency != nil && e.timeout != NoTimeout {
+		return e.latency.timeout(host, e.timeout)
+	}
+	return e.timeout
+}
+
+// run executes command on host with the runner, using RunCombined when
+// combined output was requested and is supported.
+func (e *Executor) run(ctx context.Context, host, command string) *HostResult {
+	if e.combined {
+		if cr, ok := e.runner.(CombinedRunner); ok {
+			return cr.RunCombined(ctx, host, command)
+		}
+	}
+	return e.runner.Run(ctx, host, command)
+}
+
+// NewRunID returns a random 16-character hex correlation ID.
+func NewRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,7 +2,9 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -83,6 +85,26 @@ func TestExecute_PreservesHostOrder(t *testing.T) {
 	}
 }
 
+func TestExecute_StartsHostsInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			mu.Lock()
+			started = append(started, host)
+			mu.Unlock()
+			return &HostResult{Host: host}
+		},
+	}
+
+	hosts := []string{"canary", "web-03", "web-01", "web-02", "web-05", "web-04"}
+	New(runner, WithConcurrency(1)).Execute(context.Background(), hosts, "test")
+
+	if fmt.Sprint(started) != fmt.Sprint(hosts) {
+		t.Errorf("start order = %v, want %v", started, hosts)
+	}
+}
+
 func TestExecute_ConcurrencyLimiting(t *testing.T) {
 	var running atomic.Int32
 	var maxRunning atomic.Int32
@@ -295,3 +317,295 @@ func TestWithTimeout_IgnoresInvalid(t *testing.T) {
 		t.Errorf("expected default timeout 30s, got %v", e.timeout)
 	}
 }
+
+func TestNoTimeout(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			if _, ok := ctx.Deadline(); ok {
+				return &HostResult{Host: host, Err: errors.New("unexpected deadline")}
+			}
+			return &HostResult{Host: host}
+		},
+	}
+	e := New(runner, WithTimeout(NoTimeout), WithAdaptiveTimeout(0, time.Second))
+	if got := e.hostTimeout("a"); got != NoTimeout {
+		t.Errorf("hostTimeout = %v, want NoTimeout", got)
+	}
+	results := e.Execute(context.Background(), []string{"a"}, "backup")
+	if results[0].Err != nil {
+		t.Errorf("err = %v, want none", results[0].Err)
+	}
+}
+
+func TestWithHostTimeouts(t *testing.T) {
+	runner := &mockRunner{}
+	e := New(runner, WithTimeout(time.Minute), WithHostTimeouts(map[string]time.Duration{
+		"slow": 10 * time.Minute,
+		"big":  NoTimeout,
+		"bad":  -time.Second,
+	}))
+
+	tests := map[string]time.Duration{
+		"slow":  10 * time.Minute,
+		"big":   NoTimeout,
+		"bad":   time.Minute,
+		"other": time.Minute,
+	}
+	for host, want := range tests {
+		if got := e.hostTimeout(host); got != want {
+			t.Errorf("hostTimeout(%s) = %v, want %v", host, got, want)
+		}
+	}
+	if got := e.WithFixedTimeout(time.Second).hostTimeout("big"); got != time.Second {
+		t.Errorf("fixed copy hostTimeout(big) = %v, want 1s", got)
+	}
+}
+
+func TestWithFixedTimeout(t *testing.T) {
+	runner := &mockRunner{}
+	e := New(runner, WithAdaptiveTimeout(30*time.Second, time.Second))
+	c := e.WithFixedTimeout(10 * time.Minute)
+
+	if got := c.hostTimeout("a"); got != 10*time.Minute {
+		t.Errorf("copy timeout = %v, want 10m", got)
+	}
+	if e.timeout != 30*time.Second || e.latency == nil {
+		t.Error("WithFixedTimeout should leave the original executor unchanged")
+	}
+	if c.runner != e.runner || c.cancels != e.cancels {
+		t.Error("copy should share the runner and cancel registry")
+	}
+}
+
+func TestConnectionCounts(t *testing.T) {
+	results := []*HostResult{
+		{Host: "a", Reused: true},
+		{Host: "b", Reused: true},
+		{Host: "c"},
+		nil,
+	}
+	warm, cold := ConnectionCounts(results)
+	if warm != 2 || cold != 1 {
+		t.Errorf("ConnectionCounts = (%d, %d), want (2, 1)", warm, cold)
+	}
+}
+
+func TestExecute_ResultCache(t *testing.T) {
+	var calls atomic.Int32
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			n := calls.Add(1)
+			return &HostResult{Stdout: []byte(fmt.Sprintf("run %d", n))}
+		},
+	}
+
+	now := time.Now()
+	e := New(runner, WithResultCache(time.Minute))
+	e.cache.now = func() time.Time { return now }
+
+	first := e.Execute(context.Background(), []string{"host-a"}, "lsb_release -a")
+	second := e.Execute(context.Background(), []string{"host-a"}, "lsb_release -a")
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 runner call, got %d", calls.Load())
+	}
+	if first[0].Cached || !second[0].Cached {
+		t.Errorf("Cached = %v, %v; want false, true", first[0].Cached, second[0].Cached)
+	}
+	if string(second[0].Stdout) != "run 1" {
+		t.Errorf("cached stdout = %q, want %q", second[0].Stdout, "run 1")
+	}
+
+	// A different command is not served from the cache.
+	e.Execute(context.Background(), []string{"host-a"}, "uname -r")
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 runner calls, got %d", calls.Load())
+	}
+
+	// After the TTL expires the command runs again.
+	now = now.Add(2 * time.Minute)
+	third := e.Execute(context.Background(), []string{"host-a"}, "lsb_release -a")
+	if third[0].Cached || calls.Load() != 3 {
+		t.Errorf("expected fresh run after TTL, Cached=%v calls=%d", third[0].Cached, calls.Load())
+	}
+}
+
+func TestExecute_ResultCacheSkipsErrors(t *testing.T) {
+	var calls atomic.Int32
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			calls.Add(1)
+			return &HostResult{Err: fmt.Errorf("connection refused")}
+		},
+	}
+
+	e := New(runner, WithResultCache(time.Minute))
+	e.Execute(context.Background(), []string{"host-a"}, "uptime")
+	e.Execute(context.Background(), []string{"host-a"}, "uptime")
+
+	if calls.Load() != 2 {
+		t.Errorf("expected errors not to be cached, got %d calls", calls.Load())
+	}
+}
+
+func TestExecuteNoCache(t *testing.T) {
+	var calls atomic.Int32
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			calls.Add(1)
+			return &HostResult{Stdout: []byte("ok")}
+		},
+	}
+
+	e := New(runner, WithResultCache(time.Minute))
+	e.Execute(context.Background(), []string{"host-a"}, "systemctl restart nginx")
+	r := e.ExecuteNoCache(context.Background(), []string{"host-a"}, "systemctl restart nginx")
+	if r[0].Cached || calls.Load() != 2 {
+		t.Errorf("ExecuteNoCache should always run, Cached=%v calls=%d", r[0].Cached, calls.Load())
+	}
+
+	e.ClearCache()
+	e.Execute(context.Background(), []string{"host-a"}, "systemctl restart nginx")
+	if calls.Load() != 3 {
+		t.Errorf("expected run after ClearCache, got %d calls", calls.Load())
+	}
+}
+
+func TestExecute_RunID(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			mu.Lock()
+			commands = append(commands, command)
+			mu.Unlock()
+			return &HostResult{Host: host}
+		},
+	}
+
+	e := New(runner, WithRunID("abc123"))
+	results := e.Execute(context.Background(), []string{"host-a", "host-b"}, "uptime")
+
+	for _, r := range results {
+		if r.RunID != "abc123" {
+			t.Errorf("%s: expected run ID abc123, got %q", r.Host, r.RunID)
+		}
+	}
+	for _, c := range commands {
+		if c != "export HERD_RUN_ID=abc123; uptime" {
+			t.Errorf("unexpected remote command %q", c)
+		}
+	}
+}
+
+func TestExecute_RunIDGeneratedPerRun(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host}
+		},
+	}
+
+	e := New(runner, WithRunID(""))
+	first := e.Execute(context.Background(), []string{"host-a", "host-b"}, "uptime")
+	second := e.Execute(context.Background(), []string{"host-a"}, "uptime")
+
+	if len(first[0].RunID) != 16 {
+		t.Fatalf("expected generated 16-char run ID, got %q", first[0].RunID)
+	}
+	if first[0].RunID != first[1].RunID {
+		t.Errorf("hosts in one run should share an ID: %q vs %q", first[0].RunID, first[1].RunID)
+	}
+	if second[0].RunID == first[0].RunID {
+		t.Errorf("expected a new ID for each run, got %q twice", first[0].RunID)
+	}
+}
+
+func TestExecute_Rewrite(t *testing.T) {
+	var mu sync.Mutex
+	commands := make(map[string]string)
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			mu.Lock()
+			commands[host] = command
+			mu.Unlock()
+			return &HostResult{Host: host}
+		},
+	}
+	rewrite := func(host, command string) string {
+		if host == "pi-1" {
+			return "sudo " + command
+		}
+		return command
+	}
+
+	e := New(runner, WithRewrite(rewrite), WithRunID("abc123"))
+	e.Execute(context.Background(), []string{"pi-1", "web-1"}, "uptime")
+
+	if got := commands["pi-1"]; got != "export HERD_RUN_ID=abc123; sudo uptime" {
+		t.Errorf("pi-1: remote command = %q", got)
+	}
+	if got := commands["web-1"]; got != "export HERD_RUN_ID=abc123; uptime" {
+		t.Errorf("web-1: remote command = %q", got)
+	}
+}
+
+func TestExecute_NoRunIDByDefault(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			if command != "uptime" {
+				t.Errorf("command should be unchanged, got %q", command)
+			}
+			return &HostResult{Host: host}
+		},
+	}
+
+	results := New(runner).Execute(context.Background(), []string{"host-a"}, "uptime")
+	if results[0].RunID != "" {
+		t.Errorf("expected no run ID, got %q", results[0].RunID)
+	}
+}
+
+// combinedMockRunner records whether RunCombined was used.
+type combinedMockRunner struct {
+	mockRunner
+	combinedCalls atomic.Int32
+}
+
+func (m *combinedMockRunner) RunCombined(ctx context.Context, host string, command string) *HostResult {
+	m.combinedCalls.Add(1)
+	return &HostResult{Host: host, Stdout: []byte("out\nerr\n")}
+}
+
+func TestExecute_CombinedOutput(t *testing.T) {
+	runner := &combinedMockRunner{mockRunner: mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host, Stdout: []byte("out\n"), Stderr: []byte("err\n")}
+		},
+	}}
+
+	results := New(runner).Execute(context.Background(), []string{"host-a"}, "make")
+	if runner.combinedCalls.Load() != 0 || string(results[0].Stderr) != "err\n" {
+		t.Error("expected separate streams without WithCombinedOutput")
+	}
+
+	results = New(runner, WithCombinedOutput()).Execute(context.Background(), []string{"host-a", "host-b"}, "make")
+	if got := runner.combinedCalls.Load(); got != 2 {
+		t.Errorf("expected 2 RunCombined calls, got %d", got)
+	}
+	if string(results[0].Stdout) != "out\nerr\n" || results[0].Stderr != nil {
+		t.Errorf("unexpected combined result: stdout=%q stderr=%q", results[0].Stdout, results[0].Stderr)
+	}
+}
+
+func TestExecute_CombinedOutputFallsBackToRun(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host, Stdout: []byte("out\n")}
+		},
+	}
+
+	results := New(runner, WithCombinedOutput()).Execute(context.Background(), []string{"host-a"}, "make")
+	if string(results[0].Stdout) != "out\n" {
+		t.Errorf("expected Run fallback, got stdout %q", results[0].Stdout)
+	}
+}
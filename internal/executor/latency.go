@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// latencyWindow is the number of recent durations kept per host.
+const latencyWindow = 10
+
+// latencyHistory records recent command durations per host and derives an
+// adaptive per-host timeout from them.
+type latencyHistory struct {
+	mu     sync.Mutex
+	margin time.Duration
+	hosts  map[string][]time.Duration
+}
+
+func newLatencyHistory(margin time.Duration) *latencyHistory {
+	return &latencyHistory{
+		margin: margin,
+		hosts:  make(map[string][]time.Duration),
+	}
+}
+
+// timeout returns the slowest recent duration for host plus the margin, or
+// base if the host has no history.
+func (l *latencyHistory) timeout(host string, base time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	durations := l.hosts[host]
+	if len(durations) == 0 {
+		return base
+	}
+	var slowest time.Duration
+	for _, d := range durations {
+		slowest = max(slowest, d)
+	}
+	return slowest + l.margin
+}
+
+// record adds the duration of a completed result to its host's history. A
+// timeout clears the host's history instead, so the next run falls back to
+// the base timeout rather than timing out again at the same limit.
+func (l *latencyHistory) record(r *HostResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case r.Err == nil:
+		durations := append(l.hosts[r.Host], r.Duration)
+		if len(durations) > latencyWindow {
+			durations = durations[len(durations)-latencyWindow:]
+		}
+		l.hosts[r.Host] = durations
+	case errors.Is(r.Err, context.DeadlineExceeded):
+		delete(l.hosts, r.Host)
+	}
+}
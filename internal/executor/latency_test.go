@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistory_Timeout(t *testing.T) {
+	l := newLatencyHistory(time.Second)
+	base := 30 * time.Second
+
+	if got := l.timeout("a", base); got != base {
+		t.Errorf("no history: timeout = %v, want base %v", got, base)
+	}
+
+	l.record(&HostResult{Host: "a", Duration: 2 * time.Second})
+	l.record(&HostResult{Host: "a", Duration: 5 * time.Second})
+	l.record(&HostResult{Host: "a", Duration: 3 * time.Second})
+	if got, want := l.timeout("a", base), 6*time.Second; got != want {
+		t.Errorf("timeout = %v, want slowest+margin %v", got, want)
+	}
+
+	// Connection errors are not latency samples.
+	l.record(&HostResult{Host: "a", Duration: time.Minute, Err: errors.New("refused")})
+	if got, want := l.timeout("a", base), 6*time.Second; got != want {
+		t.Errorf("after error: timeout = %v, want %v", got, want)
+	}
+
+	// A timeout resets the host to the base timeout.
+	l.record(&HostResult{Host: "a", Err: context.DeadlineExceeded})
+	if got := l.timeout("a", base); got != base {
+		t.Errorf("after timeout: timeout = %v, want base %v", got, base)
+	}
+}
+
+func TestLatencyHistory_Window(t *testing.T) {
+	l := newLatencyHistory(0)
+	l.record(&HostResult{Host: "a", Duration: time.Minute})
+	for range latencyWindow {
+		l.record(&HostResult{Host: "a", Duration: time.Second})
+	}
+	if got := l.timeout("a", time.Hour); got != time.Second {
+		t.Errorf("timeout = %v, want old sample aged out (1s)", got)
+	}
+}
+
+func TestExecute_AdaptiveTimeout(t *testing.T) {
+	var remaining []time.Duration
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			deadline, _ := ctx.Deadline()
+			remaining = append(remaining, time.Until(deadline))
+			return &HostResult{Host: host}
+		},
+	}
+
+	e := New(runner, WithAdaptiveTimeout(10*time.Second, 500*time.Millisecond))
+	e.Execute(context.Background(), []string{"fast"}, "true")
+	e.Execute(context.Background(), []string{"fast"}, "true")
+
+	if remaining[0] < 5*time.Second {
+		t.Errorf("first run deadline in %v, want base timeout", remaining[0])
+	}
+	if remaining[1] > time.Second {
+		t.Errorf("second run deadline in %v, want latency plus margin", remaining[1])
+	}
+}
@@ -0,0 +1,35 @@
+package executor
+
+// Limiter bounds how many hosts run at once across every executor that
+// shares it; see WithLimiter.
+type Limiter struct {
+	sem chan struct{}
+}
+
+// NewLimiter returns a Limiter that lets n hosts run at once. n <= 0 uses
+// the executor's default.
+func NewLimiter(n int) *Limiter {
+	if n <= 0 {
+		n = defaultConcurrency
+	}
+	return &Limiter{sem: make(chan struct{}, n)}
+}
+
+// WithLimiter makes the executor take its concurrency slots from l rather
+// than its own limit, so executors running side by side, such as one per
+// group in a multi-group recipe, stay within l's limit together. It
+// overrides WithConcurrency.
+func WithLimiter(l *Limiter) Option {
+	return func(e *Executor) {
+		e.limiter = l
+	}
+}
+
+// semaphore returns the channel bounding a run: the shared limiter's if
+// there is one, otherwise a fresh one sized to the concurrency limit.
+func (e *Executor) semaphore() chan struct{} {
+	if e.limiter != nil {
+		return e.limiter.sem
+	}
+	return make(chan struct{}, e.concurrency)
+}
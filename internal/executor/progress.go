@@ -0,0 +1,48 @@
+package executor
+
+import "sync"
+
+// ProgressFunc is called as each host of a run finishes, with the number of
+// hosts done so far, the run's total and the host's result.
+type ProgressFunc func(done, total int, last *HostResult)
+
+// WithProgress calls fn as each host finishes, including hosts answered
+// from the result cache or skipped because the run was cancelled, so it is
+// called exactly once per host. Calls are serialized and done increases by
+// one each time. fn runs on the worker that finished the host, holding up
+// that worker's slot, so it should return quickly: update a counter or a
+// progress bar, and hand anything slower to another goroutine.
+func WithProgress(fn ProgressFunc) Option {
+	return func(e *Executor) {
+		e.progress = fn
+	}
+}
+
+// progressCounter counts the finished hosts of one run for WithProgress.
+// A nil counter ignores finished hosts.
+type progressCounter struct {
+	mu    sync.Mutex
+	fn    ProgressFunc
+	done  int
+	total int
+}
+
+// newProgress returns a counter for a run over total hosts, or nil without
+// WithProgress.
+func (e *Executor) newProgress(total int) *progressCounter {
+	if e.progress == nil {
+		return nil
+	}
+	return &progressCounter{fn: e.progress, total: total}
+}
+
+// finish records that the host of r has finished.
+func (p *progressCounter) finish(r *HostResult) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.fn(p.done, p.total, r)
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWithProgress(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host}
+		},
+	}
+
+	var dones []int
+	seen := make(map[string]bool)
+	e := New(runner, WithConcurrency(4), WithProgress(func(done, total int, last *HostResult) {
+		// Calls are serialized, so no locking is needed here.
+		if total != 10 {
+			t.Errorf("total = %d, want 10", total)
+		}
+		dones = append(dones, done)
+		seen[last.Host] = true
+	}))
+
+	hosts := make([]string, 10)
+	for i := range hosts {
+		hosts[i] = string(rune('a' + i))
+	}
+	e.Execute(context.Background(), hosts, "uptime")
+
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !slices.Equal(dones, want) {
+		t.Errorf("done counts = %v, want %v", dones, want)
+	}
+	if len(seen) != 10 {
+		t.Errorf("progress reported %d distinct hosts, want 10", len(seen))
+	}
+}
+
+func TestWithProgress_CachedAndCancelled(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host, Stdout: []byte("ok")}
+		},
+	}
+
+	calls := 0
+	e := New(runner, WithResultCache(time.Minute), WithProgress(func(done, total int, last *HostResult) {
+		calls++
+	}))
+	e.Execute(context.Background(), []string{"a", "b"}, "uptime")
+	e.Execute(context.Background(), []string{"a", "b"}, "uptime") // from the cache
+	if calls != 4 {
+		t.Errorf("progress called %d times over two cached runs, want 4", calls)
+	}
+
+	calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.ExecuteNoCache(ctx, []string{"a", "b", "c"}, "uptime")
+	if calls != 3 {
+		t.Errorf("progress called %d times for a cancelled run, want 3", calls)
+	}
+}
+
+func TestWithProgress_Stream(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host}
+		},
+	}
+
+	calls := 0
+	e := New(runner, WithProgress(func(done, total int, last *HostResult) {
+		calls++
+	}))
+	for range e.ExecuteStream(context.Background(), []string{"a", "b", "c"}, "uptime") {
+	}
+	if calls != 3 {
+		t.Errorf("progress called %d times, want 3", calls)
+	}
+}
@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotRun is recorded as the error of a reachable host that was skipped
+// because other hosts in the run were unreachable. See
+// WithRequireAllReachable.
+var ErrNotRun = errors.New("not run: other hosts unreachable")
+
+// Connector is optionally implemented by Runners that can connect to a host
+// without running a command. See WithRequireAllReachable.
+type Connector interface {
+	Connect(ctx context.Context, host string) error
+}
+
+// UnreachableError is returned by ExecuteRun when all hosts are required to
+// be reachable and some could not be connected to.
+type UnreachableError struct {
+	Hosts []string // unreachable hosts, in the order given to ExecuteRun
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("%d host(s) unreachable: %s", len(e.Hosts), strings.Join(e.Hosts, ", "))
+}
+
+// WithRequireAllReachable makes ExecuteRun connect to every host before
+// running anything, and fail the whole run with an *UnreachableError,
+// running the command nowhere, if any host cannot be connected to. A Runner
+// that does not implement Connector, such as a relay, cannot make that
+// check, so ExecuteRun fails with ErrReachabilityUnchecked instead of
+// running the command.
+func WithRequireAllReachable(require bool) Option {
+	return func(e *Executor) {
+		e.requireReachable = require
+	}
+}
+
+// ErrReachabilityUnchecked is returned by ExecuteRun, and recorded for every
+// host, when all hosts are required to be reachable but the Runner does not
+// implement Connector. See WithRequireAllReachable.
+var ErrReachabilityUnchecked = errors.New("not run: runner cannot check that hosts are reachable")
+
+// ExecuteRun is like Execute, but with WithRequireAllReachable it first
+// checks that every host is reachable. If some are not, it returns their
+// connection errors alongside ErrNotRun for the rest, and an
+// *UnreachableError, without running the command on any host.
+func (e *Executor) ExecuteRun(ctx context.Context, hosts []string, command string) ([]*HostResult, error) {
+	if e.requireReachable {
+		c, ok := e.runner.(Connector)
+		if !ok {
+			results := make([]*HostResult, len(hosts))
+			for i, host := range hosts {
+				results[i] = &HostResult{Host: host, Err: ErrReachabilityUnchecked, ExitCode: -1}
+			}
+			return results, ErrReachabilityUnchecked
+		}
+		if results, err := e.connectAll(ctx, c, hosts); err != nil {
+			return results, err
+		}
+	}
+	return e.Execute(ctx, hosts, command), nil
+}
+
+// connectAll connects to hosts in parallel, bounded by the concurrency limit
+// and each host's timeout. If any host fails it returns a result for every
+// host and an *UnreachableError; otherwise it returns nil, nil.
+func (e *Executor) connectAll(ctx context.Context, c Connector, hosts []string) ([]*HostResult, error) {
+	errs := make([]error, len(hosts))
+	durations := make([]time.Duration, len(hosts))
+
+	sem := e.semaphore()
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int, h string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			hostCtx, cancel := ctx, context.CancelFunc(func() {})
+			if timeout := e.hostTimeout(h); timeout != NoTimeout {
+				hostCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			defer cancel()
+
+			start := time.Now()
+			errs[idx] = c.Connect(hostCtx, h)
+			durations[idx] = time.Since(start)
+		}(i, host)
+	}
+	wg.Wait()
+
+	var unreachable []string
+	for i, err := range errs {
+		if err != nil {
+			unreachable = append(unreachable, hosts[i])
+		}
+	}
+	if len(unreachable) == 0 {
+		return nil, nil
+	}
+
+	results := make([]*HostResult, len(hosts))
+	for i, host := range hosts {
+		err := errs[i]
+		if err == nil {
+			err = ErrNotRun
+		}
+		results[i] = &HostResult{Host: host, Err: err, ExitCode: -1, Duration: durations[i]}
+	}
+	return results, &UnreachableError{Hosts: unreachable}
+}
@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// connectorMockRunner is a mockRunner that also implements Connector,
+// failing to connect to the hosts in down.
+type connectorMockRunner struct {
+	mockRunner
+	down map[string]bool
+}
+
+func (m *connectorMockRunner) Connect(ctx context.Context, host string) error {
+	if m.down[host] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestExecuteRun_RequireAllReachable(t *testing.T) {
+	var ran atomic.Int32
+	runner := &connectorMockRunner{
+		mockRunner: mockRunner{handler: func(ctx context.Context, host string, command string) *HostResult {
+			ran.Add(1)
+			return &HostResult{Host: host}
+		}},
+		down: map[string]bool{"host-b": true},
+	}
+
+	e := New(runner, WithRequireAllReachable(true))
+	results, err := e.ExecuteRun(context.Background(), []string{"host-a", "host-b", "host-c"}, "uptime")
+
+	var unreachable *UnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("err = %v, want *UnreachableError", err)
+	}
+	if len(unreachable.Hosts) != 1 || unreachable.Hosts[0] != "host-b" {
+		t.Errorf("unreachable hosts = %v, want [host-b]", unreachable.Hosts)
+	}
+	if n := ran.Load(); n != 0 {
+		t.Errorf("command ran on %d hosts, want none", n)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if results[1].Err == nil || errors.Is(results[1].Err, ErrNotRun) {
+		t.Errorf("host-b err = %v, want its connection error", results[1].Err)
+	}
+	for _, i := range []int{0, 2} {
+		if !errors.Is(results[i].Err, ErrNotRun) {
+			t.Errorf("%s err = %v, want ErrNotRun", results[i].Host, results[i].Err)
+		}
+	}
+}
+
+func TestExecuteRun_AllReachable(t *testing.T) {
+	runner := &connectorMockRunner{
+		mockRunner: mockRunner{handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host, Stdout: []byte("ok")}
+		}},
+	}
+
+	e := New(runner, WithRequireAllReachable(true))
+	results, err := e.ExecuteRun(context.Background(), []string{"host-a", "host-b"}, "uptime")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range results {
+		if r.Err != nil || string(r.Stdout) != "ok" {
+			t.Errorf("%s: stdout %q, err %v", r.Host, r.Stdout, r.Err)
+		}
+	}
+}
+
+func TestExecuteRun_NotRequired(t *testing.T) {
+	runner := &connectorMockRunner{
+		mockRunner: mockRunner{handler: func(ctx context.Context, host string, command string) *HostResult {
+			if host == "host-b" {
+				return &HostResult{Host: host, Err: errors.New("connection refused")}
+			}
+			return &HostResult{Host: host}
+		}},
+		down: map[string]bool{"host-b": true},
+	}
+
+	results, err := New(runner).ExecuteRun(context.Background(), []string{"host-a", "host-b"}, "uptime")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Err != nil {
+		t.Errorf("host-a should still run, got err %v", results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Error("expected host-b to report its connection error")
+	}
+}
+
+func TestExecuteRun_RequireAllReachableWithoutConnector(t *testing.T) {
+	var runs atomic.Int32
+	runner := &mockRunner{handler: func(ctx context.Context, host string, command string) *HostResult {
+		runs.Add(1)
+		return &HostResult{Host: host}
+	}}
+
+	e := New(runner, WithRequireAllReachable(true))
+	results, err := e.ExecuteRun(context.Background(), []string{"host-a", "host-b"}, "uptime")
+	if !errors.Is(err, ErrReachabilityUnchecked) {
+		t.Fatalf("err = %v, want ErrReachabilityUnchecked", err)
+	}
+	if n := runs.Load(); n != 0 {
+		t.Errorf("command ran on %d hosts, want none", n)
+	}
+	for _, r := range results {
+		if !errors.Is(r.Err, ErrReachabilityUnchecked) {
+			t.Errorf("%s: err = %v, want ErrReachabilityUnchecked", r.Host, r.Err)
+		}
+	}
+}
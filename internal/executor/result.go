@@ -9,5 +9,32 @@ type HostResult struct {
 	Stderr   []byte
 	ExitCode int
 	Duration time.Duration
-	Err      error // connection/timeout errors
+	Err      error  // connection/timeout errors
+	Reused   bool   // true if a cached (warm) connection was used
+	Cached   bool   // true if served from the executor's result cache
+	RunID    string // correlation ID exported as HERD_RUN_ID; see WithRunID
+	Attempts int    // runs it took, more than 1 after retries; see WithRetry
+
+	// ConnectDuration and RunDuration split Duration into time spent
+	// getting a connection and running the command, when the Runner
+	// reports them. ConnectDuration is near zero on a reused connection.
+	ConnectDuration time.Duration
+	RunDuration     time.Duration
+}
+
+// ConnectionCounts reports how many results ran on a reused (warm)
+// connection and how many needed a fresh dial (cold). Results served from the
+// result cache used no connection and are not counted.
+func ConnectionCounts(results []*HostResult) (warm, cold int) {
+	for _, r := range results {
+		if r == nil || r.Cached {
+			continue
+		}
+		if r.Reused {
+			warm++
+		} else {
+			cold++
+		}
+	}
+	return warm, cold
 }
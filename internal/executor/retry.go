@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithRetry runs each host's command up to attempts times when connecting
+// to the host fails, waiting backoff before the first retry and doubling
+// the wait after each one. Only failures to connect are retried, never a
+// command that may have started: non-zero exit codes, errors after the
+// connection was made, timeouts and cancellation are not retried, nor is a
+// run that already produced output. Authentication and host key failures
+// are not retried either, since retrying them can lock accounts and cannot
+// succeed. Retries stop at the host's deadline. Values of attempts below 2
+// disable retries.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(e *Executor) {
+		e.attempts = attempts
+		e.backoff = max(backoff, 0)
+	}
+}
+
+// retry calls attempt until it succeeds, fails in a way not worth
+// retrying, or runs out of attempts, and returns the last result with its
+// attempt count. attempt also reports whether its run produced output,
+// which a retry would repeat. When retries are enabled, attempt's context
+// is marked so runners leave connect failures to the executor (see
+// RetriesConnectErrors).
+func (e *Executor) retry(ctx context.Context, attempt func(context.Context) (*HostResult, bool)) *HostResult {
+	attemptCtx := ctx
+	if e.attempts >= 2 {
+		attemptCtx = context.WithValue(ctx, retryingKey{}, true)
+	}
+	for n := 1; ; n++ {
+		result, output := attempt(attemptCtx)
+		result.Attempts = n
+		if n >= e.attempts || output || !retryable(ctx, result.Err) {
+			return result
+		}
+		select {
+		case <-time.After(e.backoff << (n - 1)):
+		case <-ctx.Done():
+			return result
+		}
+	}
+}
+
+// retryingKey marks the context of a run whose executor retries connect
+// failures.
+type retryingKey struct{}
+
+// RetriesConnectErrors reports whether ctx belongs to a run whose executor
+// retries failed connections itself (see WithRetry). A Runner that redials
+// on its own should then leave connect failures to the executor, so the two
+// retry loops don't multiply.
+func RetriesConnectErrors(ctx context.Context) bool {
+	retrying, _ := ctx.Value(retryingKey{}).(bool)
+	return retrying
+}
+
+// connectFailure is implemented by errors marking a failure to connect to a
+// host, before any command started, such as those from the ssh package's
+// WrapConnectError.
+type connectFailure interface {
+	ConnectFailed() bool
+}
+
+// failureKinder is implemented by errors that know their failure category,
+// such as the ssh package's DialError.
+type failureKinder interface {
+	FailureKind() string
+}
+
+// retryable reports whether err is a connect failure worth another attempt
+// under ctx: not an authentication or host key failure, and not a timeout
+// or cancellation of ctx.
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var cf connectFailure
+	if !errors.As(err, &cf) || !cf.ConnectFailed() {
+		return false
+	}
+	var fk failureKinder
+	if errors.As(err, &fk) {
+		switch fk.FailureKind() {
+		case "auth", "host-key":
+			return false
+		}
+	}
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrCancelled)
+}
@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyRunner fails each host's first failures runs with err, then succeeds
+// with an exit code of exit.
+type flakyRunner struct {
+	mu       sync.Mutex
+	runs     map[string]int
+	failures int
+	err      error
+	exit     int
+}
+
+func (f *flakyRunner) Run(ctx context.Context, host string, command string) *HostResult {
+	f.mu.Lock()
+	f.runs[host]++
+	n := f.runs[host]
+	f.mu.Unlock()
+	if n <= f.failures {
+		return &HostResult{Host: host, ExitCode: -1, Err: f.err}
+	}
+	return &HostResult{Host: host, ExitCode: f.exit}
+}
+
+func newFlakyRunner(failures int, err error) *flakyRunner {
+	return &flakyRunner{runs: make(map[string]int), failures: failures, err: err}
+}
+
+// dialErr is a connect failure, as marked by the ssh package, of the given
+// failure kind.
+type dialErr struct {
+	msg  string
+	kind string
+}
+
+func (e *dialErr) Error() string       { return e.msg }
+func (e *dialErr) ConnectFailed() bool { return true }
+func (e *dialErr) FailureKind() string { return e.kind }
+
+var errRefused = &dialErr{msg: "connection refused", kind: "refused"}
+
+func TestWithRetry(t *testing.T) {
+	runner := newFlakyRunner(2, errRefused)
+	e := New(runner, WithRetry(3, 10*time.Millisecond))
+
+	start := time.Now()
+	results := e.Execute(context.Background(), []string{"host-a"}, "uptime")
+	if r := results[0]; r.Err != nil || r.Attempts != 3 {
+		t.Errorf("err = %v, attempts = %d; want success after 3 attempts", r.Err, r.Attempts)
+	}
+	// Waits of 10ms and 20ms before the two retries.
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected backoff between attempts, finished in %v", elapsed)
+	}
+}
+
+func TestWithRetry_GivesUp(t *testing.T) {
+	runner := newFlakyRunner(5, errRefused)
+	e := New(runner, WithRetry(2, 0))
+
+	r := e.Execute(context.Background(), []string{"host-a"}, "uptime")[0]
+	if r.Err == nil || r.Attempts != 2 {
+		t.Errorf("err = %v, attempts = %d; want failure after 2 attempts", r.Err, r.Attempts)
+	}
+	if n := runner.runs["host-a"]; n != 2 {
+		t.Errorf("ran %d times, want 2", n)
+	}
+}
+
+func TestWithRetry_SkipsNonRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner *flakyRunner
+	}{
+		{"exit code", &flakyRunner{runs: make(map[string]int), exit: 1}},
+		{"canceled", newFlakyRunner(5, context.Canceled)},
+		{"cancelled host", newFlakyRunner(5, ErrCancelled)},
+		{"timeout", newFlakyRunner(5, context.DeadlineExceeded)},
+		{"auth", newFlakyRunner(5, &dialErr{msg: "authentication failed", kind: "auth"})},
+		{"host key", newFlakyRunner(5, &dialErr{msg: "host key verification failed", kind: "host-key"})},
+		{"after connect", newFlakyRunner(5, io.EOF)},
+		{"plain error", newFlakyRunner(5, errors.New("sudo: a password is required"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(tt.runner, WithRetry(3, 0))
+			r := e.Execute(context.Background(), []string{"host-a"}, "uptime")[0]
+			if r.Attempts != 1 || tt.runner.runs["host-a"] != 1 {
+				t.Errorf("attempts = %d, runs = %d; want 1", r.Attempts, tt.runner.runs["host-a"])
+			}
+		})
+	}
+}
+
+func TestWithRetry_RespectsDeadline(t *testing.T) {
+	runner := newFlakyRunner(5, errRefused)
+	e := New(runner, WithRetry(5, time.Second), WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	r := e.Execute(context.Background(), []string{"host-a"}, "uptime")[0]
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("retries outlived the host deadline: %v", elapsed)
+	}
+	if r.Err == nil || r.Attempts != 1 {
+		t.Errorf("err = %v, attempts = %d; want one failed attempt", r.Err, r.Attempts)
+	}
+}
+
+func TestWithRetry_NoRetryByDefault(t *testing.T) {
+	runner := newFlakyRunner(1, errRefused)
+	r := New(runner).Execute(context.Background(), []string{"host-a"}, "uptime")[0]
+	if r.Err == nil || r.Attempts != 1 {
+		t.Errorf("err = %v, attempts = %d; want one failed attempt", r.Err, r.Attempts)
+	}
+}
+
+func TestWithRetry_Stream(t *testing.T) {
+	runner := newFlakyRunner(1, errRefused)
+	e := New(runner, WithRetry(2, 0))
+
+	var final StreamChunk
+	for c := range e.ExecuteStream(context.Background(), []string{"host-a"}, "uptime") {
+		if c.Final {
+			final = c
+		}
+	}
+	if final.Err != nil || runner.runs["host-a"] != 2 {
+		t.Errorf("err = %v after %d runs; want success on the retry", final.Err, runner.runs["host-a"])
+	}
+}
+
+func TestWithRetry_MarksContext(t *testing.T) {
+	var marked []bool
+	runner := &mockRunner{handler: func(ctx context.Context, host string, command string) *HostResult {
+		marked = append(marked, RetriesConnectErrors(ctx))
+		return &HostResult{Host: host}
+	}}
+	New(runner).Execute(context.Background(), []string{"host-a"}, "uptime")
+	New(runner, WithRetry(2, 0)).Execute(context.Background(), []string{"host-a"}, "uptime")
+	if len(marked) != 2 || marked[0] || !marked[1] {
+		t.Errorf("RetriesConnectErrors = %v, want [false true]", marked)
+	}
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"context"
+	"sync"
+)
+
+// OutputSink receives each host's output as it arrives, so a program
+// embedding the executor can feed it to another tool in real time. Calls
+// for different hosts, and for the two streams of one host, may be
+// concurrent. data may be retained; it is not modified after the call.
+// See WithOutputSink.
+type OutputSink interface {
+	OnStdout(host string, data []byte)
+	OnStderr(host string, data []byte)
+}
+
+// WithOutputSink sends every host's output to sink while commands run, from
+// Execute, ExecuteNoCache and ExecuteStream alike. Results still carry the
+// full output. Output arrives piece by piece when the Runner implements
+// StreamRunner, and all at once when the host finishes otherwise; results
+// answered from the cache are delivered in full as well.
+func WithOutputSink(sink OutputSink) Option {
+	return func(e *Executor) {
+		e.sink = sink
+	}
+}
+
+// emit sends one piece of host's output to the sink, if there is one.
+func (e *Executor) emit(host string, stream int, data []byte) {
+	if e.sink == nil || len(data) == 0 {
+		return
+	}
+	if stream == Stderr {
+		e.sink.OnStderr(host, data)
+	} else {
+		e.sink.OnStdout(host, data)
+	}
+}
+
+// runToSink runs command on host like run, also sending its output to the
+// sink: as it arrives when the runner supports streaming, and once the host
+// finishes otherwise. Combined output is never streamed, since StreamRunner
+// keeps the two streams apart.
+func (e *Executor) runToSink(ctx context.Context, host, command string) *HostResult {
+	sr, ok := e.runner.(StreamRunner)
+	if e.sink == nil || !ok || e.combined {
+		result := e.run(ctx, host, command)
+		e.emit(host, Stdout, result.Stdout)
+		e.emit(host, Stderr, result.Stderr)
+		return result
+	}
+
+	var mu sync.Mutex
+	var stdout, stderr []byte
+	result := sr.RunStream(ctx, host, command, func(stream int, data []byte) {
+		e.emit(host, stream, data)
+		mu.Lock()
+		defer mu.Unlock()
+		if stream == Stderr {
+			stderr = append(stderr, data...)
+		} else {
+			stdout = append(stdout, data...)
+		}
+	})
+	mu.Lock()
+	result.Stdout, result.Stderr = stdout, stderr
+	mu.Unlock()
+	return result
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink collects the output it receives per host and stream.
+type recordingSink struct {
+	mu     sync.Mutex
+	stdout map[string]string
+	stderr map[string]string
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{stdout: make(map[string]string), stderr: make(map[string]string)}
+}
+
+func (s *recordingSink) OnStdout(host string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stdout[host] += string(data)
+}
+
+func (s *recordingSink) OnStderr(host string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stderr[host] += string(data)
+}
+
+func TestOutputSink_Streaming(t *testing.T) {
+	sink := newRecordingSink()
+	seen := make(chan string, 1)
+	runner := &mockStreamRunner{
+		stream: func(ctx context.Context, host string, emit func(int, []byte)) *HostResult {
+			emit(Stdout, []byte("line 1\n"))
+			// The sink sees output before the command finishes.
+			sink.mu.Lock()
+			seen <- sink.stdout[host]
+			sink.mu.Unlock()
+			emit(Stderr, []byte("warning\n"))
+			emit(Stdout, []byte("line 2\n"))
+			return &HostResult{ExitCode: 1}
+		},
+	}
+
+	results := New(runner, WithOutputSink(sink)).Execute(context.Background(), []string{"a"}, "cmd")
+
+	if got := <-seen; got != "line 1\n" {
+		t.Errorf("sink had %q mid-run, want the first line", got)
+	}
+	if sink.stdout["a"] != "line 1\nline 2\n" || sink.stderr["a"] != "warning\n" {
+		t.Errorf("sink got stdout %q, stderr %q", sink.stdout["a"], sink.stderr["a"])
+	}
+	r := results[0]
+	if string(r.Stdout) != "line 1\nline 2\n" || string(r.Stderr) != "warning\n" || r.ExitCode != 1 {
+		t.Errorf("result should still carry the full output, got %+v", r)
+	}
+}
+
+func TestOutputSink_NonStreamingRunner(t *testing.T) {
+	sink := newRecordingSink()
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Stdout: []byte("hello " + host + "\n"), Stderr: []byte("oops\n")}
+		},
+	}
+
+	New(runner, WithOutputSink(sink)).Execute(context.Background(), []string{"a", "b"}, "cmd")
+
+	for _, h := range []string{"a", "b"} {
+		if sink.stdout[h] != "hello "+h+"\n" || sink.stderr[h] != "oops\n" {
+			t.Errorf("%s: sink got stdout %q, stderr %q", h, sink.stdout[h], sink.stderr[h])
+		}
+	}
+}
+
+func TestOutputSink_CachedResults(t *testing.T) {
+	sink := newRecordingSink()
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Stdout: []byte("v1\n")}
+		},
+	}
+	e := New(runner, WithOutputSink(sink), WithResultCache(time.Minute))
+
+	e.Execute(context.Background(), []string{"a"}, "cmd")
+	e.Execute(context.Background(), []string{"a"}, "cmd")
+
+	if sink.stdout["a"] != "v1\nv1\n" {
+		t.Errorf("sink got %q, want the output of both runs", sink.stdout["a"])
+	}
+}
+
+func TestOutputSink_ExecuteStream(t *testing.T) {
+	sink := newRecordingSink()
+	runner := &mockStreamRunner{
+		stream: func(ctx context.Context, host string, emit func(int, []byte)) *HostResult {
+			emit(Stdout, []byte("out\n"))
+			emit(Stderr, []byte("err\n"))
+			return &HostResult{}
+		},
+	}
+
+	output, _ := collect(t, New(runner, WithOutputSink(sink)).ExecuteStream(context.Background(), []string{"a"}, "cmd"))
+
+	if len(output["a"]) != 2 {
+		t.Errorf("channel got %d chunks, want 2", len(output["a"]))
+	}
+	if sink.stdout["a"] != "out\n" || sink.stderr["a"] != "err\n" {
+		t.Errorf("sink got stdout %q, stderr %q", sink.stdout["a"], sink.stderr["a"])
+	}
+}
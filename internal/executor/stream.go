@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stream identifiers for StreamChunk.Stream.
+const (
+	Stdout = 1
+	Stderr = 2
+)
+
+// StreamChunk is a piece of output from one host, or that host's final
+// status. Output chunks carry Stream and Data; the last chunk for each host
+// has Final set and carries ExitCode, Err, and Duration instead.
+type StreamChunk struct {
+	Host   string
+	Stream int // Stdout or Stderr; zero on the final chunk
+	Data   []byte
+
+	Final    bool
+	ExitCode int
+	Err      error
+	Duration time.Duration
+}
+
+// StreamRunner is optionally implemented by Runners that can deliver output
+// while a command runs. RunStream calls emit with each piece of output as it
+// arrives, then returns the result without Stdout or Stderr. emit may be
+// called concurrently for the two streams, and may retain data. See
+// ExecuteStream.
+type StreamRunner interface {
+	RunStream(ctx context.Context, host string, command string, emit func(stream int, data []byte)) *HostResult
+}
+
+// ExecuteStream runs command on all hosts like ExecuteNoCache, but sends
+// output on the returned channel as it arrives instead of collecting it.
+// Each host ends with a Final chunk, and the channel is closed once every
+// host has finished. Chunks from different hosts interleave. The caller must
+// drain the channel; a slow reader holds up the commands producing output.
+// With a context from NewRun, CancelHost stops a single host early.
+// A Runner that does not implement StreamRunner has its output sent in one
+// chunk per stream when the host finishes.
+func (e *Executor) ExecuteStream(ctx context.Context, hosts []string, command string) <-chan StreamChunk {
+	out := make(chan StreamChunk, e.concurrency)
+	runID := e.newRunID()
+
+	go func() {
+		defer close(out)
+
+		progress := e.newProgress(len(hosts))
+		sem := e.semaphore()
+		var wg sync.WaitGroup
+
+		for _, host := range hosts {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				progress.finish(&HostResult{Host: host, ExitCode: -1, Err: ctx.Err()})
+				out <- StreamChunk{Host: host, Final: true, ExitCode: -1, Err: ctx.Err()}
+				continue
+			}
+
+			wg.Add(1)
+			go func(h string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				hostCtx, done := e.hostContext(ctx, h)
+				defer done()
+
+				start := time.Now()
+				result := e.retry(hostCtx, func(attemptCtx context.Context) (*HostResult, bool) {
+					return e.runStream(attemptCtx, h, e.remoteCommand(h, command, runID), out)
+				})
+				result.Duration = time.Since(start)
+				result.Host = h
+				recordContextErr(hostCtx, result)
+				if e.latency != nil {
+					e.latency.record(result)
+				}
+				progress.finish(result)
+
+				out <- StreamChunk{
+					Host:     h,
+					Final:    true,
+					ExitCode: result.ExitCode,
+					Err:      result.Err,
+					Duration: result.Duration,
+				}
+			}(host)
+		}
+
+		wg.Wait()
+	}()
+
+	return out
+}
+
+// runStream runs command on host, sending its output to out as it arrives
+// when the runner supports streaming, and all at once when it does not. It
+// reports whether any output was sent.
+func (e *Executor) runStream(ctx context.Context, host, command string, out chan<- StreamChunk) (*HostResult, bool) {
+	if sr, ok := e.runner.(StreamRunner); ok {
+		var sent atomic.Bool
+		result := sr.RunStream(ctx, host, command, func(stream int, data []byte) {
+			sent.Store(true)
+			e.emit(host, stream, data)
+			out <- StreamChunk{Host: host, Stream: stream, Data: data}
+		})
+		return result, sent.Load()
+	}
+
+	result := e.run(ctx, host, command)
+	if len(result.Stdout) > 0 {
+		e.emit(host, Stdout, result.Stdout)
+		out <- StreamChunk{Host: host, Stream: Stdout, Data: result.Stdout}
+	}
+	if len(result.Stderr) > 0 {
+		e.emit(host, Stderr, result.Stderr)
+		out <- StreamChunk{Host: host, Stream: Stderr, Data: result.Stderr}
+	}
+	return result, len(result.Stdout) > 0 || len(result.Stderr) > 0
+}
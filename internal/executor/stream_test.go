@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mockStreamRunner emits output through RunStream.
+type mockStreamRunner struct {
+	mockRunner
+	stream func(ctx context.Context, host string, emit func(int, []byte)) *HostResult
+}
+
+func (m *mockStreamRunner) RunStream(ctx context.Context, host string, command string, emit func(int, []byte)) *HostResult {
+	return m.stream(ctx, host, emit)
+}
+
+// collect drains ch, returning each host's output chunks and final chunk.
+func collect(t *testing.T, ch <-chan StreamChunk) (map[string][]StreamChunk, map[string]StreamChunk) {
+	t.Helper()
+	output := make(map[string][]StreamChunk)
+	final := make(map[string]StreamChunk)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				return output, final
+			}
+			if _, done := final[c.Host]; done {
+				t.Errorf("%s: chunk after final chunk: %+v", c.Host, c)
+			}
+			if c.Final {
+				final[c.Host] = c
+			} else {
+				output[c.Host] = append(output[c.Host], c)
+			}
+		case <-timeout:
+			t.Fatal("stream not closed")
+		}
+	}
+}
+
+func TestExecuteStream(t *testing.T) {
+	runner := &mockStreamRunner{
+		stream: func(ctx context.Context, host string, emit func(int, []byte)) *HostResult {
+			emit(Stdout, []byte("line 1\n"))
+			emit(Stderr, []byte("warning\n"))
+			emit(Stdout, []byte("line 2\n"))
+			code := 0
+			if host == "b" {
+				code = 3
+			}
+			return &HostResult{ExitCode: code}
+		},
+	}
+
+	output, final := collect(t, New(runner).ExecuteStream(context.Background(), []string{"a", "b"}, "cmd"))
+
+	for _, h := range []string{"a", "b"} {
+		chunks := output[h]
+		if len(chunks) != 3 {
+			t.Fatalf("%s: got %d chunks, want 3", h, len(chunks))
+		}
+		if chunks[0].Stream != Stdout || string(chunks[0].Data) != "line 1\n" ||
+			chunks[1].Stream != Stderr || string(chunks[1].Data) != "warning\n" ||
+			chunks[2].Stream != Stdout || string(chunks[2].Data) != "line 2\n" {
+			t.Errorf("%s: chunks out of order: %+v", h, chunks)
+		}
+		if _, ok := final[h]; !ok {
+			t.Errorf("%s: no final chunk", h)
+		}
+	}
+	if final["a"].ExitCode != 0 || final["b"].ExitCode != 3 {
+		t.Errorf("exit codes = %d, %d; want 0, 3", final["a"].ExitCode, final["b"].ExitCode)
+	}
+	if final["a"].Duration == 0 {
+		t.Error("final chunk should carry the duration")
+	}
+}
+
+func TestExecuteStream_PlainRunner(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Stdout: []byte("out\n"), Stderr: []byte("err\n"), ExitCode: 1}
+		},
+	}
+
+	output, final := collect(t, New(runner).ExecuteStream(context.Background(), []string{"a"}, "cmd"))
+
+	chunks := output["a"]
+	if len(chunks) != 2 || string(chunks[0].Data) != "out\n" || chunks[0].Stream != Stdout ||
+		string(chunks[1].Data) != "err\n" || chunks[1].Stream != Stderr {
+		t.Errorf("chunks = %+v, want stdout then stderr", chunks)
+	}
+	if final["a"].ExitCode != 1 {
+		t.Errorf("exit code = %d, want 1", final["a"].ExitCode)
+	}
+}
+
+func TestExecuteStream_Timeout(t *testing.T) {
+	runner := &mockStreamRunner{
+		stream: func(ctx context.Context, host string, emit func(int, []byte)) *HostResult {
+			emit(Stdout, []byte("partial"))
+			<-ctx.Done()
+			return &HostResult{ExitCode: -1}
+		},
+	}
+
+	output, final := collect(t, New(runner, WithTimeout(20*time.Millisecond)).ExecuteStream(context.Background(), []string{"a"}, "cmd"))
+
+	if len(output["a"]) != 1 {
+		t.Errorf("got %d chunks, want the partial output", len(output["a"]))
+	}
+	if !errors.Is(final["a"].Err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", final["a"].Err)
+	}
+}
+
+func TestExecuteStream_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Err: ctx.Err()}
+		},
+	}
+
+	_, final := collect(t, New(runner, WithConcurrency(1)).ExecuteStream(ctx, []string{"a", "b", "c"}, "cmd"))
+
+	if len(final) != 3 {
+		t.Fatalf("got %d final chunks, want 3", len(final))
+	}
+	for h, c := range final {
+		if !errors.Is(c.Err, context.Canceled) {
+			t.Errorf("%s: err = %v, want canceled", h, c.Err)
+		}
+	}
+}
+
+func TestExecuteStream_NoHosts(t *testing.T) {
+	runner := &mockRunner{handler: func(context.Context, string, string) *HostResult { return &HostResult{} }}
+	output, final := collect(t, New(runner).ExecuteStream(context.Background(), nil, "cmd"))
+	if len(output) != 0 || len(final) != 0 {
+		t.Errorf("expected no chunks, got %v %v", output, final)
+	}
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// waitPollInterval is how long ExecuteAndWaitFor waits between runs of the
+// log command on a host.
+var waitPollInterval = time.Second
+
+// WaitResult is the outcome of ExecuteAndWaitFor on one host.
+type WaitResult struct {
+	Host    string
+	Action  *HostResult   // result of the action command
+	Log     *HostResult   // last run of the log command; nil if it never ran
+	Matched bool          // the pattern appeared before the timeout
+	Line    string        // first line of the log command's output matching the pattern
+	Waited  time.Duration // time from the end of the action to the match, or to giving up
+}
+
+// ExecuteAndWaitFor runs command on hosts, then on each host where it
+// succeeded runs logCmd repeatedly until a line of its stdout matches
+// pattern or timeout has passed since the action finished, e.g. to restart
+// a service and confirm it logged that it is ready. The action always runs,
+// bypassing the result cache. Hosts where the action fails are not polled.
+// Each run of logCmd counts against the concurrency limit and is bounded by
+// the host's timeout; a failing run is retried at the next poll. Results are
+// returned in the same order as hosts.
+func (e *Executor) ExecuteAndWaitFor(ctx context.Context, hosts []string, command, logCmd string, pattern *regexp.Regexp, timeout time.Duration) []*WaitResult {
+	actions := e.ExecuteNoCache(ctx, hosts, command)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	runID := e.newRunID()
+	sem := e.semaphore()
+	results := make([]*WaitResult, len(hosts))
+	var wg sync.WaitGroup
+	for i, action := range actions {
+		results[i] = &WaitResult{Host: hosts[i], Action: action}
+		if action.Err != nil || action.ExitCode != 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(w *WaitResult) {
+			defer wg.Done()
+			e.waitFor(waitCtx, w, e.remoteCommand(w.Host, logCmd, runID), pattern, sem)
+		}(results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// waitFor polls logCmd on w.Host until its output matches pattern or ctx
+// ends, recording the outcome in w.
+func (e *Executor) waitFor(ctx context.Context, w *WaitResult, logCmd string, pattern *regexp.Regexp, sem chan struct{}) {
+	start := time.Now()
+	defer func() { w.Waited = time.Since(start) }()
+
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		hostCtx, done := e.hostContext(ctx, w.Host)
+		result := e.run(hostCtx, w.Host, logCmd)
+		recordContextErr(hostCtx, result)
+		done()
+		<-sem
+
+		result.Host = w.Host
+		w.Log = result
+		if line, ok := matchLine(result.Stdout, pattern); ok {
+			w.Matched, w.Line = true, line
+			return
+		}
+
+		select {
+		case <-time.After(waitPollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// matchLine returns the first line of out that matches pattern.
+func matchLine(out []byte, pattern *regexp.Regexp) (string, bool) {
+	for _, line := range strings.Split(string(out), "\n") {
+		if pattern.MatchString(line) {
+			return line, true
+		}
+	}
+	return "", false
+}
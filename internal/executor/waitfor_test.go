@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteAndWaitFor(t *testing.T) {
+	orig := waitPollInterval
+	waitPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { waitPollInterval = orig })
+
+	// host-a logs the marker on its third poll, host-b never does, and the
+	// action fails on host-c.
+	var mu sync.Mutex
+	polls := make(map[string]int)
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			if command == "systemctl restart app" {
+				if host == "host-c" {
+					return &HostResult{Host: host, ExitCode: 1}
+				}
+				return &HostResult{Host: host}
+			}
+			mu.Lock()
+			polls[host]++
+			n := polls[host]
+			mu.Unlock()
+			out := "starting\n"
+			if host == "host-a" && n >= 3 {
+				out += "app ready on :8080\n"
+			}
+			return &HostResult{Host: host, Stdout: []byte(out)}
+		},
+	}
+
+	e := New(runner)
+	results := e.ExecuteAndWaitFor(context.Background(), []string{"host-a", "host-b", "host-c"},
+		"systemctl restart app", "journalctl -u app -n 20", regexp.MustCompile(`ready on`), 100*time.Millisecond)
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	a, b, c := results[0], results[1], results[2]
+	if !a.Matched || a.Line != "app ready on :8080" || polls["host-a"] != 3 {
+		t.Errorf("host-a: matched %v, line %q after %d polls; want a match on the third poll", a.Matched, a.Line, polls["host-a"])
+	}
+	if b.Matched || b.Log == nil || polls["host-b"] < 2 {
+		t.Errorf("host-b: matched %v after %d polls; want repeated polls without a match", b.Matched, polls["host-b"])
+	}
+	if b.Waited < 100*time.Millisecond {
+		t.Errorf("host-b gave up after %v, want the full timeout", b.Waited)
+	}
+	if c.Matched || c.Log != nil || c.Action.ExitCode != 1 {
+		t.Errorf("host-c: matched %v, log %v; want no polling after the failed action", c.Matched, c.Log)
+	}
+}
+
+func TestExecuteAndWaitFor_ContextCancelled(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *HostResult {
+			return &HostResult{Host: host}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	results := New(runner).ExecuteAndWaitFor(ctx, []string{"host-a"}, "restart", "tail log", regexp.MustCompile("never"), time.Minute)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waiting outlived the context: %v", elapsed)
+	}
+	if results[0].Matched {
+		t.Error("expected no match")
+	}
+}
@@ -0,0 +1,39 @@
+package grouper
+
+import (
+	"bytes"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+// StripANSI returns results with ANSI escape sequences (colors, cursor
+// movement) removed from stdout and stderr. Results without any escape
+// sequences are returned as-is; the others are copied, so the originals keep
+// their raw output.
+func StripANSI(results []*executor.HostResult) []*executor.HostResult {
+	out := make([]*executor.HostResult, len(results))
+	for i, r := range results {
+		if r == nil || (!hasEscape(r.Stdout) && !hasEscape(r.Stderr)) {
+			out[i] = r
+			continue
+		}
+		c := *r
+		c.Stdout = stripBytes(r.Stdout)
+		c.Stderr = stripBytes(r.Stderr)
+		out[i] = &c
+	}
+	return out
+}
+
+func hasEscape(b []byte) bool {
+	return bytes.IndexByte(b, 0x1b) >= 0 || bytes.IndexByte(b, 0x9b) >= 0
+}
+
+func stripBytes(b []byte) []byte {
+	if !hasEscape(b) {
+		return b
+	}
+	return []byte(ansi.Strip(string(b)))
+}
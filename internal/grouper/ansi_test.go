@@ -0,0 +1,53 @@
+package grouper
+
+import (
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+func TestStripANSI(t *testing.T) {
+	plain := &executor.HostResult{Host: "a", Stdout: []byte("ok\n")}
+	colored := &executor.HostResult{
+		Host:   "b",
+		Stdout: []byte("\x1b[32mok\x1b[0m\n"),
+		Stderr: []byte("\x1b[2K\x1b[1Gwarn\n"),
+	}
+
+	out := StripANSI([]*executor.HostResult{plain, colored, nil})
+
+	if out[0] != plain {
+		t.Error("expected result without escape codes to be returned as-is")
+	}
+	if out[1] == colored {
+		t.Fatal("expected colored result to be copied")
+	}
+	if got := string(out[1].Stdout); got != "ok\n" {
+		t.Errorf("stdout = %q, want %q", got, "ok\n")
+	}
+	if got := string(out[1].Stderr); got != "warn\n" {
+		t.Errorf("stderr = %q, want %q", got, "warn\n")
+	}
+	if string(colored.Stdout) != "\x1b[32mok\x1b[0m\n" {
+		t.Error("original result should keep its raw output")
+	}
+	if out[2] != nil {
+		t.Error("expected nil result to stay nil")
+	}
+}
+
+func TestGroupIgnoresColorCodes(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("active\n")},
+		{Host: "b", Stdout: []byte("\x1b[32mactive\x1b[0m\n")},
+		{Host: "c", Stdout: []byte("\x1b[1;32mactive\x1b[m\n")},
+	}
+
+	gr := Group(results)
+	if len(gr.Groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(gr.Groups))
+	}
+	if got := string(gr.Groups[0].Stdout); got != "active\n" {
+		t.Errorf("group stdout = %q, want stripped output", got)
+	}
+}
@@ -0,0 +1,64 @@
+package grouper
+
+import (
+	"errors"
+	"net"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+// FailClass categorizes a connection failure.
+type FailClass string
+
+// Failure classes, as reported by ClassifyFailure.
+const (
+	FailAuth        FailClass = "auth"
+	FailRefused     FailClass = "refused"
+	FailUnreachable FailClass = "unreachable"
+	FailHostKey     FailClass = "host-key"
+	FailOther       FailClass = "other"
+)
+
+// FailClasses lists every failure class in display order.
+var FailClasses = []FailClass{FailAuth, FailHostKey, FailRefused, FailUnreachable, FailOther}
+
+// failureKinder is implemented by errors that know their failure category,
+// such as the ssh package's DialError.
+type failureKinder interface {
+	FailureKind() string
+}
+
+// ClassifyFailure returns the FailClass of a connection error. Errors that
+// carry their own category are trusted; otherwise DNS and other network
+// errors are classed as FailUnreachable, and anything else as FailOther.
+func ClassifyFailure(err error) FailClass {
+	var fk failureKinder
+	if errors.As(err, &fk) {
+		switch k := FailClass(fk.FailureKind()); k {
+		case FailAuth, FailHostKey, FailRefused, FailUnreachable:
+			return k
+		case "timeout":
+			return FailUnreachable
+		}
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return FailUnreachable
+	}
+	return FailOther
+}
+
+// ClassifyFailed buckets failed results by ClassifyFailure. Hosts keep their
+// input order within a class, and classes with no hosts are absent.
+func ClassifyFailed(failed []*executor.HostResult) map[FailClass][]*executor.HostResult {
+	if len(failed) == 0 {
+		return nil
+	}
+	byClass := make(map[FailClass][]*executor.HostResult)
+	for _, r := range failed {
+		c := ClassifyFailure(r.Err)
+		byClass[c] = append(byClass[c], r)
+	}
+	return byClass
+}
@@ -0,0 +1,79 @@
+package grouper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+type kindErr string
+
+func (e kindErr) Error() string       { return string(e) }
+func (e kindErr) FailureKind() string { return string(e) }
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want FailClass
+	}{
+		{"auth", fmt.Errorf("connect: %w", kindErr("auth")), FailAuth},
+		{"host key", kindErr("host-key"), FailHostKey},
+		{"refused", kindErr("refused"), FailRefused},
+		{"dial timeout", kindErr("timeout"), FailUnreachable},
+		{"dns", &net.DNSError{Err: "no such host", Name: "x"}, FailUnreachable},
+		{"net op", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, FailUnreachable},
+		{"other", errors.New("boom"), FailOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyFailure(tt.err); got != tt.want {
+				t.Errorf("ClassifyFailure = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_FailedByClass(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Err: errors.New("boom")},
+		{Host: "b", Err: kindErr("refused")},
+		{Host: "c", Err: kindErr("auth")},
+		{Host: "d", Err: kindErr("refused")},
+		{Host: "e", Stdout: []byte("ok\n")},
+	}
+	gr := Group(results)
+	if len(gr.Failed) != 4 {
+		t.Fatalf("Failed = %d, want 4", len(gr.Failed))
+	}
+	want := map[FailClass][]string{
+		FailAuth:    {"c"},
+		FailRefused: {"b", "d"},
+		FailOther:   {"a"},
+	}
+	if len(gr.FailedByClass) != len(want) {
+		t.Fatalf("got %d classes, want %d", len(gr.FailedByClass), len(want))
+	}
+	for class, hosts := range want {
+		got := gr.FailedByClass[class]
+		if len(got) != len(hosts) {
+			t.Errorf("%s: got %d hosts, want %d", class, len(got), len(hosts))
+			continue
+		}
+		for i, h := range hosts {
+			if got[i].Host != h {
+				t.Errorf("%s host %d = %q, want %q", class, i, got[i].Host, h)
+			}
+		}
+	}
+}
+
+func TestGroup_DialTimeoutIsTimedOut(t *testing.T) {
+	gr := Group([]*executor.HostResult{{Host: "a", Err: kindErr("timeout")}})
+	if len(gr.TimedOut) != 1 || len(gr.Failed) != 0 {
+		t.Errorf("TimedOut=%d Failed=%d, want 1/0", len(gr.TimedOut), len(gr.Failed))
+	}
+}
@@ -0,0 +1,39 @@
+package grouper
+
+import "github.com/agent462/herd/internal/executor"
+
+// Flat is like Group but does not group at all: every host that completed
+// gets a group of its own, in input order, and none is the norm. No hashing
+// or diffing is done, which suits commands whose output is expected to
+// differ on every host, such as hostname. ANSI stripping, StderrAsOutput,
+// whitespace normalization and ExitMap apply as in Group; Masks and the diff
+// settings have no effect.
+func (o Options) Flat(results []*executor.HostResult) *GroupedResults {
+	gr := &GroupedResults{hostResults: results, opts: o, flat: true}
+	for _, r := range StripANSI(results) {
+		gr.Elapsed = max(gr.Elapsed, r.Duration)
+		if o.StderrAsOutputWhenEmpty {
+			r = stderrAsOutput(r)
+		}
+		if o.TrimWhitespace || o.CollapseSpaces {
+			r = o.normalizeWhitespace(r)
+		}
+		if r.Err != nil {
+			if isTimeout(r.Err) {
+				gr.TimedOut = append(gr.TimedOut, r)
+			} else {
+				gr.Failed = append(gr.Failed, r)
+			}
+			continue
+		}
+		gr.Groups = append(gr.Groups, OutputGroup{
+			Hosts:    []string{r.Host},
+			Stdout:   r.Stdout,
+			Stderr:   r.Stderr,
+			ExitCode: r.ExitCode,
+			Status:   exitStatus(o.ExitMap, r.ExitCode),
+		})
+	}
+	gr.FailedByClass = ClassifyFailed(gr.Failed)
+	return gr
+}
@@ -0,0 +1,47 @@
+package grouper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+func TestFlat(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "web-02", Stdout: []byte("web-02\n"), Duration: time.Second},
+		{Host: "web-01", Stdout: []byte("\x1b[1mweb-01\x1b[0m\n")},
+		{Host: "web-03", Stdout: []byte("web-02\n"), ExitCode: 1},
+		{Host: "web-04", ExitCode: -1, Err: errors.New("connection refused")},
+		{Host: "web-05", ExitCode: -1, Err: context.DeadlineExceeded},
+	}
+
+	gr := Options{ExitMap: map[int]ExitStatus{1: StatusWarn}}.Flat(results)
+
+	if len(gr.Groups) != 3 {
+		t.Fatalf("got %d groups, want one per completed host", len(gr.Groups))
+	}
+	for i, want := range []string{"web-02", "web-01", "web-03"} {
+		g := gr.Groups[i]
+		if len(g.Hosts) != 1 || g.Hosts[0] != want {
+			t.Errorf("group %d hosts = %v, want [%s] in input order", i, g.Hosts, want)
+		}
+		if g.IsNorm || g.Diff != "" {
+			t.Errorf("group %d should have no norm or diff: %+v", i, g)
+		}
+	}
+	if string(gr.Groups[1].Stdout) != "web-01\n" {
+		t.Errorf("ANSI should be stripped, got %q", gr.Groups[1].Stdout)
+	}
+	if gr.Groups[2].Status != StatusWarn {
+		t.Errorf("exit 1 status = %v, want warn from ExitMap", gr.Groups[2].Status)
+	}
+	if len(gr.Failed) != 1 || len(gr.TimedOut) != 1 || len(gr.FailedByClass) == 0 {
+		t.Errorf("failed = %v, timed out = %v", gr.Failed, gr.TimedOut)
+	}
+	if gr.Elapsed != time.Second {
+		t.Errorf("elapsed = %v, want 1s", gr.Elapsed)
+	}
+}
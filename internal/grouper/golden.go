@@ -0,0 +1,96 @@
+package grouper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+// GoldenResult holds the outcome of comparing one host's output against its
+// golden file.
+type GoldenResult struct {
+	Host   string
+	Stdout []byte
+	Golden []byte
+	Diff   string // unified diff golden vs actual; empty when the host passes
+}
+
+// GoldenReport holds per-host pass/fail results of a golden-file comparison.
+// Unlike GroupedResults there is no norm: each host is checked against its
+// own externally defined baseline.
+type GoldenReport struct {
+	Passed   []GoldenResult
+	Mismatch []GoldenResult
+	Missing  []string // hosts with no golden file
+	Failed   []*executor.HostResult
+	TimedOut []*executor.HostResult
+}
+
+// OK reports whether every host matched its golden file.
+func (r *GoldenReport) OK() bool {
+	return len(r.Mismatch) == 0 && len(r.Missing) == 0 && len(r.Failed) == 0 && len(r.TimedOut) == 0
+}
+
+// LoadGolden reads dir/<host>.txt for each host. Hosts without a golden file
+// are omitted from the returned map; any other read error is returned.
+func LoadGolden(dir string, hosts []string) (map[string][]byte, error) {
+	golden := make(map[string][]byte, len(hosts))
+	for _, h := range hosts {
+		if h != filepath.Base(h) {
+			return nil, fmt.Errorf("host %q cannot be used as a golden file name", h)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, h+".txt"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("read golden file for %s: %w", h, err)
+		}
+		golden[h] = data
+	}
+	return golden, nil
+}
+
+// CompareGolden checks each host's stdout against its entry in golden.
+// Connection errors and timeouts are reported separately, as in Group, and
+// hosts with no golden entry are listed as Missing. Within each category
+// hosts are sorted by name.
+func CompareGolden(results []*executor.HostResult, golden map[string][]byte) *GoldenReport {
+	report := &GoldenReport{}
+
+	for _, r := range results {
+		if r.Err != nil {
+			if isTimeout(r.Err) {
+				report.TimedOut = append(report.TimedOut, r)
+			} else {
+				report.Failed = append(report.Failed, r)
+			}
+			continue
+		}
+
+		want, ok := golden[r.Host]
+		if !ok {
+			report.Missing = append(report.Missing, r.Host)
+			continue
+		}
+
+		gr := GoldenResult{Host: r.Host, Stdout: r.Stdout, Golden: want}
+		if bytes.Equal(r.Stdout, want) {
+			report.Passed = append(report.Passed, gr)
+			continue
+		}
+		gr.Diff = LabeledDiff(string(want), string(r.Stdout), "golden", r.Host)
+		report.Mismatch = append(report.Mismatch, gr)
+	}
+
+	sort.Slice(report.Passed, func(i, j int) bool { return report.Passed[i].Host < report.Passed[j].Host })
+	sort.Slice(report.Mismatch, func(i, j int) bool { return report.Mismatch[i].Host < report.Mismatch[j].Host })
+	sort.Strings(report.Missing)
+
+	return report
+}
@@ -0,0 +1,93 @@
+package grouper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+func TestLoadGolden(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "web-01.txt"), []byte("ok\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := LoadGolden(dir, []string{"web-01", "web-02"})
+	if err != nil {
+		t.Fatalf("LoadGolden: %v", err)
+	}
+	if got := string(golden["web-01"]); got != "ok\n" {
+		t.Errorf("web-01 golden = %q, want %q", got, "ok\n")
+	}
+	if _, ok := golden["web-02"]; ok {
+		t.Error("web-02 should be absent when its golden file does not exist")
+	}
+}
+
+func TestLoadGoldenRejectsPathHosts(t *testing.T) {
+	if _, err := LoadGolden(t.TempDir(), []string{"../etc/passwd"}); err == nil {
+		t.Fatal("expected error for host containing a path separator")
+	}
+}
+
+func TestCompareGolden(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "web-02", Stdout: []byte("v1\n")},
+		{Host: "web-01", Stdout: []byte("v1\n")},
+		{Host: "web-03", Stdout: []byte("v2\n")},
+		{Host: "web-04", Stdout: []byte("v1\n")},
+		{Host: "web-05", Err: errors.New("connection refused")},
+		{Host: "web-06", Err: context.DeadlineExceeded},
+	}
+	golden := map[string][]byte{
+		"web-01": []byte("v1\n"),
+		"web-02": []byte("v1\n"),
+		"web-03": []byte("v1\n"),
+		"web-05": []byte("v1\n"),
+	}
+
+	report := CompareGolden(results, golden)
+
+	if len(report.Passed) != 2 || report.Passed[0].Host != "web-01" || report.Passed[1].Host != "web-02" {
+		t.Errorf("Passed = %+v, want web-01, web-02", report.Passed)
+	}
+	if len(report.Mismatch) != 1 || report.Mismatch[0].Host != "web-03" {
+		t.Fatalf("Mismatch = %+v, want web-03", report.Mismatch)
+	}
+	diff := report.Mismatch[0].Diff
+	for _, want := range []string{"--- golden", "+++ web-03", "-v1", "+v2"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "web-04" {
+		t.Errorf("Missing = %v, want [web-04]", report.Missing)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Host != "web-05" {
+		t.Errorf("Failed = %+v, want web-05", report.Failed)
+	}
+	if len(report.TimedOut) != 1 || report.TimedOut[0].Host != "web-06" {
+		t.Errorf("TimedOut = %+v, want web-06", report.TimedOut)
+	}
+	if report.OK() {
+		t.Error("OK() should be false with mismatches")
+	}
+}
+
+func TestCompareGoldenAllPass(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("same\n")},
+		{Host: "b", Stdout: []byte("same\n")},
+	}
+	golden := map[string][]byte{"a": []byte("same\n"), "b": []byte("same\n")}
+
+	report := CompareGolden(results, golden)
+	if !report.OK() {
+		t.Errorf("expected OK report, got %+v", report)
+	}
+}
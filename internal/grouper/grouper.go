@@ -1,13 +1,16 @@
 package grouper
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/agent462/herd/internal/executor"
 )
@@ -18,8 +21,9 @@ type OutputGroup struct {
 	Stdout   []byte
 	Stderr   []byte
 	ExitCode int
-	IsNorm   bool   // true if this is the largest (majority) group
-	Diff     string // unified diff vs the norm group; empty for the norm itself
+	Status   ExitStatus // meaning of ExitCode; see Options.ExitMap and Failed
+	IsNorm   bool       // true if this is the largest (majority) group
+	Diff     string     // unified diff vs the norm group; empty for the norm itself
 }
 
 // GroupedResults holds the categorized results of a parallel command execution.
@@ -27,25 +31,86 @@ type GroupedResults struct {
 	Groups   []OutputGroup
 	Failed   []*executor.HostResult
 	TimedOut []*executor.HostResult
+
+	// FailedByClass splits Failed by failure class; Failed remains the
+	// flat union in input order.
+	FailedByClass map[FailClass][]*executor.HostResult
+
+	// Elapsed is the duration of the slowest host, i.e. roughly how long
+	// the parallel run took.
+	Elapsed time.Duration
+
+	// hostResults, opts and flat record what was grouped and how, so
+	// Filter and Merge can regroup a subset the same way.
+	hostResults []*executor.HostResult
+	opts        Options
+	flat        bool
 }
 
 // Group categorizes host results by identical output and exit code, identifies
 // the majority group as the "norm", and computes unified diffs for outliers.
 // Both zero and non-zero exit code results are grouped together so that (e.g.)
 // 20 hosts returning exit code 3 with the same output appear as a single group
-// rather than 20 individual entries.
+// rather than 20 individual entries. ANSI escape sequences are stripped first
+// (see StripANSI), so hosts whose output differs only in terminal colors
+// group together and the grouped layout is not corrupted.
 func Group(results []*executor.HostResult) *GroupedResults {
-	gr := &GroupedResults{}
+	return Options{}.Group(results)
+}
+
+// OutputKey is the key function used by Group: stdout, stderr and exit code.
+// The exit code is included so that hosts with the same output but different
+// exit codes land in separate groups.
+func OutputKey(r *executor.HostResult) []byte {
+	var key []byte
+	key = append(key, r.Stdout...)
+	key = append(key, 0) // NUL separator prevents collisions
+	key = append(key, r.Stderr...)
+	key = append(key, 0)
+	key = append(key, byte(r.ExitCode>>24), byte(r.ExitCode>>16), byte(r.ExitCode>>8), byte(r.ExitCode))
+	return key
+}
+
+// GroupBy is like Group but buckets hosts by the key keyFn derives from each
+// completed result (e.g. a single parsed field) instead of their full output.
+// Each group keeps the full output of its first host for display, and diffs
+// are computed between those outputs.
+func GroupBy(results []*executor.HostResult, keyFn func(*executor.HostResult) []byte) *GroupedResults {
+	return Options{}.GroupBy(results, keyFn)
+}
+
+// GroupWithOptions is like Group but applies opts, e.g. to trim whitespace
+// before grouping. It is the same as opts.Group(results).
+func GroupWithOptions(results []*executor.HostResult, opts Options) *GroupedResults {
+	return opts.Group(results)
+}
+
+// Group is like the package-level Group but computes diffs with o.
+func (o Options) Group(results []*executor.HostResult) *GroupedResults {
+	return o.GroupBy(StripANSI(results), OutputKey)
+}
+
+// GroupBy is like the package-level GroupBy but computes diffs with o.
+func (o Options) GroupBy(results []*executor.HostResult, keyFn func(*executor.HostResult) []byte) *GroupedResults {
+	gr := &GroupedResults{hostResults: results, opts: o}
 
 	// Separate errors from completed results.
 	type hashEntry struct {
 		hash   string
 		result *executor.HostResult
+		masked []byte // stdout with Masks applied, for diffs
 	}
 
 	var completed []hashEntry
 
 	for _, r := range results {
+		gr.Elapsed = max(gr.Elapsed, r.Duration)
+		if o.StderrAsOutputWhenEmpty {
+			r = stderrAsOutput(r)
+		}
+		if o.TrimWhitespace || o.CollapseSpaces {
+			r = o.normalizeWhitespace(r)
+		}
 		if r.Err != nil {
 			if isTimeout(r.Err) {
 				gr.TimedOut = append(gr.TimedOut, r)
@@ -55,20 +120,18 @@ func Group(results []*executor.HostResult) *GroupedResults {
 			continue
 		}
 
-		// Include exit code in the hash so that hosts with the same output
-		// but different exit codes land in separate groups.
-		var hashBuf []byte
-		hashBuf = append(hashBuf, r.Stdout...)
-		hashBuf = append(hashBuf, 0) // NUL separator prevents collisions
-		hashBuf = append(hashBuf, r.Stderr...)
-		hashBuf = append(hashBuf, 0)
-		hashBuf = append(hashBuf, byte(r.ExitCode>>24), byte(r.ExitCode>>16), byte(r.ExitCode>>8), byte(r.ExitCode))
-		h := sha256.Sum256(hashBuf)
+		keyed := r
+		if len(o.Masks) > 0 {
+			keyed = o.mask(r)
+		}
+		h := sha256.Sum256(keyFn(keyed))
 		completed = append(completed, hashEntry{
 			hash:   fmt.Sprintf("%x", h),
 			result: r,
+			masked: keyed.Stdout,
 		})
 	}
+	gr.FailedByClass = ClassifyFailed(gr.Failed)
 
 	if len(completed) == 0 {
 		return gr
@@ -79,6 +142,7 @@ func Group(results []*executor.HostResult) *GroupedResults {
 		hosts    []string
 		stdout   []byte
 		stderr   []byte
+		masked   []byte
 		exitCode int
 	}
 	groups := make(map[string]*groupData)
@@ -91,6 +155,7 @@ func Group(results []*executor.HostResult) *GroupedResults {
 			g = &groupData{
 				stdout:   entry.result.Stdout,
 				stderr:   entry.result.Stderr,
+				masked:   entry.masked,
 				exitCode: entry.result.ExitCode,
 			}
 			groups[entry.hash] = g
@@ -109,7 +174,7 @@ func Group(results []*executor.HostResult) *GroupedResults {
 		}
 	}
 
-	normStdout := string(groups[normHash].stdout)
+	normStdout := string(groups[normHash].masked)
 
 	// Build output groups. Norm group first, then outliers in insertion order.
 	normGroup := groups[normHash]
@@ -119,6 +184,7 @@ func Group(results []*executor.HostResult) *GroupedResults {
 		Stdout:   normGroup.stdout,
 		Stderr:   normGroup.stderr,
 		ExitCode: normGroup.exitCode,
+		Status:   exitStatus(o.ExitMap, normGroup.exitCode),
 		IsNorm:   true,
 	})
 
@@ -128,12 +194,13 @@ func Group(results []*executor.HostResult) *GroupedResults {
 		}
 		g := groups[h]
 		sort.Strings(g.hosts)
-		diff := unifiedDiff(normStdout, string(g.stdout))
+		diff := o.LabeledDiff(normStdout, string(g.masked), "norm", "outlier")
 		gr.Groups = append(gr.Groups, OutputGroup{
 			Hosts:    g.hosts,
 			Stdout:   g.stdout,
 			Stderr:   g.stderr,
 			ExitCode: g.exitCode,
+			Status:   exitStatus(o.ExitMap, g.exitCode),
 			IsNorm:   false,
 			Diff:     diff,
 		})
@@ -142,11 +209,95 @@ func Group(results []*executor.HostResult) *GroupedResults {
 	return gr
 }
 
+// Merge combines grouped results from several partial runs (e.g. relay
+// partitions or batches) into a single view. Groups with identical output that
+// were split across parts are reunified, and the norm and diffs are recomputed
+// over the union, so the result matches what Group would have produced for a
+// single run over all hosts. The parts are regrouped with the options of the
+// first one, and each host keeps its own result, including its RunID. Nil
+// parts are ignored.
+func Merge(parts ...*GroupedResults) *GroupedResults {
+	var first *GroupedResults
+	var results []*executor.HostResult
+	var elapsed time.Duration
+	for _, p := range parts {
+		if p == nil {
+			continue
+		}
+		if first == nil {
+			first = p
+		}
+		elapsed = max(elapsed, p.Elapsed)
+		results = append(results, p.results()...)
+	}
+	if first == nil {
+		first = &GroupedResults{}
+	}
+	merged := first.regroup(results)
+	merged.Elapsed = max(merged.Elapsed, elapsed)
+	return merged
+}
+
+// Filter returns a view of gr containing only the hosts for which pred
+// returns true, given each host's stdout. The matching hosts are regrouped
+// by output with the options gr was grouped with, so masks, whitespace
+// normalization and flat mode still apply, and the norm and diffs are
+// recomputed over what remains, so the view looks as if only the matching
+// hosts had run.
+// Failed and timed-out hosts are tested against whatever stdout they
+// produced.
+func (gr *GroupedResults) Filter(pred func(host string, out []byte) bool) *GroupedResults {
+	var kept []*executor.HostResult
+	for _, r := range gr.results() {
+		if pred(r.Host, r.Stdout) {
+			kept = append(kept, r)
+		}
+	}
+	filtered := gr.regroup(kept)
+	filtered.Elapsed = gr.Elapsed
+	return filtered
+}
+
+// regroup groups results the way gr was grouped.
+func (gr *GroupedResults) regroup(results []*executor.HostResult) *GroupedResults {
+	if gr.flat {
+		return gr.opts.Flat(results)
+	}
+	return gr.opts.Group(results)
+}
+
+// results expands gr back into one result per host: the results it was
+// grouped from, if recorded, or else each grouped host with its group's
+// output, then the failed and timed-out results.
+func (gr *GroupedResults) results() []*executor.HostResult {
+	if gr.hostResults != nil {
+		return gr.hostResults
+	}
+	var results []*executor.HostResult
+	for _, g := range gr.Groups {
+		for _, h := range g.Hosts {
+			results = append(results, &executor.HostResult{
+				Host:     h,
+				Stdout:   g.Stdout,
+				Stderr:   g.Stderr,
+				ExitCode: g.ExitCode,
+			})
+		}
+	}
+	results = append(results, gr.Failed...)
+	results = append(results, gr.TimedOut...)
+	return results
+}
+
 // isTimeout checks if an error represents a timeout.
 func isTimeout(err error) bool {
 	if errors.Is(err, context.DeadlineExceeded) {
 		return true
 	}
+	var fk failureKinder
+	if errors.As(err, &fk) && fk.FailureKind() == "timeout" {
+		return true
+	}
 	var netErr net.Error
 	if errors.As(err, &netErr) {
 		return netErr.Timeout()
@@ -154,21 +305,178 @@ func isTimeout(err error) bool {
 	return false
 }
 
-// maxDiffLines is the maximum number of lines (in either input) before
-// the diff engine gives up computing an LCS and falls back to showing
-// the full removal/addition. This avoids O(n*m) blowup on very large outputs.
+// maxDiffLines is the default cutoff for DiffLCS: inputs with more lines
+// than this get a full removal/addition diff instead of an LCS, which costs
+// O(n*m) time and memory.
 const maxDiffLines = 500
 
+// maxMyersDiffLines is the default cutoff for DiffMyers, whose cost grows
+// with the number of differing lines rather than the input size.
+const maxMyersDiffLines = 50000
+
+// DiffAlgorithm selects how outlier diffs are computed.
+type DiffAlgorithm int
+
+const (
+	// DiffLCS computes a quadratic longest common subsequence. It is the
+	// default and is practical up to a few hundred lines.
+	DiffLCS DiffAlgorithm = iota
+	// DiffMyers uses the Myers O(ND) algorithm after trimming the common
+	// prefix and suffix, so large, mostly identical outputs (package lists,
+	// config files) diff quickly.
+	DiffMyers
+)
+
+// Options tunes how Group and GroupBy compute diffs and interpret exit
+// codes. The zero value matches the package-level functions.
+type Options struct {
+	// MaxDiffLines is the number of lines (in either input) above which a
+	// diff falls back to a full removal/addition. Zero uses the algorithm's
+	// default: 500 for DiffLCS and 50000 for DiffMyers.
+	MaxDiffLines int
+	Algorithm    DiffAlgorithm
+
+	// ExitMap overrides the status of specific exit codes, e.g. {1: ok}
+	// for diff. Unmapped codes are ok when 0 and fail otherwise.
+	ExitMap map[int]ExitStatus
+
+	// StderrAsOutputWhenEmpty treats the stderr of a host that exits 0
+	// with no stdout as its stdout, so tools that log only to stderr group
+	// and diff on what they printed rather than showing empty output.
+	StderrAsOutputWhenEmpty bool
+
+	// TrimWhitespace strips leading and trailing whitespace from each line
+	// of output before grouping, so hosts whose output differs only in
+	// padding (e.g. uptime) form one group. CollapseSpaces also reduces
+	// each run of spaces and tabs within a line to a single space, for
+	// column-aligned output whose widths vary by host. Grouped output,
+	// the norm and diffs all use the normalized text.
+	TrimWhitespace bool
+	CollapseSpaces bool
+
+	// Masks are replaced with "<masked>" wherever they match before hosts
+	// are grouped, so per-host details such as timestamps and PIDs don't
+	// split otherwise identical output. Groups still show the first host's
+	// original output; diffs compare the masked text, so only differences
+	// outside the masks appear.
+	Masks []*regexp.Regexp
+}
+
+// MaskPlaceholder replaces text matched by Options.Masks.
+const MaskPlaceholder = "<masked>"
+
+// mask returns a copy of r with Masks applied to its output.
+func (o Options) mask(r *executor.HostResult) *executor.HostResult {
+	masked := *r
+	for _, re := range o.Masks {
+		masked.Stdout = re.ReplaceAllLiteral(masked.Stdout, []byte(MaskPlaceholder))
+		masked.Stderr = re.ReplaceAllLiteral(masked.Stderr, []byte(MaskPlaceholder))
+	}
+	return &masked
+}
+
+// normalizeWhitespace returns a copy of r with its output normalized as
+// configured by TrimWhitespace and CollapseSpaces.
+func (o Options) normalizeWhitespace(r *executor.HostResult) *executor.HostResult {
+	normalized := *r
+	normalized.Stdout = o.normalizeLines(r.Stdout)
+	normalized.Stderr = o.normalizeLines(r.Stderr)
+	return &normalized
+}
+
+// normalizeLines applies TrimWhitespace and CollapseSpaces to each line of b.
+func (o Options) normalizeLines(b []byte) []byte {
+	if len(b) == 0 {
+		return b
+	}
+	lines := bytes.Split(b, []byte("\n"))
+	for i, line := range lines {
+		if o.TrimWhitespace {
+			line = bytes.TrimSpace(line)
+		}
+		if o.CollapseSpaces {
+			line = collapseSpaces(line)
+		}
+		lines[i] = line
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// collapseSpaces replaces each run of spaces and tabs in line with a single
+// space.
+func collapseSpaces(line []byte) []byte {
+	out := make([]byte, 0, len(line))
+	inRun := false
+	for _, c := range line {
+		if c == ' ' || c == '\t' {
+			if !inRun {
+				out = append(out, ' ')
+			}
+			inRun = true
+			continue
+		}
+		out = append(out, c)
+		inRun = false
+	}
+	return out
+}
+
+// stderrAsOutput returns a copy of r with its stderr moved to stdout if it
+// exited 0 with stderr but no stdout, and r itself otherwise.
+func stderrAsOutput(r *executor.HostResult) *executor.HostResult {
+	if r.Err != nil || r.ExitCode != 0 || len(r.Stdout) > 0 || len(r.Stderr) == 0 {
+		return r
+	}
+	folded := *r
+	folded.Stdout, folded.Stderr = r.Stderr, nil
+	return &folded
+}
+
+// maxLines returns the effective diff cutoff.
+func (o Options) maxLines() int {
+	switch {
+	case o.MaxDiffLines > 0:
+		return o.MaxDiffLines
+	case o.Algorithm == DiffMyers:
+		return maxMyersDiffLines
+	default:
+		return maxDiffLines
+	}
+}
+
 // unifiedDiff computes a simple unified diff between two strings.
 func unifiedDiff(a, b string) string {
+	return LabeledDiff(a, b, "norm", "outlier")
+}
+
+// LabeledDiff computes a unified diff between two strings using the given
+// labels for the --- and +++ header lines. It is exported so callers can diff
+// arbitrary pairs of groups rather than each outlier against the norm.
+func LabeledDiff(a, b, aLabel, bLabel string) string {
+	return Options{}.LabeledDiff(a, b, aLabel, bLabel)
+}
+
+// LabeledDiff is like the package-level LabeledDiff but uses o's algorithm
+// and cutoff.
+func (o Options) LabeledDiff(a, b, aLabel, bLabel string) string {
 	aLines := splitLines(a)
 	bLines := splitLines(b)
 
+	var lcs []string
+	ok := len(aLines) <= o.maxLines() && len(bLines) <= o.maxLines()
+	if ok {
+		if o.Algorithm == DiffMyers {
+			lcs, ok = myersLCS(aLines, bLines)
+		} else {
+			lcs = computeLCS(aLines, bLines)
+		}
+	}
+
 	// For very large outputs, skip LCS and show full removal/addition.
-	if len(aLines) > maxDiffLines || len(bLines) > maxDiffLines {
+	if !ok {
 		var out strings.Builder
-		out.WriteString("--- norm\n")
-		out.WriteString("+++ outlier\n")
+		out.WriteString("--- " + aLabel + "\n")
+		out.WriteString("+++ " + bLabel + "\n")
 		for _, line := range aLines {
 			out.WriteString("-")
 			out.WriteString(line)
@@ -182,12 +490,9 @@ func unifiedDiff(a, b string) string {
 		return out.String()
 	}
 
-	// Compute LCS-based diff.
-	lcs := computeLCS(aLines, bLines)
-
 	var out strings.Builder
-	out.WriteString("--- norm\n")
-	out.WriteString("+++ outlier\n")
+	out.WriteString("--- " + aLabel + "\n")
+	out.WriteString("+++ " + bLabel + "\n")
 
 	ai, bi, li := 0, 0, 0
 
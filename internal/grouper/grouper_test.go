@@ -1,10 +1,13 @@
 package grouper
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -203,6 +206,169 @@ func TestGroupNonZeroExitGrouped(t *testing.T) {
 	}
 }
 
+func TestGroupExitMap(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("same\n"), ExitCode: 0},
+		{Host: "host-b", Stdout: []byte("changed\n"), ExitCode: 1},
+		{Host: "host-c", Stdout: []byte("stale\n"), ExitCode: 3},
+		{Host: "host-d", Stdout: []byte("broken\n"), ExitCode: 2},
+	}
+
+	gr := Options{ExitMap: map[int]ExitStatus{1: StatusOK, 3: StatusWarn}}.Group(results)
+
+	want := map[string]ExitStatus{"host-a": StatusOK, "host-b": StatusOK, "host-c": StatusWarn, "host-d": StatusFail}
+	for _, g := range gr.Groups {
+		for _, h := range g.Hosts {
+			if g.Status != want[h] {
+				t.Errorf("%s: status = %q, want %q", h, g.Status, want[h])
+			}
+			if g.Failed() != (want[h] == StatusFail) {
+				t.Errorf("%s: Failed() = %v", h, g.Failed())
+			}
+		}
+	}
+}
+
+func TestGroupStderrAsOutputWhenEmpty(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stderr: []byte("openjdk 17\n")},
+		{Host: "host-b", Stderr: []byte("openjdk 17\n")},
+		{Host: "host-c", Stderr: []byte("openjdk 11\n")},
+		{Host: "host-d", Stdout: []byte("out\n"), Stderr: []byte("log\n")},
+		{Host: "host-e", Stderr: []byte("not found\n"), ExitCode: 127},
+	}
+
+	gr := Options{StderrAsOutputWhenEmpty: true}.Group(results)
+
+	if len(gr.Groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(gr.Groups))
+	}
+	norm := gr.Groups[0]
+	if strings.Join(norm.Hosts, ",") != "host-a,host-b" || string(norm.Stdout) != "openjdk 17\n" || norm.Stderr != nil {
+		t.Errorf("norm = %+v, want stderr shown as stdout", norm)
+	}
+	if !strings.Contains(gr.Groups[1].Diff, "+openjdk 11") {
+		t.Errorf("expected a diff on the folded output, got:\n%s", gr.Groups[1].Diff)
+	}
+	if g := gr.Groups[2]; string(g.Stdout) != "out\n" || string(g.Stderr) != "log\n" {
+		t.Errorf("host with stdout should be unchanged, got %+v", g)
+	}
+	if g := gr.Groups[3]; len(g.Stdout) != 0 || string(g.Stderr) != "not found\n" {
+		t.Errorf("non-zero exit should be unchanged, got %+v", g)
+	}
+	if results[0].Stdout != nil {
+		t.Error("input results should not be modified")
+	}
+
+	if off := Group(results); len(off.Groups[0].Stdout) != 0 {
+		t.Errorf("without the option stdout should stay empty, got %q", off.Groups[0].Stdout)
+	}
+}
+
+func TestGroupTrimWhitespace(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte(" 10:00 up 3 days\n")},
+		{Host: "host-b", Stdout: []byte("10:00 up 3 days  \n")},
+		{Host: "host-c", Stdout: []byte("\t10:00 up 3 days\r\n")},
+		{Host: "host-d", Stdout: []byte("10:00 up 9 days\n")},
+	}
+
+	if got := len(Group(results).Groups); got != 4 {
+		t.Fatalf("without trimming expected 4 groups, got %d", got)
+	}
+
+	gr := Options{TrimWhitespace: true}.Group(results)
+	if len(gr.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(gr.Groups))
+	}
+	norm := gr.Groups[0]
+	if strings.Join(norm.Hosts, ",") != "host-a,host-b,host-c" || string(norm.Stdout) != "10:00 up 3 days\n" {
+		t.Errorf("norm = %v %q, want hosts a-c with trimmed output", norm.Hosts, norm.Stdout)
+	}
+	if diff := gr.Groups[1].Diff; !strings.Contains(diff, "-10:00 up 3 days\n") || !strings.Contains(diff, "+10:00 up 9 days\n") {
+		t.Errorf("diff should use the trimmed output, got:\n%s", diff)
+	}
+	if string(results[0].Stdout) != " 10:00 up 3 days\n" {
+		t.Error("input results should not be modified")
+	}
+}
+
+func TestGroupWithOptions(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte(" 10:00 up 3 days  \n")},
+		{Host: "b", Stdout: []byte("10:00 up 3 days\n")},
+	}
+	opts := Options{TrimWhitespace: true}
+	if got, want := GroupWithOptions(results, opts), opts.Group(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupWithOptions = %+v, want %+v", got, want)
+	}
+	if gr := GroupWithOptions(results, opts); len(gr.Groups) != 1 {
+		t.Errorf("expected trimmed output in one group, got %d groups", len(gr.Groups))
+	}
+}
+
+func TestGroupCollapseSpaces(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("/dev/sda1   50G  10G\n")},
+		{Host: "host-b", Stdout: []byte("/dev/sda1 50G\t10G\n")},
+		{Host: "host-c", Stdout: []byte("  /dev/sda1 50G 10G\n")},
+	}
+
+	if got := len(Options{CollapseSpaces: true}.Group(results).Groups); got != 2 {
+		t.Errorf("collapsing alone should keep leading space significant: got %d groups, want 2", got)
+	}
+	gr := Options{TrimWhitespace: true, CollapseSpaces: true}.Group(results)
+	if len(gr.Groups) != 1 || string(gr.Groups[0].Stdout) != "/dev/sda1 50G 10G\n" {
+		t.Errorf("expected one group with normalized output, got %+v", gr.Groups)
+	}
+}
+
+func TestGroupMasks(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("active since 10:01:02\nMain PID: 811\n")},
+		{Host: "host-b", Stdout: []byte("active since 11:22:33\nMain PID: 811\n")},
+		{Host: "host-c", Stdout: []byte("active since 09:00:00\nMain PID: 905\n")},
+	}
+	opts := Options{Masks: []*regexp.Regexp{regexp.MustCompile(`\d{2}:\d{2}:\d{2}`)}}
+
+	gr := opts.Group(results)
+
+	if len(gr.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(gr.Groups))
+	}
+	norm := gr.Groups[0]
+	if strings.Join(norm.Hosts, ",") != "host-a,host-b" {
+		t.Errorf("norm hosts = %v, want host-a,host-b", norm.Hosts)
+	}
+	if string(norm.Stdout) != "active since 10:01:02\nMain PID: 811\n" {
+		t.Errorf("norm should show the original output, got %q", norm.Stdout)
+	}
+	diff := gr.Groups[1].Diff
+	if strings.Contains(diff, "-active since") || strings.Contains(diff, "+active since") || !strings.Contains(diff, "+Main PID: 905") {
+		t.Errorf("diff should only show unmasked differences, got:\n%s", diff)
+	}
+}
+
+func TestOutputGroupFailedWithoutStatus(t *testing.T) {
+	if (&OutputGroup{ExitCode: 0}).Failed() {
+		t.Error("exit 0 without status should not fail")
+	}
+	if !(&OutputGroup{ExitCode: 1}).Failed() {
+		t.Error("exit 1 without status should fail")
+	}
+}
+
+func TestParseExitStatus(t *testing.T) {
+	for _, s := range []string{"ok", "warn", "fail"} {
+		if _, err := ParseExitStatus(s); err != nil {
+			t.Errorf("ParseExitStatus(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseExitStatus("maybe"); err == nil {
+		t.Error("expected error for invalid status")
+	}
+}
+
 func TestGroupNonZeroIdenticalGrouped(t *testing.T) {
 	// Multiple hosts with the same non-zero exit code and output should be grouped.
 	results := []*executor.HostResult{
@@ -277,6 +443,235 @@ func TestGroupSameStderrGroupedTogether(t *testing.T) {
 	}
 }
 
+func TestGroupByCustomKey(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("6.1.0\nuptime 3 days\n")},
+		{Host: "host-b", Stdout: []byte("6.1.0\nuptime 9 days\n")},
+		{Host: "host-c", Stdout: []byte("5.15.0\nuptime 1 day\n")},
+	}
+	firstLine := func(r *executor.HostResult) []byte {
+		line, _, _ := strings.Cut(string(r.Stdout), "\n")
+		return []byte(line)
+	}
+
+	gr := GroupBy(results, firstLine)
+
+	if len(gr.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(gr.Groups))
+	}
+	norm := gr.Groups[0]
+	if got := strings.Join(norm.Hosts, ","); got != "host-a,host-b" {
+		t.Errorf("norm hosts = %s, want host-a,host-b", got)
+	}
+	if string(norm.Stdout) != "6.1.0\nuptime 3 days\n" {
+		t.Errorf("expected norm to keep full output of first host, got %q", norm.Stdout)
+	}
+	if !strings.Contains(gr.Groups[1].Diff, "+5.15.0") {
+		t.Errorf("expected outlier diff to show full output, got:\n%s", gr.Groups[1].Diff)
+	}
+}
+
+func TestGroupUsesOutputKey(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("x"), ExitCode: 0},
+		{Host: "b", Stdout: []byte("x"), ExitCode: 1},
+		{Host: "c", Stdout: []byte("x"), Stderr: []byte("e")},
+	}
+	if !reflect.DeepEqual(Group(results), GroupBy(results, OutputKey)) {
+		t.Error("Group should equal GroupBy with OutputKey")
+	}
+	if len(Group(results).Groups) != 3 {
+		t.Error("OutputKey should separate hosts by exit code and stderr")
+	}
+}
+
+func TestMergeReunifiesSplitGroups(t *testing.T) {
+	partA := []*executor.HostResult{
+		{Host: "a1", Stdout: []byte("v2\n")},
+		{Host: "a2", Stdout: []byte("v2\n")},
+		{Host: "a3", Stdout: []byte("v1\n")},
+	}
+	partB := []*executor.HostResult{
+		{Host: "b1", Stdout: []byte("v1\n")},
+		{Host: "b2", Stdout: []byte("v1\n")},
+		{Host: "b3", Stdout: []byte("v1\n")},
+		{Host: "b4", Err: errors.New("connection refused")},
+		{Host: "b5", Err: context.DeadlineExceeded},
+	}
+
+	merged := Merge(Group(partA), nil, Group(partB))
+
+	if len(merged.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(merged.Groups))
+	}
+	norm := merged.Groups[0]
+	if !norm.IsNorm || string(norm.Stdout) != "v1\n" {
+		t.Errorf("expected v1 norm, got %q (IsNorm=%v)", norm.Stdout, norm.IsNorm)
+	}
+	if got := strings.Join(norm.Hosts, ","); got != "a3,b1,b2,b3" {
+		t.Errorf("norm hosts = %s, want a3,b1,b2,b3", got)
+	}
+	outlier := merged.Groups[1]
+	if outlier.IsNorm || !strings.Contains(outlier.Diff, "+v2") {
+		t.Errorf("expected v2 outlier with diff against v1, got IsNorm=%v diff:\n%s", outlier.IsNorm, outlier.Diff)
+	}
+	if len(merged.Failed) != 1 || merged.Failed[0].Host != "b4" {
+		t.Errorf("Failed = %+v, want b4", merged.Failed)
+	}
+	if len(merged.TimedOut) != 1 || merged.TimedOut[0].Host != "b5" {
+		t.Errorf("TimedOut = %+v, want b5", merged.TimedOut)
+	}
+}
+
+func TestMergeMatchesSingleRun(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "h1", Stdout: []byte("x\n")},
+		{Host: "h2", Stdout: []byte("y\n"), ExitCode: 1},
+		{Host: "h3", Stdout: []byte("x\n")},
+		{Host: "h4", Stdout: []byte("z\n"), Stderr: []byte("warn\n")},
+		{Host: "h5", Stdout: []byte("x\n")},
+		{Host: "h6", Stdout: []byte("y\n"), ExitCode: 1},
+	}
+
+	want := Group(results)
+	got := Merge(Group(results[:2]), Group(results[2:4]), Group(results[4:]))
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge differs from single run:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMergeKeepsOptionsAndRunIDs(t *testing.T) {
+	opts := Options{Masks: []*regexp.Regexp{regexp.MustCompile(`pid=\d+`)}}
+	a := opts.Group([]*executor.HostResult{
+		{Host: "a", Stdout: []byte("up pid=1\n"), RunID: "run-1"},
+	})
+	b := opts.Group([]*executor.HostResult{
+		{Host: "b", Stdout: []byte("up pid=2\n"), RunID: "run-1"},
+	})
+
+	merged := Merge(a, b)
+	if len(merged.Groups) != 1 || strings.Join(merged.Groups[0].Hosts, ",") != "a,b" {
+		t.Errorf("masked hosts should merge into one group, got %+v", merged.Groups)
+	}
+	for _, r := range merged.results() {
+		if r.RunID != "run-1" {
+			t.Errorf("%s RunID = %q, want run-1", r.Host, r.RunID)
+		}
+	}
+}
+
+func TestElapsed(t *testing.T) {
+	a := Group([]*executor.HostResult{
+		{Host: "a", Stdout: []byte("x\n"), Duration: 2 * time.Second},
+		{Host: "b", Err: context.DeadlineExceeded, Duration: 5 * time.Second},
+	})
+	if a.Elapsed != 5*time.Second {
+		t.Errorf("Elapsed = %v, want 5s", a.Elapsed)
+	}
+	b := Group([]*executor.HostResult{
+		{Host: "c", Stdout: []byte("x\n"), Duration: 7 * time.Second},
+	})
+	if got := Merge(a, b).Elapsed; got != 7*time.Second {
+		t.Errorf("merged Elapsed = %v, want 7s", got)
+	}
+}
+
+func TestMergeEmpty(t *testing.T) {
+	merged := Merge()
+	if len(merged.Groups) != 0 || len(merged.Failed) != 0 || len(merged.TimedOut) != 0 {
+		t.Errorf("expected empty result, got %+v", merged)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	gr := Options{ExitMap: map[int]ExitStatus{1: StatusOK}}.Group([]*executor.HostResult{
+		{Host: "h1", Stdout: []byte("nginx 1.24\n")},
+		{Host: "h2", Stdout: []byte("nginx 1.24\n")},
+		{Host: "h3", Stdout: []byte("nginx 1.24\n")},
+		{Host: "h4", Stdout: []byte("nginx 1.22\n"), ExitCode: 1},
+		{Host: "h5", Stdout: []byte("apache\n")},
+		{Host: "h6", Err: errors.New("connection refused")},
+		{Host: "h7", Err: context.DeadlineExceeded},
+	})
+	gr.Elapsed = 3 * time.Second
+
+	// Drop h1 and h2 from the norm, leaving h3 and h4 tied.
+	filtered := gr.Filter(func(host string, out []byte) bool {
+		return bytes.Contains(out, []byte("nginx")) && host != "h1" && host != "h2"
+	})
+
+	if len(filtered.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(filtered.Groups))
+	}
+	norm, outlier := filtered.Groups[0], filtered.Groups[1]
+	if !norm.IsNorm || strings.Join(norm.Hosts, ",") != "h3" {
+		t.Errorf("norm = %v (IsNorm=%v), want h3", norm.Hosts, norm.IsNorm)
+	}
+	if outlier.IsNorm || strings.Join(outlier.Hosts, ",") != "h4" || !strings.Contains(outlier.Diff, "+nginx 1.22") {
+		t.Errorf("outlier = %v (IsNorm=%v), diff:\n%s", outlier.Hosts, outlier.IsNorm, outlier.Diff)
+	}
+	if outlier.Status != StatusOK {
+		t.Errorf("outlier status = %q, want the mapped status ok", outlier.Status)
+	}
+	if len(filtered.Failed) != 0 || len(filtered.TimedOut) != 0 {
+		t.Errorf("failed hosts without matching output should be dropped: %+v %+v", filtered.Failed, filtered.TimedOut)
+	}
+	if filtered.Elapsed != 3*time.Second {
+		t.Errorf("Elapsed = %v, want 3s", filtered.Elapsed)
+	}
+	if len(gr.Groups) != 3 || len(gr.Groups[0].Hosts) != 3 {
+		t.Error("Filter should not modify the original results")
+	}
+}
+
+func TestFilterKeepsOptions(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "h1", Stdout: []byte("up pid=101\n")},
+		{Host: "h2", Stdout: []byte("up pid=202\n")},
+		{Host: "h3", Stdout: []byte("down pid=303\n")},
+	}
+	opts := Options{Masks: []*regexp.Regexp{regexp.MustCompile(`pid=\d+`)}}
+	always := func(string, []byte) bool { return true }
+
+	filtered := opts.Group(results).Filter(always)
+	if len(filtered.Groups) != 2 || strings.Join(filtered.Groups[0].Hosts, ",") != "h1,h2" {
+		t.Errorf("masked hosts should stay grouped after Filter, got %+v", filtered.Groups)
+	}
+
+	// Each host is tested against its own output, not its group's.
+	filtered = opts.Group(results).Filter(func(_ string, out []byte) bool {
+		return bytes.Contains(out, []byte("pid=202"))
+	})
+	if len(filtered.Groups) != 1 || strings.Join(filtered.Groups[0].Hosts, ",") != "h2" {
+		t.Errorf("expected only h2, got %+v", filtered.Groups)
+	}
+
+	flat := opts.Flat(results).Filter(always)
+	if len(flat.Groups) != 3 || flat.Groups[0].IsNorm {
+		t.Errorf("flat results should stay flat after Filter, got %+v", flat.Groups)
+	}
+}
+
+func TestFilterKeepsFailedHosts(t *testing.T) {
+	gr := Group([]*executor.HostResult{
+		{Host: "h1", Stdout: []byte("ok\n")},
+		{Host: "h2", Err: errors.New("connection refused")},
+	})
+
+	filtered := gr.Filter(func(host string, out []byte) bool { return host == "h2" })
+
+	if len(filtered.Groups) != 0 {
+		t.Errorf("expected no groups, got %+v", filtered.Groups)
+	}
+	if len(filtered.Failed) != 1 || filtered.Failed[0].Host != "h2" {
+		t.Errorf("Failed = %+v, want h2", filtered.Failed)
+	}
+	if len(filtered.FailedByClass) == 0 {
+		t.Error("FailedByClass should be recomputed")
+	}
+}
+
 // timeoutError implements net.Error with Timeout() == true.
 type timeoutError struct{}
 
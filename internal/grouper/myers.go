@@ -0,0 +1,98 @@
+package grouper
+
+import "slices"
+
+// maxMyersEdits bounds the edit distance myersLCS will search. Memory grows
+// with its square, so inputs that differ by more lines than this fall back
+// to a full removal/addition diff.
+const maxMyersEdits = 2000
+
+// myersLCS returns a longest common subsequence of a and b using the Myers
+// O(ND) algorithm, after trimming their common prefix and suffix. It returns
+// false if the inputs differ by more than maxMyersEdits lines.
+func myersLCS(a, b []string) ([]string, bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	middle, ok := myersMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if !ok {
+		return nil, false
+	}
+	lcs := make([]string, 0, prefix+len(middle)+suffix)
+	lcs = append(lcs, a[:prefix]...)
+	lcs = append(lcs, middle...)
+	lcs = append(lcs, a[len(a)-suffix:]...)
+	return lcs, true
+}
+
+// myersMiddle runs the greedy Myers search over a and b, recording the
+// furthest-reaching x per diagonal for each edit distance d, then backtracks
+// through those snapshots to collect the common lines.
+func myersMiddle(a, b []string) ([]string, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxMyersEdits)
+
+	// v[offset+k] is the furthest x reached on diagonal k = x - y.
+	offset := limit + 1
+	v := make([]int, 2*offset+1)
+
+	// trace[d] holds v for diagonals -d-1..d+1 as it was before round d.
+	var trace [][]int
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down: insertion from b
+			} else {
+				x = v[offset+k-1] + 1 // move right: deletion from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Backtrack from (n, m), collecting diagonal (common) lines in reverse.
+	var common []string
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snap := trace[d] // snap[k+d+1] is v[k] before round d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && snap[k-1+d+1] < snap[k+1+d+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := snap[prevK+d+1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			common = append(common, a[x-1])
+			x--
+			y--
+		}
+		if d > 0 {
+			x, y = prevX, prevY
+		}
+	}
+	slices.Reverse(common)
+	return common, true
+}
@@ -0,0 +1,112 @@
+package grouper
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+// isSubsequence reports whether sub appears in order within s.
+func isSubsequence(sub, s []string) bool {
+	i := 0
+	for _, line := range s {
+		if i < len(sub) && sub[i] == line {
+			i++
+		}
+	}
+	return i == len(sub)
+}
+
+func TestMyersLCS_MatchesLCSLength(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	alphabet := []string{"a", "b", "c", "d"}
+	randLines := func() []string {
+		lines := make([]string, rng.IntN(30))
+		for i := range lines {
+			lines[i] = alphabet[rng.IntN(len(alphabet))]
+		}
+		return lines
+	}
+	for i := range 500 {
+		a, b := randLines(), randLines()
+		got, ok := myersLCS(a, b)
+		if !ok {
+			t.Fatalf("case %d: myersLCS gave up", i)
+		}
+		want := computeLCS(a, b)
+		if len(got) != len(want) {
+			t.Fatalf("case %d: len = %d, want %d\na=%v\nb=%v", i, len(got), len(want), a, b)
+		}
+		if !isSubsequence(got, a) || !isSubsequence(got, b) {
+			t.Fatalf("case %d: %v is not common to a=%v b=%v", i, got, a, b)
+		}
+	}
+}
+
+func TestMyersLCS_GivesUpOnLargeEditDistance(t *testing.T) {
+	a := make([]string, maxMyersEdits)
+	b := make([]string, maxMyersEdits)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+	}
+	if _, ok := myersLCS(a, b); ok {
+		t.Error("expected myersLCS to give up on completely different inputs")
+	}
+}
+
+func TestOptions_LabeledDiff(t *testing.T) {
+	// 2000 package lines with one version changed: past the LCS cutoff, so
+	// only DiffMyers yields a minimal diff.
+	var a, b strings.Builder
+	for i := range 2000 {
+		fmt.Fprintf(&a, "pkg%d 1.0\n", i)
+		if i == 1234 {
+			fmt.Fprintf(&b, "pkg%d 1.1\n", i)
+		} else {
+			fmt.Fprintf(&b, "pkg%d 1.0\n", i)
+		}
+	}
+
+	changes := func(diff string) int {
+		n := 0
+		for _, line := range strings.Split(diff, "\n")[2:] {
+			if strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") {
+				n++
+			}
+		}
+		return n
+	}
+
+	if got := changes(LabeledDiff(a.String(), b.String(), "x", "y")); got != 4000 {
+		t.Errorf("default diff changed %d lines, want full 4000-line fallback", got)
+	}
+	myers := Options{Algorithm: DiffMyers}.LabeledDiff(a.String(), b.String(), "x", "y")
+	if got := changes(myers); got != 2 {
+		t.Errorf("Myers diff changed %d lines, want 2", got)
+	}
+	if !strings.Contains(myers, "-pkg1234 1.0\n+pkg1234 1.1\n") {
+		t.Errorf("Myers diff missing the changed line:\n%.300s", myers)
+	}
+	if got := changes(Options{MaxDiffLines: 3000}.LabeledDiff("a\nb\n", "a\nc\n", "x", "y")); got != 2 {
+		t.Errorf("LCS diff with raised cutoff changed %d lines, want 2", got)
+	}
+	if got := changes(Options{Algorithm: DiffMyers, MaxDiffLines: 100}.LabeledDiff(a.String(), b.String(), "x", "y")); got != 4000 {
+		t.Errorf("explicit cutoff ignored: changed %d lines, want 4000", got)
+	}
+}
+
+func TestOptions_Group(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("x\ny\n")},
+		{Host: "b", Stdout: []byte("x\ny\n")},
+		{Host: "c", Stdout: []byte("x\nz\n")},
+	}
+	gr := Options{Algorithm: DiffMyers}.Group(results)
+	if len(gr.Groups) != 2 || gr.Groups[1].Diff != "--- norm\n+++ outlier\n x\n-y\n+z\n" {
+		t.Errorf("unexpected groups: %+v", gr.Groups)
+	}
+}
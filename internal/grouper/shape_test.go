@@ -0,0 +1,31 @@
+package grouper_test
+
+// These tests check which group becomes the norm with groupertest, which
+// imports grouper and so can only be used from an external test package.
+
+import (
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+	gt "github.com/agent462/herd/internal/groupertest"
+)
+
+func TestGroupNormIsLargestGroup(t *testing.T) {
+	results := append([]*executor.HostResult{gt.Result("host-a", "v1\n")},
+		gt.Same("v2\n", "host-b", "host-c", "host-d")...)
+	results = append(results, gt.Exit("host-e", "v3\n", 1))
+
+	gr := grouper.Group(results)
+
+	// The norm is picked by size; outliers keep the order they appeared in.
+	gt.AssertGroups(t, gr, []string{"host-b", "host-c", "host-d"}, [][]string{{"host-a"}, {"host-e"}})
+}
+
+func TestGroupNormTieGoesToFirstGroup(t *testing.T) {
+	results := append(gt.Same("v2\n", "host-c", "host-d"), gt.Same("v1\n", "host-a", "host-b")...)
+
+	gr := grouper.Group(results)
+
+	gt.AssertGroups(t, gr, []string{"host-c", "host-d"}, [][]string{{"host-a", "host-b"}})
+}
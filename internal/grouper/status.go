@@ -0,0 +1,44 @@
+package grouper
+
+import "fmt"
+
+// ExitStatus is the meaning of a command's exit code. Most commands fail
+// on any non-zero exit, but some use other codes for normal outcomes: diff
+// and grep exit 1 for "differences found" and "no match".
+type ExitStatus string
+
+const (
+	StatusOK   ExitStatus = "ok"
+	StatusWarn ExitStatus = "warn"
+	StatusFail ExitStatus = "fail"
+)
+
+// ParseExitStatus parses "ok", "warn" or "fail".
+func ParseExitStatus(s string) (ExitStatus, error) {
+	switch st := ExitStatus(s); st {
+	case StatusOK, StatusWarn, StatusFail:
+		return st, nil
+	}
+	return "", fmt.Errorf("invalid exit status %q (want ok, warn or fail)", s)
+}
+
+// exitStatus returns the status of exit code code under exitMap: the mapped
+// status if there is one, otherwise ok for 0 and fail for anything else.
+func exitStatus(exitMap map[int]ExitStatus, code int) ExitStatus {
+	if st, ok := exitMap[code]; ok {
+		return st
+	}
+	if code == 0 {
+		return StatusOK
+	}
+	return StatusFail
+}
+
+// Failed reports whether the group's exit code means failure. Groups built
+// without a Status (e.g. by hand) fail on any non-zero exit code.
+func (g *OutputGroup) Failed() bool {
+	if g.Status == "" {
+		return g.ExitCode != 0
+	}
+	return g.Status == StatusFail
+}
@@ -0,0 +1,83 @@
+package grouper
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+// Expectation is the output Verify requires of every host: an exact value,
+// or a regular expression when Pattern is set.
+type Expectation struct {
+	Value   string
+	Pattern *regexp.Regexp
+}
+
+// ParseExpectation parses an expected value, written as /regex/ for a
+// pattern and as the literal value otherwise.
+func ParseExpectation(s string) (Expectation, error) {
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		re, err := regexp.Compile(s[1 : len(s)-1])
+		if err != nil {
+			return Expectation{}, err
+		}
+		return Expectation{Value: s, Pattern: re}, nil
+	}
+	return Expectation{Value: s}, nil
+}
+
+// Match reports whether out, with surrounding whitespace trimmed, meets the
+// expectation.
+func (e Expectation) Match(out []byte) bool {
+	out = bytes.TrimSpace(out)
+	if e.Pattern != nil {
+		return e.Pattern.Match(out)
+	}
+	return string(out) == strings.TrimSpace(e.Value)
+}
+
+// VerifyReport holds per-host pass/fail results of checking output against
+// an Expectation.
+type VerifyReport struct {
+	Expected Expectation
+	Passed   []string
+	Mismatch []*executor.HostResult
+	Failed   []*executor.HostResult
+	TimedOut []*executor.HostResult
+}
+
+// OK reports whether every host ran and met the expectation. A caller
+// running verification as a one-shot check exits non-zero when it is false.
+func (r *VerifyReport) OK() bool {
+	return len(r.Mismatch) == 0 && len(r.Failed) == 0 && len(r.TimedOut) == 0
+}
+
+// Verify checks each host's trimmed stdout against exp. Connection errors
+// and timeouts are reported separately, as in Group, and count as failures
+// rather than mismatches. Within each category hosts are sorted by name.
+func Verify(results []*executor.HostResult, exp Expectation) *VerifyReport {
+	report := &VerifyReport{Expected: exp}
+
+	for _, r := range results {
+		switch {
+		case r.Err != nil && isTimeout(r.Err):
+			report.TimedOut = append(report.TimedOut, r)
+		case r.Err != nil:
+			report.Failed = append(report.Failed, r)
+		case r.ExitCode == 0 && exp.Match(r.Stdout):
+			report.Passed = append(report.Passed, r.Host)
+		default:
+			report.Mismatch = append(report.Mismatch, r)
+		}
+	}
+
+	sort.Strings(report.Passed)
+	for _, rs := range [][]*executor.HostResult{report.Mismatch, report.Failed, report.TimedOut} {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].Host < rs[j].Host })
+	}
+
+	return report
+}
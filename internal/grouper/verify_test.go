@@ -0,0 +1,73 @@
+package grouper
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+func TestVerify(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "web-02", Stdout: []byte("12\n")},
+		{Host: "web-01", Stdout: []byte("  12  \n")},
+		{Host: "web-03", Stdout: []byte("11\n")},
+		{Host: "web-04", Stdout: []byte("12\n"), ExitCode: 1},
+		{Host: "web-08", Err: errors.New("connection refused")},
+		{Host: "web-05", Err: errors.New("connection refused")},
+		{Host: "web-07", Err: context.DeadlineExceeded},
+		{Host: "web-06", Err: context.DeadlineExceeded},
+	}
+
+	exp, err := ParseExpectation("12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Verify(results, exp)
+
+	if want := []string{"web-01", "web-02"}; !slices.Equal(report.Passed, want) {
+		t.Errorf("passed = %v, want %v", report.Passed, want)
+	}
+	var mismatch []string
+	for _, r := range report.Mismatch {
+		mismatch = append(mismatch, r.Host)
+	}
+	if want := []string{"web-03", "web-04"}; !slices.Equal(mismatch, want) {
+		t.Errorf("mismatch = %v, want %v", mismatch, want)
+	}
+	if len(report.Failed) != 2 || report.Failed[0].Host != "web-05" {
+		t.Errorf("failed = %v, want web-05 and web-08 in order", report.Failed)
+	}
+	if len(report.TimedOut) != 2 || report.TimedOut[0].Host != "web-06" {
+		t.Errorf("timed out = %v, want web-06 and web-07 in order", report.TimedOut)
+	}
+	if report.OK() {
+		t.Error("OK() = true with mismatches")
+	}
+}
+
+func TestVerifyPattern(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("nginx version: nginx/1.24.0\n")},
+		{Host: "b", Stdout: []byte("nginx version: nginx/1.25.3\n")},
+	}
+
+	exp, err := ParseExpectation(`/nginx\/1\.2[45]\./`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Pattern == nil {
+		t.Fatal("expected a pattern")
+	}
+	if report := Verify(results, exp); !report.OK() {
+		t.Errorf("expected all hosts to match, mismatched %d", len(report.Mismatch))
+	}
+}
+
+func TestParseExpectationInvalid(t *testing.T) {
+	if _, err := ParseExpectation("/[/"); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
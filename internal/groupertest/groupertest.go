@@ -0,0 +1,110 @@
+// Package groupertest provides result fixtures and assertions for tests of
+// grouped output.
+package groupertest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+)
+
+// Result returns a result for host that exited 0 with the given stdout.
+func Result(host, stdout string) *executor.HostResult {
+	return &executor.HostResult{Host: host, Stdout: []byte(stdout)}
+}
+
+// Exit returns a result for host that exited with code and the given stdout.
+func Exit(host, stdout string, code int) *executor.HostResult {
+	return &executor.HostResult{Host: host, Stdout: []byte(stdout), ExitCode: code}
+}
+
+// Failed returns a result for host that failed to run, e.g. to connect.
+func Failed(host string, err error) *executor.HostResult {
+	return &executor.HostResult{Host: host, ExitCode: -1, Err: err}
+}
+
+// TimedOut returns a result for host that hit its timeout.
+func TimedOut(host string) *executor.HostResult {
+	return Failed(host, context.DeadlineExceeded)
+}
+
+// Same returns a result for each host, all exiting 0 with the same stdout.
+func Same(stdout string, hosts ...string) []*executor.HostResult {
+	results := make([]*executor.HostResult, len(hosts))
+	for i, h := range hosts {
+		results[i] = Result(h, stdout)
+	}
+	return results
+}
+
+// AssertGroups fails t unless grouped has a norm group with exactly the
+// hosts in wantNorm, followed by one outlier group per entry of
+// wantOutliers with exactly those hosts, in order. Host order within a group
+// does not matter. A nil wantNorm expects no groups at all. Failed and
+// timed-out hosts are not checked.
+func AssertGroups(t testing.TB, grouped *grouper.GroupedResults, wantNorm []string, wantOutliers [][]string) {
+	t.Helper()
+
+	var want [][]string
+	if wantNorm != nil {
+		want = append([][]string{wantNorm}, wantOutliers...)
+	}
+	got := make([][]string, len(grouped.Groups))
+	for i, g := range grouped.Groups {
+		got[i] = g.Hosts
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d groups, want %d\n got: %s\nwant: %s", len(got), len(want), describe(got), describe(want))
+		return
+	}
+
+	for i, g := range grouped.Groups {
+		if g.IsNorm != (i == 0) {
+			t.Errorf("group %d (%s): IsNorm = %v, want %v", i, strings.Join(g.Hosts, ", "), g.IsNorm, i == 0)
+		}
+		if !sameHosts(g.Hosts, want[i]) {
+			t.Errorf("%s hosts = [%s], want [%s]", label(i), strings.Join(g.Hosts, ", "), strings.Join(want[i], ", "))
+		}
+	}
+}
+
+// label names group i for failure messages.
+func label(i int) string {
+	if i == 0 {
+		return "norm"
+	}
+	return fmt.Sprintf("outlier %d", i)
+}
+
+// sameHosts reports whether a and b hold the same hosts in any order.
+func sameHosts(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// describe formats groups of hosts as "norm [a, b] outliers [c] [d]".
+func describe(groups [][]string) string {
+	if len(groups) == 0 {
+		return "no groups"
+	}
+	var s strings.Builder
+	for i, hosts := range groups {
+		switch i {
+		case 0:
+			s.WriteString("norm ")
+		case 1:
+			s.WriteString(" outliers ")
+		default:
+			s.WriteString(" ")
+		}
+		s.WriteString("[" + strings.Join(hosts, ", ") + "]")
+	}
+	return s.String()
+}
@@ -0,0 +1,92 @@
+package groupertest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+)
+
+// recorder is a testing.TB that records failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFixtures(t *testing.T) {
+	if r := Result("a", "x\n"); r.Host != "a" || string(r.Stdout) != "x\n" || r.ExitCode != 0 || r.Err != nil {
+		t.Errorf("Result = %+v", r)
+	}
+	if r := Exit("a", "x\n", 3); r.ExitCode != 3 || string(r.Stdout) != "x\n" {
+		t.Errorf("Exit = %+v", r)
+	}
+
+	results := append(Same("x\n", "a", "b"), Failed("c", errors.New("connection refused")), TimedOut("d"))
+	gr := grouper.Group(results)
+	if len(gr.Failed) != 1 || gr.Failed[0].Host != "c" {
+		t.Errorf("Failed fixture should group as failed, got %+v", gr.Failed)
+	}
+	if len(gr.TimedOut) != 1 || gr.TimedOut[0].Host != "d" {
+		t.Errorf("TimedOut fixture should group as timed out, got %+v", gr.TimedOut)
+	}
+	AssertGroups(t, gr, []string{"a", "b"}, nil)
+}
+
+func TestAssertGroups(t *testing.T) {
+	gr := grouper.Group([]*executor.HostResult{
+		Result("web-1", "v2\n"),
+		Result("web-2", "v2\n"),
+		Result("web-3", "v1\n"),
+		Exit("web-4", "v2\n", 1),
+	})
+
+	tests := []struct {
+		name     string
+		norm     []string
+		outliers [][]string
+		wantErr  string // substring of the first failure; empty for none
+	}{
+		{"match", []string{"web-1", "web-2"}, [][]string{{"web-3"}, {"web-4"}}, ""},
+		{"host order ignored", []string{"web-2", "web-1"}, [][]string{{"web-3"}, {"web-4"}}, ""},
+		{"wrong norm", []string{"web-1"}, [][]string{{"web-3"}, {"web-4"}}, "norm hosts = [web-1, web-2], want [web-1]"},
+		{"outliers out of order", []string{"web-1", "web-2"}, [][]string{{"web-4"}, {"web-3"}}, "outlier 1 hosts = [web-3], want [web-4]"},
+		{"missing outlier", []string{"web-1", "web-2"}, [][]string{{"web-3"}}, "got 3 groups, want 2"},
+		{"no groups expected", nil, nil, "got 3 groups, want 0"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &recorder{}
+			AssertGroups(rec, gr, tc.norm, tc.outliers)
+			switch {
+			case tc.wantErr == "" && len(rec.errors) > 0:
+				t.Errorf("unexpected failure: %s", rec.errors[0])
+			case tc.wantErr != "" && len(rec.errors) == 0:
+				t.Errorf("expected failure containing %q", tc.wantErr)
+			case tc.wantErr != "" && !strings.Contains(rec.errors[0], tc.wantErr):
+				t.Errorf("failure = %q, want it to contain %q", rec.errors[0], tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssertGroupsEmpty(t *testing.T) {
+	rec := &recorder{}
+	AssertGroups(rec, grouper.Group([]*executor.HostResult{TimedOut("a")}), nil, nil)
+	if len(rec.errors) > 0 {
+		t.Errorf("unexpected failure: %s", rec.errors[0])
+	}
+
+	AssertGroups(rec, grouper.Group(nil), []string{"a"}, nil)
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "got: no groups") {
+		t.Errorf("failures = %q, want one describing no groups", rec.errors)
+	}
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Aggregate computes fleet-wide statistics for a numeric field: the sum and
+// mean of its values, and the smallest and largest values as written. Values
+// may be plain numbers, percentages or sizes such as "1.5Gi" (see
+// parseNumber); sizes are summed in bytes, and plain numbers count as bytes
+// alongside them. Hosts without a value ("-") or whose connection failed are
+// skipped. It is an error for no result to have the field, for no host to
+// have a value, for a value not to be a number, or for values to mix units,
+// such as a percentage and a size.
+func Aggregate(parsed []*HostParsed, field string) (sum, mean float64, min, max FieldValue, err error) {
+	sum, mean, min, max, _, err = aggregate(parsed, field)
+	return sum, mean, min, max, err
+}
+
+// aggregate implements Aggregate, also returning the number of hosts
+// counted.
+func aggregate(parsed []*HostParsed, field string) (sum, mean float64, min, max FieldValue, count int, err error) {
+	idx := fieldIndex(parsed, field)
+	if idx < 0 {
+		return 0, 0, FieldValue{}, FieldValue{}, 0, fmt.Errorf("no field %q", field)
+	}
+
+	var lo, hi float64
+	var kind, kindHost string
+	for _, hp := range parsed {
+		v := fieldValue(hp, idx)
+		if hp.Err != nil || v == "" || v == "-" {
+			continue
+		}
+		n, ok := parseNumber(v)
+		if !ok {
+			return 0, 0, FieldValue{}, FieldValue{}, 0, fmt.Errorf("field %q: %s has non-numeric value %q", field, hp.Host, v)
+		}
+		switch k := unitKind(v); {
+		case kind == "" || kind == k:
+			kind, kindHost = k, hp.Host
+		case kind == "number" && k == "size", kind == "size" && k == "number":
+			kind, kindHost = "size", hp.Host
+		default:
+			return 0, 0, FieldValue{}, FieldValue{}, 0, fmt.Errorf("field %q: %s has a %s (%q) but %s has a %s", field, hp.Host, k, v, kindHost, kind)
+		}
+		if count == 0 || n < lo {
+			lo, min = n, FieldValue{Field: field, Value: v}
+		}
+		if count == 0 || n > hi {
+			hi, max = n, FieldValue{Field: field, Value: v}
+		}
+		sum += n
+		count++
+	}
+	if count == 0 {
+		return 0, 0, FieldValue{}, FieldValue{}, 0, fmt.Errorf("field %q: no host has a value", field)
+	}
+	return sum, sum / float64(count), min, max, count, nil
+}
+
+// unitKind classifies a numeric value by its unit: "percentage", "size" or
+// plain "number".
+func unitKind(v string) string {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.HasSuffix(v, "%"):
+		return "percentage"
+	case strings.TrimRight(v, "KMGTPkmgtpiB") != v:
+		return "size"
+	}
+	return "number"
+}
+
+// FormatAggregate renders Aggregate's statistics for field across parsed:
+// a heading with the number of hosts counted, then the sum, mean, min and
+// max. The sum and mean are shown in the unit of the field's values, so
+// sizes read like "5.2Gi". If color is true, the heading is bold.
+func FormatAggregate(parsed []*HostParsed, field string, color bool) (string, error) {
+	sum, mean, lo, hi, counted, err := aggregate(parsed, field)
+	if err != nil {
+		return "", err
+	}
+
+	heading := fmt.Sprintf(" %s across %d %s", field, counted, pluralHost(counted))
+	if skipped := len(parsed) - counted; skipped > 0 {
+		heading += fmt.Sprintf(" (%d without a value)", skipped)
+	}
+	heading += ":"
+	if color {
+		heading = "\033[1m" + heading + "\033[0m"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(heading)
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "   sum   %s\n", formatQuantity(sum, hi.Value))
+	fmt.Fprintf(&sb, "   mean  %s\n", formatQuantity(mean, hi.Value))
+	fmt.Fprintf(&sb, "   min   %s\n", lo.Value)
+	fmt.Fprintf(&sb, "   max   %s\n", hi.Value)
+	return sb.String(), nil
+}
+
+// pluralHost returns "host" or "hosts" for n.
+func pluralHost(n int) string {
+	if n == 1 {
+		return "host"
+	}
+	return "hosts"
+}
+
+// sizeSuffixes lists size units from largest to smallest, as used by
+// formatQuantity.
+var sizeSuffixes = []string{"P", "T", "G", "M", "K"}
+
+// formatQuantity formats n in the style of like, a value it was computed
+// from: with a "%" for percentages, in the largest fitting unit for sizes
+// ("Gi" when like uses binary suffixes such as "Mi"), and as a plain number
+// otherwise. Results are rounded to two decimal places, or one for sizes.
+func formatQuantity(n float64, like string) string {
+	round := func(n float64, places int) string {
+		p := math.Pow(10, float64(places))
+		return strconv.FormatFloat(math.Round(n*p)/p, 'f', -1, 64)
+	}
+	if strings.HasSuffix(like, "%") {
+		return round(n, 2) + "%"
+	}
+	if strings.TrimRight(like, "KMGTPkmgtpiB") == like {
+		return round(n, 2)
+	}
+
+	binary := strings.Contains(like, "i")
+	for _, unit := range sizeSuffixes {
+		if mult := sizeUnits[unit]; math.Abs(n) >= mult {
+			if binary {
+				unit += "i"
+			}
+			return round(n/mult, 1) + unit
+		}
+	}
+	if binary {
+		return round(n, 1) + "B"
+	}
+	return round(n, 1)
+}
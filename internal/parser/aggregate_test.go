@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	row := func(host, used string) *HostParsed {
+		return &HostParsed{Host: host, Fields: []FieldValue{{Field: "used", Value: used}}}
+	}
+	parsed := []*HostParsed{
+		row("a", "1.5Gi"),
+		row("b", "512Mi"),
+		row("c", "-"),
+		row("d", "2Gi"),
+		{Host: "e", Fields: []FieldValue{{Field: "used", Value: "9Gi"}}, Err: errors.New("connection refused")},
+	}
+
+	sum, mean, lo, hi, err := Aggregate(parsed, "used")
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if want := 4.0 * (1 << 30); sum != want {
+		t.Errorf("sum = %v, want %v", sum, want)
+	}
+	if want := 4.0 * (1 << 30) / 3; mean != want {
+		t.Errorf("mean = %v, want %v", mean, want)
+	}
+	if lo.Value != "512Mi" || hi.Value != "2Gi" {
+		t.Errorf("min, max = %q, %q; want 512Mi, 2Gi", lo.Value, hi.Value)
+	}
+	if lo.Field != "used" || hi.Field != "used" {
+		t.Errorf("min, max fields = %q, %q; want used", lo.Field, hi.Field)
+	}
+}
+
+func TestAggregatePercentAndPlain(t *testing.T) {
+	parsed := []*HostParsed{
+		{Host: "a", Fields: []FieldValue{{Field: "use_pct", Value: "42%"}, {Field: "count", Value: "3"}}},
+		{Host: "b", Fields: []FieldValue{{Field: "use_pct", Value: "90%"}, {Field: "count", Value: "12"}}},
+	}
+	sum, mean, _, hi, err := Aggregate(parsed, "use_pct")
+	if err != nil || sum != 132 || mean != 66 || hi.Value != "90%" {
+		t.Errorf("use_pct: sum %v, mean %v, max %q, err %v; want 132, 66, 90%%", sum, mean, hi.Value, err)
+	}
+	sum, mean, lo, _, err := Aggregate(parsed, "count")
+	if err != nil || sum != 15 || mean != 7.5 || lo.Value != "3" {
+		t.Errorf("count: sum %v, mean %v, min %q, err %v; want 15, 7.5, 3", sum, mean, lo.Value, err)
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	row := func(v string) *HostParsed {
+		return &HostParsed{Host: "a", Fields: []FieldValue{{Field: "x", Value: v}}}
+	}
+	tests := []struct {
+		name   string
+		parsed []*HostParsed
+		field  string
+	}{
+		{"unknown field", []*HostParsed{row("1")}, "y"},
+		{"no values", []*HostParsed{row("-"), row("-")}, "x"},
+		{"non-numeric", []*HostParsed{row("1"), row("eth0")}, "x"},
+		{"percentage and size", []*HostParsed{row("42%"), row("9G")}, "x"},
+		{"number and percentage", []*HostParsed{row("3"), row("42%")}, "x"},
+	}
+	for _, tt := range tests {
+		if _, _, _, _, err := Aggregate(tt.parsed, tt.field); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestAggregatePlainNumbersWithSizes(t *testing.T) {
+	parsed := []*HostParsed{
+		{Host: "a", Fields: []FieldValue{{Field: "used", Value: "0"}}},
+		{Host: "b", Fields: []FieldValue{{Field: "used", Value: "1K"}}},
+	}
+	sum, _, _, _, err := Aggregate(parsed, "used")
+	if err != nil || sum != 1024 {
+		t.Errorf("sum %v, err %v; want 1024 with plain numbers counted as bytes", sum, err)
+	}
+}
+
+func TestFormatAggregate(t *testing.T) {
+	row := func(host, used string) *HostParsed {
+		return &HostParsed{Host: host, Fields: []FieldValue{{Field: "used", Value: used}}}
+	}
+	parsed := []*HostParsed{row("a", "1.5Gi"), row("b", "512Mi"), row("c", "-"), row("d", "2Gi")}
+
+	out, err := FormatAggregate(parsed, "used", false)
+	if err != nil {
+		t.Fatalf("FormatAggregate: %v", err)
+	}
+	for _, want := range []string{
+		"used across 3 hosts (1 without a value):",
+		"   sum   4Gi\n",
+		"   mean  1.3Gi\n",
+		"   min   512Mi\n",
+		"   max   2Gi\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := FormatAggregate(parsed, "missing", false); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestFormatQuantity(t *testing.T) {
+	tests := []struct {
+		n    float64
+		like string
+		want string
+	}{
+		{66.666, "90%", "66.67%"},
+		{7.5, "3", "7.5"},
+		{20 << 30, "9G", "20G"},
+		{1.25 * (1 << 20), "512Mi", "1.3Mi"},
+		{100, "1K", "100"},
+	}
+	for _, tt := range tests {
+		if got := formatQuantity(tt.n, tt.like); got != tt.want {
+			t.Errorf("formatQuantity(%v, %q) = %q, want %q", tt.n, tt.like, got, tt.want)
+		}
+	}
+}
@@ -8,6 +8,10 @@ func BuiltinParsers() map[string]*OutputParser {
 		"disk":   BuiltinDisk(),
 		"free":   BuiltinFree(),
 		"uptime": BuiltinUptime(),
+		"net":    BuiltinNetwork(),
+
+		"security-updates": BuiltinSecurityUpdates(),
+		"os-release":       BuiltinOSRelease(),
 	}
 }
 
@@ -53,3 +57,41 @@ func BuiltinUptime() *OutputParser {
 		},
 	}
 }
+
+// BuiltinNetwork parses "ip -br addr show" output.
+// Fields: interface, state, ipv4
+// Extracts from the first interface with an IPv4 address outside 127.0.0.0/8.
+func BuiltinNetwork() *OutputParser {
+	return &OutputParser{
+		rules: []rule{
+			{field: "interface", re: regexp.MustCompile(`(?m)^(\S+)[ \t]+\S+[ \t]+(?:\S+[ \t]+)*?` + nonLoopbackIPv4 + `/\d+`)},
+			{field: "state", re: regexp.MustCompile(`(?m)^\S+[ \t]+(\S+)[ \t]+(?:\S+[ \t]+)*?` + nonLoopbackIPv4 + `/\d+`)},
+			{field: "ipv4", re: regexp.MustCompile(`(?m)^\S+[ \t]+\S+[ \t]+(?:\S+[ \t]+)*?(` + nonLoopbackIPv4 + `)/\d+`)},
+		},
+	}
+}
+
+// nonLoopbackIPv4 matches a dotted IPv4 address whose first octet is not 127.
+const nonLoopbackIPv4 = `(?:2\d\d|1[013-9]\d|12[0-68-9]|[1-9]?\d)\.\d+\.\d+\.\d+`
+
+// BuiltinSecurityUpdates parses the output of the security-updates recipe.
+// Fields: count
+func BuiltinSecurityUpdates() *OutputParser {
+	return &OutputParser{
+		rules: []rule{
+			{field: "count", re: regexp.MustCompile(`(?m)^security updates:\s+(\d+)\s*$`)},
+		},
+	}
+}
+
+// BuiltinOSRelease parses /etc/os-release.
+// Fields: id, version_id, pretty_name
+func BuiltinOSRelease() *OutputParser {
+	return &OutputParser{
+		rules: []rule{
+			{field: "id", re: regexp.MustCompile(`(?m)^ID="?([^"\n]+)"?\s*$`)},
+			{field: "version_id", re: regexp.MustCompile(`(?m)^VERSION_ID="?([^"\n]+)"?\s*$`)},
+			{field: "pretty_name", re: regexp.MustCompile(`(?m)^PRETTY_NAME="?([^"\n]+)"?\s*$`)},
+		},
+	}
+}
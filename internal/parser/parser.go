@@ -3,7 +3,11 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/agent462/herd/internal/config"
 	"github.com/agent462/herd/internal/executor"
@@ -24,9 +28,12 @@ type HostParsed struct {
 
 // rule is a compiled extract rule.
 type rule struct {
-	field  string
-	re     *regexp.Regexp // nil if using column mode
-	column int            // 0 if using regex mode (1-based when set)
+	field     string
+	re        *regexp.Regexp // nil if using column mode
+	column    int            // 0 if using regex mode (1-based when set)
+	columnEnd int            // last column to join; -1 for a single column, 0 for through the last
+	delimiter string         // "" splits on whitespace
+	noHeader  bool           // the first line is data, not a header
 }
 
 // OutputParser extracts structured fields from command output.
@@ -39,17 +46,23 @@ type OutputParser struct {
 func New(rules []config.ExtractRule) (*OutputParser, error) {
 	compiled := make([]rule, 0, len(rules))
 	for _, r := range rules {
-		cr := rule{field: r.Field}
+		cr := rule{field: r.Field, columnEnd: -1, delimiter: r.Delimiter, noHeader: r.NoHeader}
 		if r.Pattern != "" {
 			re, err := regexp.Compile(r.Pattern)
 			if err != nil {
 				return nil, fmt.Errorf("invalid regex for field %q: %w", r.Field, err)
 			}
 			cr.re = re
+		} else if r.ColumnRange != "" {
+			start, end, err := config.ParseColumnRange(r.ColumnRange)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", r.Field, err)
+			}
+			cr.column, cr.columnEnd = start, end
 		} else if r.Column > 0 {
 			cr.column = r.Column
 		} else {
-			return nil, fmt.Errorf("rule for field %q must have pattern or column", r.Field)
+			return nil, fmt.Errorf("rule for field %q must have pattern or column (or column_range)", r.Field)
 		}
 		compiled = append(compiled, cr)
 	}
@@ -73,7 +86,7 @@ func (p *OutputParser) Parse(host string, stdout []byte) *HostParsed {
 				value = matches[1]
 			}
 		} else if r.column > 0 {
-			value = extractColumn(text, r.column)
+			value = extractColumns(text, r)
 		}
 		hp.Fields = append(hp.Fields, FieldValue{Field: r.field, Value: value})
 	}
@@ -81,22 +94,58 @@ func (p *OutputParser) Parse(host string, stdout []byte) *HostParsed {
 	return hp
 }
 
-// extractColumn splits text into lines, finds the first non-empty data line
-// (skipping the first line as a header), splits by whitespace, and returns
-// the column at the given 1-based index.
-func extractColumn(text string, col int) string {
+// Key returns the extracted field values of r's stdout joined by NUL bytes.
+// It can be passed to grouper.GroupBy to group hosts by parsed fields rather
+// than by their full output.
+func (p *OutputParser) Key(r *executor.HostResult) []byte {
+	hp := p.Parse(r.Host, r.Stdout)
+	var key []byte
+	for i, f := range hp.Fields {
+		if i > 0 {
+			key = append(key, 0)
+		}
+		key = append(key, f.Value...)
+	}
+	return key
+}
+
+// extractColumns returns the column, or range of columns, selected by r.
+// It skips the first line as a header, unless r.noHeader is set, and takes
+// the first non-empty data line. Without a delimiter it splits the line by
+// whitespace and joins a range with spaces; with one it splits on the
+// delimiter as cut does and joins a range with the delimiter. It returns
+// "-" when the line has too few columns.
+func extractColumns(text string, r rule) string {
 	lines := strings.Split(strings.TrimSpace(text), "\n")
-	// Skip the first line (header) and find the first non-empty data line.
-	for i := 1; i < len(lines); i++ {
+	first, sep := 1, " "
+	if r.noHeader {
+		first = 0
+	}
+	if r.delimiter != "" {
+		sep = r.delimiter
+	}
+	for i := first; i < len(lines); i++ {
 		line := strings.TrimSpace(lines[i])
 		if line == "" {
 			continue
 		}
-		fields := strings.Fields(line)
-		if col <= len(fields) {
-			return fields[col-1]
+		var fields []string
+		if r.delimiter != "" {
+			fields = strings.Split(line, r.delimiter)
+		} else {
+			fields = strings.Fields(line)
+		}
+		if r.column > len(fields) {
+			return "-"
+		}
+		end := r.columnEnd
+		switch {
+		case end < 0:
+			end = r.column
+		case end == 0 || end > len(fields):
+			end = len(fields)
 		}
-		return "-"
+		return strings.TrimSpace(strings.Join(fields[r.column-1:end], sep))
 	}
 	return "-"
 }
@@ -114,9 +163,123 @@ func (p *OutputParser) ParseAll(results []*executor.HostResult) []*HostParsed {
 	return parsed
 }
 
+// fieldIndex returns the position of field in the first result that has
+// it, or -1 if none does.
+func fieldIndex(parsed []*HostParsed, field string) int {
+	for _, hp := range parsed {
+		for i, fv := range hp.Fields {
+			if fv.Field == field {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// fieldValue returns hp's value at idx, or "" if it has no such field.
+func fieldValue(hp *HostParsed, idx int) string {
+	if idx < len(hp.Fields) {
+		return hp.Fields[idx].Value
+	}
+	return ""
+}
+
+// sizeUnits maps size suffixes to multipliers. Like df -h and free -h,
+// single-letter suffixes are powers of 1024.
+var sizeUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+	"P": 1 << 50,
+}
+
+// parseNumber parses a plain number, a percentage ("42%" is 42), or a size
+// with a unit suffix such as "9G", "512Mi", "1.5GiB" or "300k", returning
+// the size in bytes. It reports false for anything else, including "-".
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if !numericValue.MatchString(s) {
+		return 0, false
+	}
+	num := strings.TrimRight(s, "%KMGTPkmgtpiB")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	unit := strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(s[len(num):], "B"), "i"))
+	if unit == "%" {
+		return n, true
+	}
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, false
+	}
+	return n * mult, true
+}
+
 // FormatTable renders parsed results as a formatted ASCII table with column alignment.
 // If color is true, use ANSI codes for the header.
 func FormatTable(parsed []*HostParsed, color bool) string {
+	return formatTable(parsed, color, 0, false)
+}
+
+// FormatTableSorted renders parsed results like FormatTable, with rows
+// ordered by sortField: ascending, or descending if desc is true. Numbers,
+// sizes and percentages compare numerically (so "9G" sorts before "10G")
+// and come before non-numeric values, which compare as strings. Hosts with
+// no value ("-") come last either way, and ties keep host order. If no
+// result has sortField, rows keep host order. parsed itself is not
+// reordered.
+func FormatTableSorted(parsed []*HostParsed, color bool, sortField string, desc bool) string {
+	sorted := slices.Clone(parsed)
+	if idx := fieldIndex(sorted, sortField); idx >= 0 {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return lessValue(fieldValue(sorted[i], idx), fieldValue(sorted[j], idx), desc)
+		})
+	}
+	return formatTable(sorted, color, 0, false)
+}
+
+// lessValue reports whether field value a sorts before b in the order
+// FormatTableSorted describes.
+func lessValue(a, b string, desc bool) bool {
+	aMissing, bMissing := a == "" || a == "-", b == "" || b == "-"
+	if aMissing || bMissing {
+		return !aMissing
+	}
+	an, aOK := parseNumber(a)
+	bn, bOK := parseNumber(b)
+	if aOK != bOK {
+		return aOK
+	}
+	if desc {
+		a, b, an, bn = b, a, bn, an
+	}
+	if aOK {
+		return an < bn
+	}
+	return a < b
+}
+
+// FormatTableWidth renders parsed results like FormatTable, fitted to width
+// terminal columns: the widest columns are narrowed until the table fits,
+// and cells too long for their column are cut short with "…". Columns whose
+// values are all numbers, optionally with a "%" or size suffix such as
+// "50G", are right-aligned. A width of 0 or less never truncates.
+func FormatTableWidth(parsed []*HostParsed, color bool, width int) string {
+	return formatTable(parsed, color, width, true)
+}
+
+// minColumnWidth is the narrowest FormatTableWidth makes a column, so every
+// cell keeps a few characters before its ellipsis.
+const minColumnWidth = 4
+
+// numericValue matches values FormatTableWidth right-aligns.
+var numericValue = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?(%|[KMGTPkmgtp]i?B?)?$`)
+
+func formatTable(parsed []*HostParsed, color bool, width int, alignNumbers bool) string {
 	if len(parsed) == 0 {
 		return ""
 	}
@@ -126,20 +289,48 @@ func FormatTable(parsed []*HostParsed, color bool) string {
 	for _, fv := range parsed[0].Fields {
 		headers = append(headers, strings.ToUpper(fv.Field))
 	}
+	rows := make([][]string, len(parsed))
+	for i, hp := range parsed {
+		rows[i] = []string{hp.Host}
+		for _, fv := range hp.Fields {
+			rows[i] = append(rows[i], fv.Value)
+		}
+	}
 
 	// Calculate max widths.
 	widths := make([]int, len(headers))
 	for i, h := range headers {
-		widths[i] = len(h)
+		widths[i] = utf8.RuneCountInString(h)
 	}
-	for _, hp := range parsed {
-		if len(hp.Host) > widths[0] {
-			widths[0] = len(hp.Host)
+	for _, row := range rows {
+		for i, v := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(v))
+			}
 		}
-		for i, fv := range hp.Fields {
-			if len(fv.Value) > widths[i+1] {
-				widths[i+1] = len(fv.Value)
+	}
+	if width > 0 {
+		fitWidths(widths, width)
+	}
+
+	// A column is numeric when it has at least one value and every value
+	// is a number; "-" marks a missing value.
+	rightAlign := make([]bool, len(headers))
+	if alignNumbers {
+		for i := 1; i < len(headers); i++ {
+			seen := false
+			rightAlign[i] = true
+			for _, row := range rows {
+				if i >= len(row) || row[i] == "" || row[i] == "-" {
+					continue
+				}
+				seen = true
+				if !numericValue.MatchString(row[i]) {
+					rightAlign[i] = false
+					break
+				}
 			}
+			rightAlign[i] = rightAlign[i] && seen
 		}
 	}
 
@@ -149,7 +340,16 @@ func FormatTable(parsed []*HostParsed, color bool) string {
 	formatRow := func(values []string) string {
 		parts := make([]string, len(values))
 		for i, v := range values {
-			parts[i] = fmt.Sprintf("%-*s", widths[i], v)
+			if i >= len(widths) {
+				parts[i] = v
+				continue
+			}
+			v = Truncate(v, widths[i])
+			if rightAlign[i] {
+				parts[i] = fmt.Sprintf("%*s", widths[i], v)
+			} else {
+				parts[i] = fmt.Sprintf("%-*s", widths[i], v)
+			}
 		}
 		return strings.Join(parts, "  ")
 	}
@@ -174,14 +374,47 @@ func FormatTable(parsed []*HostParsed, color bool) string {
 	sb.WriteString("\n")
 
 	// Write data rows.
-	for _, hp := range parsed {
-		values := []string{hp.Host}
-		for _, fv := range hp.Fields {
-			values = append(values, fv.Value)
-		}
-		sb.WriteString(formatRow(values))
+	for _, row := range rows {
+		sb.WriteString(formatRow(row))
 		sb.WriteString("\n")
 	}
 
 	return sb.String()
 }
+
+// fitWidths narrows the widest columns, one character at a time, until the
+// table, with two spaces between columns, fits in width. Columns are not
+// narrowed below minColumnWidth, so a table with many columns may still be
+// wider than width.
+func fitWidths(widths []int, width int) {
+	total := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	for total > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+// Truncate shortens s to width characters, ending it with "…" when
+// anything was cut.
+func Truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "…"
+}
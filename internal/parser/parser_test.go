@@ -5,9 +5,11 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/agent462/herd/internal/config"
 	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
 )
 
 func TestNewValidRegexRule(t *testing.T) {
@@ -188,6 +190,49 @@ val1 val2
 	}
 }
 
+func TestParseDelimiterAndRange(t *testing.T) {
+	passwd := "pi:x:1000:1000:Pi User:/home/pi:/bin/bash\n"
+	rules := []config.ExtractRule{
+		{Field: "home", Column: 6, Delimiter: ":", NoHeader: true},
+		{Field: "ids", ColumnRange: "3-4", Delimiter: ":", NoHeader: true},
+		{Field: "rest", ColumnRange: "6-", Delimiter: ":", NoHeader: true},
+		{Field: "clamped", ColumnRange: "6-99", Delimiter: ":", NoHeader: true},
+		{Field: "missing", ColumnRange: "9-10", Delimiter: ":", NoHeader: true},
+	}
+	p, err := New(rules)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	hp := p.Parse("host1", []byte(passwd))
+	want := []string{"/home/pi", "1000:1000", "/home/pi:/bin/bash", "/home/pi:/bin/bash", "-"}
+	for i, w := range want {
+		if hp.Fields[i].Value != w {
+			t.Errorf("%s = %q, want %q", hp.Fields[i].Field, hp.Fields[i].Value, w)
+		}
+	}
+
+	// Without a delimiter a range splits on whitespace, after the header.
+	p, err = New([]config.ExtractRule{{Field: "usage", ColumnRange: "2-4"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	df := "Filesystem      Size  Used Avail Use% Mounted on\n/dev/sda1        50G   20G   28G  42% /\n"
+	if got := p.Parse("host1", []byte(df)).Fields[0].Value; got != "50G 20G 28G" {
+		t.Errorf("usage = %q, want %q", got, "50G 20G 28G")
+	}
+
+	// Delimited output skips its header too.
+	p, err = New([]config.ExtractRule{{Field: "used", Column: 3, Delimiter: ","}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	csv := "name,total,used\nmem,8G,3G\n"
+	if got := p.Parse("host1", []byte(csv)).Fields[0].Value; got != "3G" {
+		t.Errorf("used = %q, want %q", got, "3G")
+	}
+}
+
 func TestParseAll(t *testing.T) {
 	rules := []config.ExtractRule{
 		{Field: "val", Pattern: `result:\s+(\S+)`},
@@ -327,6 +372,51 @@ func TestFormatTableAlignment(t *testing.T) {
 	}
 }
 
+func TestFormatTableWidth(t *testing.T) {
+	parsed := []*HostParsed{
+		{
+			Host:   "web-01.us-east-1.prod.example.com",
+			Fields: []FieldValue{{Field: "use", Value: "85%"}, {Field: "mount", Value: "/var/lib/docker/overlay2"}},
+		},
+		{
+			Host:   "db-01",
+			Fields: []FieldValue{{Field: "use", Value: "7%"}, {Field: "mount", Value: "/"}},
+		},
+	}
+
+	output := FormatTableWidth(parsed, false, 40)
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d:\n%s", len(lines), output)
+	}
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n > 40 {
+			t.Errorf("line is %d columns, want at most 40: %q", n, line)
+		}
+	}
+	if !strings.Contains(lines[2], "…") {
+		t.Errorf("long host should be truncated with an ellipsis: %q", lines[2])
+	}
+	// The numeric USE column is right-aligned.
+	if !strings.Contains(lines[3], "   7%") {
+		t.Errorf("numeric column should be right-aligned: %q", lines[3])
+	}
+
+	// Without a width nothing is truncated.
+	if output := FormatTableWidth(parsed, true, 0); !strings.Contains(output, "web-01.us-east-1.prod.example.com") || !strings.Contains(output, "\033[1;36m") {
+		t.Errorf("unlimited width should keep full values and the colored header:\n%s", output)
+	}
+}
+
+func TestFormatTableWidthMinimum(t *testing.T) {
+	parsed := []*HostParsed{{Host: "host-with-long-name", Fields: []FieldValue{{Field: "a", Value: "value"}}}}
+	output := FormatTableWidth(parsed, false, 5)
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if got := lines[2]; got != "hos…  val…" {
+		t.Errorf("row = %q, want columns narrowed no further than %d", got, minColumnWidth)
+	}
+}
+
 // --- Built-in parser tests ---
 
 func TestBuiltinDisk(t *testing.T) {
@@ -422,10 +512,186 @@ func TestBuiltinUptime(t *testing.T) {
 	}
 }
 
+func TestBuiltinNetwork(t *testing.T) {
+	ipOutput := `lo               UNKNOWN        127.0.0.1/8 ::1/128
+eth0             DOWN
+wlan0            UP             192.168.1.42/24 fe80::ba27:ebff:fe12:3456/64
+docker0          DOWN           172.17.0.1/16
+`
+	p := BuiltinNetwork()
+	hp := p.Parse("server1", []byte(ipOutput))
+
+	expected := map[string]string{
+		"interface": "wlan0",
+		"state":     "UP",
+		"ipv4":      "192.168.1.42",
+	}
+
+	if len(hp.Fields) != len(expected) {
+		t.Fatalf("expected %d fields, got %d", len(expected), len(hp.Fields))
+	}
+
+	for _, fv := range hp.Fields {
+		want, ok := expected[fv.Field]
+		if !ok {
+			t.Errorf("unexpected field %q", fv.Field)
+			continue
+		}
+		if fv.Value != want {
+			t.Errorf("field %q: got %q, want %q", fv.Field, fv.Value, want)
+		}
+	}
+
+	// Only loopback configured: no match.
+	hp = p.Parse("server2", []byte("lo               UNKNOWN        127.0.0.1/8 ::1/128\n"))
+	for _, fv := range hp.Fields {
+		if fv.Value != "-" {
+			t.Errorf("loopback only: field %q = %q, want -", fv.Field, fv.Value)
+		}
+	}
+}
+
+func TestBuiltinSecurityUpdates(t *testing.T) {
+	p := BuiltinSecurityUpdates()
+
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"security updates: 12\n", "12"},
+		{"security updates: 0\n", "0"},
+		{"security updates: unknown\n", "-"},
+		{"", "-"},
+	}
+	for _, tt := range tests {
+		hp := p.Parse("server1", []byte(tt.output))
+		if got := hp.Fields[0].Value; got != tt.want {
+			t.Errorf("Parse(%q) count = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinOSRelease(t *testing.T) {
+	osRelease := `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+NAME="Debian GNU/Linux"
+VERSION_ID="12"
+VERSION="12 (bookworm)"
+ID=debian
+`
+	hp := BuiltinOSRelease().Parse("server1", []byte(osRelease))
+
+	expected := map[string]string{
+		"id":          "debian",
+		"version_id":  "12",
+		"pretty_name": "Debian GNU/Linux 12 (bookworm)",
+	}
+	for _, fv := range hp.Fields {
+		if want := expected[fv.Field]; fv.Value != want {
+			t.Errorf("field %q: got %q, want %q", fv.Field, fv.Value, want)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"42", 42, true},
+		{"42%", 42, true},
+		{"-1.5", -1.5, true},
+		{"9G", 9 << 30, true},
+		{"512Mi", 512 << 20, true},
+		{"1.5GiB", 1.5 * (1 << 30), true},
+		{"300k", 300 << 10, true},
+		{"-", 0, false},
+		{"eth0", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNumber(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormatTableSorted(t *testing.T) {
+	row := func(host, size, pct string) *HostParsed {
+		return &HostParsed{Host: host, Fields: []FieldValue{{Field: "size", Value: size}, {Field: "use_pct", Value: pct}}}
+	}
+	parsed := []*HostParsed{
+		row("a", "10G", "9%"),
+		row("b", "-", "-"),
+		row("c", "9G", "42%"),
+		row("d", "512M", "100%"),
+	}
+	hostOrder := func(table string) string {
+		var hosts []string
+		for _, line := range strings.Split(strings.TrimSpace(table), "\n")[2:] {
+			hosts = append(hosts, strings.Fields(line)[0])
+		}
+		return strings.Join(hosts, ",")
+	}
+
+	tests := []struct {
+		field string
+		desc  bool
+		want  string
+	}{
+		{"size", false, "d,c,a,b"},
+		{"size", true, "a,c,d,b"},
+		{"use_pct", true, "d,c,a,b"},
+		{"use_pct", false, "a,c,d,b"},
+		{"missing", true, "a,b,c,d"},
+	}
+	for _, tt := range tests {
+		if got := hostOrder(FormatTableSorted(parsed, false, tt.field, tt.desc)); got != tt.want {
+			t.Errorf("FormatTableSorted(%s, desc=%v) order = %s, want %s", tt.field, tt.desc, got, tt.want)
+		}
+	}
+	if parsed[0].Host != "a" || parsed[1].Host != "b" {
+		t.Error("FormatTableSorted reordered its input")
+	}
+
+	// A column mixing numbers and words puts the numbers first either way.
+	mixed := []*HostParsed{
+		row("a", "n/a", "-"),
+		row("b", "10G", "-"),
+		row("c", "-", "-"),
+		row("d", "auto", "-"),
+		row("e", "9G", "-"),
+	}
+	for _, tt := range []struct {
+		desc bool
+		want string
+	}{
+		{false, "e,b,d,a,c"},
+		{true, "b,e,a,d,c"},
+	} {
+		if got := hostOrder(FormatTableSorted(mixed, false, "size", tt.desc)); got != tt.want {
+			t.Errorf("mixed column, desc=%v: order = %s, want %s", tt.desc, got, tt.want)
+		}
+	}
+
+	// Equal values keep host order.
+	counts := []*HostParsed{
+		row("a", "3", "-"),
+		row("b", "-", "-"),
+		row("c", "12", "-"),
+		row("d", "3", "-"),
+		row("e", "0", "-"),
+	}
+	if got := hostOrder(FormatTableSorted(counts, false, "size", true)); got != "c,a,d,e,b" {
+		t.Errorf("ties: order = %s, want c,a,d,e,b", got)
+	}
+}
+
 func TestBuiltinParsersMap(t *testing.T) {
 	parsers := BuiltinParsers()
 
-	expectedNames := []string{"disk", "free", "uptime"}
+	expectedNames := []string{"disk", "free", "uptime", "net", "security-updates", "os-release"}
 	for _, name := range expectedNames {
 		if _, ok := parsers[name]; !ok {
 			t.Errorf("BuiltinParsers() missing %q", name)
@@ -435,3 +701,26 @@ func TestBuiltinParsersMap(t *testing.T) {
 		t.Errorf("expected %d built-in parsers, got %d", len(expectedNames), len(parsers))
 	}
 }
+
+func TestKeyGroupsByParsedField(t *testing.T) {
+	p, err := New([]config.ExtractRule{
+		{Field: "kernel", Pattern: `Linux \S+ (\S+)`},
+	})
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("Linux pi-a 6.1.0-rpi7 #1 SMP Mon Jan 1\n")},
+		{Host: "b", Stdout: []byte("Linux pi-b 6.1.0-rpi7 #1 SMP Tue Feb 2\n")},
+		{Host: "c", Stdout: []byte("Linux pi-c 5.15.0-rpi2 #1 SMP Wed Mar 3\n")},
+	}
+
+	gr := grouper.GroupBy(results, p.Key)
+	if len(gr.Groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(gr.Groups))
+	}
+	if got := strings.Join(gr.Groups[0].Hosts, ","); got != "a,b" {
+		t.Errorf("norm hosts = %s, want a,b", got)
+	}
+}
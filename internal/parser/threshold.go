@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Threshold is a numeric limit on a parsed field, such as "> 90". A value
+// breaches the threshold when the comparison holds.
+type Threshold struct {
+	Op    string // one of >, >=, <, <=, ==, !=
+	Limit float64
+}
+
+// thresholdOps lists the comparison operators, two-character ones first so
+// they win over their one-character prefixes when parsing.
+var thresholdOps = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// ParseThreshold parses an expression like "use_pct>90" into its field name
+// and threshold.
+func ParseThreshold(expr string) (string, Threshold, error) {
+	for _, op := range thresholdOps {
+		field, limit, ok := strings.Cut(expr, op)
+		if !ok {
+			continue
+		}
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return "", Threshold{}, fmt.Errorf("threshold %q: missing field name", expr)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(limit), "%"), 64)
+		if err != nil {
+			return "", Threshold{}, fmt.Errorf("threshold %q: limit must be a number", expr)
+		}
+		return field, Threshold{Op: op, Limit: n}, nil
+	}
+	return "", Threshold{}, fmt.Errorf("threshold %q: expected field, operator and limit (e.g. use_pct>90)", expr)
+}
+
+// Breached reports whether v crosses the threshold.
+func (t Threshold) Breached(v float64) bool {
+	switch t.Op {
+	case ">":
+		return v > t.Limit
+	case ">=":
+		return v >= t.Limit
+	case "<":
+		return v < t.Limit
+	case "<=":
+		return v <= t.Limit
+	case "==":
+		return v == t.Limit
+	case "!=":
+		return v != t.Limit
+	}
+	return false
+}
+
+func (t Threshold) String() string {
+	return t.Op + " " + strconv.FormatFloat(t.Limit, 'f', -1, 64)
+}
+
+// Alert records a host whose parsed field breached a threshold.
+type Alert struct {
+	Host      string
+	Field     string
+	Value     string
+	Threshold Threshold
+}
+
+// CheckThresholds returns an Alert for every parsed field value that
+// breaches its threshold, in host order and then field order. Values are
+// compared numerically, ignoring a trailing "%"; hosts that failed to parse
+// and non-numeric values never alert.
+func CheckThresholds(parsed []*HostParsed, thresholds map[string]Threshold) []Alert {
+	var alerts []Alert
+	for _, hp := range parsed {
+		if hp.Err != nil {
+			continue
+		}
+		for _, fv := range hp.Fields {
+			t, ok := thresholds[fv.Field]
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseFloat(strings.TrimSuffix(fv.Value, "%"), 64)
+			if err != nil || !t.Breached(n) {
+				continue
+			}
+			alerts = append(alerts, Alert{Host: hp.Host, Field: fv.Field, Value: fv.Value, Threshold: t})
+		}
+	}
+	return alerts
+}
+
+// FormatAlerts renders alerts as a prominent list, one line per breach, or
+// a short all-clear line when there are none. If color is true, alerts are
+// shown in red and the all-clear in green.
+func FormatAlerts(alerts []Alert, color bool) string {
+	paint := func(s, code string) string {
+		if !color {
+			return s
+		}
+		return code + s + "\033[0m"
+	}
+	if len(alerts) == 0 {
+		return paint("no thresholds breached", "\033[32m") + "\n"
+	}
+
+	hostW, fieldW := 0, 0
+	for _, a := range alerts {
+		hostW = max(hostW, len(a.Host))
+		fieldW = max(fieldW, len(a.Field))
+	}
+
+	var sb strings.Builder
+	noun := "alerts"
+	if len(alerts) == 1 {
+		noun = "alert"
+	}
+	sb.WriteString(paint(fmt.Sprintf(" %d %s:", len(alerts), noun), "\033[1;31m"))
+	sb.WriteString("\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&sb, "   %-*s  %-*s  %s (%s)\n", hostW, a.Host, fieldW, a.Field, paint(a.Value, "\033[31m"), a.Threshold)
+	}
+	return sb.String()
+}
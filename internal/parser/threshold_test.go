@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr    string
+		field   string
+		want    Threshold
+		wantErr bool
+	}{
+		{expr: "use_pct>90", field: "use_pct", want: Threshold{Op: ">", Limit: 90}},
+		{expr: "use_pct >= 90%", field: "use_pct", want: Threshold{Op: ">=", Limit: 90}},
+		{expr: "avail<1.5", field: "avail", want: Threshold{Op: "<", Limit: 1.5}},
+		{expr: "load<=0", field: "load", want: Threshold{Op: "<=", Limit: 0}},
+		{expr: "count!=3", field: "count", want: Threshold{Op: "!=", Limit: 3}},
+		{expr: "count==3", field: "count", want: Threshold{Op: "==", Limit: 3}},
+		{expr: "use_pct", wantErr: true},
+		{expr: ">90", wantErr: true},
+		{expr: "use_pct>high", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			field, th, err := ParseThreshold(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s %v", field, th)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if field != tt.field || th != tt.want {
+				t.Errorf("got %q %v, want %q %v", field, th, tt.field, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	parsed := []*HostParsed{
+		{Host: "web-1", Fields: []FieldValue{{"use_pct", "93%"}, {"avail", "2G"}}},
+		{Host: "web-2", Fields: []FieldValue{{"use_pct", "40%"}, {"avail", "20G"}}},
+		{Host: "web-3", Fields: []FieldValue{{"use_pct", "-"}, {"avail", "-"}}},
+		{Host: "web-4", Err: errors.New("boom")},
+		{Host: "web-5", Fields: []FieldValue{{"use_pct", "90%"}, {"avail", "1"}}},
+	}
+	alerts := CheckThresholds(parsed, map[string]Threshold{
+		"use_pct": {Op: ">", Limit: 90},
+		"avail":   {Op: "<", Limit: 5},
+	})
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2: %+v", len(alerts), alerts)
+	}
+	if a := alerts[0]; a.Host != "web-1" || a.Field != "use_pct" || a.Value != "93%" {
+		t.Errorf("alerts[0] = %+v", a)
+	}
+	if a := alerts[1]; a.Host != "web-5" || a.Field != "avail" {
+		t.Errorf("alerts[1] = %+v, want web-5 avail", a)
+	}
+}
+
+func TestFormatAlerts(t *testing.T) {
+	out := FormatAlerts([]Alert{
+		{Host: "web-1", Field: "use_pct", Value: "93%", Threshold: Threshold{">", 90}},
+	}, false)
+	if !strings.Contains(out, "1 alert:") || !strings.Contains(out, "web-1  use_pct  93% (> 90)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if out := FormatAlerts(nil, false); out != "no thresholds breached\n" {
+		t.Errorf("empty output = %q", out)
+	}
+}
@@ -1,6 +1,12 @@
 package recipe
 
-import "github.com/agent462/herd/internal/config"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agent462/herd/internal/config"
+)
 
 // BuiltinRecipes returns all built-in recipes keyed by name.
 func BuiltinRecipes() map[string]config.Recipe {
@@ -13,6 +19,8 @@ func BuiltinRecipes() map[string]config.Recipe {
 		"user-audit":    builtinUserAudit(),
 		"log-tail":      builtinLogTail(),
 		"os-version":    builtinOSVersion(),
+
+		"security-updates": builtinSecurityUpdates(),
 	}
 }
 
@@ -22,10 +30,14 @@ func IsBuiltin(name string) bool {
 	return ok
 }
 
-// ResolveRecipe looks up a recipe by name. User-defined recipes in cfg
+// ResolveRecipe looks up a recipe by name, or by an abbreviation that
+// ExpandName resolves to a single recipe. User-defined recipes in cfg
 // override built-ins. Returns the recipe, whether a built-in exists for
 // that name, and whether the recipe was found at all.
 func ResolveRecipe(name string, cfg *config.Config) (config.Recipe, bool, bool) {
+	if full, err := ExpandName(name, cfg); err == nil {
+		name = full
+	}
 	_, isBuiltin := BuiltinRecipes()[name]
 
 	if cfg != nil {
@@ -41,6 +53,55 @@ func ResolveRecipe(name string, cfg *config.Config) (config.Recipe, bool, bool)
 	return config.Recipe{}, false, false
 }
 
+// ExpandName returns the full name of the recipe that name stands for, the
+// way git resolves abbreviated commands. An exact name is returned as is.
+// Otherwise name may be a prefix of one recipe name, such as "sec" for
+// "security-updates", or failing that its letters in order, such as "scup".
+// Several matches are reported as an ambiguous name, listing them; no match
+// returns name unchanged, so looking it up reports it as unknown.
+func ExpandName(name string, cfg *config.Config) (string, error) {
+	merged := MergedRecipes(cfg)
+	if _, ok := merged[name]; ok || name == "" {
+		return name, nil
+	}
+	names := make([]string, 0, len(merged))
+	for n := range merged {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var matches []string
+	for _, n := range names {
+		if strings.HasPrefix(n, name) {
+			matches = append(matches, n)
+		}
+	}
+	if len(matches) == 0 {
+		for _, n := range names {
+			if isSubsequence(name, n) {
+				matches = append(matches, n)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return name, nil
+	case 1:
+		return matches[0], nil
+	}
+	return name, fmt.Errorf("recipe %q is ambiguous: could be %s", name, strings.Join(matches, ", "))
+}
+
+// isSubsequence reports whether every byte of s appears in t in order.
+func isSubsequence(s, t string) bool {
+	for i := 0; i < len(t) && len(s) > 0; i++ {
+		if t[i] == s[0] {
+			s = s[1:]
+		}
+	}
+	return s == ""
+}
+
 // MergedRecipes returns built-in recipes merged with user-defined recipes.
 // User recipes override built-ins with the same name.
 func MergedRecipes(cfg *config.Config) map[string]config.Recipe {
@@ -61,68 +122,83 @@ func MergedRecipes(cfg *config.Config) map[string]config.Recipe {
 func builtinDiskCheck() config.Recipe {
 	return config.Recipe{
 		Description: "Check disk usage on root filesystem",
-		Steps:       []string{"df -h /"},
+		Steps:       config.PlainSteps("df -h /"),
 	}
 }
 
 func builtinUptime() config.Recipe {
 	return config.Recipe{
 		Description: "Show uptime and load averages",
-		Steps:       []string{"uptime"},
+		Steps:       config.PlainSteps("uptime"),
 	}
 }
 
 func builtinRebootCheck() config.Recipe {
 	return config.Recipe{
 		Description: "Check if hosts require a reboot",
-		Steps: []string{
+		Steps: config.PlainSteps(
 			`test -f /var/run/reboot-required && echo "REBOOT REQUIRED" || echo "no reboot needed"`,
-		},
+		),
 	}
 }
 
 func builtinServiceCheck() config.Recipe {
 	return config.Recipe{
 		Description: "Check sshd status; drill into hosts that differ",
-		Steps: []string{
+		Steps: config.PlainSteps(
 			"systemctl is-active sshd",
 			"@differs systemctl status sshd --no-pager",
-		},
+		),
 	}
 }
 
 func builtinPortCheck() config.Recipe {
 	return config.Recipe{
 		Description: "List listening TCP ports (ss with netstat fallback)",
-		Steps: []string{
+		Steps: config.PlainSteps(
 			"ss -tlnp 2>/dev/null || netstat -tlnp 2>/dev/null",
-		},
+		),
 	}
 }
 
 func builtinUserAudit() config.Recipe {
 	return config.Recipe{
 		Description: "List users with login shells",
-		Steps: []string{
+		Steps: config.PlainSteps(
 			`grep -v -e '/nologin$' -e '/false$' /etc/passwd | cut -d: -f1,7`,
-		},
+		),
 	}
 }
 
 func builtinLogTail() config.Recipe {
 	return config.Recipe{
 		Description: "Show recent error log entries",
-		Steps: []string{
+		Steps: config.PlainSteps(
 			"journalctl -p err --no-pager -n 20 2>/dev/null || tail -20 /var/log/syslog 2>/dev/null || tail -20 /var/log/messages",
-		},
+		),
 	}
 }
 
 func builtinOSVersion() config.Recipe {
 	return config.Recipe{
 		Description: "Show OS version across fleet",
-		Steps: []string{
+		Steps: config.PlainSteps(
 			`grep PRETTY_NAME /etc/os-release 2>/dev/null | cut -d= -f2 | tr -d '"' || uname -sr`,
-		},
+		),
+	}
+}
+
+// builtinSecurityUpdates prints "security updates: N" so the output pairs
+// with the security-updates parser. Hosts without apt, dnf or yum report
+// "unknown".
+func builtinSecurityUpdates() config.Recipe {
+	return config.Recipe{
+		Description: "Count pending security updates (apt, dnf or yum)",
+		Steps: config.PlainSteps(
+			`if command -v apt-get >/dev/null 2>&1; then n=$(apt-get -s upgrade 2>/dev/null | grep '^Inst' | grep -ci security); ` +
+				`elif command -v dnf >/dev/null 2>&1; then n=$(dnf -q updateinfo list --security 2>/dev/null | grep -c .); ` +
+				`elif command -v yum >/dev/null 2>&1; then n=$(yum -q updateinfo list security 2>/dev/null | grep -c .); ` +
+				`else n=unknown; fi; echo "security updates: $n"`,
+		),
 	}
 }
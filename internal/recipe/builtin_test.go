@@ -1,6 +1,7 @@
 package recipe
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/agent462/herd/internal/config"
@@ -15,6 +16,7 @@ var expectedBuiltins = []string{
 	"user-audit",
 	"log-tail",
 	"os-version",
+	"security-updates",
 }
 
 func TestBuiltinRecipes_AllPresent(t *testing.T) {
@@ -72,7 +74,7 @@ func TestResolveRecipe_BuiltinFound(t *testing.T) {
 func TestResolveRecipe_UserOverridesBuiltin(t *testing.T) {
 	userRecipe := config.Recipe{
 		Description: "my custom uptime",
-		Steps:       []string{"uptime -s"},
+		Steps:       config.PlainSteps("uptime -s"),
 	}
 	cfg := &config.Config{
 		Recipes: map[string]config.Recipe{
@@ -90,7 +92,7 @@ func TestResolveRecipe_UserOverridesBuiltin(t *testing.T) {
 	if r.Description != "my custom uptime" {
 		t.Errorf("expected user description, got %q", r.Description)
 	}
-	if len(r.Steps) != 1 || r.Steps[0] != "uptime -s" {
+	if len(r.Steps) != 1 || r.Steps[0].Command != "uptime -s" {
 		t.Errorf("expected user steps, got %v", r.Steps)
 	}
 }
@@ -98,7 +100,7 @@ func TestResolveRecipe_UserOverridesBuiltin(t *testing.T) {
 func TestResolveRecipe_UserOnly(t *testing.T) {
 	userRecipe := config.Recipe{
 		Description: "custom recipe",
-		Steps:       []string{"echo hi"},
+		Steps:       config.PlainSteps("echo hi"),
 	}
 	cfg := &config.Config{
 		Recipes: map[string]config.Recipe{
@@ -152,7 +154,7 @@ func TestMergedRecipes_ContainsBoth(t *testing.T) {
 		Recipes: map[string]config.Recipe{
 			"my-recipe": {
 				Description: "user recipe",
-				Steps:       []string{"echo custom"},
+				Steps:       config.PlainSteps("echo custom"),
 			},
 		},
 	}
@@ -177,7 +179,7 @@ func TestMergedRecipes_UserOverrideWins(t *testing.T) {
 		Recipes: map[string]config.Recipe{
 			"uptime": {
 				Description: "overridden",
-				Steps:       []string{"uptime -s"},
+				Steps:       config.PlainSteps("uptime -s"),
 			},
 		},
 	}
@@ -199,10 +201,58 @@ func TestMergedRecipes_NilConfig(t *testing.T) {
 func TestBuiltinRecipes_StepsParse(t *testing.T) {
 	for name, r := range BuiltinRecipes() {
 		for i, raw := range r.Steps {
-			step := ParseStep(raw)
+			step := ParseStep(raw.String())
 			if step.Command == "" {
 				t.Errorf("recipe %q step %d: ParseStep produced empty command from %q", name, i, raw)
 			}
 		}
 	}
 }
+
+func TestExpandName(t *testing.T) {
+	cfg := &config.Config{
+		Recipes: map[string]config.Recipe{
+			"deploy":         {Steps: config.PlainSteps("git pull")},
+			"deploy-canary":  {Steps: config.PlainSteps("git pull")},
+			"rotate-secrets": {Steps: config.PlainSteps("rotate")},
+		},
+	}
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{"deploy", "deploy", ""},
+		{"deploy-c", "deploy-canary", ""},
+		{"sec", "security-updates", ""},
+		{"scup", "security-updates", ""},
+		{"rot", "rotate-secrets", ""},
+		{"dep", "dep", "could be deploy, deploy-canary"},
+		{"u", "u", "could be uptime, user-audit"},
+		{"nonexistent", "nonexistent", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandName(tt.name, cfg)
+			if got != tt.want {
+				t.Errorf("ExpandName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveRecipe_Abbreviation(t *testing.T) {
+	r, isBuiltin, found := ResolveRecipe("sec", nil)
+	if !found || !isBuiltin || r.Description != builtinSecurityUpdates().Description {
+		t.Errorf("ResolveRecipe(\"sec\") = %+v, %v, %v; want security-updates", r, isBuiltin, found)
+	}
+	if _, _, found := ResolveRecipe("u", nil); found {
+		t.Error("an ambiguous abbreviation should not be found")
+	}
+}
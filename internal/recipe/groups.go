@@ -0,0 +1,69 @@
+package recipe
+
+import (
+	"context"
+	"sync"
+
+	"github.com/agent462/herd/internal/config"
+	"github.com/agent462/herd/internal/executor"
+)
+
+// GroupRun holds the outcome of running a recipe against one group.
+type GroupRun struct {
+	Group   string
+	Hosts   []string
+	Results []StepResult
+	Err     error // host resolution, executor or step error; Results holds the steps that ran
+}
+
+// ExecutorFunc builds the executor that runs a recipe against a group's
+// resolved hosts, typically over a connection pool for those hosts, and the
+// Transferer for its push and pull steps. The executor must be built with
+// executor.WithLimiter(limiter), which every group shares. The Transferer
+// may be nil if the recipe has no transfer steps.
+type ExecutorFunc func(group string, hosts []config.Host, limiter *executor.Limiter) (*executor.Executor, Transferer, error)
+
+// RunAcrossGroups runs steps against each named group concurrently, keeping
+// the results of each group separate. Commands run on at most concurrency
+// hosts at once across all groups, not per group. Within a group the steps
+// run in sequence as with Runner.Run, so selectors like @failed refer to
+// that group's previous step only. Results are returned in the order of
+// groups.
+func RunAcrossGroups(ctx context.Context, cfg *config.Config, groups []string, steps []Step, concurrency int, newExec ExecutorFunc) []GroupRun {
+	limiter := executor.NewLimiter(concurrency)
+	runs := make([]GroupRun, len(groups))
+	var wg sync.WaitGroup
+	for i, name := range groups {
+		runs[i].Group = name
+		wg.Add(1)
+		go func(run *GroupRun) {
+			defer wg.Done()
+
+			hosts, err := config.ResolveHosts(cfg, run.Group, nil)
+			if err != nil {
+				run.Err = err
+				return
+			}
+			run.Hosts = make([]string, len(hosts))
+			tags := make(map[string][]string, len(hosts))
+			for j, h := range hosts {
+				run.Hosts[j] = h.Name
+				tags[h.Name] = h.Tags
+			}
+
+			exec, t, err := newExec(run.Group, hosts, limiter)
+			if err != nil {
+				run.Err = err
+				return
+			}
+			runner := New(exec, run.Hosts)
+			runner.SetHostTags(tags)
+			if t != nil {
+				runner.SetTransfer(t)
+			}
+			run.Results, run.Err = runner.Run(ctx, steps)
+		}(&runs[i])
+	}
+	wg.Wait()
+	return runs
+}
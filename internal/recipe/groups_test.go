@@ -0,0 +1,150 @@
+package recipe
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/config"
+	"github.com/agent462/herd/internal/executor"
+)
+
+func groupsConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Groups["dc1"] = config.Group{Hosts: []config.HostEntry{{Host: "a1"}, {Host: "a2"}}}
+	cfg.Groups["dc2"] = config.Group{Hosts: []config.HostEntry{{Host: "b1"}, {Host: "b2"}}}
+	return cfg
+}
+
+func TestRunAcrossGroups(t *testing.T) {
+	// Each group's first step waits until both groups have started, so the
+	// test only completes if the groups run concurrently.
+	var started sync.WaitGroup
+	started.Add(2)
+	release := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(release)
+	}()
+
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			if command == "deploy" && strings.HasSuffix(host, "1") {
+				started.Done()
+				select {
+				case <-release:
+				case <-time.After(5 * time.Second):
+					return &executor.HostResult{Host: host, Err: context.DeadlineExceeded}
+				}
+			}
+			exit := 0
+			if host == "a2" || host == "b1" {
+				exit = 1
+			}
+			return &executor.HostResult{Host: host, Stdout: []byte(command), ExitCode: exit}
+		},
+	}
+
+	var mu sync.Mutex
+	built := make(map[string]int)
+	newExec := func(group string, hosts []config.Host, limiter *executor.Limiter) (*executor.Executor, Transferer, error) {
+		mu.Lock()
+		built[group] = len(hosts)
+		mu.Unlock()
+		return executor.New(runner, executor.WithLimiter(limiter)), nil, nil
+	}
+
+	steps := []Step{ParseStep("deploy"), ParseStep("@failed status")}
+	runs := RunAcrossGroups(context.Background(), groupsConfig(), []string{"dc2", "dc1"}, steps, 2, newExec)
+
+	if len(runs) != 2 || runs[0].Group != "dc2" || runs[1].Group != "dc1" {
+		t.Fatalf("runs not in group order: %+v", runs)
+	}
+	if built["dc1"] != 2 || built["dc2"] != 2 {
+		t.Errorf("executors built with %v hosts, want 2 each", built)
+	}
+
+	// @failed in the second step must only see the group's own failures.
+	want := map[string]string{"dc1": "a2", "dc2": "b1"}
+	for _, run := range runs {
+		if run.Err != nil {
+			t.Fatalf("%s: unexpected error: %v", run.Group, run.Err)
+		}
+		if len(run.Results) != 2 {
+			t.Fatalf("%s: got %d step results, want 2", run.Group, len(run.Results))
+		}
+		hosts := run.Results[1].Hosts
+		if len(hosts) != 1 || hosts[0] != want[run.Group] {
+			t.Errorf("%s: @failed selected %v, want [%s]", run.Group, hosts, want[run.Group])
+		}
+		if run.Results[0].Results[0].Err != nil {
+			t.Errorf("%s: groups did not run concurrently", run.Group)
+		}
+	}
+}
+
+func TestRunAcrossGroups_UnknownGroup(t *testing.T) {
+	newExec := func(group string, hosts []config.Host, limiter *executor.Limiter) (*executor.Executor, Transferer, error) {
+		t.Errorf("executor built for unresolvable group %q", group)
+		return nil, nil, nil
+	}
+	runs := RunAcrossGroups(context.Background(), groupsConfig(), []string{"nope"}, []Step{ParseStep("uptime")}, 0, newExec)
+	if len(runs) != 1 || runs[0].Err == nil {
+		t.Fatalf("expected an error for unknown group, got %+v", runs)
+	}
+}
+
+func TestRunAcrossGroups_Tags(t *testing.T) {
+	cfg := groupsConfig()
+	cfg.Groups["dc1"] = config.Group{Hosts: []config.HostEntry{{Host: "a1", Tags: []string{"canary"}}, {Host: "a2"}}}
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			return &executor.HostResult{Host: host}
+		},
+	}
+	newExec := func(group string, hosts []config.Host, limiter *executor.Limiter) (*executor.Executor, Transferer, error) {
+		return executor.New(runner, executor.WithLimiter(limiter)), nil, nil
+	}
+
+	runs := RunAcrossGroups(context.Background(), cfg, []string{"dc1"}, []Step{ParseStep("@tag:canary uptime")}, 0, newExec)
+
+	if runs[0].Err != nil {
+		t.Fatalf("unexpected error: %v", runs[0].Err)
+	}
+	if hosts := runs[0].Results[0].Hosts; len(hosts) != 1 || hosts[0] != "a1" {
+		t.Errorf("@tag:canary selected %v, want [a1]", hosts)
+	}
+}
+
+func TestRunAcrossGroups_SharedConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			mu.Lock()
+			active++
+			peak = max(peak, active)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+			return &executor.HostResult{Host: host}
+		},
+	}
+	newExec := func(group string, hosts []config.Host, limiter *executor.Limiter) (*executor.Executor, Transferer, error) {
+		return executor.New(runner, executor.WithConcurrency(2), executor.WithLimiter(limiter)), nil, nil
+	}
+
+	runs := RunAcrossGroups(context.Background(), groupsConfig(), []string{"dc1", "dc2"}, []Step{ParseStep("uptime")}, 2, newExec)
+	for _, run := range runs {
+		if run.Err != nil {
+			t.Fatalf("%s: unexpected error: %v", run.Group, run.Err)
+		}
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrent hosts = %d across groups, want at most 2", peak)
+	}
+}
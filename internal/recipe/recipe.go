@@ -3,36 +3,205 @@ package recipe
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/agent462/herd/internal/config"
 	"github.com/agent462/herd/internal/executor"
 	"github.com/agent462/herd/internal/grouper"
 	"github.com/agent462/herd/internal/selector"
+	"github.com/agent462/herd/internal/transfer"
 )
 
 // Step represents a single command in a recipe, optionally scoped to a selector.
 type Step struct {
 	Selector string // "" means @all
 	Command  string
+	Transfer *Transfer // non-nil for push and pull steps; Command holds the directive
+
+	// RetryExitCodes lists exit codes that are known to be transient. A
+	// host exiting with one of them is rerun, up to Retries more times.
+	RetryExitCodes []int
+	Retries        int
+
+	// ExitMap sets the meaning of exit codes when grouping this step's
+	// results, e.g. {1: ok} for diff; see grouper.Options.ExitMap.
+	ExitMap map[int]grouper.ExitStatus
+
+	// StderrAsOutput, TrimWhitespace and CollapseSpaces normalize output
+	// before grouping; see the grouper.Options fields of the same names.
+	StderrAsOutput bool
+	TrimWhitespace bool
+	CollapseSpaces bool
+
+	// Masks are ignored when grouping this step's results; see
+	// grouper.Options.Masks.
+	Masks []*regexp.Regexp
+
+	// Timeout replaces the executor's per-host timeout for this step; 0
+	// keeps it and executor.NoTimeout runs the step without one.
+	Timeout time.Duration
+
+	// AbortOnFailure stops the recipe after this step if any host failed:
+	// a connection error, a timeout or an exit code that means failure.
+	AbortOnFailure bool
 }
 
 // StepResult holds the outcome of executing a single recipe step.
 type StepResult struct {
 	Step    Step
 	Hosts   []string
-	Results []*executor.HostResult
+	Results []*executor.HostResult // final attempt for each host
 	Grouped *grouper.GroupedResults
+	Retried map[string]int // host -> reruns, for hosts that were retried
+
+	// Elapsed is the step's wall-clock time, from resolving its hosts to
+	// the last host finishing, including retries. Each host's own time is
+	// its Results entry's Duration.
+	Elapsed time.Duration
+}
+
+// Slowest returns the result of the host that took longest, or nil if the
+// step ran on no hosts.
+func (sr StepResult) Slowest() *executor.HostResult {
+	var slowest *executor.HostResult
+	for _, hr := range sr.Results {
+		if slowest == nil || hr.Duration > slowest.Duration {
+			slowest = hr
+		}
+	}
+	return slowest
+}
+
+// StepPlan describes what a recipe step would do, as reported by DryRun.
+type StepPlan struct {
+	Index int // 1-based step number
+	Step  Step
+	Hosts []string // hosts the step would run on; nil if Unresolvable is set
+
+	// Unresolvable explains why Hosts could not be determined without
+	// running the earlier steps, e.g. because the selector uses @differs.
+	Unresolvable string
+}
+
+// Transfer is a file transfer run by a recipe step instead of a command.
+type Transfer struct {
+	Pull   bool   // false pushes Local to Remote; true pulls Remote into the Local directory
+	Local  string // local file to push, or directory to pull into (saved as Local/<host>/<name>)
+	Remote string // remote file
+}
+
+// Transferer runs the file transfers of push and pull steps. It is
+// implemented by *transfer.Executor.
+type Transferer interface {
+	Push(ctx context.Context, hosts []string, localPath, remotePath string, progressFn transfer.ProgressFunc) []*transfer.TransferResult
+	Pull(ctx context.Context, hosts []string, remotePath, localDir string, progressFn transfer.ProgressFunc) []*transfer.TransferResult
 }
 
 // ParseStep parses a raw step string into a Step using selector.ParseInput.
+// A command of the form "push local:<file> remote:<file>" or
+// "pull remote:<file> local:<dir>" becomes a transfer step.
 func ParseStep(raw string) Step {
 	sel, cmd := selector.ParseInput(raw)
-	return Step{Selector: sel, Command: cmd}
+	return Step{Selector: sel, Command: cmd, Transfer: parseTransfer(cmd)}
+}
+
+// parseTransfer returns the transfer described by a push or pull directive,
+// or nil if cmd is an ordinary command. Both paths must carry their local:
+// or remote: prefix, so commands that merely start with "push" still run.
+func parseTransfer(cmd string) *Transfer {
+	fields := strings.Fields(cmd)
+	if len(fields) != 3 {
+		return nil
+	}
+	var t Transfer
+	var src, dst string
+	switch fields[0] {
+	case "push":
+		src, dst = "local:", "remote:"
+	case "pull":
+		src, dst = "remote:", "local:"
+		t.Pull = true
+	default:
+		return nil
+	}
+	from, ok1 := strings.CutPrefix(fields[1], src)
+	to, ok2 := strings.CutPrefix(fields[2], dst)
+	if !ok1 || !ok2 || from == "" || to == "" {
+		return nil
+	}
+	if t.Pull {
+		t.Remote, t.Local = from, to
+	} else {
+		t.Local, t.Remote = from, to
+	}
+	return &t
+}
+
+// Steps parses a recipe's steps and applies their timeouts, failure
+// policies, retry rules and exit code mappings. A step's mapping comes from
+// its own exit_status if set, and otherwise from
+// cfg's per-command defaults, which also supply the output normalization
+// settings; cfg may be nil.
+func Steps(cfg *config.Config, rec config.Recipe) []Step {
+	steps := make([]Step, len(rec.Steps))
+	for i, raw := range rec.Steps {
+		steps[i] = ParseStep(raw.String())
+		steps[i].Timeout = raw.Timeout.Timeout()
+		steps[i].AbortOnFailure = raw.OnFailure == "abort"
+		steps[i].RetryExitCodes = raw.RetryExitCodes
+		steps[i].Retries = raw.Retries
+		steps[i].ExitMap = ExitMap(raw.ExitStatus)
+		if steps[i].ExitMap == nil && steps[i].Transfer == nil {
+			steps[i].ExitMap = ExitMap(cfg.ExitMap(steps[i].Command))
+		}
+		if cfg != nil {
+			steps[i].StderrAsOutput = cfg.Defaults.StderrAsOutput
+			steps[i].TrimWhitespace = cfg.Defaults.TrimWhitespace
+			steps[i].CollapseSpaces = cfg.Defaults.CollapseSpaces
+			steps[i].Masks = cfg.MaskPatterns()
+		}
+	}
+	return steps
+}
+
+// ExitMap converts a validated exit code to status mapping from the config
+// for the grouper. It returns nil for an empty mapping.
+func ExitMap(codes map[int]string) map[int]grouper.ExitStatus {
+	if len(codes) == 0 {
+		return nil
+	}
+	m := make(map[int]grouper.ExitStatus, len(codes))
+	for code, status := range codes {
+		m[code] = grouper.ExitStatus(status)
+	}
+	return m
+}
+
+// GroupOptions returns the grouping options cfg configures for command: its
+// exit code mapping and how output is normalized and masked before
+// grouping. cfg may be nil.
+func GroupOptions(cfg *config.Config, command string) grouper.Options {
+	if cfg == nil {
+		return grouper.Options{}
+	}
+	return grouper.Options{
+		ExitMap:                 ExitMap(cfg.ExitMap(command)),
+		StderrAsOutputWhenEmpty: cfg.Defaults.StderrAsOutput,
+		TrimWhitespace:          cfg.Defaults.TrimWhitespace,
+		CollapseSpaces:          cfg.Defaults.CollapseSpaces,
+		Masks:                   cfg.MaskPatterns(),
+	}
 }
 
 // Runner executes recipe steps sequentially with selector propagation.
 type Runner struct {
 	exec     *executor.Executor
+	transfer Transferer // nil unless SetTransfer is called; push and pull steps fail
 	allHosts []string
+	hostTags map[string][]string // nil unless SetHostTags is called; @tag: steps fail
 }
 
 // New creates a Runner with the given executor and full host list.
@@ -43,12 +212,27 @@ func New(exec *executor.Executor, hosts []string) *Runner {
 	}
 }
 
+// SetTransfer sets how push and pull steps transfer files, typically a
+// transfer.Executor over the same connection pool as the command executor.
+func (r *Runner) SetTransfer(t Transferer) {
+	r.transfer = t
+}
+
+// SetHostTags sets the tags of each host, from config, so steps can select
+// hosts with @tag:.
+func (r *Runner) SetHostTags(tags map[string][]string) {
+	r.hostTags = tags
+}
+
 // Run executes steps sequentially. After each step, the selector State is
 // updated with the step's GroupedResults, so @differs/@ok/@failed in step N
-// references step N-1's results.
+// references step N-1's results. A step with a Timeout runs on a copy of the
+// executor with that timeout. If a step with AbortOnFailure has failed
+// hosts, Run returns its results so far along with an error.
 func (r *Runner) Run(ctx context.Context, steps []Step) ([]StepResult, error) {
 	state := &selector.State{
 		AllHosts: r.allHosts,
+		HostTags: r.hostTags,
 	}
 
 	results := make([]StepResult, 0, len(steps))
@@ -58,24 +242,165 @@ func (r *Runner) Run(ctx context.Context, steps []Step) ([]StepResult, error) {
 			return results, fmt.Errorf("recipe cancelled: %w", err)
 		}
 
+		start := time.Now()
 		hosts, err := selector.Resolve(step.Selector, state)
 		if err != nil {
 			return results, fmt.Errorf("step %q: %w", step.Command, err)
 		}
 
-		hostResults := r.exec.Execute(ctx, hosts, step.Command)
-		grouped := grouper.Group(hostResults)
+		var hostResults []*executor.HostResult
+		var retried map[string]int
+		if step.Transfer != nil {
+			if r.transfer == nil {
+				return results, fmt.Errorf("step %q: file transfers are not available", step.Command)
+			}
+			hostResults = r.runTransfer(ctx, hosts, step.Transfer)
+		} else {
+			exec := r.exec
+			if step.Timeout != 0 {
+				exec = exec.WithFixedTimeout(step.Timeout)
+			}
+			hostResults = exec.Execute(ctx, hosts, step.Command)
+			retried = retry(ctx, exec, step, hostResults)
+		}
+		grouped := grouper.Options{
+			ExitMap:                 step.ExitMap,
+			StderrAsOutputWhenEmpty: step.StderrAsOutput,
+			TrimWhitespace:          step.TrimWhitespace,
+			CollapseSpaces:          step.CollapseSpaces,
+			Masks:                   step.Masks,
+		}.Group(hostResults)
 
 		results = append(results, StepResult{
 			Step:    step,
 			Hosts:   hosts,
 			Results: hostResults,
 			Grouped: grouped,
+			Retried: retried,
+			Elapsed: time.Since(start),
 		})
 
-		// Propagate grouped results so the next step can use @ok, @differs, etc.
+		// Propagate results so the next step can use @ok, @differs, @match:, etc.
 		state.Grouped = grouped
+		state.Results = hostResults
+
+		if step.AbortOnFailure {
+			if n := failedCount(grouped); n > 0 {
+				return results, fmt.Errorf("step %q: %d %s failed; aborting (on_failure: abort)", step.Command, n, pluralHost(n))
+			}
+		}
 	}
 
 	return results, nil
 }
+
+// DryRun reports the hosts each step would run on, without running
+// anything. Since no step produces results, a step whose selector refers to
+// the previous step's results, such as @differs or @failed, is reported as
+// unresolvable rather than failing the plan. Other selector errors are
+// returned as Run would return them, along with the plans so far.
+func (r *Runner) DryRun(ctx context.Context, steps []Step) ([]StepPlan, error) {
+	state := &selector.State{
+		AllHosts: r.allHosts,
+		HostTags: r.hostTags,
+	}
+
+	plans := make([]StepPlan, 0, len(steps))
+	for i, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return plans, fmt.Errorf("recipe cancelled: %w", err)
+		}
+
+		plan := StepPlan{Index: i + 1, Step: step}
+		if terms := selector.ResultTerms(step.Selector); len(terms) > 0 {
+			plan.Unresolvable = fmt.Sprintf("selector uses the previous step's results (%s)", strings.Join(terms, ", "))
+		} else {
+			hosts, err := selector.Resolve(step.Selector, state)
+			if err != nil {
+				return plans, fmt.Errorf("step %q: %w", step.Command, err)
+			}
+			plan.Hosts = hosts
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// runTransfer runs a push or pull on hosts and reports each host's outcome
+// as a HostResult, so transfer steps group and propagate like commands.
+// Stdout is the file's checksum and remote path in sha256sum format; hosts
+// that pulled a different file therefore land in their own group.
+func (r *Runner) runTransfer(ctx context.Context, hosts []string, t *Transfer) []*executor.HostResult {
+	var transferred []*transfer.TransferResult
+	if t.Pull {
+		transferred = r.transfer.Pull(ctx, hosts, t.Remote, t.Local, nil)
+	} else {
+		transferred = r.transfer.Push(ctx, hosts, t.Local, t.Remote, nil)
+	}
+	results := make([]*executor.HostResult, len(transferred))
+	for i, tr := range transferred {
+		results[i] = &executor.HostResult{
+			Host:     tr.Host,
+			Err:      tr.Err,
+			Duration: tr.Duration,
+		}
+		if tr.Err == nil {
+			results[i].Stdout = []byte(tr.Checksum + "  " + t.Remote + "\n")
+		}
+	}
+	return results
+}
+
+// failedCount returns how many hosts failed a step: those with connection
+// errors, timeouts, or exit codes that mean failure.
+func failedCount(grouped *grouper.GroupedResults) int {
+	n := len(grouped.Failed) + len(grouped.TimedOut)
+	for _, g := range grouped.Groups {
+		if g.Failed() {
+			n += len(g.Hosts)
+		}
+	}
+	return n
+}
+
+func pluralHost(n int) string {
+	if n == 1 {
+		return "host"
+	}
+	return "hosts"
+}
+
+// retry reruns the step with exec on hosts whose exit code is in
+// step.RetryExitCodes, up to step.Retries times, replacing their entries in
+// results with the latest attempt. Connection errors and other exit codes
+// are left alone. It returns how many times each retried host was rerun.
+func retry(ctx context.Context, exec *executor.Executor, step Step, results []*executor.HostResult) map[string]int {
+	if step.Retries <= 0 || len(step.RetryExitCodes) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(results))
+	for i, res := range results {
+		index[res.Host] = i
+	}
+
+	var retried map[string]int
+	for range step.Retries {
+		var hosts []string
+		for _, res := range results {
+			if res.Err == nil && slices.Contains(step.RetryExitCodes, res.ExitCode) {
+				hosts = append(hosts, res.Host)
+			}
+		}
+		if len(hosts) == 0 || ctx.Err() != nil {
+			break
+		}
+		if retried == nil {
+			retried = make(map[string]int)
+		}
+		for _, res := range exec.Execute(ctx, hosts, step.Command) {
+			results[index[res.Host]] = res
+			retried[res.Host]++
+		}
+	}
+	return retried
+}
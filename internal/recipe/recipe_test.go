@@ -2,10 +2,16 @@ package recipe
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/agent462/herd/internal/config"
 	"github.com/agent462/herd/internal/executor"
 	"github.com/agent462/herd/internal/grouper"
+	"github.com/agent462/herd/internal/transfer"
 )
 
 // --- ParseStep tests ---
@@ -40,6 +46,104 @@ func TestParseStep_MultipleSelectors(t *testing.T) {
 	}
 }
 
+func TestParseStep_Transfer(t *testing.T) {
+	tests := []struct {
+		raw      string
+		selector string
+		want     *Transfer
+	}{
+		{"push local:app.conf remote:/etc/app.conf", "", &Transfer{Local: "app.conf", Remote: "/etc/app.conf"}},
+		{"@failed push local:app.conf remote:/etc/app.conf", "@failed", &Transfer{Local: "app.conf", Remote: "/etc/app.conf"}},
+		{"pull remote:/var/log/app.log local:./logs", "", &Transfer{Pull: true, Local: "./logs", Remote: "/var/log/app.log"}},
+		{"git push origin main", "", nil},
+		{"push app.conf /etc/app.conf", "", nil},
+		{"pull local:./logs remote:/var/log/app.log", "", nil},
+		{"push local: remote:/etc/app.conf", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			step := ParseStep(tt.raw)
+			if step.Selector != tt.selector {
+				t.Errorf("selector = %q, want %q", step.Selector, tt.selector)
+			}
+			switch {
+			case tt.want == nil && step.Transfer != nil:
+				t.Errorf("transfer = %+v, want a plain command", *step.Transfer)
+			case tt.want != nil && (step.Transfer == nil || *step.Transfer != *tt.want):
+				t.Errorf("transfer = %+v, want %+v", step.Transfer, *tt.want)
+			}
+		})
+	}
+}
+
+func TestSteps_AppliesRetry(t *testing.T) {
+	steps := Steps(nil, config.Recipe{Steps: []config.RecipeStep{
+		{Command: "apt-get update", RetryExitCodes: []int{100}, Retries: 3},
+		{Command: "@ok apt-get -y upgrade"},
+	}})
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+	if steps[0].Retries != 3 || len(steps[0].RetryExitCodes) != 1 || steps[0].RetryExitCodes[0] != 100 {
+		t.Errorf("step 1 = %+v, want retry on 100 up to 3 times", steps[0])
+	}
+	if steps[1].Selector != "@ok" || steps[1].Retries != 0 {
+		t.Errorf("step 2 = %+v, want @ok without retry", steps[1])
+	}
+}
+
+func TestSteps_AppliesStepOptions(t *testing.T) {
+	steps := Steps(nil, config.Recipe{Steps: []config.RecipeStep{
+		{Command: "apt-get update"},
+		{Command: "apt-get -y upgrade", Selector: "@ok", Timeout: config.Duration{Duration: 10 * time.Minute}, OnFailure: "abort"},
+	}})
+	if steps[0].Timeout != 0 || steps[0].AbortOnFailure {
+		t.Errorf("plain step = %+v, want default timeout and continue", steps[0])
+	}
+	if steps[1].Selector != "@ok" || steps[1].Command != "apt-get -y upgrade" || steps[1].Timeout != 10*time.Minute || !steps[1].AbortOnFailure {
+		t.Errorf("structured step = %+v", steps[1])
+	}
+}
+
+func TestSteps_AppliesExitStatus(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Defaults.ExitStatus = map[string]map[int]string{"diff": {1: "ok"}}
+	steps := Steps(cfg, config.Recipe{Steps: []config.RecipeStep{
+		{Command: "diff a b"},
+		{Command: "diff c d", ExitStatus: map[int]string{1: "warn"}},
+		{Command: "uptime"},
+	}})
+	if steps[0].ExitMap[1] != grouper.StatusOK {
+		t.Errorf("step 1 exit map = %v, want default {1: ok}", steps[0].ExitMap)
+	}
+	if steps[1].ExitMap[1] != grouper.StatusWarn {
+		t.Errorf("step 2 exit map = %v, want step {1: warn}", steps[1].ExitMap)
+	}
+	if steps[2].ExitMap != nil {
+		t.Errorf("step 3 exit map = %v, want nil", steps[2].ExitMap)
+	}
+}
+
+func TestSteps_AppliesStderrAsOutput(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Defaults.StderrAsOutput = true
+	cfg.Defaults.TrimWhitespace = true
+	cfg.Defaults.Masks = []string{`PID: \d+`}
+	steps := Steps(cfg, config.Recipe{Steps: config.PlainSteps("java -version")})
+	if !steps[0].StderrAsOutput {
+		t.Error("expected stderr_as_output from the defaults")
+	}
+	if !steps[0].TrimWhitespace || steps[0].CollapseSpaces {
+		t.Errorf("step = %+v, want trim_whitespace from the defaults", steps[0])
+	}
+	if len(steps[0].Masks) != 1 {
+		t.Errorf("step masks = %v, want the default mask", steps[0].Masks)
+	}
+	if Steps(nil, config.Recipe{Steps: config.PlainSteps("java -version")})[0].StderrAsOutput {
+		t.Error("expected stderr_as_output off without a config")
+	}
+}
+
 // --- Mock runner ---
 
 type mockRunner struct {
@@ -353,6 +457,134 @@ func TestRun_WithFailedHosts(t *testing.T) {
 	assertHostsEqual(t, "step 1 hosts", results[1].Hosts, []string{"host-c"})
 }
 
+func TestRun_AbortOnFailure(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			if host == "host-b" {
+				return &executor.HostResult{Host: host, ExitCode: 1}
+			}
+			return &executor.HostResult{Host: host, Stdout: []byte("ok")}
+		},
+	}
+	hosts := []string{"host-a", "host-b"}
+
+	steps := []Step{
+		{Command: "migrate"},
+		{Command: "restart"},
+	}
+	results, err := New(executor.New(runner), hosts).Run(context.Background(), steps)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("continue: got %d results, err %v; want both steps to run", len(results), err)
+	}
+
+	steps[0].AbortOnFailure = true
+	results, err = New(executor.New(runner), hosts).Run(context.Background(), steps)
+	if err == nil || !strings.Contains(err.Error(), "1 host failed") {
+		t.Errorf("err = %v, want an abort error", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("got %d results, want only the failed step", len(results))
+	}
+}
+
+func TestRun_StepTimeout(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			select {
+			case <-time.After(time.Second):
+				return &executor.HostResult{Host: host, Stdout: []byte("done")}
+			case <-ctx.Done():
+				return &executor.HostResult{Host: host, Err: ctx.Err()}
+			}
+		},
+	}
+	r := New(executor.New(runner, executor.WithTimeout(5*time.Second)), []string{"host-a"})
+
+	results, err := r.Run(context.Background(), []Step{{Command: "sleep 1", Timeout: 20 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results[0].Grouped.TimedOut) != 1 {
+		t.Errorf("step with a 20ms timeout should time out, got %+v", results[0].Grouped)
+	}
+}
+
+func TestRun_TagSelector(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			mu.Lock()
+			ran = append(ran, host)
+			mu.Unlock()
+			return &executor.HostResult{Host: host}
+		},
+	}
+	r := New(executor.New(runner, executor.WithConcurrency(1)), []string{"web-01", "web-02", "db-01"})
+
+	if _, err := r.Run(context.Background(), []Step{ParseStep("@tag:prod uptime")}); err == nil {
+		t.Error("expected an error without host tags")
+	}
+
+	r.SetHostTags(map[string][]string{"web-01": {"prod", "web"}, "db-01": {"prod"}})
+	results, err := r.Run(context.Background(), []Step{ParseStep("@tag:prod & @tag:web uptime")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHostsEqual(t, "hosts", results[0].Hosts, []string{"web-01"})
+	assertHostsEqual(t, "ran", ran, []string{"web-01"})
+}
+
+func TestDryRun(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			t.Errorf("dry run ran %q on %s", command, host)
+			return &executor.HostResult{Host: host}
+		},
+	}
+	r := New(executor.New(runner), []string{"web-01", "web-02", "db-01"})
+
+	steps := []Step{
+		{Command: "git pull"},
+		{Selector: "@web-*", Command: "systemctl restart app"},
+		{Selector: "@web-*,@differs", Command: "systemctl status app"},
+		{Selector: "!@db-*", Command: "uptime"},
+	}
+	plans, err := r.DryRun(context.Background(), steps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plans) != 4 {
+		t.Fatalf("got %d plans, want 4", len(plans))
+	}
+
+	assertHostsEqual(t, "step 1", plans[0].Hosts, []string{"web-01", "web-02", "db-01"})
+	assertHostsEqual(t, "step 2", plans[1].Hosts, []string{"web-01", "web-02"})
+	if plans[2].Hosts != nil || !strings.Contains(plans[2].Unresolvable, "@differs") {
+		t.Errorf("step 3 = %+v, want unresolvable because of @differs", plans[2])
+	}
+	assertHostsEqual(t, "step 4", plans[3].Hosts, []string{"web-01", "web-02"})
+	for i, p := range plans {
+		if p.Index != i+1 || p.Step.Command != steps[i].Command {
+			t.Errorf("plan %d = %+v", i, p)
+		}
+	}
+}
+
+func TestDryRun_InvalidSelector(t *testing.T) {
+	r := New(executor.New(&mockRunner{}), []string{"web-01"})
+	plans, err := r.DryRun(context.Background(), []Step{
+		{Command: "uptime"},
+		{Selector: "@first=0", Command: "uptime"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for an invalid selector")
+	}
+	if len(plans) != 1 {
+		t.Errorf("got %d plans, want the steps before the error", len(plans))
+	}
+}
+
 // --- helpers ---
 
 // verifyGroupedResults is a helper to inspect grouped output for tests.
@@ -377,3 +609,199 @@ func assertHostsEqual(t *testing.T, label string, got, want []string) {
 		}
 	}
 }
+
+func TestRun_RetryExitCodes(t *testing.T) {
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			mu.Lock()
+			attempts[host]++
+			n := attempts[host]
+			mu.Unlock()
+			switch host {
+			case "transient": // fails twice, then succeeds
+				if n <= 2 {
+					return &executor.HostResult{Host: host, ExitCode: 100}
+				}
+			case "fatal":
+				return &executor.HostResult{Host: host, ExitCode: 1}
+			case "stuck":
+				return &executor.HostResult{Host: host, ExitCode: 100}
+			}
+			return &executor.HostResult{Host: host, Stdout: []byte("done\n")}
+		},
+	}
+
+	r := New(executor.New(runner), []string{"ok", "transient", "fatal", "stuck"})
+	results, err := r.Run(context.Background(), []Step{
+		{Command: "apt-get update", RetryExitCodes: []int{100}, Retries: 3},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantAttempts := map[string]int{"ok": 1, "transient": 3, "fatal": 1, "stuck": 4}
+	for host, want := range wantAttempts {
+		if attempts[host] != want {
+			t.Errorf("%s: ran %d times, want %d", host, attempts[host], want)
+		}
+	}
+
+	sr := results[0]
+	if sr.Retried["transient"] != 2 || sr.Retried["stuck"] != 3 || len(sr.Retried) != 2 {
+		t.Errorf("Retried = %v, want transient:2 stuck:3", sr.Retried)
+	}
+	exit := make(map[string]int)
+	for _, res := range sr.Results {
+		exit[res.Host] = res.ExitCode
+	}
+	if exit["transient"] != 0 || exit["stuck"] != 100 || exit["fatal"] != 1 {
+		t.Errorf("final exit codes = %v", exit)
+	}
+	if len(sr.Grouped.Groups) != 3 {
+		t.Errorf("expected 3 output groups after retry, got %d", len(sr.Grouped.Groups))
+	}
+}
+
+// fakeTransferer records transfers and reports a per-host checksum.
+type fakeTransferer struct {
+	mu    sync.Mutex
+	calls []string
+	sums  map[string]string // host -> checksum; missing hosts fail
+}
+
+func (f *fakeTransferer) results(op string, hosts []string) []*transfer.TransferResult {
+	f.mu.Lock()
+	f.calls = append(f.calls, op)
+	f.mu.Unlock()
+	out := make([]*transfer.TransferResult, len(hosts))
+	for i, h := range hosts {
+		out[i] = &transfer.TransferResult{Host: h, Checksum: f.sums[h]}
+		if f.sums[h] == "" {
+			out[i].Err = errors.New("sftp: permission denied")
+		}
+	}
+	return out
+}
+
+func (f *fakeTransferer) Push(_ context.Context, hosts []string, localPath, remotePath string, _ transfer.ProgressFunc) []*transfer.TransferResult {
+	return f.results("push "+localPath+" "+remotePath, hosts)
+}
+
+func (f *fakeTransferer) Pull(_ context.Context, hosts []string, remotePath, localDir string, _ transfer.ProgressFunc) []*transfer.TransferResult {
+	return f.results("pull "+remotePath+" "+localDir, hosts)
+}
+
+func TestRun_TransferSteps(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			mu.Lock()
+			ran = append(ran, host)
+			mu.Unlock()
+			return &executor.HostResult{Host: host, Stdout: []byte("restarted\n")}
+		},
+	}
+	ft := &fakeTransferer{sums: map[string]string{"host-a": "abc", "host-b": "abc"}}
+
+	r := New(executor.New(runner), []string{"host-a", "host-b", "host-c"})
+	r.SetTransfer(ft)
+	results, err := r.Run(context.Background(), []Step{
+		ParseStep("push local:app.conf remote:/etc/app.conf"),
+		ParseStep("@ok systemctl restart app"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ft.calls) != 1 || ft.calls[0] != "push app.conf /etc/app.conf" {
+		t.Errorf("transfers = %v", ft.calls)
+	}
+	push := results[0].Grouped
+	if len(push.Groups) != 1 || string(push.Groups[0].Stdout) != "abc  /etc/app.conf\n" {
+		t.Errorf("push groups = %+v", push.Groups)
+	}
+	if len(push.Failed) != 1 || push.Failed[0].Host != "host-c" {
+		t.Errorf("push failed = %+v, want host-c", push.Failed)
+	}
+	assertHostsEqual(t, "restart", results[1].Hosts, []string{"host-a", "host-b"})
+	if len(ran) != 2 {
+		t.Errorf("restart ran on %v, want host-a and host-b", ran)
+	}
+}
+
+func TestRun_TransferWithoutTransferer(t *testing.T) {
+	r := New(executor.New(&mockRunner{}), []string{"host-a"})
+	_, err := r.Run(context.Background(), []Step{ParseStep("pull remote:/etc/hosts local:out")})
+	if err == nil {
+		t.Fatal("expected error for transfer step without a Transferer")
+	}
+}
+
+// --- Config tests ---
+
+func TestGroupOptions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Defaults.StderrAsOutput = true
+	cfg.Defaults.TrimWhitespace = true
+	cfg.Defaults.ExitStatus = map[string]map[int]string{"diff": {1: "ok"}}
+
+	opts := GroupOptions(cfg, "diff a b")
+	if !opts.StderrAsOutputWhenEmpty || opts.ExitMap[1] != grouper.StatusOK {
+		t.Errorf("GroupOptions = %+v, want stderr as output and {1: ok}", opts)
+	}
+	if !opts.TrimWhitespace || opts.CollapseSpaces {
+		t.Errorf("GroupOptions = %+v, want only trim_whitespace", opts)
+	}
+	if opts := GroupOptions(nil, "diff"); opts.StderrAsOutputWhenEmpty || opts.ExitMap != nil {
+		t.Errorf("nil config GroupOptions = %+v, want zero value", opts)
+	}
+}
+
+func TestNoTimeoutMatchesExecutor(t *testing.T) {
+	if config.NoTimeout != executor.NoTimeout {
+		t.Errorf("config.NoTimeout = %v, executor.NoTimeout = %v", config.NoTimeout, executor.NoTimeout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    config.RecipeStep
+		wantErr string
+	}{
+		{"plain command", config.RecipeStep{Command: "uptime"}, ""},
+		{"keyword selector", config.RecipeStep{Command: "@failed systemctl status app"}, ""},
+		{"combined selectors", config.RecipeStep{Command: "@differs,@tag:prod & @web-* uptime"}, ""},
+		{"failure class", config.RecipeStep{Command: "@failed:auth uptime"}, ""},
+		{"host name", config.RecipeStep{Command: "@host1 uptime"}, ""},
+		{"unknown host is allowed", config.RecipeStep{Command: "@elsewhere uptime"}, ""},
+		{"unknown failure class", config.RecipeStep{Command: "@failed:bogus uptime"}, `recipe "r" step 2: @failed: unknown failure class "bogus"`},
+		{"empty tag", config.RecipeStep{Command: "@tag: uptime"}, "tag name required"},
+		{"bad fact", config.RecipeStep{Command: "@fact:arch uptime"}, "expected name=value"},
+		{"bad glob", config.RecipeStep{Command: "@web-[ uptime"}, "invalid pattern"},
+		{"group name", config.RecipeStep{Command: "@test uptime"}, "@test is a group"},
+		{"group name as selector", config.RecipeStep{Command: "uptime", Selector: "@test"}, "@test is a group"},
+		{"no command", config.RecipeStep{Command: "@ok"}, "has no command"},
+		{"selector without command", config.RecipeStep{Selector: "@ok"}, "has no command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Groups["test"] = config.Group{Hosts: []config.HostEntry{{Host: "host1"}}}
+			cfg.Recipes = map[string]config.Recipe{"r": {Steps: []config.RecipeStep{{Command: "echo first"}, tt.step}}}
+			err := Validate(cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package recipe
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agent462/herd/internal/parser"
+)
+
+// timelineBarWidth is the width of the bar for the slowest step.
+const timelineBarWidth = 20
+
+// timelineCommandWidth caps the command column; longer commands are cut
+// short with "…".
+const timelineCommandWidth = 40
+
+// FormatTimeline renders how long each step of a recipe run took, one line
+// per step with a bar scaled to the slowest step, followed by the host that
+// took longest. It is meant for finding the slow step in a deploy.
+func FormatTimeline(results []StepResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+
+	var total, longest time.Duration
+	commandWidth := 0
+	for _, sr := range results {
+		total += sr.Elapsed
+		longest = max(longest, sr.Elapsed)
+		commandWidth = max(commandWidth, len([]rune(sr.Step.Command)))
+	}
+	commandWidth = min(commandWidth, timelineCommandWidth)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Timeline (%s):\n", roundElapsed(total))
+	for i, sr := range results {
+		bar := 1
+		if longest > 0 {
+			bar = max(1, int(float64(timelineBarWidth)*float64(sr.Elapsed)/float64(longest)+0.5))
+		}
+		fmt.Fprintf(&b, "  %2d  %-*s  %8s  %-*s",
+			i+1, timelineBarWidth, strings.Repeat("█", bar),
+			roundElapsed(sr.Elapsed), commandWidth, parser.Truncate(sr.Step.Command, commandWidth))
+		if slowest := sr.Slowest(); slowest != nil {
+			fmt.Fprintf(&b, "  slowest: %s (%s)", slowest.Host, roundElapsed(slowest.Duration))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// roundElapsed rounds d for display: to the millisecond under a second,
+// otherwise to a tenth of a second.
+func roundElapsed(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(100 * time.Millisecond)
+}
@@ -0,0 +1,71 @@
+package recipe
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+func TestRun_Elapsed(t *testing.T) {
+	runner := &mockRunner{
+		handler: func(ctx context.Context, host string, command string) *executor.HostResult {
+			if host == "host-b" {
+				time.Sleep(30 * time.Millisecond)
+			}
+			return &executor.HostResult{Host: host}
+		},
+	}
+	r := New(executor.New(runner), []string{"host-a", "host-b"})
+
+	results, err := r.Run(context.Background(), []Step{{Command: "deploy"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sr := results[0]
+	if sr.Elapsed < 30*time.Millisecond {
+		t.Errorf("Elapsed = %v, want at least the slowest host's 30ms", sr.Elapsed)
+	}
+	if slowest := sr.Slowest(); slowest == nil || slowest.Host != "host-b" {
+		t.Errorf("Slowest = %+v, want host-b", slowest)
+	}
+	if (StepResult{}).Slowest() != nil {
+		t.Error("Slowest of a step without hosts should be nil")
+	}
+}
+
+func TestFormatTimeline(t *testing.T) {
+	results := []StepResult{
+		{
+			Step:    Step{Command: "apt-get update"},
+			Results: []*executor.HostResult{{Host: "web-01", Duration: 2 * time.Second}, {Host: "web-03", Duration: 11900 * time.Millisecond}},
+			Elapsed: 12 * time.Second,
+		},
+		{
+			Step:    Step{Command: "systemctl restart nginx"},
+			Results: []*executor.HostResult{{Host: "web-01", Duration: 400 * time.Millisecond}},
+			Elapsed: 400 * time.Millisecond,
+		},
+	}
+
+	out := FormatTimeline(results)
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want a header and one per step:\n%s", len(lines), out)
+	}
+	if lines[0] != "Timeline (12.4s):" {
+		t.Errorf("header = %q, want the total", lines[0])
+	}
+	if !strings.Contains(lines[1], strings.Repeat("█", timelineBarWidth)) || !strings.Contains(lines[1], "12s") ||
+		!strings.Contains(lines[1], "slowest: web-03 (11.9s)") {
+		t.Errorf("step 1 = %q, want a full bar, 12s and web-03 as slowest", lines[1])
+	}
+	if !strings.Contains(lines[2], " █ ") || !strings.Contains(lines[2], "400ms") {
+		t.Errorf("step 2 = %q, want a short bar and 400ms", lines[2])
+	}
+	if FormatTimeline(nil) != "" {
+		t.Error("FormatTimeline(nil) should be empty")
+	}
+}
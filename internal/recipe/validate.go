@@ -0,0 +1,61 @@
+package recipe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agent462/herd/internal/config"
+	"github.com/agent462/herd/internal/selector"
+)
+
+// Validate checks the selectors of cfg's recipe steps, which config.Validate
+// leaves to this package. Every step needs a command after its selector.
+// Selectors match host names, so a bare name that is a group but no host is
+// reported as a likely mistake. Other host names are not checked, since
+// recipes may run against hosts given on the command line.
+func Validate(cfg *config.Config) error {
+	names := make([]string, 0, len(cfg.Recipes))
+	for name := range cfg.Recipes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for i, step := range cfg.Recipes[name].Steps {
+			if err := validateStep(cfg, step); err != nil {
+				return fmt.Errorf("recipe %q step %d: %w", name, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateStep checks one recipe step's selector.
+func validateStep(cfg *config.Config, step config.RecipeStep) error {
+	sel, command := selector.ParseInput(step.String())
+	if strings.TrimSpace(command) == "" {
+		return fmt.Errorf("%q has no command", step.String())
+	}
+	if err := selector.Validate(sel); err != nil {
+		return err
+	}
+	for _, pattern := range selector.HostPatterns(sel) {
+		if _, isGroup := cfg.Groups[pattern]; isGroup && !hasHost(cfg, pattern) {
+			return fmt.Errorf("@%s is a group, but selectors match host names (tag the hosts and use @tag:%s)", pattern, pattern)
+		}
+	}
+	return nil
+}
+
+// hasHost reports whether any group in cfg lists host.
+func hasHost(cfg *config.Config, host string) bool {
+	for _, g := range cfg.Groups {
+		for _, e := range g.Hosts {
+			if e.Host == host {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+// Package relay implements experimental tree fan-out for large fleets. A copy
+// of herd is pushed to a few relay hosts; each relay runs the command against
+// a partition of the targets and reports per-host results back as JSON, which
+// are merged into a single result set.
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agent462/herd/internal/cmdutil"
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/transfer"
+)
+
+// Relay dispatches a command to relay hosts, each executing it against its
+// share of the target hosts.
+type Relay struct {
+	runner       executor.Runner
+	binary       string
+	concurrency  int
+	timeout      time.Duration
+	relayTimeout time.Duration
+}
+
+// Option configures a Relay.
+type Option func(*Relay)
+
+// WithConcurrency sets the maximum number of parallel connections each relay
+// opens to its targets.
+func WithConcurrency(n int) Option {
+	return func(r *Relay) {
+		if n > 0 {
+			r.concurrency = n
+		}
+	}
+}
+
+// WithTimeout sets the per-target command timeout used by each relay.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.timeout = d
+		}
+	}
+}
+
+// WithRelayTimeout sets how long a single relay may take to finish its whole
+// partition.
+func WithRelayTimeout(d time.Duration) Option {
+	return func(r *Relay) {
+		if d > 0 {
+			r.relayTimeout = d
+		}
+	}
+}
+
+// New creates a Relay that reaches relay hosts through runner and invokes
+// the herd binary at binary (a path on the relay hosts).
+func New(runner executor.Runner, binary string, opts ...Option) *Relay {
+	r := &Relay{
+		runner:       runner,
+		binary:       binary,
+		concurrency:  20,
+		timeout:      30 * time.Second,
+		relayTimeout: 10 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Deploy pushes the local herd binary to remotePath on every relay. The
+// binary must be built for the relays' OS and architecture.
+func Deploy(ctx context.Context, t *transfer.Executor, relays []string, localBinary, remotePath string) error {
+	var errs []error
+	for _, res := range t.Push(ctx, relays, localBinary, remotePath, nil) {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("deploy to relay %s: %w", res.Host, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Partition splits hosts into at most n contiguous chunks whose sizes differ
+// by at most one. Empty chunks are never returned.
+func Partition(hosts []string, n int) [][]string {
+	if n <= 0 || len(hosts) == 0 {
+		return nil
+	}
+	if n > len(hosts) {
+		n = len(hosts)
+	}
+
+	parts := make([][]string, 0, n)
+	size, extra := len(hosts)/n, len(hosts)%n
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + size
+		if i < extra {
+			end++
+		}
+		parts = append(parts, hosts[start:end])
+		start = end
+	}
+	return parts
+}
+
+// Execute partitions targets across relays, runs command on each partition
+// via its relay, and merges the results. Results are returned in the same
+// order as targets. If a relay fails or returns unusable output, every
+// target in its partition is reported with an error naming the relay.
+func (r *Relay) Execute(ctx context.Context, relays, targets []string, command string) []*executor.HostResult {
+	if len(relays) == 0 {
+		results := make([]*executor.HostResult, len(targets))
+		for i, h := range targets {
+			results[i] = &executor.HostResult{Host: h, Err: errors.New("no relay hosts")}
+		}
+		return results
+	}
+
+	parts := Partition(targets, len(relays))
+	relays = relays[:len(parts)]
+
+	byRelay := make(map[string][]string, len(parts))
+	for i, relay := range relays {
+		byRelay[relay] = parts[i]
+	}
+
+	ex := executor.New(&relayRunner{r: r, command: command, parts: byRelay},
+		executor.WithConcurrency(len(relays)),
+		executor.WithTimeout(r.relayTimeout),
+	)
+	relayResults := ex.Execute(ctx, relays, "")
+
+	merged := make(map[string]*executor.HostResult, len(targets))
+	for i, rr := range relayResults {
+		for _, res := range decodeRelayResult(rr, parts[i]) {
+			merged[res.Host] = res
+		}
+	}
+
+	results := make([]*executor.HostResult, len(targets))
+	for i, h := range targets {
+		results[i] = merged[h]
+	}
+	return results
+}
+
+// remoteCommand builds the herd invocation a relay runs for its partition.
+func (r *Relay) remoteCommand(command string, hosts []string) string {
+	args := []string{
+		r.binary, "exec", command,
+		"--json",
+		"--concurrency", strconv.Itoa(r.concurrency),
+		"--timeout", r.timeout.String(),
+	}
+	args = append(args, hosts...)
+	return cmdutil.Join(args)
+}
+
+// relayRunner adapts a Relay to executor.Runner so relays are driven with the
+// same bounded fan-out and timeouts as ordinary hosts.
+type relayRunner struct {
+	r       *Relay
+	command string
+	parts   map[string][]string
+}
+
+func (rr *relayRunner) Run(ctx context.Context, host string, _ string) *executor.HostResult {
+	return rr.r.runner.Run(ctx, host, rr.r.remoteCommand(rr.command, rr.parts[host]))
+}
+
+// jsonResult mirrors the per-host object written by herd exec --json.
+type jsonResult struct {
+	Host     string `json:"host"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+	RunID    string `json:"run_id,omitempty"`
+}
+
+// decodeRelayResult converts one relay's output into results for its
+// partition. herd exec exits non-zero when any target fails, so the exit code
+// is ignored as long as stdout holds a valid result set.
+func decodeRelayResult(rr *executor.HostResult, hosts []string) []*executor.HostResult {
+	fail := func(err error) []*executor.HostResult {
+		out := make([]*executor.HostResult, len(hosts))
+		for i, h := range hosts {
+			out[i] = &executor.HostResult{Host: h, Err: fmt.Errorf("relay %s: %w", rr.Host, err)}
+		}
+		return out
+	}
+
+	if rr.Err != nil {
+		return fail(rr.Err)
+	}
+
+	var decoded []jsonResult
+	if err := json.Unmarshal(rr.Stdout, &decoded); err != nil {
+		msg := strings.TrimSpace(string(rr.Stderr))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fail(fmt.Errorf("invalid relay output: %s", msg))
+	}
+
+	byHost := make(map[string]jsonResult, len(decoded))
+	for _, d := range decoded {
+		byHost[d.Host] = d
+	}
+
+	out := make([]*executor.HostResult, len(hosts))
+	for i, h := range hosts {
+		d, ok := byHost[h]
+		if !ok {
+			out[i] = &executor.HostResult{Host: h, Err: fmt.Errorf("relay %s: no result returned", rr.Host)}
+			continue
+		}
+		dur, _ := time.ParseDuration(d.Duration)
+		res := &executor.HostResult{
+			Host:     h,
+			Stdout:   []byte(d.Stdout),
+			Stderr:   []byte(d.Stderr),
+			ExitCode: d.ExitCode,
+			Duration: dur,
+			RunID:    d.RunID,
+		}
+		if d.Error != "" {
+			res.Err = remoteError(d.Error)
+		}
+		out[i] = res
+	}
+	return out
+}
+
+// remoteError reconstructs an error from its relayed message, restoring
+// context.DeadlineExceeded so timeouts are still grouped as timeouts.
+func remoteError(msg string) error {
+	if msg == context.DeadlineExceeded.Error() {
+		return context.DeadlineExceeded
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+type mockRunner struct {
+	handler func(ctx context.Context, host, command string) *executor.HostResult
+}
+
+func (m *mockRunner) Run(ctx context.Context, host, command string) *executor.HostResult {
+	return m.handler(ctx, host, command)
+}
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []string
+		n     int
+		want  []int
+	}{
+		{"even", []string{"a", "b", "c", "d"}, 2, []int{2, 2}},
+		{"uneven", []string{"a", "b", "c", "d", "e"}, 3, []int{2, 2, 1}},
+		{"more relays than hosts", []string{"a", "b"}, 5, []int{1, 1}},
+		{"no hosts", nil, 3, nil},
+		{"no relays", []string{"a"}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := Partition(tt.hosts, tt.n)
+			if len(parts) != len(tt.want) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.want))
+			}
+			var flat []string
+			for i, p := range parts {
+				if len(p) != tt.want[i] {
+					t.Errorf("part %d: got %d hosts, want %d", i, len(p), tt.want[i])
+				}
+				flat = append(flat, p...)
+			}
+			if tt.want != nil && strings.Join(flat, ",") != strings.Join(tt.hosts, ",") {
+				t.Errorf("partitions %v do not cover %v in order", parts, tt.hosts)
+			}
+		})
+	}
+}
+
+// fakeRelay answers each relay command with a JSON result for every target
+// named on the command line after the --timeout value.
+func fakeRelay(t *testing.T, failRelay string) *mockRunner {
+	return &mockRunner{handler: func(_ context.Context, host, command string) *executor.HostResult {
+		if host == failRelay {
+			return &executor.HostResult{Host: host, Err: errors.New("connection refused")}
+		}
+		fields := strings.Fields(command)
+		var out []jsonResult
+		for _, target := range fields[8:] {
+			r := jsonResult{Host: target, Stdout: "ok from " + target + " via " + host + "\n", Duration: "5ms"}
+			if target == "slow" {
+				r.Error = context.DeadlineExceeded.Error()
+			}
+			out = append(out, r)
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &executor.HostResult{Host: host, Stdout: data, ExitCode: 1}
+	}}
+}
+
+func TestExecuteMergesRelayResults(t *testing.T) {
+	r := New(fakeRelay(t, ""), "/tmp/herd")
+	targets := []string{"t1", "t2", "t3", "slow"}
+
+	results := r.Execute(context.Background(), []string{"relay-a", "relay-b"}, targets, "uptime")
+
+	if len(results) != len(targets) {
+		t.Fatalf("got %d results, want %d", len(results), len(targets))
+	}
+	for i, res := range results {
+		if res.Host != targets[i] {
+			t.Errorf("result %d: host %q, want %q", i, res.Host, targets[i])
+		}
+	}
+	if got := string(results[0].Stdout); got != "ok from t1 via relay-a\n" {
+		t.Errorf("t1 stdout = %q", got)
+	}
+	if got := string(results[2].Stdout); got != "ok from t3 via relay-b\n" {
+		t.Errorf("t3 stdout = %q", got)
+	}
+	if results[0].Duration == 0 {
+		t.Error("expected relayed duration to be parsed")
+	}
+	if !errors.Is(results[3].Err, context.DeadlineExceeded) {
+		t.Errorf("slow err = %v, want context.DeadlineExceeded", results[3].Err)
+	}
+}
+
+func TestExecuteRelayFailure(t *testing.T) {
+	r := New(fakeRelay(t, "relay-b"), "/tmp/herd")
+
+	results := r.Execute(context.Background(), []string{"relay-a", "relay-b"}, []string{"t1", "t2", "t3", "t4"}, "uptime")
+
+	for _, res := range results[:2] {
+		if res.Err != nil {
+			t.Errorf("%s: unexpected error %v", res.Host, res.Err)
+		}
+	}
+	for _, res := range results[2:] {
+		if res.Err == nil || !strings.Contains(res.Err.Error(), "relay relay-b") {
+			t.Errorf("%s: err = %v, want relay-b failure", res.Host, res.Err)
+		}
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	r := New(nil, "/opt/herd bin/herd", WithConcurrency(5))
+	got := r.remoteCommand("uptime -p", []string{"a", "b"})
+	want := `'/opt/herd bin/herd' exec 'uptime -p' --json --concurrency 5 --timeout 30s a b`
+	if got != want {
+		t.Errorf("remoteCommand =\n  %s\nwant\n  %s", got, want)
+	}
+}
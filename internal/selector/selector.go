@@ -1,28 +1,38 @@
 package selector
 
 import (
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"path"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/agent462/herd/internal/executor"
 	"github.com/agent462/herd/internal/grouper"
 )
 
 // State holds the context needed for selector resolution:
 // the full host list and (optionally) the results from the last command.
 type State struct {
-	AllHosts []string
-	Grouped  *grouper.GroupedResults // nil if no command has been run yet
-	HostTags map[string][]string    // host name -> tags (nil if tags not available)
+	AllHosts  []string
+	Grouped   *grouper.GroupedResults      // nil if no command has been run yet
+	HostTags  map[string][]string          // host name -> tags (nil if tags not available)
+	HostFacts map[string]map[string]string // host name -> facts such as os=debian-12 (nil if not probed)
+	Results   []*executor.HostResult       // raw results of the last command, for @match: (nil if none)
+	Rand      *rand.Rand                   // source for @random; nil uses the global source
 }
 
 // ParseInput splits a REPL input line into a selector part and a command part.
-// If the input starts with @, the comma-separated list of @-prefixed tokens
-// is the selector (spaces around commas are tolerated). The rest is the command.
-// Otherwise the selector is empty, implying @all.
+// If the input starts with @ (or !@), the list of @-prefixed tokens joined by
+// commas or ampersands is the selector (spaces around the separators are
+// tolerated). The rest is the command. Otherwise the selector is empty,
+// implying @all.
 func ParseInput(input string) (sel, command string) {
 	input = strings.TrimSpace(input)
-	if !strings.HasPrefix(input, "@") {
+	if !startsTerm(input) {
 		return "", input
 	}
 
@@ -33,32 +43,40 @@ func ParseInput(input string) (sel, command string) {
 		for i < len(input) && input[i] == ' ' {
 			i++
 		}
-		if i >= len(input) || input[i] != '@' {
+		if i >= len(input) || !startsTerm(input[i:]) {
 			break
 		}
-		// Advance past this selector token.
-		for i < len(input) && input[i] != ' ' && input[i] != ',' {
+		if input[i] == '!' {
+			i++
+		}
+		// Advance past this selector token. A @match:/regex/ token runs to
+		// its closing slash, so the regex may contain spaces and separators.
+		if strings.HasPrefix(input[i:], matchPrefix) {
+			end, _ := regexEnd(input[i+len(matchPrefix):])
+			i += len(matchPrefix) + end
+		}
+		for i < len(input) && input[i] != ' ' && input[i] != ',' && input[i] != '&' {
 			i++
 		}
 
-		// Look ahead past whitespace for a comma.
+		// Look ahead past whitespace for a separator.
 		j := i
 		for j < len(input) && input[j] == ' ' {
 			j++
 		}
-		if j >= len(input) || input[j] != ',' {
-			break // no comma → end of selector list
+		if j >= len(input) || (input[j] != ',' && input[j] != '&') {
+			break // no separator → end of selector list
 		}
-		// Found comma; verify the next non-space char is @.
-		j++ // skip comma
+		// Found separator; verify the next non-space char is @.
+		j++ // skip separator
 		k := j
 		for k < len(input) && input[k] == ' ' {
 			k++
 		}
-		if k >= len(input) || input[k] != '@' {
-			break // trailing comma, not a combined selector
+		if k >= len(input) || !startsTerm(input[k:]) {
+			break // trailing separator, not a combined selector
 		}
-		i = j // advance past comma; loop will skip whitespace
+		i = j // advance past separator; loop will skip whitespace
 	}
 
 	sel = strings.TrimSpace(input[:i])
@@ -68,26 +86,62 @@ func ParseInput(input string) (sel, command string) {
 	return sel, strings.TrimSpace(input[i:])
 }
 
+// ParseConcurrency strips a leading %N concurrency override from a command,
+// as in "@web-* %1 systemctl restart app". It returns n = 0 and the command
+// unchanged when there is no override, and an error when N is not a positive
+// integer.
+func ParseConcurrency(command string) (n int, rest string, err error) {
+	if !strings.HasPrefix(command, "%") {
+		return 0, command, nil
+	}
+	token, rest, _ := strings.Cut(command[1:], " ")
+	if token == "" || strings.Trim(token, "0123456789") != "" {
+		return 0, command, nil // not a %N prefix; leave it to the remote shell
+	}
+	n, err = strconv.Atoi(token)
+	if err != nil || n < 1 {
+		return 0, command, fmt.Errorf("invalid concurrency %%%s: must be at least 1", token)
+	}
+	return n, strings.TrimSpace(rest), nil
+}
+
 // Resolve maps a selector string to a list of host names.
-// An empty selector is equivalent to @all.
+// An empty selector is equivalent to @all. Comma-separated parts are unioned;
+// within a part, selectors joined by & are intersected left to right, so
+// @web-* & @random picks one random web host. A part prefixed with ! is
+// excluded from the union of the others, wherever it appears, so
+// @all,!@differs is every host outside the outliers. A selector of only
+// excluded parts starts from all hosts: !@web-* is every host but the web
+// servers.
 func Resolve(sel string, state *State) ([]string, error) {
 	if sel == "" || sel == "@all" {
 		return state.AllHosts, nil
 	}
 
-	parts := strings.Split(sel, ",")
 	seen := make(map[string]bool)
+	excluded := make(map[string]bool)
 	var result []string
+	positive := false
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
+	for _, part := range splitTerms(sel, ',') {
+		part, negated, err := cutNegation(part)
+		if err != nil {
+			return nil, err
+		}
 		if part == "" {
 			continue
 		}
-		hosts, err := resolveSingle(part, state)
+		hosts, err := resolveIntersection(part, state)
 		if err != nil {
 			return nil, err
 		}
+		if negated {
+			for _, h := range hosts {
+				excluded[h] = true
+			}
+			continue
+		}
+		positive = true
 		for _, h := range hosts {
 			if !seen[h] {
 				seen[h] = true
@@ -96,7 +150,196 @@ func Resolve(sel string, state *State) ([]string, error) {
 		}
 	}
 
-	return result, nil
+	if !positive && len(excluded) > 0 {
+		for _, h := range state.AllHosts {
+			if !seen[h] {
+				seen[h] = true
+				result = append(result, h)
+			}
+		}
+	}
+	if len(excluded) == 0 {
+		return result, nil
+	}
+	var kept []string
+	for _, h := range result {
+		if !excluded[h] {
+			kept = append(kept, h)
+		}
+	}
+	return kept, nil
+}
+
+// startsTerm reports whether s begins with a selector term: @name or the
+// excluded form !@name.
+func startsTerm(s string) bool {
+	return strings.HasPrefix(s, "@") || strings.HasPrefix(s, "!@")
+}
+
+// cutNegation trims a comma-separated part of a selector and strips its !
+// prefix, reporting whether it had one. A bare ! is an error.
+func cutNegation(part string) (rest string, negated bool, err error) {
+	part = strings.TrimSpace(part)
+	rest, negated = strings.CutPrefix(part, "!")
+	rest = strings.TrimSpace(rest)
+	if negated && rest == "" {
+		return "", false, fmt.Errorf("invalid selector %q: ! must be followed by a selector (e.g. !@web-*)", part)
+	}
+	return rest, negated, nil
+}
+
+// resolveIntersection resolves selectors joined by &. Each selector after the
+// first is resolved against the hosts selected so far, so set-level picks
+// like @any and @random choose from the narrowed set.
+func resolveIntersection(part string, state *State) ([]string, error) {
+	terms := splitTerms(part, '&')
+	hosts, err := resolveSingle(strings.TrimSpace(terms[0]), state)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, term := range terms[1:] {
+		narrowed := *state
+		narrowed.AllHosts = hosts
+		matched, err := resolveSingle(strings.TrimSpace(term), &narrowed)
+		if err != nil {
+			return nil, err
+		}
+		keep := make(map[string]bool, len(matched))
+		for _, h := range matched {
+			keep[h] = true
+		}
+		var next []string
+		for _, h := range hosts {
+			if keep[h] {
+				next = append(next, h)
+			}
+		}
+		hosts = next
+	}
+	return hosts, nil
+}
+
+// Validate checks the syntax of a selector without resolving it: every term
+// must be a known keyword, a well-formed @tag:, @os:, @fact: or @failed:
+// selector, or a valid host glob. Use it to catch mistakes in selectors that
+// will only be resolved later, such as those in recipe steps.
+func Validate(sel string) error {
+	for _, part := range splitTerms(sel, ',') {
+		part, _, err := cutNegation(part)
+		if err != nil {
+			return err
+		}
+		if part == "" {
+			continue
+		}
+		for _, term := range splitTerms(part, '&') {
+			if err := validateSingle(strings.TrimSpace(term)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// HostPatterns returns the host name and glob terms of a selector, excluded
+// or not, without the leading @, skipping keywords, @random=N/@first=N/@last=N
+// and @tag:/@os:/@fact:/@failed:/@match: terms.
+func HostPatterns(sel string) []string {
+	var patterns []string
+	for _, part := range splitTerms(sel, ',') {
+		part, _, _ = cutNegation(part)
+		for _, term := range splitTerms(part, '&') {
+			term = strings.TrimSpace(term)
+			name, ok := strings.CutPrefix(term, "@")
+			if !ok || name == "" || keywords[name] || strings.Contains(name, ":") || isCountSelector(name) {
+				continue
+			}
+			patterns = append(patterns, name)
+		}
+	}
+	return patterns
+}
+
+// ResultTerms returns the terms of a selector, with their @, that refer to
+// the last command's results: @ok, @differs, @failed, @timeout, @failed:
+// and @match:. A selector with any such term cannot be resolved before that
+// command has run.
+func ResultTerms(sel string) []string {
+	var terms []string
+	for _, part := range splitTerms(sel, ',') {
+		part, _, _ = cutNegation(part)
+		for _, term := range splitTerms(part, '&') {
+			term = strings.TrimSpace(term)
+			name, ok := strings.CutPrefix(term, "@")
+			if !ok {
+				continue
+			}
+			switch {
+			case name == "ok", name == "differs", name == "failed", name == "timeout",
+				strings.HasPrefix(name, "failed:"), strings.HasPrefix(name, "match:"):
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// keywords are the selectors that take no argument.
+var keywords = map[string]bool{
+	"all": true, "ok": true, "differs": true, "failed": true,
+	"timeout": true, "any": true, "random": true,
+}
+
+func validateSingle(sel string) error {
+	name, ok := strings.CutPrefix(sel, "@")
+	if !ok {
+		return fmt.Errorf("invalid selector %q: must start with @", sel)
+	}
+	if name == "" {
+		return fmt.Errorf("invalid selector %q: name required", sel)
+	}
+	if keywords[name] {
+		return nil
+	}
+	if kind, arg, ok := cutCount(name); ok {
+		_, err := parseCount(kind, arg)
+		return err
+	}
+	switch {
+	case strings.HasPrefix(name, "tag:"):
+		if strings.TrimPrefix(name[4:], "!") == "" {
+			return fmt.Errorf("@tag: tag name required (use @tag:name or @tag:!name)")
+		}
+	case strings.HasPrefix(name, "os:"):
+		if name[3:] == "" {
+			return fmt.Errorf("@os: OS name required (use @os:debian or @os:debian-12)")
+		}
+	case strings.HasPrefix(name, "fact:"):
+		fact, pattern, ok := strings.Cut(name[5:], "=")
+		if !ok || fact == "" {
+			return fmt.Errorf("@fact: expected name=value (e.g. @fact:arch=aarch64)")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	case strings.HasPrefix(name, "match:"):
+		if _, err := compileMatch(name[6:]); err != nil {
+			return err
+		}
+	case strings.HasPrefix(name, "failed:"):
+		if name[7:] == "timeout" {
+			return nil
+		}
+		if _, err := failedClasses(name[7:]); err != nil {
+			return err
+		}
+	default:
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", name, err)
+		}
+	}
+	return nil
 }
 
 func resolveSingle(sel string, state *State) ([]string, error) {
@@ -116,11 +359,30 @@ func resolveSingle(sel string, state *State) ([]string, error) {
 		return failedHosts(state)
 	case "timeout":
 		return timeoutHosts(state)
+	case "any":
+		return anyHost(state), nil
+	case "random":
+		return randomHost(state), nil
 	default:
+		if kind, arg, ok := cutCount(name); ok {
+			return countHosts(kind, arg, state)
+		}
 		// Check for @tag:tagname syntax.
 		if strings.HasPrefix(name, "tag:") {
 			return tagHosts(name[4:], state)
 		}
+		if strings.HasPrefix(name, "failed:") {
+			return failedClassHosts(name[7:], state)
+		}
+		if strings.HasPrefix(name, "match:") {
+			return outputMatchHosts(name[6:], state)
+		}
+		if strings.HasPrefix(name, "os:") {
+			return osHosts(name[3:], state)
+		}
+		if strings.HasPrefix(name, "fact:") {
+			return factHosts(name[5:], state)
+		}
 		return matchHosts(name, state.AllHosts)
 	}
 }
@@ -152,7 +414,7 @@ func differsHosts(state *State) ([]string, error) {
 	return hosts, nil
 }
 
-// failedHosts returns hosts that did not succeed: connection errors, non-zero
+// failedHosts returns hosts that did not succeed: connection errors, failing
 // exit codes, and timeouts.
 func failedHosts(state *State) ([]string, error) {
 	if state.Grouped == nil {
@@ -163,7 +425,7 @@ func failedHosts(state *State) ([]string, error) {
 		hosts = append(hosts, r.Host)
 	}
 	for _, g := range state.Grouped.Groups {
-		if g.ExitCode != 0 {
+		if g.Failed() {
 			hosts = append(hosts, g.Hosts...)
 		}
 	}
@@ -173,6 +435,156 @@ func failedHosts(state *State) ([]string, error) {
 	return hosts, nil
 }
 
+// failedClassAliases maps the broader @failed: names onto failure classes.
+var failedClassAliases = map[string][]grouper.FailClass{
+	"network": {grouper.FailRefused, grouper.FailUnreachable},
+	"hostkey": {grouper.FailHostKey},
+}
+
+// failedClasses returns the failure classes named by an @failed: class or
+// alias.
+func failedClasses(class string) ([]grouper.FailClass, error) {
+	if classes, ok := failedClassAliases[class]; ok {
+		return classes, nil
+	}
+	valid := []string{"network", "timeout", "hostkey"}
+	for _, c := range grouper.FailClasses {
+		if c == grouper.FailClass(class) {
+			return []grouper.FailClass{c}, nil
+		}
+		valid = append(valid, string(c))
+	}
+	return nil, fmt.Errorf("@failed: unknown failure class %q (valid: %s)", class, strings.Join(valid, ", "))
+}
+
+// failedClassHosts returns hosts whose connection failed with the given
+// failure class (e.g. @failed:auth). "network" covers refused and
+// unreachable hosts, "hostkey" is an alias for host-key, and "timeout"
+// selects hosts that timed out, like @timeout.
+func failedClassHosts(class string, state *State) ([]string, error) {
+	if state.Grouped == nil {
+		return nil, fmt.Errorf("@failed:%s: no previous command results", class)
+	}
+	if class == "timeout" {
+		return timeoutHosts(state)
+	}
+	classes, err := failedClasses(class)
+	if err != nil {
+		return nil, err
+	}
+	byClass := state.Grouped.FailedByClass
+	if byClass == nil {
+		byClass = grouper.ClassifyFailed(state.Grouped.Failed)
+	}
+	var hosts []string
+	for _, c := range classes {
+		for _, r := range byClass[c] {
+			hosts = append(hosts, r.Host)
+		}
+	}
+	return hosts, nil
+}
+
+// matchPrefix starts a selector that matches hosts by their last output.
+const matchPrefix = "@match:/"
+
+// regexEnd returns the index just past the slash closing a /regex/ whose
+// opening slash has been consumed, skipping backslash-escaped characters.
+// It returns len(s) and false if the regex is unterminated.
+func regexEnd(s string) (int, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			return i + 1, true
+		}
+	}
+	return len(s), false
+}
+
+// splitTerms splits s at sep, except inside @match:/regex/ terms.
+func splitTerms(s string, sep byte) []string {
+	var terms []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], matchPrefix) {
+			end, _ := regexEnd(s[i+len(matchPrefix):])
+			i += len(matchPrefix) + end - 1
+			continue
+		}
+		if s[i] == sep {
+			terms = append(terms, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(terms, s[start:])
+}
+
+// ErrNotRegex is returned by ParseRegex for an expression not written as
+// /regex/.
+var ErrNotRegex = errors.New("expected /regex/")
+
+// ParseRegex compiles a /regex/ as written in @match: selectors. A trailing
+// i makes it case-insensitive, and \/ stands for a literal slash.
+func ParseRegex(expr string) (*regexp.Regexp, error) {
+	body, ok := strings.CutPrefix(expr, "/")
+	if !ok {
+		return nil, ErrNotRegex
+	}
+	end, ok := regexEnd(body)
+	flags := body[end:]
+	if !ok || (flags != "" && flags != "i") {
+		return nil, ErrNotRegex
+	}
+	pattern := strings.ReplaceAll(body[:end-1], `\/`, "/")
+	if flags == "i" {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", body[:end-1], err)
+	}
+	return re, nil
+}
+
+// compileMatch compiles the /regex/ of a @match: selector.
+func compileMatch(expr string) (*regexp.Regexp, error) {
+	re, err := ParseRegex(expr)
+	if errors.Is(err, ErrNotRegex) {
+		return nil, fmt.Errorf("@match: expected /regex/ (e.g. @match:/error/)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("@match: %w", err)
+	}
+	return re, nil
+}
+
+// outputMatchHosts returns hosts whose stdout from the last command matches
+// the /regex/ in expr.
+func outputMatchHosts(expr string, state *State) ([]string, error) {
+	re, err := compileMatch(expr)
+	if err != nil {
+		return nil, err
+	}
+	if state.Results == nil {
+		return nil, fmt.Errorf("@match: no previous command results")
+	}
+	matched := make(map[string]bool)
+	for _, r := range state.Results {
+		if re.Match(r.Stdout) {
+			matched[r.Host] = true
+		}
+	}
+	var hosts []string
+	for _, h := range state.AllHosts {
+		if matched[h] {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
 // timeoutHosts returns hosts that timed out.
 func timeoutHosts(state *State) ([]string, error) {
 	if state.Grouped == nil {
@@ -185,6 +597,102 @@ func timeoutHosts(state *State) ([]string, error) {
 	return hosts, nil
 }
 
+// anyHost returns the first host in the set, or nil if the set is empty.
+func anyHost(state *State) []string {
+	if len(state.AllHosts) == 0 {
+		return nil
+	}
+	return state.AllHosts[:1]
+}
+
+// randomHost returns one host chosen at random from the set, or nil if the
+// set is empty. State.Rand makes the choice reproducible.
+func randomHost(state *State) []string {
+	n := len(state.AllHosts)
+	if n == 0 {
+		return nil
+	}
+	var i int
+	if state.Rand != nil {
+		i = state.Rand.IntN(n)
+	} else {
+		i = rand.IntN(n)
+	}
+	return []string{state.AllHosts[i]}
+}
+
+// countSelectors are the selectors that take a host count, as @first=N.
+var countSelectors = []string{"random", "first", "last"}
+
+// cutCount splits a @random=N, @first=N or @last=N selector name into its
+// kind and N.
+func cutCount(name string) (kind, arg string, ok bool) {
+	kind, arg, ok = strings.Cut(name, "=")
+	if !ok || !slices.Contains(countSelectors, kind) {
+		return "", "", false
+	}
+	return kind, arg, true
+}
+
+// isCountSelector reports whether name is a @random=N, @first=N or @last=N
+// selector.
+func isCountSelector(name string) bool {
+	_, _, ok := cutCount(name)
+	return ok
+}
+
+// parseCount parses the N of a count selector.
+func parseCount(kind, arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("@%s=: expected a positive number of hosts (e.g. @%s=2), got %q", kind, kind, arg)
+	}
+	return n, nil
+}
+
+// countHosts returns N hosts from the set: chosen at random for @random=N,
+// or the first or last N in order for @first=N and @last=N. Random picks
+// keep the set's order, and State.Rand makes them reproducible. It is an
+// error for N to exceed the number of hosts.
+func countHosts(kind, arg string, state *State) ([]string, error) {
+	n, err := parseCount(kind, arg)
+	if err != nil {
+		return nil, err
+	}
+	hosts := state.AllHosts
+	if n > len(hosts) {
+		return nil, fmt.Errorf("@%s=%d: only %d %s to choose from", kind, n, len(hosts), pluralHosts(len(hosts)))
+	}
+
+	switch kind {
+	case "first":
+		return hosts[:n], nil
+	case "last":
+		return hosts[len(hosts)-n:], nil
+	}
+	var perm []int
+	if state.Rand != nil {
+		perm = state.Rand.Perm(len(hosts))
+	} else {
+		perm = rand.Perm(len(hosts))
+	}
+	picked := perm[:n]
+	slices.Sort(picked)
+	result := make([]string, n)
+	for i, idx := range picked {
+		result[i] = hosts[idx]
+	}
+	return result, nil
+}
+
+// pluralHosts returns "host" or "hosts" for n.
+func pluralHosts(n int) string {
+	if n == 1 {
+		return "host"
+	}
+	return "hosts"
+}
+
 // tagHosts returns hosts that have (or don't have) a specific tag.
 // Supports negation: @tag:!staging excludes hosts with the "staging" tag.
 func tagHosts(tagExpr string, state *State) ([]string, error) {
@@ -228,6 +736,60 @@ func tagHosts(tagExpr string, state *State) ([]string, error) {
 	return matched, nil
 }
 
+// osHosts returns hosts running the given OS, from the "os" fact. A bare
+// distro such as "debian" matches every version; "debian-12" matches that
+// version only.
+func osHosts(osName string, state *State) ([]string, error) {
+	if state.HostFacts == nil {
+		return nil, fmt.Errorf("@os: host facts not available")
+	}
+	if osName == "" {
+		return nil, fmt.Errorf("@os: OS name required (use @os:debian or @os:debian-12)")
+	}
+
+	var matched []string
+	for _, h := range state.AllHosts {
+		hostOS := state.HostFacts[h]["os"]
+		if hostOS == osName || strings.HasPrefix(hostOS, osName+"-") {
+			matched = append(matched, h)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no hosts match @os:%s", osName)
+	}
+	return matched, nil
+}
+
+// factHosts returns hosts whose fact matches a name=pattern expression, where
+// pattern is a glob (e.g. @fact:arch=aarch64, @fact:kernel=6.1.*).
+func factHosts(expr string, state *State) ([]string, error) {
+	if state.HostFacts == nil {
+		return nil, fmt.Errorf("@fact: host facts not available")
+	}
+	name, pattern, ok := strings.Cut(expr, "=")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("@fact: expected name=value (e.g. @fact:arch=aarch64)")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	var matched []string
+	for _, h := range state.AllHosts {
+		value, ok := state.HostFacts[h][name]
+		if !ok {
+			continue
+		}
+		if m, _ := path.Match(pattern, value); m {
+			matched = append(matched, h)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no hosts match @fact:%s", expr)
+	}
+	return matched, nil
+}
+
 // matchHosts returns hosts whose names match the given glob pattern.
 func matchHosts(pattern string, allHosts []string) ([]string, error) {
 	if _, err := path.Match(pattern, ""); err != nil {
@@ -1,6 +1,9 @@
 package selector
 
 import (
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/agent462/herd/internal/executor"
@@ -27,6 +30,50 @@ func TestParseInput_WithSelector(t *testing.T) {
 	}
 }
 
+func TestParseConcurrency(t *testing.T) {
+	tests := []struct {
+		input   string
+		n       int
+		rest    string
+		wantErr bool
+	}{
+		{input: "uptime", n: 0, rest: "uptime"},
+		{input: "%1 systemctl restart app", n: 1, rest: "systemctl restart app"},
+		{input: "%25 uptime", n: 25, rest: "uptime"},
+		{input: "%3", n: 3, rest: ""},
+		{input: "%0 uptime", wantErr: true},
+		{input: "%s uptime", n: 0, rest: "%s uptime"},
+		{input: "% uptime", n: 0, rest: "% uptime"},
+	}
+	for _, tt := range tests {
+		n, rest, err := ParseConcurrency(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseConcurrency(%q) expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseConcurrency(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if n != tt.n || rest != tt.rest {
+			t.Errorf("ParseConcurrency(%q) = %d, %q; want %d, %q", tt.input, n, rest, tt.n, tt.rest)
+		}
+	}
+}
+
+func TestParseInput_ConcurrencyOverride(t *testing.T) {
+	sel, cmd := ParseInput("@web-* %1 systemctl restart app")
+	if sel != "@web-*" {
+		t.Errorf("sel = %q, want %q", sel, "@web-*")
+	}
+	n, rest, err := ParseConcurrency(cmd)
+	if err != nil || n != 1 || rest != "systemctl restart app" {
+		t.Errorf("ParseConcurrency(%q) = %d, %q, %v", cmd, n, rest, err)
+	}
+}
+
 func TestParseInput_CombinedSelector(t *testing.T) {
 	sel, cmd := ParseInput("@differs,@failed systemctl restart nginx")
 	if sel != "@differs,@failed" {
@@ -37,6 +84,23 @@ func TestParseInput_CombinedSelector(t *testing.T) {
 	}
 }
 
+func TestParseInput_Excluded(t *testing.T) {
+	tests := []struct {
+		input, sel, cmd string
+	}{
+		{"!@web-* uptime", "!@web-*", "uptime"},
+		{"@all,!@differs uptime", "@all,!@differs", "uptime"},
+		{"@web-*, !@web-01 & @tag:prod df -h", "@web-*, !@web-01 & @tag:prod", "df -h"},
+		{"!ls", "", "!ls"},
+	}
+	for _, tc := range tests {
+		sel, cmd := ParseInput(tc.input)
+		if sel != tc.sel || cmd != tc.cmd {
+			t.Errorf("ParseInput(%q) = %q, %q; want %q, %q", tc.input, sel, cmd, tc.sel, tc.cmd)
+		}
+	}
+}
+
 func TestParseInput_CombinedSelectorSpaces(t *testing.T) {
 	sel, cmd := ParseInput("@differs, @failed systemctl restart nginx")
 	if sel != "@differs, @failed" {
@@ -137,6 +201,153 @@ func TestResolve_Failed(t *testing.T) {
 	assertHosts(t, hosts, []string{"a", "b", "c"})
 }
 
+func TestResolve_FailedRespectsExitStatus(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"a", "b", "c"},
+		Grouped: &grouper.GroupedResults{
+			Groups: []grouper.OutputGroup{
+				{Hosts: []string{"a"}, ExitCode: 1, Status: grouper.StatusOK},
+				{Hosts: []string{"b"}, ExitCode: 3, Status: grouper.StatusWarn},
+				{Hosts: []string{"c"}, ExitCode: 2, Status: grouper.StatusFail},
+			},
+		},
+	}
+	hosts, err := Resolve("@failed", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHosts(t, hosts, []string{"c"})
+}
+
+func TestResolve_FailedClass(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"a", "b", "c", "d", "e", "f"},
+		Grouped: &grouper.GroupedResults{
+			Failed: []*executor.HostResult{{Host: "a"}, {Host: "b"}, {Host: "c"}, {Host: "e"}},
+			FailedByClass: map[grouper.FailClass][]*executor.HostResult{
+				grouper.FailRefused:     {{Host: "a"}},
+				grouper.FailUnreachable: {{Host: "b"}},
+				grouper.FailHostKey:     {{Host: "c"}},
+				grouper.FailAuth:        {{Host: "e"}},
+			},
+			TimedOut: []*executor.HostResult{{Host: "d"}},
+			Groups: []grouper.OutputGroup{
+				{Hosts: []string{"f"}, ExitCode: 1},
+			},
+		},
+	}
+	tests := []struct {
+		sel  string
+		want []string
+	}{
+		{"@failed:auth", []string{"e"}},
+		{"@failed:refused", []string{"a"}},
+		{"@failed:unreachable", []string{"b"}},
+		{"@failed:network", []string{"a", "b"}},
+		{"@failed:hostkey", []string{"c"}},
+		{"@failed:host-key", []string{"c"}},
+		{"@failed:timeout", []string{"d"}},
+		{"@failed:other", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			hosts, err := Resolve(tt.sel, state)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertHosts(t, hosts, tt.want)
+		})
+	}
+
+	if _, err := Resolve("@failed:bogus", state); err == nil {
+		t.Error("expected error for unknown failure class")
+	}
+	if _, err := Resolve("@failed:auth", &State{AllHosts: state.AllHosts}); err == nil {
+		t.Error("expected error without previous results")
+	}
+}
+
+func TestResolve_Match(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"a", "b", "c", "d"},
+		Results: []*executor.HostResult{
+			{Host: "a", Stdout: []byte("all good\n")},
+			{Host: "b", Stdout: []byte("ERROR: disk full, 1/2 mounts\n")},
+			{Host: "c", Stdout: []byte("error: timeout\n")},
+			{Host: "d", Stderr: []byte("error on stderr only\n")},
+		},
+	}
+	tests := []struct {
+		sel  string
+		want []string
+	}{
+		{"@match:/error/", []string{"c"}},
+		{"@match:/error/i", []string{"b", "c"}},
+		{`@match:/disk full, 1\/2/`, []string{"b"}},
+		{"@match:/a{1,2}ll/ & @a", []string{"a"}},
+		{"@match:/nothing/", nil},
+		{"@c,@match:/ERROR/", []string{"c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			hosts, err := Resolve(tt.sel, state)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertHosts(t, hosts, tt.want)
+		})
+	}
+
+	for _, sel := range []string{"@match:error", "@match:/error", "@match:/(/", "@match:/x/g"} {
+		if _, err := Resolve(sel, state); err == nil {
+			t.Errorf("Resolve(%q): expected error", sel)
+		}
+	}
+	if _, err := Resolve("@match:/x/", &State{AllHosts: state.AllHosts}); err == nil {
+		t.Error("expected error without previous results")
+	}
+}
+
+func TestParseInput_Match(t *testing.T) {
+	sel, cmd := ParseInput("@match:/disk full, 1 & 2/i,@web-* df -h")
+	if sel != "@match:/disk full, 1 & 2/i,@web-*" {
+		t.Errorf("sel = %q", sel)
+	}
+	if cmd != "df -h" {
+		t.Errorf("cmd = %q, want %q", cmd, "df -h")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{"", "@all", "@ok,@differs", "@web-* & @random", "@tag:!prod", "@os:debian-12",
+		"@fact:arch=aarch64", "@failed:auth", "@failed:network", "@failed:timeout", "@pi-garage", "@match:/a,b/i", "@random=2", "@web-* & @first=1,@last=3", "@all,!@differs", "!@web-* & @tag:prod"}
+	for _, sel := range valid {
+		if err := Validate(sel); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", sel, err)
+		}
+	}
+	invalid := []string{"web", "@", "@tag:", "@tag:!", "@os:", "@fact:=x", "@fact:arch", "@fact:arch=[", "@failed:bogus", "@web-[", "@match:/(/", "@random=", "@first=x", "@last=0", "@random=-1", "@all,!", "!web"}
+	for _, sel := range invalid {
+		if err := Validate(sel); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", sel)
+		}
+	}
+}
+
+func TestHostPatterns(t *testing.T) {
+	got := HostPatterns("@ok,@web-* & @random,@tag:prod,@db1,@first=2,!@db2")
+	assertHosts(t, got, []string{"web-*", "db1", "db2"})
+}
+
+func TestResultTerms(t *testing.T) {
+	got := ResultTerms("@web-* & @ok,@differs,!@failed:auth,@tag:prod,@match:/err/,@random")
+	assertHosts(t, got, []string{"@ok", "@differs", "@failed:auth", "@match:/err/"})
+
+	if terms := ResultTerms("@web-*,@tag:prod,@first=2"); len(terms) != 0 {
+		t.Errorf("ResultTerms = %v, want none", terms)
+	}
+}
+
 func TestResolve_Timeout(t *testing.T) {
 	state := &State{
 		AllHosts: []string{"a", "b"},
@@ -233,6 +444,50 @@ func TestResolve_InvalidSelector(t *testing.T) {
 	}
 }
 
+func TestResolve_Excluded(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"web-01", "web-02", "db-01", "db-02"},
+		Grouped: &grouper.GroupedResults{
+			Groups: []grouper.OutputGroup{
+				{Hosts: []string{"web-01", "db-01", "db-02"}, IsNorm: true},
+				{Hosts: []string{"web-02"}, IsNorm: false},
+			},
+		},
+	}
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"@all,!@differs", []string{"web-01", "db-01", "db-02"}},
+		{"!@differs,@all", []string{"web-01", "db-01", "db-02"}},
+		{"!@web-*", []string{"db-01", "db-02"}},
+		{"!@web-*,!@db-02", []string{"db-01"}},
+		{"@web-*,@db-01,!@differs", []string{"web-01", "db-01"}},
+		{"@all,!@web-* & @differs", []string{"web-01", "db-01", "db-02"}},
+		{"@web-*,!@web-*", nil},
+	}
+	for _, tc := range tests {
+		hosts, err := Resolve(tc.selector, state)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.selector, err)
+		}
+		assertHosts(t, hosts, tc.want)
+	}
+}
+
+func TestResolve_ExcludedErrors(t *testing.T) {
+	state := &State{AllHosts: []string{"a", "b"}}
+
+	_, err := Resolve("!@ok", state)
+	if err == nil || !strings.Contains(err.Error(), "@ok: no previous command results") {
+		t.Errorf("!@ok: err = %v, want no previous command results", err)
+	}
+	_, err = Resolve("@all,!", state)
+	if err == nil || !strings.Contains(err.Error(), "! must be followed by a selector") {
+		t.Errorf("@all,!: err = %v, want a bare ! error", err)
+	}
+}
+
 func TestResolve_DiffersEmpty(t *testing.T) {
 	// All hosts identical → no differs.
 	state := &State{
@@ -287,6 +542,71 @@ func TestResolve_TagSelector(t *testing.T) {
 	assertHosts(t, hosts, []string{"a", "c"})
 }
 
+func TestResolve_OSSelector(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"a", "b", "c", "d"},
+		HostFacts: map[string]map[string]string{
+			"a": {"os": "debian-12"},
+			"b": {"os": "debian-11"},
+			"c": {"os": "ubuntu-22.04"},
+			"d": {"os": "debianish"},
+		},
+	}
+
+	hosts, err := Resolve("@os:debian", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHosts(t, hosts, []string{"a", "b"})
+
+	hosts, err = Resolve("@os:debian-12", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHosts(t, hosts, []string{"a"})
+
+	if _, err := Resolve("@os:rhel", state); err == nil {
+		t.Error("expected error for unmatched OS")
+	}
+	if _, err := Resolve("@os:debian", &State{AllHosts: state.AllHosts}); err == nil {
+		t.Error("expected error without OS information")
+	}
+}
+
+func TestResolve_FactSelector(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"a", "b", "c"},
+		HostFacts: map[string]map[string]string{
+			"a": {"arch": "aarch64", "kernel": "6.1.0-18-arm64"},
+			"b": {"arch": "x86_64", "kernel": "6.1.0-18-amd64"},
+			"c": {"arch": "x86_64", "kernel": "5.15.0-91-generic"},
+		},
+	}
+
+	tests := []struct {
+		sel  string
+		want []string
+	}{
+		{"@fact:arch=x86_64", []string{"b", "c"}},
+		{"@fact:kernel=6.1.*", []string{"a", "b"}},
+		{"@fact:arch=x86_64 & @fact:kernel=5.*", []string{"c"}},
+	}
+	for _, tt := range tests {
+		hosts, err := Resolve(tt.sel, state)
+		if err != nil {
+			t.Errorf("Resolve(%q) unexpected error: %v", tt.sel, err)
+			continue
+		}
+		assertHosts(t, hosts, tt.want)
+	}
+
+	for _, sel := range []string{"@fact:arch", "@fact:=x86_64", "@fact:arch=riscv64"} {
+		if _, err := Resolve(sel, state); err == nil {
+			t.Errorf("Resolve(%q) expected error", sel)
+		}
+	}
+}
+
 func TestResolve_TagSelectorNegated(t *testing.T) {
 	state := &State{
 		AllHosts: []string{"a", "b", "c"},
@@ -392,3 +712,162 @@ func assertHosts(t *testing.T, got, want []string) {
 		}
 	}
 }
+
+func TestParseInput_IntersectionSelector(t *testing.T) {
+	sel, cmd := ParseInput("@web-* & @random uptime")
+	if sel != "@web-* & @random" {
+		t.Errorf("sel = %q, want %q", sel, "@web-* & @random")
+	}
+	if cmd != "uptime" {
+		t.Errorf("cmd = %q, want %q", cmd, "uptime")
+	}
+}
+
+func TestResolve_Any(t *testing.T) {
+	state := &State{AllHosts: []string{"a", "b", "c"}}
+	hosts, err := Resolve("@any", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHosts(t, hosts, []string{"a"})
+}
+
+func TestResolve_AnyEmpty(t *testing.T) {
+	hosts, err := Resolve("@any", &State{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hosts) != 0 {
+		t.Errorf("expected no hosts, got %v", hosts)
+	}
+}
+
+func TestResolve_RandomSeeded(t *testing.T) {
+	all := []string{"a", "b", "c", "d", "e"}
+	pick := func() []string {
+		state := &State{AllHosts: all, Rand: rand.New(rand.NewPCG(1, 2))}
+		hosts, err := Resolve("@random", state)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return hosts
+	}
+
+	first := pick()
+	if len(first) != 1 {
+		t.Fatalf("expected one host, got %v", first)
+	}
+	assertHosts(t, pick(), first)
+}
+
+func TestResolve_IntersectionWithRandom(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"db-01", "web-01", "web-02", "web-03"},
+		Rand:     rand.New(rand.NewPCG(7, 7)),
+	}
+	for i := 0; i < 20; i++ {
+		hosts, err := Resolve("@web-* & @random", state)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(hosts) != 1 || hosts[0] == "db-01" {
+			t.Fatalf("expected one web host, got %v", hosts)
+		}
+	}
+}
+
+func TestResolve_Count(t *testing.T) {
+	state := &State{AllHosts: []string{"a", "b", "c", "d", "e"}}
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"@first=2", []string{"a", "b"}},
+		{"@last=2", []string{"d", "e"}},
+		{"@first=5", []string{"a", "b", "c", "d", "e"}},
+		{"@first=1,@last=1", []string{"a", "e"}},
+	}
+	for _, tc := range tests {
+		hosts, err := Resolve(tc.selector, state)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.selector, err)
+		}
+		assertHosts(t, hosts, tc.want)
+	}
+}
+
+func TestResolve_RandomCountSeeded(t *testing.T) {
+	all := []string{"a", "b", "c", "d", "e"}
+	pick := func() []string {
+		state := &State{AllHosts: all, Rand: rand.New(rand.NewPCG(3, 4))}
+		hosts, err := Resolve("@random=3", state)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return hosts
+	}
+
+	first := pick()
+	if len(first) != 3 {
+		t.Fatalf("expected three hosts, got %v", first)
+	}
+	if !slices.IsSortedFunc(first, func(x, y string) int { return slices.Index(all, x) - slices.Index(all, y) }) {
+		t.Errorf("random hosts should keep host order, got %v", first)
+	}
+	assertHosts(t, pick(), first)
+}
+
+func TestResolve_CountComposes(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"db-01", "web-01", "web-02", "web-03"},
+		Rand:     rand.New(rand.NewPCG(5, 6)),
+	}
+	hosts, err := Resolve("@web-* & @first=2", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHosts(t, hosts, []string{"web-01", "web-02"})
+
+	hosts, err = Resolve("@db-*,@web-* & @random=2", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hosts) != 3 || hosts[0] != "db-01" {
+		t.Errorf("expected db-01 and two web hosts, got %v", hosts)
+	}
+}
+
+func TestResolve_CountErrors(t *testing.T) {
+	state := &State{AllHosts: []string{"a", "b", "c"}}
+	tests := []struct {
+		selector string
+		wantErr  string
+	}{
+		{"@first=5", "@first=5: only 3 hosts to choose from"},
+		{"@random=4", "@random=4: only 3 hosts to choose from"},
+		{"@last=x", `@last=: expected a positive number of hosts (e.g. @last=2), got "x"`},
+		{"@random=0", `got "0"`},
+	}
+	for _, tc := range tests {
+		_, err := Resolve(tc.selector, state)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: err = %v, want it to contain %q", tc.selector, err, tc.wantErr)
+		}
+	}
+}
+
+func TestResolve_Intersection(t *testing.T) {
+	state := &State{
+		AllHosts: []string{"web-01", "web-02", "db-01"},
+		HostTags: map[string][]string{
+			"web-01": {"prod"},
+			"web-02": {"staging"},
+			"db-01":  {"prod"},
+		},
+	}
+	hosts, err := Resolve("@web-*&@tag:prod,@db-01", state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertHosts(t, hosts, []string{"web-01", "db-01"})
+}
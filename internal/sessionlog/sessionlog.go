@@ -0,0 +1,110 @@
+// Package sessionlog appends a timestamped record of every command run in an
+// interactive session (REPL or dashboard) to a log file, as an audit trail
+// that is written as the session goes rather than on explicit export.
+package sessionlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agent462/herd/internal/grouper"
+)
+
+// Logger appends session records to a file. The file is opened for each
+// record, so it may be rotated or removed while a session is running.
+type Logger struct {
+	path string
+	full bool
+	now  func() time.Time // overridable for tests
+
+	mu sync.Mutex
+}
+
+// New returns a Logger that appends to path. When full is true, each record
+// also includes every group's output and each failed host's error.
+func New(path string, full bool) *Logger {
+	return &Logger{path: path, full: full, now: time.Now}
+}
+
+// Summary holds the per-run host counts written to each record.
+type Summary struct {
+	Hosts    int
+	OK       int // hosts in the norm group with exit code 0
+	Differs  int // hosts outside the norm group with exit code 0
+	Failed   int // non-zero exit codes and connection errors
+	TimedOut int
+}
+
+// Summarize counts hosts by outcome.
+func Summarize(grouped *grouper.GroupedResults) Summary {
+	var s Summary
+	for _, g := range grouped.Groups {
+		s.Hosts += len(g.Hosts)
+		switch {
+		case g.Failed():
+			s.Failed += len(g.Hosts)
+		case g.IsNorm:
+			s.OK += len(g.Hosts)
+		default:
+			s.Differs += len(g.Hosts)
+		}
+	}
+	s.Failed += len(grouped.Failed)
+	s.TimedOut += len(grouped.TimedOut)
+	s.Hosts += len(grouped.Failed) + len(grouped.TimedOut)
+	return s
+}
+
+// Record appends one entry for input (the full input line, including any
+// selector) and its grouped results.
+func (l *Logger) Record(input string, grouped *grouper.GroupedResults) error {
+	var b strings.Builder
+	s := Summarize(grouped)
+	fmt.Fprintf(&b, "[%s] %s\n", l.now().Format(time.RFC3339), input)
+	hostWord := "hosts"
+	if s.Hosts == 1 {
+		hostWord = "host"
+	}
+	fmt.Fprintf(&b, "  %d %s: %d ok, %d differs, %d failed, %d timeout\n",
+		s.Hosts, hostWord, s.OK, s.Differs, s.Failed, s.TimedOut)
+
+	if l.full {
+		for _, g := range grouped.Groups {
+			fmt.Fprintf(&b, "  --- %s (exit %d)\n", strings.Join(g.Hosts, ", "), g.ExitCode)
+			writeIndented(&b, g.Stdout)
+			writeIndented(&b, g.Stderr)
+		}
+		for _, r := range grouped.Failed {
+			fmt.Fprintf(&b, "  --- %s failed: %v\n", r.Host, r.Err)
+		}
+		for _, r := range grouped.TimedOut {
+			fmt.Fprintf(&b, "  --- %s timed out\n", r.Host)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open session log: %w", err)
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("write session log: %w", err)
+	}
+	return f.Close()
+}
+
+func writeIndented(b *strings.Builder, out []byte) {
+	text := strings.TrimRight(string(out), "\n")
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+}
@@ -0,0 +1,96 @@
+package sessionlog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+)
+
+func testGrouped() *grouper.GroupedResults {
+	return grouper.Group([]*executor.HostResult{
+		{Host: "a", Stdout: []byte("ok\n")},
+		{Host: "b", Stdout: []byte("ok\n")},
+		{Host: "c", Stdout: []byte("other\n")},
+		{Host: "d", Stdout: []byte("boom\n"), ExitCode: 2},
+		{Host: "e", Err: errors.New("connection refused")},
+		{Host: "f", Err: context.DeadlineExceeded},
+	})
+}
+
+func TestSummarize(t *testing.T) {
+	got := Summarize(testGrouped())
+	want := Summary{Hosts: 6, OK: 2, Differs: 1, Failed: 2, TimedOut: 1}
+	if got != want {
+		t.Errorf("Summarize = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.log")
+	l := New(path, false)
+	l.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	if err := l.Record("uptime", testGrouped()); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := l.Record("@differs hostname", testGrouped()); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"[2026-01-02T03:04:05Z] uptime\n",
+		"[2026-01-02T03:04:05Z] @differs hostname\n",
+		"  6 hosts: 2 ok, 1 differs, 2 failed, 1 timeout\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "    ok") {
+		t.Errorf("summary-only log should not include output:\n%s", got)
+	}
+}
+
+func TestRecordFullOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.log")
+	l := New(path, true)
+
+	if err := l.Record("uptime", testGrouped()); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"  --- a, b (exit 0)\n    ok\n",
+		"  --- d (exit 2)\n    boom\n",
+		"  --- e failed: connection refused\n",
+		"  --- f timed out\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRecordOpenError(t *testing.T) {
+	l := New(filepath.Join(t.TempDir(), "missing", "session.log"), false)
+	if err := l.Record("uptime", testGrouped()); err == nil {
+		t.Fatal("expected error for unwritable log path")
+	}
+}
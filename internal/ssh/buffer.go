@@ -1,6 +1,9 @@
 package ssh
 
-import "sync"
+import (
+	"bytes"
+	"sync"
+)
 
 // safeBuffer is a goroutine-safe bytes buffer used for capturing
 // stdout/stderr from SSH sessions.
@@ -23,3 +26,12 @@ func (b *safeBuffer) Bytes() []byte {
 	copy(out, b.buf)
 	return out
 }
+
+// streamWriter passes a copy of each write to the function, forwarding
+// session output as it arrives.
+type streamWriter func(data []byte)
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w(bytes.Clone(p))
+	return len(p), nil
+}
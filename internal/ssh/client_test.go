@@ -2,13 +2,21 @@ package ssh
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/agent462/herd/internal/sshtest"
 )
@@ -114,13 +122,15 @@ func TestCommandNonZeroExitCode(t *testing.T) {
 	}
 }
 
-func TestConnectionTimeout(t *testing.T) {
-	// Create a listener that accepts but never completes the SSH handshake.
+// startSilentListener starts a listener that accepts connections but never
+// completes the SSH handshake, returning its port.
+func startSilentListener(t *testing.T) int {
+	t.Helper()
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("listen: %v", err)
 	}
-	defer listener.Close()
+	t.Cleanup(func() { listener.Close() })
 
 	go func() {
 		for {
@@ -141,6 +151,11 @@ func TestConnectionTimeout(t *testing.T) {
 	}()
 
 	_, port := sshtest.ParseAddr(t, listener.Addr().String())
+	return port
+}
+
+func TestConnectionTimeout(t *testing.T) {
+	port := startSilentListener(t)
 
 	t.Setenv("SSH_AUTH_SOCK", "")
 
@@ -153,7 +168,7 @@ func TestConnectionTimeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
 	defer cancel()
 
-	_, err = Dial(ctx, "127.0.0.1", conf)
+	_, err := Dial(ctx, "127.0.0.1", conf)
 	if err == nil {
 		t.Fatal("expected timeout error, got nil")
 	}
@@ -162,6 +177,127 @@ func TestConnectionTimeout(t *testing.T) {
 	}
 }
 
+func TestConnectTimeout(t *testing.T) {
+	port := startSilentListener(t)
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	conf := ClientConfig{
+		User:            "testuser",
+		Port:            port,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		ConnectTimeout:  100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := Dial(context.Background(), "127.0.0.1", conf)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("dial took %s; connect timeout not applied", elapsed)
+	}
+}
+
+func TestConnectTimeoutDoesNotLimitCommand(t *testing.T) {
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		time.Sleep(300 * time.Millisecond)
+		return "done\n", "", 0
+	}))
+	defer cleanup()
+	_, port := sshtest.ParseAddr(t, addr)
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	client, err := Dial(context.Background(), "127.0.0.1", ClientConfig{
+		User:            "testuser",
+		Port:            port,
+		IdentityFiles:   []string{keyPath},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		ConnectTimeout:  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	// The command runs longer than the connect timeout.
+	stdout, _, _, err := client.RunCommand(context.Background(), "slow")
+	if err != nil || string(stdout) != "done\n" {
+		t.Errorf("RunCommand = %q, %v; want done", stdout, err)
+	}
+}
+
+func TestResolveHostConfConnectTimeout(t *testing.T) {
+	base := ClientConfig{ConnectTimeout: 5 * time.Second}
+	hostConfs := map[string]HostConfig{"slow": {ConnectTimeout: 20 * time.Second}}
+
+	if conf, _ := resolveHostConf(base, hostConfs, "slow"); conf.ConnectTimeout != 20*time.Second {
+		t.Errorf("per-host connect timeout = %s, want 20s", conf.ConnectTimeout)
+	}
+	if conf, _ := resolveHostConf(base, hostConfs, "other"); conf.ConnectTimeout != 5*time.Second {
+		t.Errorf("default connect timeout = %s, want 5s", conf.ConnectTimeout)
+	}
+}
+
+func TestResolveHostConfShell(t *testing.T) {
+	base := ClientConfig{Shell: "bash"}
+	hostConfs := map[string]HostConfig{"bsd": {Shell: "/usr/local/bin/bash"}}
+
+	if conf, _ := resolveHostConf(base, hostConfs, "bsd"); conf.Shell != "/usr/local/bin/bash" {
+		t.Errorf("per-host shell = %q, want /usr/local/bin/bash", conf.Shell)
+	}
+	if conf, _ := resolveHostConf(base, hostConfs, "other"); conf.Shell != "bash" {
+		t.Errorf("default shell = %q, want bash", conf.Shell)
+	}
+}
+
+func TestShellWrapsCommands(t *testing.T) {
+	pubKey, keyPath := sshtest.GenerateKey(t)
+
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return cmd, "", 0
+	}))
+	defer cleanup()
+
+	host, port := sshtest.ParseAddr(t, addr)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	conf := ClientConfig{
+		User:            "testuser",
+		Port:            port,
+		IdentityFiles:   []string{keyPath},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Shell:           "bash",
+	}
+	client, err := Dial(context.Background(), host, conf)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		name string
+		sudo bool
+		want string
+	}{
+		{"plain", false, `bash -c 'echo $((1+1))'`},
+		{"sudo", true, `sudo bash -c 'echo $((1+1))'`},
+	}
+	for _, tc := range tests {
+		stdout, _, _, err := runOn(context.Background(), client, "echo $((1+1))", tc.sudo, "", "", false, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if string(stdout) != tc.want {
+			t.Errorf("%s: remote command = %q, want %q", tc.name, stdout, tc.want)
+		}
+	}
+
+	if out, _, _, _ := client.RunCommandWithSudo(context.Background(), "id", "pw"); !strings.Contains(string(out), "sudo -S bash -c id") {
+		t.Errorf("sudo with password should use the shell, got %q", out)
+	}
+}
+
 func TestResolveHostKeyCallback_MissingKnownHosts(t *testing.T) {
 	t.Setenv("HOME", t.TempDir())
 
@@ -188,6 +324,57 @@ func TestResolveHostKeyCallback_Insecure(t *testing.T) {
 	}
 }
 
+func newTestHostKey(t *testing.T) gossh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestResolveHostKeyCallback_KnownHostsFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	known := newTestHostKey(t)
+	path := filepath.Join(dir, "ci_known_hosts")
+	line := knownhosts.Line([]string{"build-01"}, known) + "\n"
+	if err := os.WriteFile(path, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first file does not exist and is skipped, as OpenSSH does.
+	conf := ClientConfig{KnownHostsFile: filepath.Join(dir, "missing") + " " + path}
+	cb, err := resolveHostKeyCallback(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	if err := cb("build-01:22", addr, known); err != nil {
+		t.Errorf("known key rejected: %v", err)
+	}
+	if err := cb("build-01:22", addr, newTestHostKey(t)); err == nil {
+		t.Error("expected mismatched key to be rejected")
+	}
+}
+
+func TestResolveHostKeyCallback_KnownHostsFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nope")
+	_, err := resolveHostKeyCallback(ClientConfig{KnownHostsFile: path})
+	if err == nil {
+		t.Fatal("expected error when configured known_hosts file is missing")
+	}
+	if !strings.Contains(err.Error(), path) {
+		t.Errorf("error should name the configured path, got: %v", err)
+	}
+}
+
 func TestResolveHostKeyCallback_ExplicitCallback(t *testing.T) {
 	explicit := gossh.InsecureIgnoreHostKey()
 	conf := ClientConfig{HostKeyCallback: explicit}
@@ -314,6 +501,62 @@ func TestCommandWithStderrOutput(t *testing.T) {
 	}
 }
 
+func TestRunCommandCombined(t *testing.T) {
+	pubKey, keyPath := sshtest.GenerateKey(t)
+
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "stdout output\n", "stderr warning\n", 3
+	}))
+	defer cleanup()
+
+	host, port := sshtest.ParseAddr(t, addr)
+	client := dialTestClient(t, host, port, keyPath)
+	defer client.Close()
+
+	output, exitCode, err := client.RunCommandCombined(context.Background(), "mixedoutput")
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if exitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", exitCode)
+	}
+	got := string(output)
+	if len(got) != len("stdout output\nstderr warning\n") ||
+		!strings.Contains(got, "stdout output\n") || !strings.Contains(got, "stderr warning\n") {
+		t.Errorf("expected both streams in combined output, got %q", got)
+	}
+}
+
+func TestRunCommandStream(t *testing.T) {
+	pubKey, keyPath := sshtest.GenerateKey(t)
+
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "stdout output\n", "stderr warning\n", 2
+	}))
+	defer cleanup()
+
+	host, port := sshtest.ParseAddr(t, addr)
+	client := dialTestClient(t, host, port, keyPath)
+	defer client.Close()
+
+	var stdout, stderr safeBuffer
+	exitCode, err := client.RunCommandStream(context.Background(), "mixedoutput",
+		func(data []byte) { stdout.Write(data) },
+		func(data []byte) { stderr.Write(data) })
+	if err != nil {
+		t.Fatalf("run command: %v", err)
+	}
+	if exitCode != 2 {
+		t.Errorf("expected exit code 2, got %d", exitCode)
+	}
+	if got := string(stdout.Bytes()); got != "stdout output\n" {
+		t.Errorf("stdout = %q", got)
+	}
+	if got := string(stderr.Bytes()); got != "stderr warning\n" {
+		t.Errorf("stderr = %q", got)
+	}
+}
+
 func TestParseJumpHost(t *testing.T) {
 	tests := []struct {
 		spec     string
@@ -427,3 +670,233 @@ func TestProxyJumpSingleHop(t *testing.T) {
 		t.Errorf("expected 1 jump client, got %d", len(client.jumpClients))
 	}
 }
+
+// fakeSSHConfig replaces ~/.ssh/config lookups with entries, keyed by host
+// and then by directive, for the duration of the test.
+func fakeSSHConfig(t *testing.T, entries map[string]map[string]string) {
+	t.Helper()
+	orig := sshConfigGet
+	sshConfigGet = func(host, key string) string { return entries[host][key] }
+	t.Cleanup(func() { sshConfigGet = orig })
+}
+
+func TestProxyJumpFromSSHConfig(t *testing.T) {
+	pubKey, keyPath := sshtest.GenerateKey(t)
+
+	bastionAddr, bastionCleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithForwardTCP())
+	defer bastionCleanup()
+	targetAddr, targetCleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "from-target\n", "", 0
+	}))
+	defer targetCleanup()
+
+	bastionHost, bastionPort := sshtest.ParseAddr(t, bastionAddr)
+	targetHost, targetPort := sshtest.ParseAddr(t, targetAddr)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	// The target's ProxyJump names an alias that ssh_config maps to the
+	// bastion's address, user and port.
+	fakeSSHConfig(t, map[string]map[string]string{
+		targetHost: {"ProxyJump": "bastion"},
+		"bastion":  {"Hostname": bastionHost, "User": "testuser", "Port": strconv.Itoa(bastionPort)},
+	})
+
+	conf := ClientConfig{
+		User:            "testuser",
+		Port:            targetPort,
+		IdentityFiles:   []string{keyPath},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	}
+	client, err := Dial(context.Background(), targetHost, conf)
+	if err != nil {
+		t.Fatalf("dial via ssh_config ProxyJump: %v", err)
+	}
+	defer client.Close()
+	if len(client.jumpClients) != 1 {
+		t.Errorf("expected 1 jump client from ssh_config, got %d", len(client.jumpClients))
+	}
+	stdout, _, _, err := client.RunCommand(context.Background(), "hello")
+	if err != nil || string(stdout) != "from-target\n" {
+		t.Errorf("RunCommand = %q, %v; want from-target", stdout, err)
+	}
+
+	// An explicit "none" overrides ssh_config and dials directly.
+	conf.ProxyJump = "none"
+	direct, err := Dial(context.Background(), targetHost, conf)
+	if err != nil {
+		t.Fatalf("dial with ProxyJump none: %v", err)
+	}
+	defer direct.Close()
+	if len(direct.jumpClients) != 0 {
+		t.Errorf("ProxyJump none should dial directly, got %d jump clients", len(direct.jumpClients))
+	}
+}
+
+func TestJumpHostsPerHop(t *testing.T) {
+	// Each hop and the target accept a different key, so the chain only
+	// connects if every hop uses its own IdentityFile.
+	bastionKey, bastionKeyPath := sshtest.GenerateKey(t)
+	innerKey, innerKeyPath := sshtest.GenerateKey(t)
+	targetKey, targetKeyPath := sshtest.GenerateKey(t)
+
+	bastionAddr, bastionCleanup := sshtest.Start(t, sshtest.WithPublicKey(bastionKey), sshtest.WithForwardTCP())
+	defer bastionCleanup()
+	innerAddr, innerCleanup := sshtest.Start(t, sshtest.WithPublicKey(innerKey), sshtest.WithForwardTCP())
+	defer innerCleanup()
+	targetAddr, targetCleanup := sshtest.Start(t, sshtest.WithPublicKey(targetKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "from-target\n", "", 0
+	}))
+	defer targetCleanup()
+
+	bastionHost, bastionPort := sshtest.ParseAddr(t, bastionAddr)
+	innerHost, innerPort := sshtest.ParseAddr(t, innerAddr)
+	targetHost, targetPort := sshtest.ParseAddr(t, targetAddr)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	conf := ClientConfig{
+		User:            "testuser",
+		Port:            targetPort,
+		IdentityFiles:   []string{targetKeyPath},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		JumpHosts: []JumpHost{
+			{Host: bastionHost, User: "jump", Port: bastionPort, IdentityFile: bastionKeyPath},
+			{Host: innerHost, User: "inner", Port: innerPort, IdentityFile: innerKeyPath},
+		},
+		// JumpHosts wins over ProxyJump.
+		ProxyJump: "unused.invalid",
+	}
+	client, err := Dial(context.Background(), targetHost, conf)
+	if err != nil {
+		t.Fatalf("dial via jump hosts: %v", err)
+	}
+	defer client.Close()
+
+	if len(client.jumpClients) != 2 {
+		t.Errorf("expected 2 jump clients, got %d", len(client.jumpClients))
+	}
+	stdout, _, _, err := client.RunCommand(context.Background(), "hello")
+	if err != nil || string(stdout) != "from-target\n" {
+		t.Errorf("RunCommand = %q, %v; want from-target", stdout, err)
+	}
+}
+
+func TestJumpHostString(t *testing.T) {
+	tests := []struct {
+		hop  JumpHost
+		want string
+	}{
+		{JumpHost{Host: "bastion"}, "bastion"},
+		{JumpHost{Host: "bastion", User: "ops", Port: 2222}, "ops@bastion:2222"},
+		{JumpHost{Host: "::1", Port: 22}, "[::1]:22"},
+	}
+	for _, tt := range tests {
+		if got := tt.hop.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.hop, got, tt.want)
+		}
+	}
+}
+
+func TestKeyboardInteractive(t *testing.T) {
+	addr, cleanup := sshtest.Start(t, sshtest.WithKeyboardInteractive(map[string]string{
+		"Password: ":          "s3cret",
+		"Verification code: ": "123456",
+	}), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+	host, port := sshtest.ParseAddr(t, addr)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	answer := func(code string) KeyboardInteractiveCallback {
+		return func(h, instruction string, questions []string, echos []bool) ([]string, error) {
+			if h != host {
+				t.Errorf("callback host = %q, want %q", h, host)
+			}
+			answers := make([]string, len(questions))
+			for i, q := range questions {
+				switch q {
+				case "Password: ":
+					answers[i] = "s3cret"
+				case "Verification code: ":
+					answers[i] = code
+				}
+			}
+			return answers, nil
+		}
+	}
+	conf := ClientConfig{
+		User:            "testuser",
+		Port:            port,
+		IdentityFiles:   []string{filepath.Join(t.TempDir(), "missing")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	}
+
+	conf.KeyboardInteractiveCallback = answer("123456")
+	client, err := Dial(context.Background(), host, conf)
+	if err != nil {
+		t.Fatalf("dial with keyboard-interactive: %v", err)
+	}
+	client.Close()
+
+	conf.KeyboardInteractiveCallback = answer("000000")
+	if _, err := Dial(context.Background(), host, conf); !errors.Is(err, ErrAuth) {
+		t.Errorf("wrong code: err = %v, want ErrAuth", err)
+	}
+}
+
+func TestPassphraseProtectedKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	sshPub, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(sshPub), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+	host, port := sshtest.ParseAddr(t, addr)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	conf := ClientConfig{
+		User:            "testuser",
+		Port:            port,
+		IdentityFiles:   []string{keyPath},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	}
+
+	// Without a passphrase source the key is skipped.
+	if _, err := Dial(context.Background(), host, conf); !errors.Is(err, ErrAuth) {
+		t.Fatalf("no passphrase: err = %v, want ErrAuth", err)
+	}
+
+	prompts := 0
+	conf.PassphraseCallback = func(path string) ([]byte, error) {
+		prompts++
+		if path != keyPath {
+			t.Errorf("callback path = %q, want %q", path, keyPath)
+		}
+		return []byte("hunter2"), nil
+	}
+	for i := 0; i < 2; i++ {
+		client, err := Dial(context.Background(), host, conf)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		client.Close()
+	}
+	if prompts != 1 {
+		t.Errorf("passphrase asked %d times, want 1", prompts)
+	}
+}
@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+func init() {
+	proxy.RegisterDialerType("http", newHTTPConnectDialer)
+}
+
+// ParseDialProxy parses a ClientConfig.DialProxy URL: socks5://host:port,
+// socks5h://host:port or http://host:port, optionally with user:password@
+// credentials.
+func ParseDialProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("dial proxy %q: unsupported scheme %q (want socks5, socks5h or http)", raw, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("dial proxy %q: missing host", raw)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("dial proxy %q: missing port", raw)
+	}
+	return u, nil
+}
+
+// dialContext dials a network address with context cancellation support,
+// through the SOCKS or HTTP CONNECT proxy at proxyURL if it is not empty.
+func dialContext(ctx context.Context, proxyURL, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{}
+	if proxyURL == "" {
+		return d.DialContext(ctx, network, addr)
+	}
+	u, err := ParseDialProxy(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	dialer, err := proxy.FromURL(u, d)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy %s: %w", u.Redacted(), err)
+	}
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("via proxy %s: %w", u.Redacted(), err)
+	}
+	return conn, nil
+}
+
+// httpConnectDialer tunnels TCP connections through an HTTP proxy with the
+// CONNECT method.
+type httpConnectDialer struct {
+	proxyAddr string
+	auth      string // Proxy-Authorization value; empty without credentials
+	forward   proxy.Dialer
+}
+
+func newHTTPConnectDialer(u *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	d := &httpConnectDialer{proxyAddr: u.Host, forward: forward}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		creds := u.User.Username() + ":" + password
+		d.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	}
+	return d, nil
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if cd, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = cd.DialContext(ctx, "tcp", d.proxyAddr)
+	} else {
+		conn, err = d.forward.Dial("tcp", d.proxyAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Abort the CONNECT exchange if ctx ends first.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.auth != "" {
+		req.Header.Set("Proxy-Authorization", d.auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, contextError(ctx, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, contextError(ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// contextError returns ctx's error if it has ended, since that is why err
+// happened, and err otherwise.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// bufferedConn is a connection whose first bytes were already read into r,
+// such as an SSH banner that arrived with the proxy's CONNECT response.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,198 @@
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/agent462/herd/internal/sshtest"
+)
+
+// pipe copies between a and b until either side closes.
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// startConnectProxy starts an HTTP proxy that only supports CONNECT,
+// counting the tunnels it opens and requiring auth when it is not empty.
+func startConnectProxy(t *testing.T, auth string, tunnels *atomic.Int32) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		if auth != "" && r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		tunnels.Add(1)
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		pipe(conn, target)
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+// startSOCKS5Proxy starts a SOCKS5 proxy supporting only CONNECT without
+// authentication, counting the tunnels it opens.
+func startSOCKS5Proxy(t *testing.T, tunnels *atomic.Int32) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				// Greeting: version, method count, methods; choose no auth.
+				hdr := make([]byte, 2)
+				if _, err := io.ReadFull(conn, hdr); err != nil {
+					conn.Close()
+					return
+				}
+				io.CopyN(io.Discard, conn, int64(hdr[1]))
+				conn.Write([]byte{5, 0})
+
+				// Request: version, CONNECT, reserved, address type.
+				req := make([]byte, 4)
+				if _, err := io.ReadFull(conn, req); err != nil {
+					conn.Close()
+					return
+				}
+				var host string
+				switch req[3] {
+				case 1:
+					ip := make([]byte, 4)
+					io.ReadFull(conn, ip)
+					host = net.IP(ip).String()
+				case 3:
+					n := make([]byte, 1)
+					io.ReadFull(conn, n)
+					name := make([]byte, n[0])
+					io.ReadFull(conn, name)
+					host = string(name)
+				}
+				port := make([]byte, 2)
+				io.ReadFull(conn, port)
+				target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+				tunnels.Add(1)
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				pipe(conn, target)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialProxy(t *testing.T) {
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "proxied\n", "", 0
+	}))
+	defer cleanup()
+	host, port := sshtest.ParseAddr(t, addr)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	var httpTunnels, authTunnels, socksTunnels atomic.Int32
+	httpProxy := startConnectProxy(t, "", &httpTunnels)
+	authProxy := startConnectProxy(t, "Basic dXNlcjpzZWNyZXQ=", &authTunnels) // user:secret
+	socksProxy := startSOCKS5Proxy(t, &socksTunnels)
+
+	tests := []struct {
+		name    string
+		proxy   string
+		tunnels *atomic.Int32
+	}{
+		{"http", "http://" + httpProxy, &httpTunnels},
+		{"http auth", "http://user:secret@" + authProxy, &authTunnels},
+		{"socks5", "socks5://" + socksProxy, &socksTunnels},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := Dial(context.Background(), host, ClientConfig{
+				User:            "testuser",
+				Port:            port,
+				IdentityFiles:   []string{keyPath},
+				HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+				DialProxy:       tt.proxy,
+			})
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer client.Close()
+
+			stdout, _, _, err := client.RunCommand(context.Background(), "hostname")
+			if err != nil || string(stdout) != "proxied\n" {
+				t.Errorf("RunCommand = %q, %v; want proxied", stdout, err)
+			}
+			if n := tt.tunnels.Load(); n != 1 {
+				t.Errorf("proxy opened %d tunnels, want 1", n)
+			}
+		})
+	}
+
+	t.Run("auth rejected", func(t *testing.T) {
+		_, err := Dial(context.Background(), host, ClientConfig{
+			User:            "testuser",
+			Port:            port,
+			IdentityFiles:   []string{keyPath},
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			DialProxy:       "http://user:wrong@" + authProxy,
+		})
+		if err == nil || !strings.Contains(err.Error(), "407") {
+			t.Errorf("err = %v, want the proxy's 407", err)
+		}
+	})
+}
+
+func TestParseDialProxy(t *testing.T) {
+	for _, raw := range []string{"socks5://proxy:1080", "socks5h://proxy:1080", "http://user:pw@proxy:3128"} {
+		if _, err := ParseDialProxy(raw); err != nil {
+			t.Errorf("ParseDialProxy(%q): %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"https://proxy:3128", "proxy:3128", "socks5://proxy", "http://:3128"} {
+		if _, err := ParseDialProxy(raw); err == nil {
+			t.Errorf("ParseDialProxy(%q): expected an error", raw)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package ssh
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"syscall"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
@@ -25,8 +27,24 @@ func (e *ConnectError) Unwrap() error {
 	return e.Err
 }
 
+// ConnectFailed marks e as a failure to connect, before any command ran,
+// for the executor's retry logic.
+func (e *ConnectError) ConnectFailed() bool { return true }
+
+// connectFailure marks an error WrapConnectError has no hint for as a
+// failure to connect. Its message is the wrapped error's.
+type connectFailure struct {
+	err error
+}
+
+func (e *connectFailure) Error() string       { return e.err.Error() }
+func (e *connectFailure) Unwrap() error       { return e.err }
+func (e *connectFailure) ConnectFailed() bool { return true }
+
 // WrapConnectError wraps an SSH connection error with a friendly hint.
-// If the error doesn't match any known patterns, it's returned as-is.
+// If the error doesn't match any known patterns, it's returned with its
+// message unchanged. Either way the result is marked as a connect failure
+// (see ConnectError.ConnectFailed).
 func WrapConnectError(host string, err error) error {
 	if err == nil {
 		return nil
@@ -122,5 +140,81 @@ func WrapConnectError(host string, err error) error {
 		}
 	}
 
-	return err
+	return &connectFailure{err: err}
+}
+
+// Sentinel errors identifying why a dial failed. Errors returned by Dial
+// wrap exactly one of these when the cause is recognised, so callers can
+// test for them with errors.Is.
+var (
+	ErrAuth        = errors.New("authentication failed")
+	ErrHostKey     = errors.New("host key verification failed")
+	ErrConnRefused = errors.New("connection refused")
+	ErrTimeout     = errors.New("connection timed out")
+)
+
+// DialError is returned by Dial when a failure can be attributed to one of
+// the sentinel errors above. It wraps both the sentinel (Kind) and the
+// underlying error, and its message is the underlying error's message.
+type DialError struct {
+	Kind error
+	Host string
+	Err  error
+}
+
+func (e *DialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DialError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// FailureKind names the failure category for grouping and display:
+// "auth", "host-key", "refused" or "timeout".
+func (e *DialError) FailureKind() string {
+	switch e.Kind {
+	case ErrAuth:
+		return "auth"
+	case ErrHostKey:
+		return "host-key"
+	case ErrConnRefused:
+		return "refused"
+	default:
+		return "timeout"
+	}
+}
+
+// classifyDialError wraps err in a DialError when its cause is recognised.
+// Host key problems are checked before authentication because both surface
+// as handshake failures.
+func classifyDialError(host string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var kind error
+	msg := err.Error()
+	var keyErr *knownhosts.KeyError
+	var authErr *ssh.ServerAuthError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &keyErr),
+		strings.Contains(msg, "knownhosts"),
+		strings.Contains(msg, "no known_hosts"),
+		strings.Contains(msg, "host key"):
+		kind = ErrHostKey
+	case errors.As(err, &authErr),
+		strings.Contains(msg, "unable to authenticate"),
+		strings.Contains(msg, "no supported methods remain"):
+		kind = ErrAuth
+	case errors.Is(err, syscall.ECONNREFUSED),
+		strings.Contains(msg, "connection refused"):
+		kind = ErrConnRefused
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		kind = ErrTimeout
+	default:
+		return err
+	}
+	return &DialError{Kind: kind, Host: host, Err: err}
 }
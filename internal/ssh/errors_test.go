@@ -1,10 +1,15 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"syscall"
 	"testing"
+
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestWrapConnectError_ConnectionRefused(t *testing.T) {
@@ -75,3 +80,54 @@ func TestWrapConnectError_Unknown(t *testing.T) {
 		t.Error("expected unwrapped error for unknown error type")
 	}
 }
+
+func TestClassifyDialError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+		kind string
+	}{
+		{"auth", fmt.Errorf("ssh handshake with h:22: ssh: handshake failed: ssh: unable to authenticate"), ErrAuth, "auth"},
+		{"host key mismatch", fmt.Errorf("ssh handshake: %w", &knownhosts.KeyError{Want: []knownhosts.KnownKey{{}}}), ErrHostKey, "host-key"},
+		{"no known_hosts", fmt.Errorf("host key callback: no known_hosts file found"), ErrHostKey, "host-key"},
+		{"refused", fmt.Errorf("dial h:22: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), ErrConnRefused, "refused"},
+		{"deadline", fmt.Errorf("dial h:22: %w", context.DeadlineExceeded), ErrTimeout, "timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyDialError("h", tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Fatalf("errors.Is(%v, %v) = false", got, tt.want)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Error("underlying error should remain reachable")
+			}
+			if got.Error() != tt.err.Error() {
+				t.Errorf("message = %q, want %q", got.Error(), tt.err.Error())
+			}
+			var de *DialError
+			if !errors.As(got, &de) || de.FailureKind() != tt.kind {
+				t.Errorf("FailureKind = %v, want %q", de, tt.kind)
+			}
+		})
+	}
+}
+
+func TestClassifyDialError_Unrecognised(t *testing.T) {
+	err := errors.New("something else")
+	if got := classifyDialError("h", err); got != err {
+		t.Errorf("got %v, want error returned unchanged", got)
+	}
+	if classifyDialError("h", nil) != nil {
+		t.Error("nil should stay nil")
+	}
+}
+
+func TestWrapConnectError_KeepsDialKind(t *testing.T) {
+	err := classifyDialError("myhost", fmt.Errorf("ssh: unable to authenticate"))
+	wrapped := WrapConnectError("myhost", fmt.Errorf("connect: %w", err))
+	if !errors.Is(wrapped, ErrAuth) {
+		t.Errorf("ConnectError should still wrap ErrAuth: %v", wrapped)
+	}
+}
@@ -0,0 +1,213 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agent462/herd/internal/cmdutil"
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/parser"
+)
+
+// HostFacts is metadata about a host discovered by Probe.
+type HostFacts struct {
+	Distro  string // ID from /etc/os-release, e.g. "debian"
+	Version string // VERSION_ID, e.g. "12"; empty for rolling releases
+
+	// Values holds every fact by name: the "os" fact (see OS) and the
+	// output of each fact probe.
+	Values map[string]string
+}
+
+// OS returns the distro and version joined by a dash, e.g. "debian-12", or
+// just the distro when there is no version.
+func (f HostFacts) OS() string {
+	if f.Version == "" {
+		return f.Distro
+	}
+	return f.Distro + "-" + f.Version
+}
+
+// FactValues returns the fact values of each host in facts, as used by
+// @os: and @fact: selectors.
+func FactValues(facts map[string]HostFacts) map[string]map[string]string {
+	out := make(map[string]map[string]string, len(facts))
+	for h, f := range facts {
+		out[h] = f.Values
+	}
+	return out
+}
+
+// DefaultFactProbes returns the built-in fact probes: fact name -> shell
+// command whose trimmed output is the fact's value. The "os" fact is not a
+// probe; it comes from /etc/os-release unless a probe named "os" replaces
+// it.
+func DefaultFactProbes() map[string]string {
+	return map[string]string{
+		"arch":   "uname -m",
+		"kernel": "uname -r",
+		"cpus":   "nproc 2>/dev/null || getconf _NPROCESSORS_ONLN",
+	}
+}
+
+// MergeFactProbes returns the default fact probes with extra added, replacing
+// defaults of the same name.
+func MergeFactProbes(extra map[string]string) map[string]string {
+	probes := DefaultFactProbes()
+	for name, cmd := range extra {
+		probes[name] = cmd
+	}
+	return probes
+}
+
+// cachedFacts is a cached set of facts for one host.
+type cachedFacts struct {
+	facts   HostFacts
+	fetched time.Time
+}
+
+// SetFactProbes replaces the probe commands used by Probe and RefreshFacts
+// and sets how long collected facts stay fresh (0 keeps them for the life of
+// the pool). Cached facts are discarded.
+func (p *Pool) SetFactProbes(probes map[string]string, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.factProbes = probes
+	p.factsTTL = ttl
+	p.facts = make(map[string]cachedFacts)
+}
+
+// Facts returns a copy of the cached fact values for host, or nil if the
+// host has not been probed or its facts have expired.
+func (p *Pool) Facts(host string) map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cf, ok := p.facts[host]
+	if !ok || p.expired(cf) {
+		return nil
+	}
+	out := make(map[string]string, len(cf.facts.Values))
+	for k, v := range cf.facts.Values {
+		out[k] = v
+	}
+	return out
+}
+
+// Probe collects facts for the hosts that have none cached or whose facts
+// have expired, and returns the facts known for all hosts afterwards. opts
+// configure the probe run, such as the session's concurrency and timeout.
+func (p *Pool) Probe(ctx context.Context, hosts []string, opts ...executor.Option) map[string]HostFacts {
+	var stale []string
+	p.mu.Lock()
+	for _, h := range hosts {
+		if cf, ok := p.facts[h]; !ok || p.expired(cf) {
+			stale = append(stale, h)
+		}
+	}
+	p.mu.Unlock()
+
+	if len(stale) > 0 {
+		p.RefreshFacts(ctx, stale, opts...)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]HostFacts, len(hosts))
+	for _, h := range hosts {
+		if cf, ok := p.facts[h]; ok && !p.expired(cf) {
+			out[h] = cf.facts
+		}
+	}
+	return out
+}
+
+// RefreshFacts reads /etc/os-release and runs the fact probes on hosts,
+// replacing any cached facts. Everything for a host runs in a single
+// command, with opts applied to the run. Hosts that cannot be reached keep
+// their previous facts.
+func (p *Pool) RefreshFacts(ctx context.Context, hosts []string, opts ...executor.Option) {
+	p.mu.Lock()
+	probes := p.factProbes
+	p.mu.Unlock()
+	if probes == nil {
+		probes = DefaultFactProbes()
+	}
+
+	results := executor.New(p, opts...).Execute(ctx, hosts, factsCommand(probes))
+
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		p.facts[r.Host] = cachedFacts{facts: parseFacts(r.Host, r.Stdout), fetched: now}
+	}
+}
+
+// expired reports whether cf is past the facts TTL. p.mu must be held.
+func (p *Pool) expired(cf cachedFacts) bool {
+	return p.factsTTL > 0 && time.Since(cf.fetched) > p.factsTTL
+}
+
+// factsMarker separates /etc/os-release from the probe output in the
+// output of factsCommand.
+const factsMarker = "--herd-facts--"
+
+// factsCommand combines /etc/os-release and probes into one command. After
+// the file and a factsMarker line, it prints a name=value line per probe, in
+// name order. Each probe runs in its own subshell so one failing probe does
+// not affect the others.
+func factsCommand(probes map[string]string) string {
+	names := make([]string, 0, len(probes))
+	for name := range probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = `echo "` + name + `=$(sh -c ` + cmdutil.Quote(probes[name]) + ` 2>/dev/null | head -n 1)"`
+	}
+	return "cat /etc/os-release 2>/dev/null; echo " + factsMarker + "; " + strings.Join(parts, "; ")
+}
+
+// parseFacts reads the output of factsCommand for host: /etc/os-release,
+// parsed with the built-in os-release parser, and then name=value lines,
+// skipping facts with empty values. A probe named "os" replaces the fact
+// derived from /etc/os-release.
+func parseFacts(host string, out []byte) HostFacts {
+	f := HostFacts{Values: make(map[string]string)}
+	osRelease, probed, ok := bytes.Cut(out, []byte(factsMarker+"\n"))
+	if !ok {
+		osRelease, probed = nil, out
+	}
+
+	for _, fv := range parser.BuiltinOSRelease().Parse(host, osRelease).Fields {
+		if fv.Value == "-" {
+			continue
+		}
+		switch fv.Field {
+		case "id":
+			f.Distro = fv.Value
+		case "version_id":
+			f.Version = fv.Value
+		}
+	}
+	if f.Distro != "" {
+		f.Values["os"] = f.OS()
+	}
+
+	for _, line := range strings.Split(string(probed), "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		value = strings.TrimSpace(value)
+		if ok && name != "" && value != "" {
+			f.Values[name] = value
+		}
+	}
+	return f
+}
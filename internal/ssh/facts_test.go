@@ -0,0 +1,125 @@
+package ssh_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	hssh "github.com/agent462/herd/internal/ssh"
+	"github.com/agent462/herd/internal/sshtest"
+)
+
+// newFactsPool starts a test server answering every command with output and
+// returns a pool with "host-1" on it and an unreachable "bad-host", plus a
+// counter of commands run and the last command seen.
+func newFactsPool(t *testing.T, output string) (*hssh.Pool, *atomic.Int32, *atomic.Value) {
+	t.Helper()
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	var calls atomic.Int32
+	var lastCmd atomic.Value
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		calls.Add(1)
+		lastCmd.Store(cmd)
+		return output, "", 0
+	}))
+	t.Cleanup(cleanup)
+
+	_, port := sshtest.ParseAddr(t, addr)
+	pool := hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+		},
+		map[string]hssh.HostConfig{
+			"host-1":   {Hostname: "127.0.0.1", Port: port, IdentityFile: keyPath},
+			"bad-host": {Hostname: "127.0.0.1", Port: 1},
+		},
+	)
+	t.Cleanup(func() { pool.Close() })
+	return pool, &calls, &lastCmd
+}
+
+func TestPool_Probe(t *testing.T) {
+	pool, calls, lastCmd := newFactsPool(t, "NAME=\"Debian GNU/Linux\"\nID=debian\nVERSION_ID=\"12\"\n--herd-facts--\narch=x86_64\ncpus=4\nkernel=\n")
+
+	facts := pool.Probe(context.Background(), []string{"host-1", "bad-host"})
+
+	got := facts["host-1"]
+	if got.Distro != "debian" || got.Version != "12" || got.OS() != "debian-12" {
+		t.Errorf("host-1 os = %q %q (%s), want debian 12", got.Distro, got.Version, got.OS())
+	}
+	if got.Values["os"] != "debian-12" || got.Values["arch"] != "x86_64" || got.Values["cpus"] != "4" {
+		t.Errorf("host-1 facts = %v", got.Values)
+	}
+	if _, ok := got.Values["kernel"]; ok {
+		t.Error("expected empty fact values to be skipped")
+	}
+	if _, ok := facts["bad-host"]; ok {
+		t.Error("expected no facts for unreachable host")
+	}
+
+	cmd := lastCmd.Load().(string)
+	if !strings.Contains(cmd, "cat /etc/os-release") {
+		t.Errorf("probe command does not read /etc/os-release: %s", cmd)
+	}
+	for name := range hssh.DefaultFactProbes() {
+		if !strings.Contains(cmd, `echo "`+name+`=`) {
+			t.Errorf("probe command missing fact %q: %s", name, cmd)
+		}
+	}
+
+	// A second probe is answered from the cache; a refresh is not.
+	pool.Probe(context.Background(), []string{"host-1"})
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected host-1 to be probed once, got %d", n)
+	}
+	pool.RefreshFacts(context.Background(), []string{"host-1"})
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected RefreshFacts to probe again, got %d probes", n)
+	}
+}
+
+func TestPool_FactsTTL(t *testing.T) {
+	pool, calls, lastCmd := newFactsPool(t, "role=db\n")
+	pool.SetFactProbes(map[string]string{"role": "cat /etc/role"}, 20*time.Millisecond)
+
+	pool.Probe(context.Background(), []string{"host-1"})
+	if got := pool.Facts("host-1"); got["role"] != "db" {
+		t.Fatalf("Facts(host-1) = %v", got)
+	}
+	if cmd := lastCmd.Load().(string); !strings.Contains(cmd, "/etc/role") || strings.Contains(cmd, "uname") {
+		t.Errorf("expected only the configured probe, got %s", cmd)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if got := pool.Facts("host-1"); got != nil {
+		t.Errorf("expected expired facts to be hidden, got %v", got)
+	}
+	pool.Probe(context.Background(), []string{"host-1"})
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected expired facts to be probed again, got %d probes", n)
+	}
+}
+
+func TestPool_ProbeOSOverride(t *testing.T) {
+	pool, _, _ := newFactsPool(t, "ID=debian\nVERSION_ID=12\n--herd-facts--\nos=custom-os\n")
+	pool.SetFactProbes(hssh.MergeFactProbes(map[string]string{"os": "cat /etc/custom"}), 0)
+
+	f := pool.Probe(context.Background(), []string{"host-1"})["host-1"]
+	if f.Values["os"] != "custom-os" || f.OS() != "debian-12" {
+		t.Errorf("os fact = %q, OS() = %q; want the probe to override the fact only", f.Values["os"], f.OS())
+	}
+}
+
+func TestMergeFactProbes(t *testing.T) {
+	probes := hssh.MergeFactProbes(map[string]string{"os": "custom", "role": "cat /etc/role"})
+	if probes["os"] != "custom" || probes["role"] != "cat /etc/role" || probes["arch"] == "" {
+		t.Errorf("unexpected merged probes: %v", probes)
+	}
+}
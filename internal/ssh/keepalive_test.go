@@ -0,0 +1,183 @@
+package ssh_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	hssh "github.com/agent462/herd/internal/ssh"
+	"github.com/agent462/herd/internal/sshtest"
+)
+
+// cuttableProxy forwards TCP connections to target until cut, which drops
+// every open connection as a NAT or firewall timeout would.
+type cuttableProxy struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func startProxy(t *testing.T, target string) (*cuttableProxy, int) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &cuttableProxy{listener: l}
+	go func() {
+		for {
+			in, err := l.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", target)
+			if err != nil {
+				in.Close()
+				continue
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, in, out)
+			p.mu.Unlock()
+			go io.Copy(in, out)
+			go io.Copy(out, in)
+		}
+	}()
+	t.Cleanup(func() { l.Close(); p.cut() })
+	_, portStr, _ := net.SplitHostPort(l.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	return p, port
+}
+
+func (p *cuttableProxy) cut() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+func keepAlivePool(t *testing.T, port int, keyPath string) *hssh.Pool {
+	return hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+		},
+		map[string]hssh.HostConfig{
+			"host-1": {Hostname: "127.0.0.1", Port: port, IdentityFile: keyPath},
+		},
+		hssh.WithKeepAlive(20*time.Millisecond),
+	)
+}
+
+func TestPool_KeepAliveKeepsHealthyConnection(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+	_, port := sshtest.ParseAddr(t, addr)
+
+	pool := keepAlivePool(t, port, keyPath)
+	if r := pool.Run(context.Background(), "host-1", "true"); r.Err != nil {
+		t.Fatalf("unexpected error: %v", r.Err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if !pool.IsConnected("host-1") {
+		t.Error("healthy connection should survive keepalives")
+	}
+
+	done := make(chan error)
+	go func() { done <- pool.Close() }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not stop keepalive goroutines")
+	}
+}
+
+func TestPool_KeepAliveEvictsDeadConnection(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+
+	proxy, port := startProxy(t, addr)
+	pool := keepAlivePool(t, port, keyPath)
+	defer pool.Close()
+
+	if r := pool.Run(context.Background(), "host-1", "true"); r.Err != nil {
+		t.Fatalf("unexpected error: %v", r.Err)
+	}
+	proxy.cut()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.IsConnected("host-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("dead connection was not evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r := pool.Run(context.Background(), "host-1", "true")
+	if r.Err != nil {
+		t.Fatalf("redial after eviction: %v", r.Err)
+	}
+	if r.Reused {
+		t.Error("expected a fresh connection after eviction")
+	}
+}
+
+func TestPool_Ping(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+
+	proxy, port := startProxy(t, addr)
+	pool := hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+		},
+		map[string]hssh.HostConfig{
+			"host-1": {Hostname: "127.0.0.1", Port: port, IdentityFile: keyPath},
+		},
+	)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if pool.Ping(ctx, "host-1") {
+		t.Fatal("Ping should fail before any connection is made")
+	}
+	if r := pool.Run(ctx, "host-1", "true"); r.Err != nil {
+		t.Fatalf("unexpected error: %v", r.Err)
+	}
+	if !pool.Ping(ctx, "host-1") {
+		t.Fatal("Ping should succeed on a live connection")
+	}
+
+	proxy.cut()
+	if pool.Ping(ctx, "host-1") {
+		t.Fatal("Ping should fail once the connection is cut")
+	}
+	if pool.IsConnected("host-1") {
+		t.Error("a connection that fails Ping should be evicted")
+	}
+}
@@ -0,0 +1,104 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/agent462/herd/internal/pathutil"
+)
+
+// LoadPasswordFile reads per-host SSH passwords from path. Each non-blank
+// line not starting with # has the form "host: password". The host ends at
+// the first colon followed by a space or tab, so IPv6 addresses such as
+// "fe80::1: password" need no brackets, or at the first colon if no colon
+// is; the password is everything after it and any spaces following it. A
+// host of "*" supplies the password for hosts without their own line.
+//
+// The file must not be readable or writable by group or others, as with
+// OpenSSH private keys. Errors never include passwords.
+func LoadPasswordFile(path string) (map[string]string, error) {
+	path = pathutil.ExpandHome(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("password file: %w", err)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return nil, fmt.Errorf("password file %s is accessible by others (mode %04o); run chmod 600 %s", path, perm, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("password file: %w", err)
+	}
+	defer f.Close()
+
+	passwords := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		host, password, ok := cutHost(line)
+		host = strings.TrimSpace(host)
+		if !ok || host == "" {
+			return nil, fmt.Errorf("password file %s line %d: expected \"host: password\"", path, n)
+		}
+		passwords[host] = strings.TrimLeft(password, " \t")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("password file %s: %w", path, err)
+	}
+	return passwords, nil
+}
+
+// cutHost splits a password file line into its host and password at the
+// first colon followed by a space or tab, or else at the first colon.
+func cutHost(line string) (host, password string, ok bool) {
+	for i := 0; i+1 < len(line); i++ {
+		if line[i] == ':' && (line[i+1] == ' ' || line[i+1] == '\t') {
+			return line[:i], line[i+1:], true
+		}
+	}
+	return strings.Cut(line, ":")
+}
+
+// lookupPassword returns host's password from passwords, falling back to
+// the "*" entry.
+func lookupPassword(passwords map[string]string, host string) (string, bool) {
+	if pw, ok := passwords[host]; ok {
+		return pw, true
+	}
+	pw, ok := passwords["*"]
+	return pw, ok
+}
+
+// passwordAuthMethod returns the password auth method for host: the stored
+// password if there is one, then the interactive callback if the stored
+// password is rejected. The SSH client tries each auth method type once,
+// so both share a single retryable method. It returns nil if neither is
+// available.
+func passwordAuthMethod(host, stored string, hasStored bool, callback PasswordCallback) ssh.AuthMethod {
+	switch {
+	case hasStored && callback != nil:
+		attempt := 0
+		return ssh.RetryableAuthMethod(ssh.PasswordCallback(func() (string, error) {
+			attempt++
+			if attempt == 1 {
+				return stored, nil
+			}
+			return callback(host)
+		}), 2)
+	case hasStored:
+		return ssh.Password(stored)
+	case callback != nil:
+		return ssh.PasswordCallback(func() (string, error) {
+			return callback(host)
+		})
+	}
+	return nil
+}
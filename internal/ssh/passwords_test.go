@@ -0,0 +1,148 @@
+package ssh_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	hssh "github.com/agent462/herd/internal/ssh"
+	"github.com/agent462/herd/internal/sshtest"
+)
+
+func writePasswordFile(t *testing.T, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "passwords")
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPasswordFile(t *testing.T) {
+	path := writePasswordFile(t, "# legacy switches\nsw-01: s3cret\n\nsw-02:  pa:ss word \r\nfe80::1: v6: pass\nsw-03:nospace\n*: fallback\n", 0o600)
+	got, err := hssh.LoadPasswordFile(path)
+	if err != nil {
+		t.Fatalf("LoadPasswordFile: %v", err)
+	}
+	want := map[string]string{"sw-01": "s3cret", "sw-02": "pa:ss word ", "fe80::1": "v6: pass", "sw-03": "nospace", "*": "fallback"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for host, pw := range want {
+		if got[host] != pw {
+			t.Errorf("%s: got %q, want %q", host, got[host], pw)
+		}
+	}
+}
+
+func TestLoadPasswordFileRejectsOpenPermissions(t *testing.T) {
+	path := writePasswordFile(t, "sw-01: s3cret\n", 0o644)
+	_, err := hssh.LoadPasswordFile(path)
+	if err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+}
+
+func TestLoadPasswordFileMalformedLineHidesContent(t *testing.T) {
+	path := writePasswordFile(t, "sw-01: s3cret\nhunter2\n", 0o600)
+	_, err := hssh.LoadPasswordFile(path)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected line 2 error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "hunter2") || strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("error leaks file content: %v", err)
+	}
+}
+
+func TestPool_PasswordFile(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	addr, cleanup := sshtest.Start(t, sshtest.WithPassword("s3cret"), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+	_, port := sshtest.ParseAddr(t, addr)
+
+	tests := []struct {
+		name     string
+		file     string
+		callback hssh.PasswordCallback
+		wantErr  bool
+	}{
+		{name: "exact host", file: "127.0.0.1: s3cret\n"},
+		{name: "wildcard", file: "other: nope\n*: s3cret\n"},
+		{
+			name:     "falls back to callback",
+			file:     "127.0.0.1: wrong\n",
+			callback: func(string) (string, error) { return "s3cret", nil },
+		},
+		{name: "wrong password", file: "127.0.0.1: wrong\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := hssh.NewPool(
+				hssh.ClientConfig{
+					HostKeyCallback:  gossh.InsecureIgnoreHostKey(),
+					User:             "testuser",
+					IdentityFiles:    []string{filepath.Join(t.TempDir(), "missing")},
+					PasswordFile:     writePasswordFile(t, tt.file, 0o600),
+					PasswordCallback: tt.callback,
+				},
+				map[string]hssh.HostConfig{
+					"host-1": {Hostname: "127.0.0.1", Port: port},
+				},
+			)
+			defer pool.Close()
+
+			result := pool.Run(context.Background(), "host-1", "true")
+			if tt.wantErr {
+				if result.Err == nil {
+					t.Fatal("expected auth error")
+				}
+				return
+			}
+			if result.Err != nil {
+				t.Fatalf("unexpected error: %v", result.Err)
+			}
+		})
+	}
+}
+
+func TestPool_PasswordFileReadOnce(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	addr, cleanup := sshtest.Start(t, sshtest.WithPassword("s3cret"), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "ok\n", "", 0
+	}))
+	defer cleanup()
+	_, port := sshtest.ParseAddr(t, addr)
+
+	path := writePasswordFile(t, "*: s3cret\n", 0o600)
+	pool := hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+			IdentityFiles:   []string{filepath.Join(t.TempDir(), "missing")},
+			PasswordFile:    path,
+		},
+		map[string]hssh.HostConfig{
+			"host-1": {Hostname: "127.0.0.1", Port: port},
+			"host-2": {Hostname: "127.0.0.1", Port: port},
+		},
+	)
+	defer pool.Close()
+
+	if result := pool.Run(context.Background(), "host-1", "true"); result.Err != nil {
+		t.Fatalf("host-1: %v", result.Err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if result := pool.Run(context.Background(), "host-2", "true"); result.Err != nil {
+		t.Errorf("host-2 should dial with the passwords already loaded: %v", result.Err)
+	}
+}
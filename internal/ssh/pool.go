@@ -8,6 +8,8 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/singleflight"
 
@@ -25,15 +27,70 @@ type Pool struct {
 	hostConfs    map[string]HostConfig
 	sudo         bool
 	sudoPassword string
+	sudoUser     string                    // run sudo commands as this user; empty means root
+	retries      int                       // reconnect attempts after a reconnectable error; see SetReconnect
+	backoff      time.Duration             // delay before each reconnect attempt, doubling each time
+	facts        map[string]cachedFacts    // host -> cached facts, see Probe
+	factProbes   map[string]string         // fact name -> probe command; nil uses DefaultFactProbes
+	factsTTL     time.Duration             // facts older than this are re-probed; 0 never expires
+	active       int                       // in-flight operations, see Track
+	idle         chan struct{}             // closed when active drops to zero; nil if nobody is waiting
+	keepAlive    time.Duration             // keepalive interval; 0 disables, see WithKeepAlive
+	keepAlives   map[*Client]chan struct{} // closed to stop a client's keepalive goroutine
+	keepAliveWG  sync.WaitGroup
+	dialSem      chan struct{} // bounds concurrent dials; nil is unlimited, see WithDialConcurrency
+
+	passwordsOnce sync.Once         // loads baseConf.PasswordFile on first dial
+	passwords     map[string]string // its contents, shared by every dial
+	passwordsErr  error
+}
+
+// PoolOption configures a Pool.
+type PoolOption func(*Pool)
+
+// WithKeepAlive sends an SSH keepalive request on every cached connection
+// each interval, so connections behind NAT or firewalls stay open while a
+// session is idle. A connection whose keepalive fails, or goes unanswered
+// for an interval, is evicted and redialed on next use. An interval of 0
+// disables keepalives.
+func WithKeepAlive(interval time.Duration) PoolOption {
+	return func(p *Pool) {
+		if interval > 0 {
+			p.keepAlive = interval
+		}
+	}
+}
+
+// WithDialConcurrency limits how many connections the pool dials at once.
+// Dials beyond n wait for a slot, so targeting hundreds of hosts does not
+// start hundreds of handshakes against the SSH agent at the same time.
+// Cached connections are reused without waiting. The limit is separate from
+// executor.WithConcurrency, which bounds commands in flight: with a command
+// concurrency of 100 and a dial concurrency of 20, the first command still
+// runs on up to 100 hosts at once, but only 20 of them connect at a time.
+// n <= 0 means no limit.
+func WithDialConcurrency(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.dialSem = make(chan struct{}, n)
+		}
+	}
 }
 
 // NewPool creates a connection pool with the given base config and per-host overrides.
-func NewPool(baseConf ClientConfig, hostConfs map[string]HostConfig) *Pool {
-	return &Pool{
-		clients:   make(map[string]*Client),
-		baseConf:  baseConf,
-		hostConfs: hostConfs,
+func NewPool(baseConf ClientConfig, hostConfs map[string]HostConfig, opts ...PoolOption) *Pool {
+	p := &Pool{
+		clients:    make(map[string]*Client),
+		baseConf:   baseConf,
+		hostConfs:  hostConfs,
+		retries:    1,
+		facts:      make(map[string]cachedFacts),
+		keepAlives: make(map[*Client]chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // SetSudo enables or disables sudo mode. When password is non-empty, a PTY
@@ -46,63 +103,167 @@ func (p *Pool) SetSudo(enable bool, password string) {
 	p.sudoPassword = password
 }
 
+// SetSudoUser sets the user that sudo mode runs commands as, so they become
+// "sudo -u user ...". An empty user restores the default of root. It has no
+// effect unless sudo mode is enabled with SetSudo.
+func (p *Pool) SetSudoUser(user string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sudoUser = user
+}
+
+// SetReconnect configures how Run recovers from connection errors: up to
+// retries reconnect attempts, waiting backoff before the first and doubling
+// the wait before each further attempt. Waits are cut short when the context
+// is done. The default is a single immediate retry.
+func (p *Pool) SetReconnect(retries int, backoff time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if retries < 0 {
+		retries = 0
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	p.retries = retries
+	p.backoff = backoff
+}
+
 // Run implements executor.Runner. It reuses a cached connection if available,
 // dialing a new one if needed. If a command fails with what looks like a
-// connection error, it evicts the cached connection and retries once.
+// connection error, it evicts the cached connection and retries as
+// configured by SetReconnect (once, immediately, by default).
+// The result's Reused field reports whether the final attempt ran on a cached
+// connection.
 func (p *Pool) Run(ctx context.Context, host string, command string) *executor.HostResult {
+	return p.run(ctx, host, command, false, nil)
+}
+
+// RunCombined implements executor.CombinedRunner. It is like Run but
+// captures stdout and stderr interleaved in Stdout, leaving Stderr empty.
+func (p *Pool) RunCombined(ctx context.Context, host string, command string) *executor.HostResult {
+	return p.run(ctx, host, command, true, nil)
+}
+
+// RunStream implements executor.StreamRunner. It is like Run but passes
+// output to emit as it arrives. A command that has already produced output
+// is not retried after a connection error, so no output is emitted twice.
+func (p *Pool) RunStream(ctx context.Context, host string, command string, emit func(stream int, data []byte)) *executor.HostResult {
+	return p.run(ctx, host, command, false, emit)
+}
+
+// run runs command on host, reconnecting after connection errors. A non-nil
+// emit receives the output as it arrives instead of the result.
+func (p *Pool) run(ctx context.Context, host string, command string, combined bool, emit func(int, []byte)) *executor.HostResult {
+	defer p.Track()()
+
 	result := &executor.HostResult{Host: host}
 
-	stdout, stderr, exitCode, err := p.exec(ctx, host, command)
-	if err != nil && isReconnectable(err) {
+	p.mu.Lock()
+	retries, backoff := p.retries, p.backoff
+	p.mu.Unlock()
+
+	var emitted atomic.Bool
+	if emit != nil {
+		inner := emit
+		emit = func(stream int, data []byte) {
+			emitted.Store(true)
+			inner(stream, data)
+		}
+	}
+
+	// When the executor retries failed connections itself, only reconnect
+	// stale connections here so the two retry loops don't multiply.
+	leaveConnect := executor.RetriesConnectErrors(ctx)
+	reconnectable := func(err error) bool {
+		var cf *connectFailure
+		var ce *ConnectError
+		if leaveConnect && (errors.As(err, &cf) || errors.As(err, &ce)) {
+			return false
+		}
+		return isReconnectable(err)
+	}
+
+	err := p.exec(ctx, host, command, combined, emit, result)
+	for attempt := 0; attempt < retries && err != nil && reconnectable(err) && !emitted.Load(); attempt++ {
 		p.evict(host)
-		stdout, stderr, exitCode, err = p.exec(ctx, host, command)
+		if backoff > 0 {
+			select {
+			case <-time.After(backoff << attempt):
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return result
+			}
+		}
+		err = p.exec(ctx, host, command, combined, emit, result)
 	}
 
-	result.Stdout = stdout
-	result.Stderr = stderr
-	result.ExitCode = exitCode
 	result.Err = err
 	return result
 }
 
-func (p *Pool) exec(ctx context.Context, host string, command string) ([]byte, []byte, int, error) {
-	client, err := p.getOrDial(ctx, host)
+// exec runs command once on host, dialing it first if needed, and records
+// the output and the time spent in each phase in result. Connect time adds
+// up across reconnect attempts; run time is the last attempt's.
+func (p *Pool) exec(ctx context.Context, host string, command string, combined bool, emit func(int, []byte), result *executor.HostResult) error {
+	start := time.Now()
+	client, reused, err := p.getOrDial(ctx, host)
+	result.ConnectDuration += time.Since(start)
 	if err != nil {
-		return nil, nil, -1, WrapConnectError(host, fmt.Errorf("connect: %w", err))
+		result.Stdout, result.Stderr, result.ExitCode, result.Reused = nil, nil, -1, false
+		return WrapConnectError(host, fmt.Errorf("connect: %w", err))
 	}
 
 	p.mu.Lock()
 	sudo := p.sudo
 	sudoPW := p.sudoPassword
+	sudoUser := p.sudoUser
 	p.mu.Unlock()
 
-	if sudo && sudoPW != "" {
-		return client.RunCommandWithSudo(ctx, command, sudoPW)
-	}
-	if sudo {
-		return client.RunCommand(ctx, "sudo "+command)
-	}
-	return client.RunCommand(ctx, command)
+	start = time.Now()
+	stdout, stderr, exitCode, err := runOn(ctx, client, command, sudo, sudoPW, sudoUser, combined, emit)
+	result.RunDuration = time.Since(start)
+	result.Stdout, result.Stderr, result.ExitCode, result.Reused = stdout, stderr, exitCode, reused
+	return err
 }
 
-func (p *Pool) getOrDial(ctx context.Context, host string) (*Client, error) {
+// getOrDial returns the cached client for host, or dials a new one. reused
+// reports whether the client came from the cache; callers that share an
+// in-flight dial see reused == false.
+func (p *Pool) getOrDial(ctx context.Context, host string) (client *Client, reused bool, err error) {
 	p.mu.Lock()
 	if client, ok := p.clients[host]; ok {
 		p.mu.Unlock()
-		return client, nil
+		return client, true, nil
 	}
 	p.mu.Unlock()
 
 	// Use singleflight to deduplicate concurrent dials to the same host.
 	// DoChan lets each caller respect its own context cancellation.
 	ch := p.dialGroup.DoChan(host, func() (interface{}, error) {
+		if p.dialSem != nil {
+			select {
+			case p.dialSem <- struct{}{}:
+				defer func() { <-p.dialSem }()
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
 		conf, dialHost := resolveHostConf(p.baseConf, p.hostConfs, host)
+		if conf.PasswordFile != "" {
+			passwords, err := p.loadPasswords()
+			if err != nil {
+				return nil, err
+			}
+			conf.passwords = passwords
+		}
 		client, err := Dial(ctx, dialHost, conf)
 		if err != nil {
 			return nil, err
 		}
 		p.mu.Lock()
 		p.clients[host] = client
+		p.startKeepAlive(host, client)
 		p.mu.Unlock()
 		return client, nil
 	})
@@ -110,19 +271,29 @@ func (p *Pool) getOrDial(ctx context.Context, host string) (*Client, error) {
 	select {
 	case res := <-ch:
 		if res.Err != nil {
-			return nil, res.Err
+			return nil, false, res.Err
 		}
-		return res.Val.(*Client), nil
+		return res.Val.(*Client), false, nil
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return nil, false, ctx.Err()
 	}
 }
 
+// loadPasswords reads the pool's password file the first time it is needed,
+// so dialing a fleet does not re-read and re-check it for every host.
+func (p *Pool) loadPasswords() (map[string]string, error) {
+	p.passwordsOnce.Do(func() {
+		p.passwords, p.passwordsErr = LoadPasswordFile(p.baseConf.PasswordFile)
+	})
+	return p.passwords, p.passwordsErr
+}
+
 func (p *Pool) evict(host string) {
 	p.mu.Lock()
 	client, ok := p.clients[host]
 	if ok {
 		delete(p.clients, host)
+		p.stopKeepAlive(client)
 	}
 	p.mu.Unlock()
 
@@ -131,11 +302,85 @@ func (p *Pool) evict(host string) {
 	}
 }
 
+// startKeepAlive starts client's keepalive goroutine if keepalives are
+// enabled. The caller must hold p.mu.
+func (p *Pool) startKeepAlive(host string, client *Client) {
+	if p.keepAlive <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	p.keepAlives[client] = stop
+	p.keepAliveWG.Add(1)
+	go func() {
+		defer p.keepAliveWG.Done()
+		p.runKeepAlive(host, client, stop)
+	}()
+}
+
+// stopKeepAlive stops client's keepalive goroutine, if any. The caller must
+// hold p.mu.
+func (p *Pool) stopKeepAlive(client *Client) {
+	if stop, ok := p.keepAlives[client]; ok {
+		close(stop)
+		delete(p.keepAlives, client)
+	}
+}
+
+// runKeepAlive pings client every keepalive interval until stop is closed,
+// evicting it if a ping fails or is not answered within an interval.
+func (p *Pool) runKeepAlive(host string, client *Client, stop <-chan struct{}) {
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		reply := make(chan error, 1)
+		go func() { reply <- client.sendKeepAlive() }()
+		var err error
+		select {
+		case <-stop:
+			return
+		case err = <-reply:
+		case <-time.After(p.keepAlive):
+			err = errors.New("keepalive timed out")
+		}
+		if err != nil {
+			p.evictClient(host, client)
+			return
+		}
+	}
+}
+
+// evictClient evicts host's cached connection if it is still client, so a
+// failed keepalive cannot evict a connection dialed since.
+func (p *Pool) evictClient(host string, client *Client) {
+	p.mu.Lock()
+	current, ok := p.clients[host]
+	if ok && current == client {
+		delete(p.clients, host)
+		delete(p.keepAlives, client)
+	}
+	p.mu.Unlock()
+	client.Close()
+}
+
 // GetClient returns a connected Client for the given host, reusing a cached
 // connection if available. This is used by SFTP and other subsystems that
 // need direct access to the SSH connection.
 func (p *Pool) GetClient(ctx context.Context, host string) (*Client, error) {
-	return p.getOrDial(ctx, host)
+	client, _, err := p.getOrDial(ctx, host)
+	return client, err
+}
+
+// Connect dials host unless the pool already holds a connection to it,
+// implementing executor.Connector.
+func (p *Pool) Connect(ctx context.Context, host string) error {
+	_, _, err := p.getOrDial(ctx, host)
+	return err
 }
 
 // IsConnected reports whether a cached connection exists for the given host.
@@ -146,11 +391,93 @@ func (p *Pool) IsConnected(host string) bool {
 	return ok
 }
 
-// Close closes all cached connections and resets the pool.
+// Ping reports whether host's cached connection answers an SSH keepalive
+// before ctx is done. A connection that fails or does not answer is evicted,
+// so the next command redials. Hosts without a cached connection report
+// false without dialing.
+func (p *Pool) Ping(ctx context.Context, host string) bool {
+	p.mu.Lock()
+	client, ok := p.clients[host]
+	p.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	reply := make(chan error, 1)
+	go func() { reply <- client.sendKeepAlive() }()
+	var err error
+	select {
+	case err = <-reply:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		p.evictClient(host, client)
+		return false
+	}
+	return true
+}
+
+// Track registers an in-flight operation that CloseGraceful should wait for
+// and returns a function that marks it finished. Run tracks itself; callers
+// using GetClient directly (e.g. SFTP transfers) should wrap their work.
+func (p *Pool) Track() (done func()) {
+	p.mu.Lock()
+	p.active++
+	p.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			p.active--
+			if p.active == 0 && p.idle != nil {
+				close(p.idle)
+				p.idle = nil
+			}
+			p.mu.Unlock()
+		})
+	}
+}
+
+// CloseGraceful waits for in-flight operations to finish, or for ctx to be
+// done, and then closes all cached connections. It returns ctx.Err() if the
+// grace period ran out before operations finished.
+func (p *Pool) CloseGraceful(ctx context.Context) error {
+	p.mu.Lock()
+	var idle chan struct{}
+	if p.active > 0 {
+		if p.idle == nil {
+			p.idle = make(chan struct{})
+		}
+		idle = p.idle
+	}
+	p.mu.Unlock()
+
+	var waitErr error
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			waitErr = ctx.Err()
+		}
+	}
+
+	if err := p.Close(); err != nil {
+		return err
+	}
+	return waitErr
+}
+
+// Close closes all cached connections immediately, cutting off any in-flight
+// operations, stops keepalives, and resets the pool.
 func (p *Pool) Close() error {
 	p.mu.Lock()
 	clients := p.clients
 	p.clients = make(map[string]*Client)
+	for client := range p.keepAlives {
+		p.stopKeepAlive(client)
+	}
 	p.mu.Unlock()
 
 	var firstErr error
@@ -159,6 +486,7 @@ func (p *Pool) Close() error {
 			firstErr = err
 		}
 	}
+	p.keepAliveWG.Wait()
 	return firstErr
 }
 
@@ -181,6 +509,16 @@ func resolveHostConf(base ClientConfig, hostConfs map[string]HostConfig, host st
 		}
 		if hc.ProxyJump != "" {
 			conf.ProxyJump = hc.ProxyJump
+			conf.JumpHosts = nil
+		}
+		if len(hc.JumpHosts) > 0 {
+			conf.JumpHosts = hc.JumpHosts
+		}
+		if hc.ConnectTimeout > 0 {
+			conf.ConnectTimeout = hc.ConnectTimeout
+		}
+		if hc.Shell != "" {
+			conf.Shell = hc.Shell
 		}
 	}
 	return conf, dialHost
@@ -2,12 +2,16 @@ package ssh_test
 
 import (
 	"context"
+	"errors"
+	"net"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	gossh "golang.org/x/crypto/ssh"
 
+	"github.com/agent462/herd/internal/executor"
 	hssh "github.com/agent462/herd/internal/ssh"
 	"github.com/agent462/herd/internal/sshtest"
 )
@@ -46,6 +50,9 @@ func TestPool_BasicExecution(t *testing.T) {
 	if result.ExitCode != 0 {
 		t.Errorf("exit code = %d, want 0", result.ExitCode)
 	}
+	if result.ConnectDuration <= 0 || result.RunDuration <= 0 {
+		t.Errorf("connect = %v, run = %v; want both recorded", result.ConnectDuration, result.RunDuration)
+	}
 }
 
 func TestPool_ConnectionReuse(t *testing.T) {
@@ -80,6 +87,10 @@ func TestPool_ConnectionReuse(t *testing.T) {
 		if result.Err != nil {
 			t.Fatalf("run %d: unexpected error: %v", i, result.Err)
 		}
+		// Only the first run dials; later runs use the cached connection.
+		if want := i > 0; result.Reused != want {
+			t.Errorf("run %d: Reused = %v, want %v", i, result.Reused, want)
+		}
 	}
 
 	if !pool.IsConnected("host-1") {
@@ -141,6 +152,157 @@ func TestPool_Close(t *testing.T) {
 	}
 }
 
+func TestPool_CloseGracefulWaitsForInFlight(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		close(started)
+		<-release
+		return "done\n", "", 0
+	}))
+	defer cleanup()
+
+	_, port := sshtest.ParseAddr(t, addr)
+
+	pool := hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+		},
+		map[string]hssh.HostConfig{
+			"host-1": {Hostname: "127.0.0.1", Port: port, IdentityFile: keyPath},
+		},
+	)
+
+	resultCh := make(chan string, 1)
+	go func() {
+		result := pool.Run(context.Background(), "host-1", "slow")
+		if result.Err != nil {
+			resultCh <- "error: " + result.Err.Error()
+			return
+		}
+		resultCh <- string(result.Stdout)
+	}()
+	<-started
+
+	closeErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		closeErr <- pool.CloseGraceful(ctx)
+	}()
+
+	// CloseGraceful must not tear down the connection while the command runs.
+	select {
+	case err := <-closeErr:
+		t.Fatalf("CloseGraceful returned early: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	if got := <-resultCh; got != "done\n" {
+		t.Errorf("in-flight command result = %q, want %q", got, "done\n")
+	}
+	if err := <-closeErr; err != nil {
+		t.Errorf("CloseGraceful: %v", err)
+	}
+	if pool.IsConnected("host-1") {
+		t.Error("should not be connected after CloseGraceful")
+	}
+}
+
+func TestPool_CloseGracefulTimeout(t *testing.T) {
+	pool := hssh.NewPool(hssh.ClientConfig{}, nil)
+	done := pool.Track()
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := pool.CloseGraceful(ctx); err != context.DeadlineExceeded {
+		t.Errorf("CloseGraceful = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPool_CloseGracefulIdle(t *testing.T) {
+	pool := hssh.NewPool(hssh.ClientConfig{}, nil)
+	done := pool.Track()
+	done()
+	done() // calling done twice must not double-decrement
+
+	if err := pool.CloseGraceful(context.Background()); err != nil {
+		t.Errorf("CloseGraceful on idle pool: %v", err)
+	}
+}
+
+func TestPool_ExecuteStream(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	pubKey, keyPath := sshtest.GenerateKey(t)
+	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
+		return "out\n", "err\n", 4
+	}))
+	defer cleanup()
+
+	_, port := sshtest.ParseAddr(t, addr)
+
+	pool := hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+		},
+		map[string]hssh.HostConfig{
+			"host-1": {Hostname: "127.0.0.1", Port: port, IdentityFile: keyPath},
+			"host-2": {Hostname: "127.0.0.1", Port: port, IdentityFile: keyPath},
+		},
+	)
+	defer pool.Close()
+
+	stdout := make(map[string]string)
+	stderr := make(map[string]string)
+	final := make(map[string]executor.StreamChunk)
+	for c := range executor.New(pool).ExecuteStream(context.Background(), []string{"host-1", "host-2"}, "cmd") {
+		switch {
+		case c.Final:
+			final[c.Host] = c
+		case c.Stream == executor.Stdout:
+			stdout[c.Host] += string(c.Data)
+		case c.Stream == executor.Stderr:
+			stderr[c.Host] += string(c.Data)
+		}
+	}
+
+	for _, h := range []string{"host-1", "host-2"} {
+		if stdout[h] != "out\n" || stderr[h] != "err\n" {
+			t.Errorf("%s: stdout = %q, stderr = %q", h, stdout[h], stderr[h])
+		}
+		if c, ok := final[h]; !ok || c.Err != nil || c.ExitCode != 4 {
+			t.Errorf("%s: final chunk = %+v, want exit code 4", h, c)
+		}
+	}
+}
+
+func TestPool_RunStreamConnectionFailure(t *testing.T) {
+	pool := newUnreachablePool()
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var emitted int
+	result := pool.RunStream(ctx, "bad-host", "cmd", func(int, []byte) { emitted++ })
+	if result.Err == nil {
+		t.Fatal("expected error for unreachable host")
+	}
+	if emitted != 0 {
+		t.Errorf("emitted %d chunks for an unreachable host", emitted)
+	}
+}
+
 func TestPool_ConnectionFailure(t *testing.T) {
 	pool := hssh.NewPool(
 		hssh.ClientConfig{
@@ -160,6 +322,93 @@ func TestPool_ConnectionFailure(t *testing.T) {
 	if result.Err == nil {
 		t.Fatal("expected error for unreachable host")
 	}
+	if result.RunDuration != 0 {
+		t.Errorf("run duration = %v for a host that never connected", result.RunDuration)
+	}
+}
+
+func TestPool_RequireAllReachable(t *testing.T) {
+	pool := newUnreachablePool()
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	e := executor.New(pool, executor.WithRequireAllReachable(true))
+	results, err := e.ExecuteRun(ctx, []string{"bad-host"}, "cmd")
+	var unreachable *executor.UnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("err = %v, want *executor.UnreachableError", err)
+	}
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("results = %+v, want bad-host's connection error", results)
+	}
+}
+
+func newUnreachablePool() *hssh.Pool {
+	return hssh.NewPool(
+		hssh.ClientConfig{
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+			User:            "testuser",
+		},
+		map[string]hssh.HostConfig{
+			"bad-host": {Hostname: "127.0.0.1", Port: 1},
+		},
+	)
+}
+
+func TestPool_ReconnectBackoff(t *testing.T) {
+	pool := newUnreachablePool()
+	defer pool.Close()
+	pool.SetReconnect(3, 20*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	result := pool.Run(ctx, "bad-host", "cmd")
+	if result.Err == nil {
+		t.Fatal("expected error for unreachable host")
+	}
+	// Waits of 20ms, 40ms and 80ms before the three retries.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("expected backoff between retries, finished in %v", elapsed)
+	}
+}
+
+func TestPool_ReconnectBackoffRespectsContext(t *testing.T) {
+	pool := newUnreachablePool()
+	defer pool.Close()
+	pool.SetReconnect(5, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := pool.Run(ctx, "bad-host", "cmd")
+	if result.Err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", result.Err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("expected backoff to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestPool_LeavesConnectRetriesToExecutor(t *testing.T) {
+	pool := newUnreachablePool()
+	defer pool.Close()
+	pool.SetReconnect(3, 100*time.Millisecond)
+
+	e := executor.New(pool, executor.WithRetry(2, 0))
+	start := time.Now()
+	r := e.Execute(context.Background(), []string{"bad-host"}, "cmd")[0]
+	if r.Err == nil || r.Attempts != 2 {
+		t.Errorf("err = %v, attempts = %d; want failure after 2 attempts", r.Err, r.Attempts)
+	}
+	// The pool's own reconnects would wait at least 700ms per attempt.
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("pool retried the dial under executor retries, took %v", elapsed)
+	}
 }
 
 func TestPool_MultipleHosts(t *testing.T) {
@@ -215,3 +464,51 @@ func TestPool_MultipleHosts(t *testing.T) {
 		t.Error("both hosts should be connected")
 	}
 }
+
+func TestPool_DialConcurrency(t *testing.T) {
+	// The listener accepts connections but never completes a handshake, so
+	// each dial holds its slot until the context ends.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			defer conn.Close()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	hosts := map[string]hssh.HostConfig{}
+	for _, h := range []string{"a", "b", "c"} {
+		hosts[h] = hssh.HostConfig{Hostname: "127.0.0.1", Port: port}
+	}
+	pool := hssh.NewPool(hssh.ClientConfig{
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		User:            "testuser",
+	}, hosts, hssh.WithDialConcurrency(1))
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for h := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.GetClient(ctx, h)
+		}()
+	}
+	wg.Wait()
+
+	if n := accepted.Load(); n != 1 {
+		t.Errorf("accepted %d connections, want 1 with a dial concurrency of 1", n)
+	}
+}
@@ -3,17 +3,22 @@ package ssh
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/agent462/herd/internal/cmdutil"
 	"github.com/agent462/herd/internal/executor"
 )
 
 // HostConfig holds per-host SSH connection details.
 type HostConfig struct {
-	Hostname     string // actual hostname to dial (may differ from the map key)
-	User         string
-	Port         int
-	IdentityFile string
-	ProxyJump    string
+	Hostname       string // actual hostname to dial (may differ from the map key)
+	User           string
+	Port           int
+	IdentityFile   string
+	ProxyJump      string
+	JumpHosts      []JumpHost    // overrides ProxyJump if set
+	ConnectTimeout time.Duration // overrides ClientConfig.ConnectTimeout if set
+	Shell          string        // overrides ClientConfig.Shell if set
 }
 
 // SSHRunner implements executor.Runner using real SSH connections.
@@ -60,6 +65,22 @@ func (r *SSHRunner) CloseClient(client *Client) error {
 
 // Run executes a command on a single host via SSH.
 func (r *SSHRunner) Run(ctx context.Context, host string, command string) *executor.HostResult {
+	return r.run(ctx, host, command, false, nil)
+}
+
+// RunCombined implements executor.CombinedRunner, capturing stdout and
+// stderr interleaved in Stdout.
+func (r *SSHRunner) RunCombined(ctx context.Context, host string, command string) *executor.HostResult {
+	return r.run(ctx, host, command, true, nil)
+}
+
+// RunStream implements executor.StreamRunner, passing output to emit as it
+// arrives.
+func (r *SSHRunner) RunStream(ctx context.Context, host string, command string, emit func(stream int, data []byte)) *executor.HostResult {
+	return r.run(ctx, host, command, false, emit)
+}
+
+func (r *SSHRunner) run(ctx context.Context, host string, command string, combined bool, emit func(int, []byte)) *executor.HostResult {
 	result := &executor.HostResult{Host: host}
 
 	conf, dialHost := resolveHostConf(r.baseConf, r.hostConfs, host)
@@ -71,18 +92,56 @@ func (r *SSHRunner) Run(ctx context.Context, host string, command string) *execu
 	}
 	defer client.Close()
 
-	var stdout, stderr []byte
-	var exitCode int
-	if r.sudo && r.sudoPassword != "" {
-		stdout, stderr, exitCode, err = client.RunCommandWithSudo(ctx, command, r.sudoPassword)
-	} else if r.sudo {
-		stdout, stderr, exitCode, err = client.RunCommand(ctx, "sudo "+command)
-	} else {
-		stdout, stderr, exitCode, err = client.RunCommand(ctx, command)
-	}
+	stdout, stderr, exitCode, err := runOn(ctx, client, command, r.sudo, r.sudoPassword, "", combined, emit)
 	result.Stdout = stdout
 	result.Stderr = stderr
 	result.ExitCode = exitCode
 	result.Err = err
 	return result
 }
+
+// runOn runs command on client, wrapped in sudo when requested, as sudoUser
+// if it is set. A sudo password is delivered over a PTY, which merges the
+// output streams anyway; otherwise combined selects RunCommandCombined over
+// RunCommand. A non-nil emit streams output to it instead of returning it;
+// with a sudo password the output is only emitted once the command exits, as
+// the prompt must be stripped first. The command runs under the client's
+// Shell, inside sudo when sudo is used.
+func runOn(ctx context.Context, client *Client, command string, sudo bool, sudoPW, sudoUser string, combined bool, emit func(int, []byte)) (stdout, stderr []byte, exitCode int, err error) {
+	if sudo && sudoPW != "" {
+		stdout, stderr, exitCode, err = client.RunCommandWithSudoUser(ctx, command, sudoPW, sudoUser)
+		if emit != nil {
+			if len(stdout) > 0 {
+				emit(executor.Stdout, stdout)
+			}
+			return nil, nil, exitCode, err
+		}
+		return stdout, stderr, exitCode, err
+	}
+
+	line := client.commandLine(command)
+	if sudo {
+		line = cmdutil.WrapShell(sudoArgs(sudoUser), client.clientConf.Shell, command)
+	}
+	switch {
+	case emit != nil:
+		exitCode, err = client.runSession(ctx, line,
+			streamWriter(func(data []byte) { emit(executor.Stdout, data) }),
+			streamWriter(func(data []byte) { emit(executor.Stderr, data) }))
+		return nil, nil, exitCode, err
+	case combined:
+		stdout, exitCode, err = client.runCommandCombined(ctx, line)
+		return stdout, nil, exitCode, err
+	}
+	return client.runCommand(ctx, line)
+}
+
+// sudoArgs returns the sudo argv prefix with the given flags, adding
+// "-u user" when user is set.
+func sudoArgs(user string, flags ...string) []string {
+	args := append([]string{"sudo"}, flags...)
+	if user != "" {
+		args = append(args, "-u", user)
+	}
+	return args
+}
@@ -0,0 +1,22 @@
+package ssh
+
+import (
+	"github.com/kevinburke/ssh_config"
+
+	"github.com/agent462/herd/internal/sshconfig"
+)
+
+// sshConfigGet looks up a key for a host in the user's ~/.ssh/config. It is
+// a variable so tests can substitute their own.
+var sshConfigGet = ssh_config.Get
+
+// SSHConfigLookup returns a function that looks up a directive for a host,
+// like ssh_config's Get. With an empty path it reads the user's
+// ~/.ssh/config and /etc/ssh/ssh_config. Otherwise it reads only the file
+// at path and the files it includes (see sshconfig.File).
+func SSHConfigLookup(path string) (func(alias, key string) string, error) {
+	if path == "" {
+		return sshConfigGet, nil
+	}
+	return sshconfig.File(path)
+}
@@ -67,11 +67,11 @@ func TestRunCommandWithSudo(t *testing.T) {
 	pubKey, keyPath := sshtest.GenerateKey(t)
 
 	addr, cleanup := sshtest.Start(t, sshtest.WithPublicKey(pubKey), sshtest.WithCmdHandler(func(cmd string) (string, string, int) {
-		// The command should be wrapped with sudo -S.
-		if !strings.HasPrefix(cmd, "sudo -S ") {
-			return "", "expected sudo -S prefix", 1
+		// The command should be wrapped with sudo -S sh -c.
+		if !strings.HasPrefix(cmd, "sudo -S sh -c ") {
+			return "", "expected sudo -S sh -c prefix", 1
 		}
-		actualCmd := strings.TrimPrefix(cmd, "sudo -S ")
+		actualCmd := strings.TrimPrefix(cmd, "sudo -S sh -c ")
 		return "[sudo] password for user:\n" + actualCmd + " output\n", "", 0
 	}))
 	defer cleanup()
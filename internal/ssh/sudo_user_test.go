@@ -0,0 +1,51 @@
+package ssh_test
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPool_SudoUser(t *testing.T) {
+	pool, _, lastCmd := newFactsPool(t, "ok\n")
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		sudo bool
+		user string
+		want string
+	}{
+		{"no sudo ignores user", false, "postgres", "psql -c 'select 1'"},
+		{"sudo as root", true, "", "sudo sh -c 'psql -c '\\''select 1'\\'''"},
+		{"sudo as user", true, "postgres", "sudo -u postgres sh -c 'psql -c '\\''select 1'\\'''"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool.SetSudo(tt.sudo, "")
+			pool.SetSudoUser(tt.user)
+			if r := pool.Run(ctx, "host-1", "psql -c 'select 1'"); r.Err != nil {
+				t.Fatalf("run: %v", r.Err)
+			}
+			if got := lastCmd.Load().(string); got != tt.want {
+				t.Errorf("command = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPool_SudoUserWithPassword(t *testing.T) {
+	pool, _, lastCmd := newFactsPool(t, "[sudo] password for testuser:\nok\n")
+	pool.SetSudo(true, "secret")
+	pool.SetSudoUser("postgres")
+
+	r := pool.Run(context.Background(), "host-1", "whoami")
+	if r.Err != nil {
+		t.Fatalf("run: %v", r.Err)
+	}
+	if got, want := lastCmd.Load().(string), "sudo -S -u postgres sh -c whoami"; got != want {
+		t.Errorf("command = %q, want %q", got, want)
+	}
+	if string(r.Stdout) != "ok\n" {
+		t.Errorf("stdout = %q, want sudo prompt stripped", r.Stdout)
+	}
+}
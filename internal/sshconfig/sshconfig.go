@@ -0,0 +1,51 @@
+// Package sshconfig reads ssh_config files kept outside ~/.ssh, for the
+// config and ssh packages.
+package sshconfig
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kevinburke/ssh_config"
+
+	"github.com/agent462/herd/internal/pathutil"
+)
+
+var (
+	filesMu sync.Mutex
+	files   = make(map[string]*ssh_config.Config) // path -> parsed file
+)
+
+// File returns a function that looks up a directive for a host in the
+// ssh_config file at path, like ssh_config's Get. path may start with "~/",
+// for configs kept outside ~/.ssh such as in CI. Only that file and the
+// files it includes are read: neither ~/.ssh/config nor /etc/ssh/ssh_config
+// is consulted. Relative Include directives are resolved against ~/.ssh, as
+// ssh does for user configs. Each file is parsed once. It is an error for
+// the file to be missing or invalid.
+func File(path string) (func(alias, key string) string, error) {
+	path = pathutil.ExpandHome(path)
+
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	cfg, ok := files[path]
+	if !ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("ssh config %s: %w", path, err)
+		}
+		if cfg, err = ssh_config.DecodeBytes(data); err != nil {
+			return nil, fmt.Errorf("ssh config %s: %w", path, err)
+		}
+		files[path] = cfg
+	}
+
+	return func(alias, key string) string {
+		val, err := cfg.Get(alias, key)
+		if err != nil || val == "" {
+			return ssh_config.Default(key)
+		}
+		return val
+	}, nil
+}
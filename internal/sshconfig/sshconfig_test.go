@@ -0,0 +1,67 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	included := filepath.Join(dir, "hosts.conf")
+	if err := os.WriteFile(included, []byte("Host web-01\n  HostName 10.0.0.5\n  User deploy\n  Port 2222\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "config")
+	content := "Include " + filepath.Join(dir, "*.conf") + "\n\nHost bastion\n  HostName 10.0.0.1\n"
+	if err := os.WriteFile(main, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	get, err := File(main)
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	tests := []struct{ alias, key, want string }{
+		{"web-01", "HostName", "10.0.0.5"}, // from the included file
+		{"web-01", "User", "deploy"},
+		{"web-01", "Port", "2222"},
+		{"bastion", "HostName", "10.0.0.1"},
+		{"other", "User", ""},
+	}
+	for _, tt := range tests {
+		if got := get(tt.alias, tt.key); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.alias, tt.key, got, tt.want)
+		}
+	}
+
+	if _, err := File(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing ssh config file")
+	}
+}
+
+func TestFileIgnoresDefaultConfigs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte("Host *\n  User fromhome\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("Host web-01\n  Port 2222\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	get, err := File(path)
+	if err != nil {
+		t.Fatalf("File: %v", err)
+	}
+	if got := get("web-01", "User"); got != "" {
+		t.Errorf("User = %q, want nothing from ~/.ssh/config", got)
+	}
+	if got := get("other", "Port"); got != "22" {
+		t.Errorf("Port = %q, want the default 22", got)
+	}
+}
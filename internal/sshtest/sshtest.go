@@ -11,6 +11,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/pkg/sftp"
@@ -28,6 +29,10 @@ type ServerConfig struct {
 	ForwardTCP   bool
 	CmdHandler   CmdHandler
 	SFTPRoot     string // root directory for SFTP subsystem
+
+	// KeyboardInteractive holds the expected answer to each
+	// keyboard-interactive question, keyed by the question.
+	KeyboardInteractive map[string]string
 }
 
 // Option configures a test SSH server.
@@ -43,6 +48,13 @@ func WithPassword(pw string) Option {
 	return func(c *ServerConfig) { c.PasswordAuth = pw }
 }
 
+// WithKeyboardInteractive configures the server to accept keyboard-interactive
+// auth, asking each question in answers (in sorted order, with echo off) and
+// requiring the given answer.
+func WithKeyboardInteractive(answers map[string]string) Option {
+	return func(c *ServerConfig) { c.KeyboardInteractive = answers }
+}
+
 // WithNoAuth configures the server to accept any connection.
 func WithNoAuth() Option {
 	return func(c *ServerConfig) { c.NoAuth = true }
@@ -104,6 +116,29 @@ func Start(t *testing.T, opts ...Option) (addr string, cleanup func()) {
 		}
 	}
 
+	if len(cfg.KeyboardInteractive) > 0 {
+		questions := make([]string, 0, len(cfg.KeyboardInteractive))
+		for q := range cfg.KeyboardInteractive {
+			questions = append(questions, q)
+		}
+		sort.Strings(questions)
+		serverConf.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client(conn.User(), "", questions, make([]bool, len(questions)))
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) != len(questions) {
+				return nil, fmt.Errorf("got %d answers, want %d", len(answers), len(questions))
+			}
+			for i, q := range questions {
+				if answers[i] != cfg.KeyboardInteractive[q] {
+					return nil, fmt.Errorf("wrong answer to %q", q)
+				}
+			}
+			return nil, nil
+		}
+	}
+
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("listen: %v", err)
@@ -22,6 +22,21 @@ type ClientCloser interface {
 	CloseClient(client *hssh.Client) error
 }
 
+// OperationTracker is optionally implemented by ClientProviders that support
+// graceful shutdown (e.g. ssh.Pool). Each transfer is registered with Track
+// so that the provider can wait for it to finish before closing connections.
+type OperationTracker interface {
+	Track() (done func())
+}
+
+// HostProgress is notified as hosts finish a transfer; see WithProgress.
+// It is implemented by progress.Bar.
+type HostProgress interface {
+	Start(total int)
+	Increment(host string)
+	Finish()
+}
+
 // TransferResult holds the outcome of a file transfer for a single host.
 type TransferResult struct {
 	Host      string
@@ -36,6 +51,7 @@ type Executor struct {
 	provider    ClientProvider
 	concurrency int
 	timeout     time.Duration
+	progress    HostProgress
 }
 
 // Option configures an Executor.
@@ -59,6 +75,14 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
+// WithProgress reports each Push and Pull to p: Start with the number of
+// hosts, Increment as each host finishes, and Finish when all are done.
+func WithProgress(p HostProgress) Option {
+	return func(e *Executor) {
+		e.progress = p
+	}
+}
+
 // New creates a transfer Executor.
 func New(provider ClientProvider, opts ...Option) *Executor {
 	e := &Executor{
@@ -78,10 +102,18 @@ func (e *Executor) Push(ctx context.Context, hosts []string, localPath, remotePa
 	sem := make(chan struct{}, e.concurrency)
 	var wg sync.WaitGroup
 
+	if e.progress != nil {
+		e.progress.Start(len(hosts))
+		defer e.progress.Finish()
+	}
+
 	for i, host := range hosts {
 		wg.Add(1)
 		go func(idx int, h string) {
 			defer wg.Done()
+			if e.progress != nil {
+				defer e.progress.Increment(h)
+			}
 
 			select {
 			case sem <- struct{}{}:
@@ -94,6 +126,10 @@ func (e *Executor) Push(ctx context.Context, hosts []string, localPath, remotePa
 			hostCtx, cancel := context.WithTimeout(ctx, e.timeout)
 			defer cancel()
 
+			if tracker, ok := e.provider.(OperationTracker); ok {
+				defer tracker.Track()()
+			}
+
 			start := time.Now()
 			result := &TransferResult{Host: h}
 
@@ -127,10 +163,18 @@ func (e *Executor) Pull(ctx context.Context, hosts []string, remotePath, localDi
 	sem := make(chan struct{}, e.concurrency)
 	var wg sync.WaitGroup
 
+	if e.progress != nil {
+		e.progress.Start(len(hosts))
+		defer e.progress.Finish()
+	}
+
 	for i, host := range hosts {
 		wg.Add(1)
 		go func(idx int, h string) {
 			defer wg.Done()
+			if e.progress != nil {
+				defer e.progress.Increment(h)
+			}
 
 			select {
 			case sem <- struct{}{}:
@@ -143,6 +187,10 @@ func (e *Executor) Pull(ctx context.Context, hosts []string, remotePath, localDi
 			hostCtx, cancel := context.WithTimeout(ctx, e.timeout)
 			defer cancel()
 
+			if tracker, ok := e.provider.(OperationTracker); ok {
+				defer tracker.Track()()
+			}
+
 			start := time.Now()
 			result := &TransferResult{Host: h}
 
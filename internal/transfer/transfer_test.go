@@ -2,9 +2,12 @@ package transfer_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	hssh "github.com/agent462/herd/internal/ssh"
@@ -176,3 +179,46 @@ func TestProgressWriter(t *testing.T) {
 		t.Errorf("second call = %d, want 11", calls[1])
 	}
 }
+
+type unreachableProvider struct{}
+
+func (unreachableProvider) GetClient(ctx context.Context, host string) (*hssh.Client, error) {
+	return nil, errors.New("connection refused")
+}
+
+type recordingProgress struct {
+	mu       sync.Mutex
+	total    int
+	hosts    []string
+	finished int
+}
+
+func (p *recordingProgress) Start(total int) { p.total = total }
+
+func (p *recordingProgress) Increment(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts = append(p.hosts, host)
+}
+
+func (p *recordingProgress) Finish() { p.finished++ }
+
+func TestExecutorProgress(t *testing.T) {
+	p := &recordingProgress{}
+	e := transfer.New(unreachableProvider{}, transfer.WithProgress(p))
+	results := e.Push(context.Background(), []string{"host-a", "host-b"}, "/dev/null", "/tmp/x", nil)
+
+	if len(results) != 2 || results[0].Err == nil {
+		t.Fatalf("expected 2 failed results, got %+v", results)
+	}
+	if p.total != 2 {
+		t.Errorf("Start total = %d, want 2", p.total)
+	}
+	sort.Strings(p.hosts)
+	if strings.Join(p.hosts, ",") != "host-a,host-b" {
+		t.Errorf("incremented hosts = %v, want [host-a host-b]", p.hosts)
+	}
+	if p.finished != 1 {
+		t.Errorf("Finish called %d times, want 1", p.finished)
+	}
+}
@@ -0,0 +1,183 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+
+	"charm.land/bubbles/v2/viewport"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+
+	"github.com/agent462/herd/internal/grouper"
+)
+
+// groupCompare is a full-screen overlay for diffing two output groups against
+// each other instead of each against the norm. It first lists the groups for
+// picking; once two are marked it shows their unified diff.
+type groupCompare struct {
+	groups  []grouper.OutputGroup
+	cursor  int
+	marked  int // index of the first picked group; -1 if none
+	showing bool
+	title   string
+	vp      viewport.Model
+	visible bool
+	width   int
+	height  int
+}
+
+func newGroupCompare(width, height int) groupCompare {
+	c := groupCompare{
+		marked: -1,
+		vp:     viewport.New(viewport.WithWidth(width-4), viewport.WithHeight(height-4)),
+	}
+	c.Resize(width, height)
+	return c
+}
+
+// Show opens the group picker. It reports false, leaving the overlay closed,
+// when there are fewer than two groups to compare.
+func (c *groupCompare) Show(grouped *grouper.GroupedResults) bool {
+	if grouped == nil || len(grouped.Groups) < 2 {
+		return false
+	}
+	c.groups = grouped.Groups
+	c.cursor = 0
+	c.marked = -1
+	c.showing = false
+	c.visible = true
+	return true
+}
+
+func (c *groupCompare) Hide() {
+	c.visible = false
+	c.showing = false
+	c.groups = nil
+}
+
+func (c *groupCompare) IsVisible() bool {
+	return c.visible
+}
+
+func (c *groupCompare) Update(msg tea.Msg) tea.Cmd {
+	if !c.visible {
+		return nil
+	}
+	if c.showing {
+		var cmd tea.Cmd
+		c.vp, cmd = c.vp.Update(msg)
+		return cmd
+	}
+
+	key, ok := msg.(tea.KeyPressMsg)
+	if !ok {
+		return nil
+	}
+	switch key.String() {
+	case "up", "k":
+		if c.cursor > 0 {
+			c.cursor--
+		}
+	case "down", "j":
+		if c.cursor < len(c.groups)-1 {
+			c.cursor++
+		}
+	case "enter", "space":
+		c.pick()
+	}
+	return nil
+}
+
+// pick marks the group under the cursor. Picking a second, different group
+// computes and shows the diff between the two.
+func (c *groupCompare) pick() {
+	if c.marked < 0 {
+		c.marked = c.cursor
+		return
+	}
+	if c.marked == c.cursor {
+		c.marked = -1 // picking the same group again unmarks it
+		return
+	}
+
+	a, b := c.groups[c.marked], c.groups[c.cursor]
+	aLabel, bLabel := groupLabel(c.marked, a), groupLabel(c.cursor, b)
+	diff := grouper.LabeledDiff(string(a.Stdout), string(b.Stdout), aLabel, bLabel)
+
+	var sb strings.Builder
+	if diff == "" {
+		sb.WriteString(helpDescStyle.Render("  stdout is identical; the groups differ only in stderr or exit code"))
+	} else {
+		writeDiff(&sb, diff)
+	}
+	c.title = aLabel + " ↔ " + bLabel
+	c.vp.SetContent(sb.String())
+	c.vp.GotoTop()
+	c.showing = true
+}
+
+func (c *groupCompare) View() string {
+	if !c.visible {
+		return ""
+	}
+
+	var body, footer string
+	if c.showing {
+		body = diffHdrStyle.Render("── "+c.title+" ──") + "\n" + c.vp.View()
+		footer = "  Esc to close  │  j/k to scroll"
+	} else {
+		var b strings.Builder
+		b.WriteString(diffHdrStyle.Render("── Compare groups ──"))
+		b.WriteString("\n\n")
+		for i, g := range c.groups {
+			mark := "  "
+			if i == c.marked {
+				mark = "A "
+			}
+			line := mark + groupLabel(i, g)
+			if i == c.cursor {
+				line = helpKeyStyle.Render("▸ " + line)
+			} else {
+				line = "  " + line
+			}
+			b.WriteString(line + "\n")
+		}
+		body = b.String()
+		footer = "  Enter/Space to pick two groups  │  Esc to close"
+	}
+
+	pane := lipgloss.NewStyle().
+		Width(c.width).
+		Height(c.height - 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorCyan).
+		Render(body)
+
+	return lipgloss.JoinVertical(lipgloss.Left, pane, helpDescStyle.Render(footer))
+}
+
+func (c *groupCompare) Resize(width, height int) {
+	c.width = width
+	c.height = height
+	c.vp.SetWidth(width - 4)
+	c.vp.SetHeight(height - 4)
+}
+
+// groupLabel names a group by its position and hosts, e.g.
+// "group 2: web-03 +4 (exit 1)".
+func groupLabel(i int, g grouper.OutputGroup) string {
+	label := fmt.Sprintf("group %d:", i+1)
+	if len(g.Hosts) > 0 {
+		label += " " + g.Hosts[0]
+	}
+	if len(g.Hosts) > 1 {
+		label += fmt.Sprintf(" +%d", len(g.Hosts)-1)
+	}
+	if g.IsNorm {
+		label += " (norm)"
+	}
+	if g.ExitCode != 0 {
+		label += fmt.Sprintf(" (exit %d)", g.ExitCode)
+	}
+	return label
+}
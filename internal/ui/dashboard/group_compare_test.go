@@ -0,0 +1,84 @@
+package dashboard
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/agent462/herd/internal/grouper"
+)
+
+func compareFixture() *grouper.GroupedResults {
+	return &grouper.GroupedResults{
+		Groups: []grouper.OutputGroup{
+			{Hosts: []string{"web-01", "web-02"}, Stdout: []byte("a\nb\n"), IsNorm: true},
+			{Hosts: []string{"web-03"}, Stdout: []byte("a\nc\n"), ExitCode: 1},
+			{Hosts: []string{"web-04"}, Stdout: []byte("a\nd\n")},
+		},
+	}
+}
+
+func pressKey(c *groupCompare, s string) {
+	switch s {
+	case "enter":
+		c.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	case "down":
+		c.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	default:
+		r := []rune(s)[0]
+		c.Update(tea.KeyPressMsg{Code: r, Text: s})
+	}
+}
+
+func TestGroupCompareShowNeedsTwoGroups(t *testing.T) {
+	c := newGroupCompare(80, 24)
+	one := &grouper.GroupedResults{Groups: []grouper.OutputGroup{{Hosts: []string{"a"}}}}
+	if c.Show(nil) || c.Show(one) || c.IsVisible() {
+		t.Fatal("expected compare overlay to stay closed with fewer than two groups")
+	}
+	if !c.Show(compareFixture()) || !c.IsVisible() {
+		t.Fatal("expected compare overlay to open with three groups")
+	}
+}
+
+func TestGroupComparePickTwoGroups(t *testing.T) {
+	c := newGroupCompare(120, 40)
+	c.Show(compareFixture())
+
+	pressKey(&c, "down")
+	pressKey(&c, "enter") // mark group 2
+	pressKey(&c, "j")
+	pressKey(&c, "enter") // compare against group 3
+
+	if !c.showing {
+		t.Fatal("expected diff after picking two groups")
+	}
+	view := c.View()
+	for _, want := range []string{"group 2: web-03 (exit 1)", "group 3: web-04", "-c", "+d"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("expected view to contain %q, got:\n%s", want, view)
+		}
+	}
+}
+
+func TestGroupComparePickSameGroupUnmarks(t *testing.T) {
+	c := newGroupCompare(80, 24)
+	c.Show(compareFixture())
+
+	pressKey(&c, "enter")
+	if c.marked != 0 {
+		t.Fatalf("marked = %d, want 0", c.marked)
+	}
+	pressKey(&c, "enter")
+	if c.marked != -1 || c.showing {
+		t.Errorf("expected re-picking to unmark, got marked=%d showing=%v", c.marked, c.showing)
+	}
+}
+
+func TestGroupLabel(t *testing.T) {
+	g := grouper.OutputGroup{Hosts: []string{"web-01", "web-02", "web-03"}, IsNorm: true}
+	if got, want := groupLabel(0, g), "group 1: web-01 +2 (norm)"; got != want {
+		t.Errorf("groupLabel = %q, want %q", got, want)
+	}
+}
@@ -1,27 +1,80 @@
 package dashboard
 
 import (
+	"context"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	tea "charm.land/bubbletea/v2"
 
+	"github.com/agent462/herd/internal/executor"
 	"github.com/agent462/herd/internal/ssh"
 )
 
-// healthTickCmd returns a tea.Cmd that fires a healthTickMsg after the given interval.
+// defaultHealthConcurrency bounds health checks when
+// Config.HealthConcurrency is 0.
+const defaultHealthConcurrency = 10
+
+// healthTimeout bounds how long a health check waits for one host to
+// answer before reporting it down.
+const healthTimeout = 5 * time.Second
+
+// healthTickCmd returns a tea.Cmd that fires a healthTickMsg after the given
+// interval, jittered by up to a tenth either way so that checks drift apart
+// rather than landing on the fleet in lockstep.
 func healthTickCmd(interval time.Duration) tea.Cmd {
-	return tea.Tick(interval, func(time.Time) tea.Msg {
+	return tea.Tick(jitter(interval), func(time.Time) tea.Msg {
 		return healthTickMsg{}
 	})
 }
 
-// healthCheckCmd spawns a goroutine that checks pool connectivity for all hosts.
-func healthCheckCmd(pool *ssh.Pool, hosts []string) tea.Cmd {
+// jitter returns d shifted by a random amount of up to d/10 either way.
+func jitter(d time.Duration) time.Duration {
+	spread := d / 10
+	if spread <= 0 {
+		return d
+	}
+	return d - spread + rand.N(2*spread)
+}
+
+// healthCheckCmd spawns a goroutine that checks connectivity for all hosts
+// with check, at most concurrency hosts at a time. Each check is given
+// healthTimeout to answer, since a real probe (see ssh.Pool.Ping) waits on
+// the network.
+func healthCheckCmd(check func(ctx context.Context, host string) bool, hosts []string, concurrency int) tea.Cmd {
+	if concurrency <= 0 {
+		concurrency = defaultHealthConcurrency
+	}
 	return func() tea.Msg {
 		status := make(map[string]bool, len(hosts))
+		var mu sync.Mutex
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
 		for _, h := range hosts {
-			status[h] = pool.IsConnected(h)
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(h string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+				ok := check(ctx, h)
+				cancel()
+				mu.Lock()
+				status[h] = ok
+				mu.Unlock()
+			}(h)
 		}
+		wg.Wait()
 		return healthCheckMsg{Status: status}
 	}
 }
+
+// probeFactsCmd collects host facts (OS, arch, ...) for the host table and
+// for @os: and @fact: selectors, running the probes with opts. The pool
+// caches them.
+func probeFactsCmd(pool *ssh.Pool, hosts []string, opts []executor.Option) tea.Cmd {
+	return func() tea.Msg {
+		return hostFactsMsg{Facts: ssh.FactValues(pool.Probe(context.Background(), hosts, opts...))}
+	}
+}
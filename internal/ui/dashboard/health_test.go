@@ -0,0 +1,50 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	check := func(_ context.Context, host string) bool {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return host != "h-3"
+	}
+
+	hosts := make([]string, 20)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("h-%d", i)
+	}
+	msg := healthCheckCmd(check, hosts, 4)().(healthCheckMsg)
+
+	if peak > 4 {
+		t.Errorf("peak concurrent checks = %d, want at most 4", peak)
+	}
+	if len(msg.Status) != 20 || msg.Status["h-3"] || !msg.Status["h-4"] {
+		t.Errorf("status = %v, want all 20 hosts with h-3 down", msg.Status)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := 10 * time.Second
+	for range 100 {
+		if got := jitter(d); got < 9*time.Second || got >= 11*time.Second {
+			t.Fatalf("jitter(%v) = %v, want within a tenth of it", d, got)
+		}
+	}
+	if got := jitter(5); got != 5 {
+		t.Errorf("jitter(5ns) = %v, want unchanged when too small to spread", got)
+	}
+}
@@ -17,13 +17,19 @@ import (
 // hostEntry tracks per-host state shown in the table.
 type hostEntry struct {
 	Name      string
+	OS        string // probed OS, e.g. "debian-12"; empty until known
 	Connected bool
 	LastCmd   string
 	ExitCode  int
 	Duration  string
-	Status    string // "ok", "differs", "failed", "timeout", ""
+	Status    string          // "ok", "differs", "failed", "timeout", ""
+	History   []time.Duration // recent run durations, oldest first
+	Pinned    bool            // kept at the top of the table
 }
 
+// pinMarker prefixes pinned host names in the Host column.
+const pinMarker = "* "
+
 // hostTable wraps a bubbles/table with host state tracking.
 type hostTable struct {
 	table   table.Model
@@ -43,10 +49,12 @@ func newHostTable(hosts []string, width, height int) hostTable {
 
 	columns := []table.Column{
 		{Title: "Host", Width: 20},
+		{Title: "OS", Width: 10},
 		{Title: "Status", Width: 10},
 		{Title: "Cmd", Width: 18},
 		{Title: "Exit", Width: 5},
 		{Title: "Time", Width: 8},
+		{Title: "Trend", Width: sparklineLen},
 	}
 
 	rows := buildRows(entries)
@@ -120,7 +128,28 @@ func (h *hostTable) SelectedHost() string {
 	if row == nil {
 		return ""
 	}
-	return row[0]
+	return strings.TrimPrefix(row[0], pinMarker)
+}
+
+// TogglePin pins or unpins host, moving it to or from the top of the table
+// while keeping it selected. It reports whether the host is now pinned.
+func (h *hostTable) TogglePin(host string) bool {
+	pinned := false
+	for i := range h.entries {
+		if h.entries[i].Name == host {
+			h.entries[i].Pinned = !h.entries[i].Pinned
+			pinned = h.entries[i].Pinned
+		}
+	}
+	rows := buildRows(h.entries)
+	h.table.SetRows(rows)
+	for i, row := range rows {
+		if strings.TrimPrefix(row[0], pinMarker) == host {
+			h.table.SetCursor(i)
+			break
+		}
+	}
+	return pinned
 }
 
 func (h *hostTable) Resize(width, height int) {
@@ -132,17 +161,19 @@ func (h *hostTable) Resize(width, height int) {
 }
 
 func (h *hostTable) resizeColumns() {
-	// Available width for column content (subtract cell padding: 1 left + 1 right per column × 5 cols).
-	w := h.width - 10
+	// Available width for column content (subtract cell padding: 1 left + 1 right per column × 7 cols).
+	w := h.width - 14
 	if w < 30 {
 		w = 30
 	}
 
 	// Fixed-width columns get a share; host name gets the remainder.
+	osW := 10
 	statusW := 8
 	exitW := 4
 	timeW := 7
-	fixed := statusW + exitW + timeW
+	trendW := sparklineLen
+	fixed := osW + statusW + exitW + timeW + trendW
 
 	// Split remaining space: ~60% host, ~40% cmd.
 	remaining := w - fixed
@@ -160,10 +191,12 @@ func (h *hostTable) resizeColumns() {
 
 	h.table.SetColumns([]table.Column{
 		{Title: "Host", Width: hostW},
+		{Title: "OS", Width: osW},
 		{Title: "Status", Width: statusW},
 		{Title: "Cmd", Width: cmdW},
 		{Title: "Exit", Width: exitW},
 		{Title: "Time", Width: timeW},
+		{Title: "Trend", Width: trendW},
 	})
 }
 
@@ -176,6 +209,16 @@ func (h *hostTable) UpdateHealth(status map[string]bool) {
 	h.table.SetRows(buildRows(h.entries))
 }
 
+// UpdateFacts shows each host's probed "os" fact.
+func (h *hostTable) UpdateFacts(facts map[string]map[string]string) {
+	for i := range h.entries {
+		if os, ok := facts[h.entries[i].Name]["os"]; ok {
+			h.entries[i].OS = os
+		}
+	}
+	h.table.SetRows(buildRows(h.entries))
+}
+
 func (h *hostTable) UpdateResults(command string, grouped *grouper.GroupedResults, results []*executor.HostResult) {
 	// Build lookup maps.
 	hostStatus := make(map[string]string)
@@ -186,8 +229,11 @@ func (h *hostTable) UpdateResults(command string, grouped *grouper.GroupedResult
 		if !g.IsNorm {
 			status = "differs"
 		}
-		if g.ExitCode != 0 {
+		switch {
+		case g.Failed():
 			status = "error"
+		case g.Status == grouper.StatusWarn:
+			status = "warn"
 		}
 		for _, host := range g.Hosts {
 			hostStatus[host] = status
@@ -204,9 +250,9 @@ func (h *hostTable) UpdateResults(command string, grouped *grouper.GroupedResult
 	}
 
 	// Build duration map from the raw results (covers all hosts).
-	hostDur := make(map[string]string, len(results))
+	hostDur := make(map[string]time.Duration, len(results))
 	for _, r := range results {
-		hostDur[r.Host] = formatDuration(r.Duration)
+		hostDur[r.Host] = r.Duration
 	}
 
 	for i := range h.entries {
@@ -217,7 +263,8 @@ func (h *hostTable) UpdateResults(command string, grouped *grouper.GroupedResult
 			h.entries[i].ExitCode = hostExit[name]
 		}
 		if d, ok := hostDur[name]; ok {
-			h.entries[i].Duration = d
+			h.entries[i].Duration = formatDuration(d)
+			h.entries[i].History = recordDuration(h.entries[i].History, d)
 		}
 	}
 
@@ -235,15 +282,25 @@ func (h *hostTable) ConnectedCount() int {
 	return n
 }
 
+// buildRows renders entries as table rows, pinned hosts first. Within the
+// pinned and unpinned sets, hosts keep their original order.
 func buildRows(entries []hostEntry) []table.Row {
-	rows := make([]table.Row, len(entries))
-	for i, e := range entries {
-		status := e.Status
-		exitStr := ""
-		if e.LastCmd != "" {
-			exitStr = fmt.Sprintf("%d", e.ExitCode)
+	rows := make([]table.Row, 0, len(entries))
+	for _, pinned := range []bool{true, false} {
+		for _, e := range entries {
+			if e.Pinned != pinned {
+				continue
+			}
+			name := e.Name
+			if e.Pinned {
+				name = pinMarker + name
+			}
+			exitStr := ""
+			if e.LastCmd != "" {
+				exitStr = fmt.Sprintf("%d", e.ExitCode)
+			}
+			rows = append(rows, table.Row{name, e.OS, e.Status, e.LastCmd, exitStr, e.Duration, sparkline(e.History)})
 		}
-		rows[i] = table.Row{e.Name, status, e.LastCmd, exitStr, e.Duration}
 	}
 	return rows
 }
@@ -0,0 +1,60 @@
+package dashboard
+
+import (
+	"testing"
+
+	"charm.land/bubbles/v2/table"
+)
+
+func rowHosts(rows []table.Row) []string {
+	hosts := make([]string, len(rows))
+	for i, row := range rows {
+		hosts[i] = row[0]
+	}
+	return hosts
+}
+
+func TestBuildRowsPinnedFirst(t *testing.T) {
+	entries := []hostEntry{
+		{Name: "web-01"},
+		{Name: "web-02", Pinned: true},
+		{Name: "web-03"},
+		{Name: "web-04", Pinned: true},
+	}
+	got := rowHosts(buildRows(entries))
+	want := []string{"* web-02", "* web-04", "web-01", "web-03"}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rows = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestTogglePinKeepsSelection(t *testing.T) {
+	h := newHostTable([]string{"web-01", "web-02", "web-03"}, 100, 20)
+	h.table.SetCursor(2)
+
+	if !h.TogglePin("web-03") {
+		t.Fatal("expected web-03 to be pinned")
+	}
+	if got := h.SelectedHost(); got != "web-03" {
+		t.Errorf("selected = %q after pin, want web-03", got)
+	}
+	if got := h.table.Rows()[0][0]; got != "* web-03" {
+		t.Errorf("first row = %q, want pinned web-03", got)
+	}
+
+	if h.TogglePin("web-03") {
+		t.Fatal("expected web-03 to be unpinned")
+	}
+	if got := h.SelectedHost(); got != "web-03" {
+		t.Errorf("selected = %q after unpin, want web-03", got)
+	}
+	if got := h.table.Rows()[0][0]; got != "web-01" {
+		t.Errorf("first row = %q after unpin, want web-01", got)
+	}
+}
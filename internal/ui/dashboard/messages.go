@@ -10,6 +10,7 @@ type execResultMsg struct {
 	Command string
 	Results []*executor.HostResult
 	Grouped *grouper.GroupedResults
+	LogErr  error // session log write failure, if any
 }
 
 // healthCheckMsg carries the connection status for each host.
@@ -19,3 +20,8 @@ type healthCheckMsg struct {
 
 // healthTickMsg triggers a new health check cycle.
 type healthTickMsg struct{}
+
+// hostFactsMsg carries the probed facts of each host, e.g. os=debian-12.
+type hostFactsMsg struct {
+	Facts map[string]map[string]string
+}
@@ -2,15 +2,21 @@ package dashboard
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
 
+	"github.com/agent462/herd/internal/config"
 	"github.com/agent462/herd/internal/executor"
 	"github.com/agent462/herd/internal/grouper"
+	"github.com/agent462/herd/internal/recipe"
 	"github.com/agent462/herd/internal/selector"
+	"github.com/agent462/herd/internal/sessionlog"
 	"github.com/agent462/herd/internal/ssh"
+	"github.com/agent462/herd/internal/transfer"
 )
 
 // pane identifies which sub-model has focus.
@@ -27,8 +33,18 @@ type Config struct {
 	Pool           *ssh.Pool
 	Executor       *executor.Executor
 	AllHosts       []string
+	HostTags       map[string][]string // host name -> tags from config, for @tag: selectors
 	GroupName      string
 	HealthInterval time.Duration
+	HerdConfig     *config.Config // source of user recipes for the command palette; may be nil
+	LogFile        string         // append a record of every command run to this file; empty disables
+	LogOutput      bool           // include full output in LogFile records, not just summary counts
+	PreserveANSI   bool           // keep remote ANSI escape codes in per-host output tabs
+	Color          string         // "auto", "always", or "never"; empty uses HerdConfig.Defaults.Color
+
+	// HealthConcurrency bounds how many hosts a health check probes at
+	// once; 0 uses a default of 10.
+	HealthConcurrency int
 }
 
 // Model is the root Bubble Tea model for the dashboard.
@@ -36,21 +52,35 @@ type Model struct {
 	pool     *ssh.Pool
 	executor *executor.Executor
 	allHosts []string
+	hostTags map[string][]string // host name -> tags; nil if not available
 	group    string
+	recipes  map[string]config.Recipe
+	cfg      *config.Config     // may be nil
+	log      *sessionlog.Logger // nil unless Config.LogFile is set
+	logErr   error              // last session log write failure, shown in the status bar
 
 	hostTable    hostTable
 	outputPane   outputPane
 	commandInput commandInput
 	filterBar    filterBar
 	diffView     diffView
+	groupCompare groupCompare
+	palette      commandPalette
 
 	focused      pane
 	showHelp     bool
 	lastResults  []*executor.HostResult
 	lastGrouped  *grouper.GroupedResults
+	hostFacts    map[string]map[string]string // probed facts per host, for @os: and @fact: selectors
 	lastCommand  string
 	history      []string
 	healthTick   time.Duration
+	healthLimit  int    // hosts health-checked at once
+	colorMode    string // "always" or "never" overrides the detected color profile
+
+	// activeRun is the latest command or recipe run, whose hosts x cancels.
+	// It is shared by copies of the model and set as each run starts.
+	activeRun *atomic.Pointer[executor.Run]
 
 	width  int
 	height int
@@ -62,27 +92,70 @@ func New(cfg Config) Model {
 		cfg.HealthInterval = 10 * time.Second
 	}
 
+	recipes := recipe.MergedRecipes(cfg.HerdConfig)
+	if cfg.Pool != nil && cfg.HerdConfig != nil {
+		cfg.Pool.SetFactProbes(ssh.MergeFactProbes(cfg.HerdConfig.Defaults.Facts), cfg.HerdConfig.Defaults.FactsTTL.Duration)
+	}
+
+	var log *sessionlog.Logger
+	if cfg.LogFile != "" {
+		log = sessionlog.New(cfg.LogFile, cfg.LogOutput)
+	}
+
+	output := newOutputPane(40, 20)
+	output.preserveANSI = cfg.PreserveANSI
+
+	colorMode := cfg.Color
+	if colorMode == "" && cfg.HerdConfig != nil {
+		colorMode = cfg.HerdConfig.Defaults.Color
+	}
+
 	return Model{
 		pool:         cfg.Pool,
 		executor:     cfg.Executor,
+		activeRun:    new(atomic.Pointer[executor.Run]),
 		allHosts:     cfg.AllHosts,
+		hostTags:     cfg.HostTags,
 		group:        cfg.GroupName,
+		recipes:      recipes,
+		cfg:          cfg.HerdConfig,
+		log:          log,
 		hostTable:    newHostTable(cfg.AllHosts, 40, 20),
-		outputPane:   newOutputPane(40, 20),
+		outputPane:   output,
 		commandInput: newCommandInput(80),
 		filterBar:    newFilterBar(80),
 		diffView:     newDiffView(80, 24),
+		groupCompare: newGroupCompare(80, 24),
+		palette:      newCommandPalette(paletteActions(recipes), 80, 24),
 		focused:      paneCommandInput,
 		healthTick:   cfg.HealthInterval,
+		healthLimit:  cfg.HealthConcurrency,
+		colorMode:    colorMode,
 	}
 }
 
 // Init returns the initial command (health check tick).
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		healthTickCmd(m.healthTick),
 		m.commandInput.Focus(),
-	)
+	}
+	if m.pool != nil {
+		cmds = append(cmds, probeFactsCmd(m.pool, m.allHosts, m.factOptions()))
+	}
+	return tea.Batch(cmds...)
+}
+
+// factOptions returns the executor settings for fact probes: the config's
+// default concurrency and timeout, or none without a config.
+func (m Model) factOptions() []executor.Option {
+	if m.cfg == nil {
+		return nil
+	}
+	return []executor.Option{
+		executor.WithConcurrency(m.cfg.Defaults.Concurrency),
+		executor.WithTimeout(m.cfg.Defaults.Timeout.Timeout()),
+	}
 }
 
 // Update handles all messages.
@@ -99,7 +172,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyPressMsg:
 		return m.handleKey(msg)
 
+	case tea.ColorProfileMsg:
+		// Bubble Tea reports the profile it detected; answer with the one
+		// the color mode forces, which the program then renders with.
+		if p, ok := forcedProfile(m.colorMode); ok && msg.Profile != p {
+			return m, func() tea.Msg { return tea.ColorProfileMsg{Profile: p} }
+		}
+		return m, nil
+
 	case execResultMsg:
+		m.logErr = msg.LogErr
 		m.lastCommand = msg.Command
 		m.lastResults = msg.Results
 		m.lastGrouped = msg.Grouped
@@ -108,7 +190,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case healthTickMsg:
-		return m, healthCheckCmd(m.pool, m.allHosts)
+		return m, healthCheckCmd(m.pool.Ping, m.allHosts, m.healthLimit)
+
+	case hostFactsMsg:
+		m.hostFacts = msg.Facts
+		m.hostTable.UpdateFacts(msg.Facts)
+		return m, nil
 
 	case healthCheckMsg:
 		m.hostTable.UpdateHealth(msg.Status)
@@ -148,6 +235,15 @@ func (m Model) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	if m.groupCompare.IsVisible() {
+		if key.Code == tea.KeyEscape {
+			m.groupCompare.Hide()
+			return m, nil
+		}
+		cmd := m.groupCompare.Update(msg)
+		return m, cmd
+	}
+
 	if m.showHelp {
 		if key.Code == tea.KeyEscape || msg.String() == "?" {
 			m.showHelp = false
@@ -156,6 +252,30 @@ func (m Model) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.palette.IsVisible() {
+		switch key.Code {
+		case tea.KeyEscape:
+			m.palette.Hide()
+			return m, nil
+		case tea.KeyEnter:
+			action, ok := m.palette.Selected()
+			m.palette.Hide()
+			if !ok {
+				return m, nil
+			}
+			return m.runPaletteAction(action)
+		}
+		cmd := m.palette.Update(msg)
+		return m, cmd
+	}
+
+	// The command palette opens with ctrl+p anywhere, or ":" when not typing.
+	if msg.String() == "ctrl+p" ||
+		(msg.String() == ":" && (m.focused != paneCommandInput || m.commandInput.Value() == "")) {
+		cmd := m.palette.Show()
+		return m, cmd
+	}
+
 	// Filter bar gets keys when visible and focused.
 	if m.filterBar.IsVisible() {
 		if key.Code == tea.KeyEscape {
@@ -182,6 +302,12 @@ func (m Model) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		case "f":
 			cmd := m.filterBar.Toggle()
 			return m, cmd
+		case "c":
+			// With fewer than two groups there is nothing to compare, so
+			// the key falls through to the focused pane.
+			if m.groupCompare.Show(m.lastGrouped) {
+				return m, nil
+			}
 		}
 	} else {
 		// In command input: ctrl+c always quits, q/? quit or toggle help when empty.
@@ -247,6 +373,21 @@ func (m Model) handleHostTableKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+	case msg.String() == "p" || msg.String() == "*":
+		// Pin or unpin the selected host at the top of the table.
+		if host := m.hostTable.SelectedHost(); host != "" {
+			m.hostTable.TogglePin(host)
+		}
+		return m, nil
+
+	case msg.String() == "x":
+		// Cancel the selected host if it is still running; the rest of the
+		// run carries on and the host reports a cancellation error.
+		if host := m.hostTable.SelectedHost(); host != "" && m.executor != nil {
+			m.executor.CancelHost(m.activeRun.Load(), host)
+		}
+		return m, nil
+
 	case msg.String() == "f":
 		cmd := m.filterBar.Toggle()
 		return m, cmd
@@ -304,6 +445,94 @@ func (m Model) handleCommandInputKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// runPaletteAction dispatches an action chosen in the command palette.
+func (m Model) runPaletteAction(a paletteAction) (tea.Model, tea.Cmd) {
+	switch a.kind {
+	case actionRerun:
+		if len(m.history) == 0 {
+			return m, nil
+		}
+		return m, m.executeCommand(m.history[len(m.history)-1])
+	case actionToggleFilter:
+		cmd := m.filterBar.Toggle()
+		return m, cmd
+	case actionShowDiff:
+		host := m.hostTable.SelectedHost()
+		if host != "" && m.lastGrouped != nil {
+			m.diffView.Show(host, m.lastGrouped, m.lastResults)
+		}
+		return m, nil
+	case actionCompareGroups:
+		m.groupCompare.Show(m.lastGrouped)
+		return m, nil
+	case actionFocusHosts:
+		return m.focusPane(paneHostTable), nil
+	case actionFocusOutput:
+		return m.focusPane(paneOutput), nil
+	case actionFocusInput:
+		return m.focusPane(paneCommandInput), nil
+	case actionToggleHelp:
+		m.showHelp = !m.showHelp
+		return m, nil
+	case actionQuit:
+		return m, tea.Quit
+	case actionRunRecipe:
+		return m, m.runRecipe(a.arg)
+	}
+	return m, nil
+}
+
+// focusPane moves focus directly to p.
+func (m Model) focusPane(p pane) Model {
+	for m.focused != p {
+		m = m.cycleFocus()
+	}
+	return m
+}
+
+// runRecipe runs the named recipe's steps in order and reports the final
+// step's results like a regular command.
+func (m Model) runRecipe(name string) tea.Cmd {
+	rec, ok := m.recipes[name]
+	if !ok {
+		return nil
+	}
+	steps := recipe.Steps(m.cfg, rec)
+
+	runner := recipe.New(m.executor, m.allHosts)
+	runner.SetHostTags(m.hostTags)
+	if m.pool != nil {
+		runner.SetTransfer(transfer.New(m.pool))
+	}
+	label := "recipe " + name
+	log := m.log
+	activeRun := m.activeRun
+	return func() tea.Msg {
+		ctx, run := executor.NewRun(context.Background())
+		activeRun.Store(run)
+		stepResults, _ := runner.Run(ctx, steps)
+		var logErr error
+		if log != nil {
+			for i, sr := range stepResults {
+				input := fmt.Sprintf(":recipe %s [%d/%d] %s", name, i+1, len(steps), rec.Steps[i])
+				if err := log.Record(input, sr.Grouped); err != nil {
+					logErr = err
+				}
+			}
+		}
+		if len(stepResults) == 0 {
+			return execResultMsg{Command: label, LogErr: logErr}
+		}
+		last := stepResults[len(stepResults)-1]
+		return execResultMsg{
+			Command: label + ": " + last.Step.Command,
+			Results: last.Results,
+			Grouped: last.Grouped,
+			LogErr:  logErr,
+		}
+	}
+}
+
 func (m Model) cycleFocus() Model {
 	// Blur current.
 	switch m.focused {
@@ -334,8 +563,11 @@ func (m Model) executeCommand(input string) tea.Cmd {
 	}
 
 	state := &selector.State{
-		AllHosts: m.allHosts,
-		Grouped:  m.lastGrouped,
+		AllHosts:  m.allHosts,
+		Grouped:   m.lastGrouped,
+		HostTags:  m.hostTags,
+		HostFacts: m.hostFacts,
+		Results:   m.lastResults,
 	}
 	hosts, err := selector.Resolve(sel, state)
 	if err != nil {
@@ -348,14 +580,23 @@ func (m Model) executeCommand(input string) tea.Cmd {
 	}
 
 	exec := m.executor
+	log := m.log
+	opts := recipe.GroupOptions(m.cfg, command)
+	activeRun := m.activeRun
 	return func() tea.Msg {
-		ctx := context.Background()
+		ctx, run := executor.NewRun(context.Background())
+		activeRun.Store(run)
 		results := exec.Execute(ctx, hosts, command)
-		grouped := grouper.Group(results)
+		grouped := opts.Group(results)
+		var logErr error
+		if log != nil {
+			logErr = log.Record(input, grouped)
+		}
 		return execResultMsg{
 			Command: command,
 			Results: results,
 			Grouped: grouped,
+			LogErr:  logErr,
 		}
 	}
 }
@@ -382,6 +623,8 @@ func (m *Model) resize() {
 	m.commandInput.Resize(m.width)
 	m.filterBar.Resize(m.width)
 	m.diffView.Resize(m.width, m.height)
+	m.groupCompare.Resize(m.width, m.height)
+	m.palette.Resize(m.width, m.height)
 }
 
 // View renders the full dashboard.
@@ -407,6 +650,14 @@ func (m Model) renderContent() string {
 		return m.diffView.View()
 	}
 
+	if m.groupCompare.IsVisible() {
+		return m.groupCompare.View()
+	}
+
+	if m.palette.IsVisible() {
+		return m.palette.View()
+	}
+
 	// Main layout.
 	tableWidth := m.width * 35 / 100
 	outputWidth := m.width - tableWidth
@@ -455,7 +706,11 @@ func (m Model) renderContent() string {
 	parts = append(parts, inputStyle.Render(m.commandInput.View()))
 
 	connCount := m.hostTable.ConnectedCount()
-	parts = append(parts, renderStatusBar(len(m.allHosts), connCount, m.width, m.group))
+	warning := ""
+	if m.logErr != nil {
+		warning = "session log error"
+	}
+	parts = append(parts, renderStatusBar(len(m.allHosts), connCount, m.width, m.group, warning))
 
 	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
@@ -120,7 +120,7 @@ func TestDashboardWithLiveHosts(t *testing.T) {
 	}
 
 	// Test health check.
-	healthCmd := healthCheckCmd(pool, hosts)
+	healthCmd := healthCheckCmd(pool.Ping, hosts, 0)
 	healthMsg := healthCmd()
 	hc, ok := healthMsg.(healthCheckMsg)
 	if !ok {
@@ -0,0 +1,182 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/colorprofile"
+
+	"github.com/agent462/herd/internal/config"
+	"github.com/agent462/herd/internal/executor"
+)
+
+type fakeRunner struct{}
+
+func (fakeRunner) Run(_ context.Context, host, _ string) *executor.HostResult {
+	return &executor.HostResult{Host: host, Stdout: []byte("ok\n")}
+}
+
+func TestExecuteCommandWritesSessionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.log")
+	m := New(Config{
+		Executor: executor.New(fakeRunner{}),
+		AllHosts: []string{"a", "b"},
+		LogFile:  path,
+	})
+
+	msg, ok := m.executeCommand("@a uptime")().(execResultMsg)
+	if !ok {
+		t.Fatal("expected execResultMsg")
+	}
+	if msg.LogErr != nil {
+		t.Fatalf("unexpected log error: %v", msg.LogErr)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "] @a uptime\n") || !strings.Contains(string(data), "1 host: 1 ok") {
+		t.Errorf("unexpected session log contents:\n%s", data)
+	}
+}
+
+func TestExecuteCommandTagSelector(t *testing.T) {
+	m := New(Config{
+		Executor: executor.New(fakeRunner{}),
+		AllHosts: []string{"web-01", "web-02", "db-01"},
+		HostTags: map[string][]string{"web-01": {"prod", "web"}, "db-01": {"prod"}},
+	})
+
+	msg := m.executeCommand("@tag:prod uptime")().(execResultMsg)
+
+	var hosts []string
+	for _, r := range msg.Results {
+		hosts = append(hosts, r.Host)
+	}
+	if strings.Join(hosts, ",") != "web-01,db-01" {
+		t.Errorf("@tag:prod ran on %v, want web-01 and db-01", hosts)
+	}
+}
+
+func TestExecuteCommandReportsSessionLogError(t *testing.T) {
+	m := New(Config{
+		Executor: executor.New(fakeRunner{}),
+		AllHosts: []string{"a"},
+		LogFile:  filepath.Join(t.TempDir(), "missing", "session.log"),
+	})
+
+	msg := m.executeCommand("uptime")().(execResultMsg)
+	if msg.LogErr == nil {
+		t.Fatal("expected log error for unwritable path")
+	}
+
+	next, _ := m.Update(msg)
+	m = next.(Model)
+	if m.logErr == nil {
+		t.Error("model should remember the log error for the status bar")
+	}
+}
+
+// hangingRunner blocks on every host until its context ends.
+type hangingRunner struct{ started chan string }
+
+func (r hangingRunner) Run(ctx context.Context, host, _ string) *executor.HostResult {
+	r.started <- host
+	<-ctx.Done()
+	return &executor.HostResult{Host: host, ExitCode: -1, Err: ctx.Err()}
+}
+
+func TestCancelSelectedHost(t *testing.T) {
+	started := make(chan string, 1)
+	m := New(Config{
+		Executor: executor.New(hangingRunner{started: started}),
+		AllHosts: []string{"a"},
+	})
+	m = m.focusPane(paneHostTable)
+
+	done := make(chan execResultMsg)
+	go func() { done <- m.executeCommand("sleep 600")().(execResultMsg) }()
+	<-started
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 'x', Text: "x"})
+	m = next.(Model)
+
+	select {
+	case msg := <-done:
+		if len(msg.Results) != 1 || !errors.Is(msg.Results[0].Err, executor.ErrCancelled) {
+			t.Errorf("results = %+v, want host a cancelled", msg.Results)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("x did not cancel the running host")
+	}
+}
+
+func TestCompareKey(t *testing.T) {
+	m := New(Config{
+		Executor: executor.New(fakeRunner{}),
+		AllHosts: []string{"a"},
+	})
+
+	// In the command input, c is typed like any other key.
+	next, _ := m.Update(tea.KeyPressMsg{Code: 'c', Text: "c"})
+	m = next.(Model)
+	if m.groupCompare.IsVisible() || m.commandInput.Value() != "c" {
+		t.Errorf("compare visible = %v, input = %q; want c typed into the input",
+			m.groupCompare.IsVisible(), m.commandInput.Value())
+	}
+
+	// In the host table, c does nothing without two groups to compare.
+	m = m.focusPane(paneHostTable)
+	next, _ = m.Update(tea.KeyPressMsg{Code: 'c', Text: "c"})
+	m = next.(Model)
+	if m.groupCompare.IsVisible() {
+		t.Error("compare opened with nothing to compare")
+	}
+}
+
+func TestColorModeForcesProfile(t *testing.T) {
+	detected := colorprofile.ANSI256
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    colorprofile.Profile
+		changed bool
+	}{
+		{"always", Config{Color: "always"}, colorprofile.TrueColor, true},
+		{"never", Config{Color: "never"}, colorprofile.Ascii, true},
+		{"auto", Config{Color: "auto"}, detected, false},
+		{"empty", Config{}, detected, false},
+		{"from config", Config{HerdConfig: &config.Config{Defaults: config.Defaults{Color: "never"}}}, colorprofile.Ascii, true},
+		{"flag overrides config", Config{Color: "auto", HerdConfig: &config.Config{Defaults: config.Defaults{Color: "never"}}}, detected, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.cfg)
+			_, cmd := m.Update(tea.ColorProfileMsg{Profile: detected})
+			if !tt.changed {
+				if cmd != nil {
+					t.Errorf("detected profile was replaced with %v", cmd())
+				}
+				return
+			}
+			if cmd == nil {
+				t.Fatalf("profile not forced, want %v", tt.want)
+			}
+			msg, ok := cmd().(tea.ColorProfileMsg)
+			if !ok || msg.Profile != tt.want {
+				t.Errorf("forced %v, want %v", cmd(), tt.want)
+			}
+			// The forced profile is kept once the program reports it.
+			if _, cmd := m.Update(msg); cmd != nil {
+				t.Errorf("forced profile %v was replaced again", msg.Profile)
+			}
+		})
+	}
+}
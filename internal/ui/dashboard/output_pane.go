@@ -28,6 +28,10 @@ type outputPane struct {
 	lastGrouped *grouper.GroupedResults
 	lastResults []*executor.HostResult
 	allHosts    []string
+
+	// preserveANSI keeps remote escape codes in per-host tabs; by default
+	// they are stripped like in the grouped view.
+	preserveANSI bool
 }
 
 func newOutputPane(width, height int) outputPane {
@@ -184,12 +188,16 @@ func (o *outputPane) renderGrouped(grouped *grouper.GroupedResults) {
 	var b strings.Builder
 
 	succeeded := 0
+	warned := 0
 	nonZero := 0
 
 	for _, g := range grouped.Groups {
-		if g.ExitCode != 0 {
+		switch {
+		case g.Failed():
 			nonZero += len(g.Hosts)
-		} else {
+		case g.Status == grouper.StatusWarn:
+			warned += len(g.Hosts)
+		default:
 			succeeded += len(g.Hosts)
 		}
 		writeGroup(&b, &g, len(grouped.Groups))
@@ -208,6 +216,9 @@ func (o *outputPane) renderGrouped(grouped *grouper.GroupedResults) {
 
 	// Summary.
 	summary := fmt.Sprintf("%d succeeded", succeeded)
+	if warned > 0 {
+		summary += fmt.Sprintf(", %d warn", warned)
+	}
 	if nonZero > 0 {
 		summary += fmt.Sprintf(", %d non-zero exit", nonZero)
 	}
@@ -240,6 +251,10 @@ func (o *outputPane) renderHostOutput(host string, grouped *grouper.GroupedResul
 		b.WriteString("\n")
 	}
 
+	if !o.preserveANSI {
+		r = grouper.StripANSI([]*executor.HostResult{r})[0]
+	}
+
 	stdout := strings.TrimRight(string(r.Stdout), "\n")
 	if stdout != "" {
 		b.WriteString(stdout)
@@ -255,7 +270,11 @@ func (o *outputPane) renderHostOutput(host string, grouped *grouper.GroupedResul
 		b.WriteString("\n")
 	}
 
-	b.WriteString(fmt.Sprintf("\nexit code: %d  duration: %s\n", r.ExitCode, r.Duration))
+	b.WriteString(fmt.Sprintf("\nexit code: %d  duration: %s", r.ExitCode, r.Duration))
+	if r.ConnectDuration > 0 || r.RunDuration > 0 {
+		b.WriteString(fmt.Sprintf(" (connect %s, run %s)", r.ConnectDuration, r.RunDuration))
+	}
+	b.WriteString("\n")
 
 	o.setContent(b.String())
 	o.viewport.GotoTop()
@@ -268,9 +287,12 @@ func writeGroup(b *strings.Builder, g *grouper.OutputGroup, totalGroups int) {
 		hostWord = "host"
 	}
 
-	if g.ExitCode != 0 {
+	if g.Failed() {
 		label := fmt.Sprintf("%d %s exited with code %d:", hostCount, hostWord, g.ExitCode)
 		b.WriteString(groupHeaderError.Render(label))
+	} else if g.Status == grouper.StatusWarn {
+		label := fmt.Sprintf("%d %s exited with code %d (warn):", hostCount, hostWord, g.ExitCode)
+		b.WriteString(groupHeaderDiffer.Render(label))
 	} else if g.IsNorm {
 		var label string
 		if totalGroups == 1 && hostCount == 1 {
@@ -0,0 +1,274 @@
+package dashboard
+
+import (
+	"sort"
+	"strings"
+
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+
+	"github.com/agent462/herd/internal/config"
+)
+
+// paletteMaxItems caps how many matching actions the palette lists at once.
+const paletteMaxItems = 10
+
+// paletteActionKind identifies what a command palette entry does.
+type paletteActionKind int
+
+const (
+	actionRerun paletteActionKind = iota
+	actionToggleFilter
+	actionShowDiff
+	actionCompareGroups
+	actionFocusHosts
+	actionFocusOutput
+	actionFocusInput
+	actionToggleHelp
+	actionQuit
+	actionRunRecipe
+)
+
+// paletteAction is a single entry in the command palette.
+type paletteAction struct {
+	kind  paletteActionKind
+	title string
+	desc  string
+	arg   string // recipe name for actionRunRecipe
+}
+
+// paletteActions returns the built-in dashboard actions followed by one
+// entry per recipe, sorted by name.
+func paletteActions(recipes map[string]config.Recipe) []paletteAction {
+	actions := []paletteAction{
+		{kind: actionRerun, title: "Re-run last command"},
+		{kind: actionToggleFilter, title: "Toggle host filter", desc: "f"},
+		{kind: actionShowDiff, title: "Show diff for selected host", desc: "d"},
+		{kind: actionCompareGroups, title: "Compare two groups", desc: "c"},
+		{kind: actionFocusHosts, title: "Focus host table"},
+		{kind: actionFocusOutput, title: "Focus output pane"},
+		{kind: actionFocusInput, title: "Focus command input"},
+		{kind: actionToggleHelp, title: "Toggle help", desc: "?"},
+		{kind: actionQuit, title: "Quit", desc: "q"},
+	}
+
+	names := make([]string, 0, len(recipes))
+	for name := range recipes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		actions = append(actions, paletteAction{
+			kind:  actionRunRecipe,
+			title: "Run recipe: " + name,
+			desc:  recipes[name].Description,
+			arg:   name,
+		})
+	}
+	return actions
+}
+
+// commandPalette is an overlay that fuzzy-searches dashboard actions.
+type commandPalette struct {
+	input    textinput.Model
+	actions  []paletteAction
+	matches  []paletteAction
+	selected int
+	visible  bool
+	width    int
+	height   int
+}
+
+func newCommandPalette(actions []paletteAction, width, height int) commandPalette {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "search actions..."
+
+	p := commandPalette{
+		input:   ti,
+		actions: actions,
+	}
+	p.Resize(width, height)
+	p.filter()
+	return p
+}
+
+// Show opens the palette with an empty query.
+func (p *commandPalette) Show() tea.Cmd {
+	p.visible = true
+	p.input.Reset()
+	p.filter()
+	return p.input.Focus()
+}
+
+// Hide closes the palette.
+func (p *commandPalette) Hide() {
+	p.visible = false
+	p.input.Blur()
+}
+
+func (p *commandPalette) IsVisible() bool {
+	return p.visible
+}
+
+// Selected returns the highlighted action, if any action matches.
+func (p *commandPalette) Selected() (paletteAction, bool) {
+	if p.selected < 0 || p.selected >= len(p.matches) {
+		return paletteAction{}, false
+	}
+	return p.matches[p.selected], true
+}
+
+func (p *commandPalette) Update(msg tea.Msg) tea.Cmd {
+	if !p.visible {
+		return nil
+	}
+	if key, ok := msg.(tea.KeyPressMsg); ok {
+		switch key.String() {
+		case "up", "ctrl+k":
+			if p.selected > 0 {
+				p.selected--
+			}
+			return nil
+		case "down", "ctrl+j":
+			if p.selected < len(p.matches)-1 {
+				p.selected++
+			}
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	p.filter()
+	return cmd
+}
+
+// filter recomputes the matching actions for the current query, best match
+// first. Ties keep the original action order.
+func (p *commandPalette) filter() {
+	query := p.input.Value()
+
+	type scored struct {
+		action paletteAction
+		score  int
+	}
+	var hits []scored
+	for _, a := range p.actions {
+		if s, ok := fuzzyScore(query, a.title); ok {
+			hits = append(hits, scored{a, s})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	p.matches = p.matches[:0]
+	for _, h := range hits {
+		p.matches = append(p.matches, h.action)
+	}
+	p.selected = 0
+}
+
+func (p *commandPalette) Resize(width, height int) {
+	p.width = width
+	p.height = height
+	p.input.SetWidth(p.boxWidth() - 6)
+}
+
+func (p *commandPalette) boxWidth() int {
+	w := p.width - 4
+	if w > 64 {
+		w = 64
+	}
+	if w < 20 {
+		w = 20
+	}
+	return w
+}
+
+func (p *commandPalette) View() string {
+	if !p.visible {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(p.input.View())
+	b.WriteString("\n")
+
+	if len(p.matches) == 0 {
+		b.WriteString("\n" + helpDescStyle.Render("  no matching actions"))
+	}
+
+	// Scroll so the selection stays within the visible window.
+	start := 0
+	if p.selected >= paletteMaxItems {
+		start = p.selected - paletteMaxItems + 1
+	}
+	end := start + paletteMaxItems
+	if end > len(p.matches) {
+		end = len(p.matches)
+	}
+	for i := start; i < end; i++ {
+		a := p.matches[i]
+		line := "  " + a.title
+		if i == p.selected {
+			line = helpKeyStyle.Render("▸ " + a.title)
+		}
+		if a.desc != "" {
+			line += helpDescStyle.Render("  " + a.desc)
+		}
+		b.WriteString("\n" + line)
+	}
+
+	box := lipgloss.NewStyle().
+		Width(p.boxWidth()).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorCyan).
+		Render(b.String())
+
+	return lipgloss.Place(p.width, p.height, lipgloss.Center, lipgloss.Center, box)
+}
+
+// fuzzyScore reports whether every rune of query appears in target in order
+// (case-insensitively) and scores the match: consecutive runs and matches at
+// the start of a word score higher. Every possible starting position is tried
+// and the best score wins. An empty query matches everything.
+func fuzzyScore(query, target string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(target))
+	if len(q) == 0 {
+		return 0, true
+	}
+
+	best, found := 0, false
+	for start := range t {
+		if t[start] != q[0] {
+			continue
+		}
+		if s, ok := fuzzyScoreFrom(q, t, start); ok && (!found || s > best) {
+			best, found = s, true
+		}
+	}
+	return best, found
+}
+
+// fuzzyScoreFrom greedily matches q against t beginning at t[start].
+func fuzzyScoreFrom(q, t []rune, start int) (int, bool) {
+	score, qi, prev := 0, 0, -2
+	for ti := start; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 2
+		}
+		if ti == 0 || t[ti-1] == ' ' || t[ti-1] == ':' || t[ti-1] == '-' {
+			score += 3
+		}
+		prev = ti
+		qi++
+	}
+	return score, qi == len(q)
+}
@@ -0,0 +1,140 @@
+package dashboard
+
+import (
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/agent462/herd/internal/config"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query, target string
+		match         bool
+	}{
+		{"", "Toggle help", true},
+		{"help", "Toggle help", true},
+		{"tgh", "Toggle help", true},
+		{"TOGGLE", "Toggle help", true},
+		{"hq", "Toggle help", false},
+		{"diskx", "Run recipe: disk-check", false},
+	}
+	for _, tt := range tests {
+		if _, ok := fuzzyScore(tt.query, tt.target); ok != tt.match {
+			t.Errorf("fuzzyScore(%q, %q) match = %v, want %v", tt.query, tt.target, ok, tt.match)
+		}
+	}
+}
+
+func TestFuzzyScorePrefersContiguousWordMatch(t *testing.T) {
+	word, _ := fuzzyScore("host", "Focus host table")
+	scattered, _ := fuzzyScore("host", "Show other settings")
+	if word <= scattered {
+		t.Errorf("expected contiguous word match to outscore scattered match: %d vs %d", word, scattered)
+	}
+
+	// The best starting position wins, not the first occurrence.
+	late, _ := fuzzyScore("recipe", "Run recipe: uptime")
+	if late < 3+5*3 {
+		t.Errorf("expected full-word score for late match, got %d", late)
+	}
+}
+
+func TestPaletteActionsIncludesRecipes(t *testing.T) {
+	actions := paletteActions(map[string]config.Recipe{
+		"uptime": {Description: "Show uptime"},
+		"deploy": {},
+	})
+
+	var recipes []string
+	for _, a := range actions {
+		if a.kind == actionRunRecipe {
+			recipes = append(recipes, a.arg)
+		}
+	}
+	if len(recipes) != 2 || recipes[0] != "deploy" || recipes[1] != "uptime" {
+		t.Errorf("recipe actions = %v, want [deploy uptime]", recipes)
+	}
+}
+
+func TestPaletteFilterAndSelect(t *testing.T) {
+	p := newCommandPalette(paletteActions(nil), 80, 24)
+	p.Show()
+
+	for _, r := range "quit" {
+		p.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+	a, ok := p.Selected()
+	if !ok || a.kind != actionQuit {
+		t.Fatalf("expected Quit selected for query %q, got %+v (ok=%v)", "quit", a, ok)
+	}
+
+	p.Show()
+	p.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	a, _ = p.Selected()
+	if a.kind != actionToggleFilter {
+		t.Errorf("expected second action after down, got %q", a.title)
+	}
+	p.Update(tea.KeyPressMsg{Code: tea.KeyUp})
+	p.Update(tea.KeyPressMsg{Code: tea.KeyUp})
+	a, _ = p.Selected()
+	if a.kind != actionRerun {
+		t.Errorf("expected selection clamped at first action, got %q", a.title)
+	}
+
+	for _, r := range "zzz" {
+		p.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+	if _, ok := p.Selected(); ok {
+		t.Error("expected no selection when nothing matches")
+	}
+}
+
+func TestModelPaletteDispatch(t *testing.T) {
+	m := New(Config{AllHosts: []string{"a", "b"}})
+	sized, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	m = sized.(Model)
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 'p', Mod: tea.ModCtrl})
+	m = next.(Model)
+	if !m.palette.IsVisible() {
+		t.Fatal("ctrl+p should open the palette")
+	}
+
+	for _, r := range "help" {
+		next, _ = m.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+		m = next.(Model)
+	}
+	if m.commandInput.Value() != "" {
+		t.Errorf("palette query leaked into command input: %q", m.commandInput.Value())
+	}
+
+	next, _ = m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	m = next.(Model)
+	if m.palette.IsVisible() {
+		t.Error("palette should close after dispatch")
+	}
+	if !m.showHelp {
+		t.Error("expected Toggle help action to open help")
+	}
+}
+
+func TestModelPaletteColonOnlyWhenInputEmpty(t *testing.T) {
+	m := New(Config{AllHosts: []string{"a"}})
+
+	next, _ := m.Update(tea.KeyPressMsg{Code: 'x', Text: "x"})
+	m = next.(Model)
+	next, _ = m.Update(tea.KeyPressMsg{Code: ':', Text: ":"})
+	m = next.(Model)
+	if m.palette.IsVisible() {
+		t.Error("':' should be typed into a non-empty command input")
+	}
+
+	m.commandInput.Reset()
+	next, _ = m.Update(tea.KeyPressMsg{Code: ':', Text: ":"})
+	m = next.(Model)
+	if !m.palette.IsVisible() {
+		t.Error("':' on an empty command input should open the palette")
+	}
+}
@@ -0,0 +1,49 @@
+package dashboard
+
+import "time"
+
+// sparklineLen is the number of recent durations retained per host.
+const sparklineLen = 8
+
+// sparkBlocks are the block characters used to draw a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// recordDuration appends d to history, dropping the oldest entry once the
+// buffer holds sparklineLen values.
+func recordDuration(history []time.Duration, d time.Duration) []time.Duration {
+	history = append(history, d)
+	if len(history) > sparklineLen {
+		history = history[len(history)-sparklineLen:]
+	}
+	return history
+}
+
+// sparkline renders durations as a row of block characters scaled between
+// the smallest and largest value in the slice. A flat series renders at the
+// lowest block height.
+func sparkline(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return ""
+	}
+
+	lo, hi := durations[0], durations[0]
+	for _, d := range durations[1:] {
+		if d < lo {
+			lo = d
+		}
+		if d > hi {
+			hi = d
+		}
+	}
+
+	out := make([]rune, len(durations))
+	span := hi - lo
+	for i, d := range durations {
+		idx := 0
+		if span > 0 {
+			idx = int(int64(d-lo) * int64(len(sparkBlocks)-1) / int64(span))
+		}
+		out[i] = sparkBlocks[idx]
+	}
+	return string(out)
+}
@@ -0,0 +1,72 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []time.Duration
+		want string
+	}{
+		{"empty", nil, ""},
+		{"single", []time.Duration{time.Second}, "▁"},
+		{"flat", []time.Duration{time.Second, time.Second, time.Second}, "▁▁▁"},
+		{"rising", []time.Duration{0, 7 * time.Millisecond}, "▁█"},
+		{"full range", []time.Duration{0, 1, 2, 3, 4, 5, 6, 7}, "▁▂▃▄▅▆▇█"},
+		{"spike", []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 80 * time.Millisecond}, "▁▁█"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sparkline(tc.in); got != tc.want {
+				t.Errorf("sparkline(%v) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecordDurationCapsHistory(t *testing.T) {
+	var history []time.Duration
+	for i := 1; i <= sparklineLen+3; i++ {
+		history = recordDuration(history, time.Duration(i))
+	}
+
+	if len(history) != sparklineLen {
+		t.Fatalf("expected %d entries, got %d", sparklineLen, len(history))
+	}
+	if history[0] != 4 {
+		t.Errorf("expected oldest retained duration 4, got %d", history[0])
+	}
+	if history[len(history)-1] != sparklineLen+3 {
+		t.Errorf("expected newest duration %d, got %d", sparklineLen+3, history[len(history)-1])
+	}
+}
+
+func TestUpdateResultsRecordsDurationHistory(t *testing.T) {
+	ht := newHostTable([]string{"web-01", "web-02"}, 80, 20)
+
+	for _, d := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond} {
+		results := []*executor.HostResult{
+			{Host: "web-01", Stdout: []byte("ok\n"), Duration: d},
+			{Host: "web-02", Stdout: []byte("ok\n"), Duration: d},
+		}
+		ht.UpdateResults("uptime", grouper.Group(results), results)
+	}
+
+	for _, e := range ht.entries {
+		if len(e.History) != 3 {
+			t.Errorf("%s: expected 3 history entries, got %d", e.Name, len(e.History))
+		}
+	}
+
+	row := ht.table.Rows()[0]
+	if got := row[len(row)-1]; got != "▁▃█" {
+		t.Errorf("expected trend column %q, got %q", "▁▃█", got)
+	}
+}
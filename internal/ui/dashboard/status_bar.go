@@ -7,7 +7,8 @@ import (
 )
 
 // renderStatusBar builds the bottom status bar showing connection counts and keybind hints.
-func renderStatusBar(totalHosts, connectedHosts int, width int, groupName string) string {
+// A non-empty warning is shown in red after the connection counts.
+func renderStatusBar(totalHosts, connectedHosts int, width int, groupName, warning string) string {
 	left := fmt.Sprintf(" %d hosts", totalHosts)
 	if groupName != "" {
 		left = fmt.Sprintf(" %s: %d hosts", groupName, totalHosts)
@@ -21,6 +22,9 @@ func renderStatusBar(totalHosts, connectedHosts int, width int, groupName string
 	}
 
 	left += " │ " + connStr + disconnStr
+	if warning != "" {
+		left += " │ " + statusDisconnected.Render(warning)
+	}
 
 	// Build right-side hints, dropping lowest-priority items (from the end)
 	// when they don't fit alongside the left side.
@@ -33,6 +37,7 @@ func renderStatusBar(totalHosts, connectedHosts int, width int, groupName string
 		{"?", "help"},
 		{"f", "filter"},
 		{"d", "diff"},
+		{"^p", "actions"},
 	}
 
 	rightPadding := 1 // trailing space
@@ -75,6 +80,10 @@ func renderHelpOverlay(width, height int) string {
   1-9          Jump to output tab by number
   f            Toggle host filter bar
   d            Show diff for selected divergent host
+  p / *        Pin or unpin selected host at the top
+  x            Cancel selected host if still running
+  c            Compare two output groups directly
+  : / Ctrl+P   Command palette (recipes and actions)
   ?            Toggle this help
 
   Selectors (in command input)
@@ -83,8 +92,14 @@ func renderHelpOverlay(width, height int) string {
   @ok          Hosts in norm group
   @differs     Hosts that differ from norm
   @failed      Failed hosts (errors + non-zero exit)
+  @failed:auth Failed hosts by class (auth, hostkey, network, timeout)
   @timeout     Timed out hosts
+  @match:/re/  Hosts whose last stdout matches re
+  @any         First host in the set
+  @random      One random host
+  @random=N    N random hosts (also @first=N, @last=N)
   @pattern*    Glob match on host names
+  !@sel        Exclude a selector's hosts (@all,!@differs)
 `
 
 	style := lipgloss.NewStyle().
@@ -2,8 +2,23 @@ package dashboard
 
 import (
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/colorprofile"
 )
 
+// forcedProfile returns the color profile a color mode ("auto", "always",
+// or "never") forces on the dashboard. For "auto" (or empty) it returns
+// false and the profile Bubble Tea detects for the terminal is kept.
+func forcedProfile(mode string) (colorprofile.Profile, bool) {
+	switch mode {
+	case "always":
+		return colorprofile.TrueColor, true
+	case "never":
+		return colorprofile.Ascii, true
+	default:
+		return 0, false
+	}
+}
+
 // Color palette.
 var (
 	colorGreen   = lipgloss.Color("#04B575")
@@ -0,0 +1,27 @@
+package exec
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// IsTerminal reports whether f is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// ColorEnabled resolves a color mode ("auto", "always", or "never") to a
+// concrete on/off decision for output written to f. An empty mode is treated
+// as "auto", which enables color only when f is a terminal and NO_COLOR is
+// unset.
+func ColorEnabled(mode string, f *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return os.Getenv("NO_COLOR") == "" && IsTerminal(f)
+	}
+}
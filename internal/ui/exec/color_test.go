@@ -0,0 +1,37 @@
+package exec
+
+import (
+	"os"
+	"testing"
+)
+
+func TestColorEnabled(t *testing.T) {
+	// A regular file is never a terminal, so "auto" must disable color.
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		mode string
+		want bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"auto", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		if got := ColorEnabled(tc.mode, f); got != tc.want {
+			t.Errorf("ColorEnabled(%q) = %v, want %v", tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestColorEnabledAlwaysIgnoresNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if !ColorEnabled("always", os.Stdout) {
+		t.Error("expected \"always\" to enable color even with NO_COLOR set")
+	}
+}
@@ -0,0 +1,167 @@
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/agent462/herd/internal/grouper"
+)
+
+// CompareReport holds the host-by-host differences between two JSON exports,
+// such as the same command run before and after a change. Hosts in each
+// category are sorted by name.
+type CompareReport struct {
+	Changed   []HostChange
+	Unchanged []string
+	OnlyA     []string // hosts only in the first export
+	OnlyB     []string // hosts only in the second export
+}
+
+// HostChange describes how one host's result differs between two exports.
+// Durations and run IDs are not compared.
+type HostChange struct {
+	Host         string
+	ExitA, ExitB int
+	ErrA, ErrB   string
+	StdoutDiff   string // unified diff of stdout, a vs b; empty if unchanged
+	StderrDiff   string // unified diff of stderr, a vs b; empty if unchanged
+}
+
+// CompareExports matches the hosts of two JSON exports, as written by
+// FormatJSON and :export, and reports which hosts changed. A host changed if
+// its stdout, stderr, exit code or error differs.
+func CompareExports(a, b []byte) (CompareReport, error) {
+	var report CompareReport
+	resA, err := parseExport(a)
+	if err != nil {
+		return report, fmt.Errorf("first export: %w", err)
+	}
+	resB, err := parseExport(b)
+	if err != nil {
+		return report, fmt.Errorf("second export: %w", err)
+	}
+
+	for host, ra := range resA {
+		rb, ok := resB[host]
+		if !ok {
+			report.OnlyA = append(report.OnlyA, host)
+			continue
+		}
+		if ra.Stdout == rb.Stdout && ra.Stderr == rb.Stderr && ra.ExitCode == rb.ExitCode && ra.Error == rb.Error {
+			report.Unchanged = append(report.Unchanged, host)
+			continue
+		}
+		c := HostChange{Host: host, ExitA: ra.ExitCode, ExitB: rb.ExitCode, ErrA: ra.Error, ErrB: rb.Error}
+		if ra.Stdout != rb.Stdout {
+			c.StdoutDiff = grouper.LabeledDiff(ra.Stdout, rb.Stdout, "a/"+host, "b/"+host)
+		}
+		if ra.Stderr != rb.Stderr {
+			c.StderrDiff = grouper.LabeledDiff(ra.Stderr, rb.Stderr, "a/"+host+" stderr", "b/"+host+" stderr")
+		}
+		report.Changed = append(report.Changed, c)
+	}
+	for host := range resB {
+		if _, ok := resA[host]; !ok {
+			report.OnlyB = append(report.OnlyB, host)
+		}
+	}
+
+	sort.Slice(report.Changed, func(i, j int) bool { return report.Changed[i].Host < report.Changed[j].Host })
+	sort.Strings(report.Unchanged)
+	sort.Strings(report.OnlyA)
+	sort.Strings(report.OnlyB)
+	return report, nil
+}
+
+// parseExport decodes a JSON export into its results keyed by host.
+func parseExport(data []byte) (map[string]jsonResult, error) {
+	var results []jsonResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("not a herd JSON export: %w", err)
+	}
+	byHost := make(map[string]jsonResult, len(results))
+	for _, r := range results {
+		if r.Host == "" {
+			return nil, fmt.Errorf("result without a host")
+		}
+		if _, dup := byHost[r.Host]; dup {
+			return nil, fmt.Errorf("host %q appears more than once", r.Host)
+		}
+		byHost[r.Host] = r
+	}
+	return byHost, nil
+}
+
+// FormatCompare renders a comparison of two exports: each changed host with
+// its exit code or error change and diffs, then hosts found in only one
+// export, unchanged hosts, and a summary line.
+func (f *Formatter) FormatCompare(report CompareReport) string {
+	var b strings.Builder
+
+	for _, c := range report.Changed {
+		b.WriteString(f.colorize(" 1 host changed:", colorYellow))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(c.Host, colorCyan))
+		b.WriteString("\n")
+		if c.ExitA != c.ExitB {
+			b.WriteString(fmt.Sprintf("   exit code %d -> %d\n", c.ExitA, c.ExitB))
+		}
+		if c.ErrA != c.ErrB {
+			b.WriteString(fmt.Sprintf("   error %s -> %s\n", describeErr(c.ErrA), describeErr(c.ErrB)))
+		}
+		for _, diff := range []string{c.StdoutDiff, c.StderrDiff} {
+			if diff != "" {
+				b.WriteString("\n")
+				f.writeDiff(&b, diff)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	for _, only := range []struct {
+		hosts []string
+		which string
+	}{{report.OnlyA, "first"}, {report.OnlyB, "second"}} {
+		if len(only.hosts) == 0 {
+			continue
+		}
+		label := fmt.Sprintf(" %d %s only in the %s export:", len(only.hosts), pluralHost(len(only.hosts)), only.which)
+		b.WriteString(f.colorize(label, colorYellow))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(strings.Join(only.hosts, ", "), colorCyan))
+		b.WriteString("\n\n")
+	}
+
+	if len(report.Unchanged) > 0 && !f.ErrorsOnly {
+		label := fmt.Sprintf(" %d %s unchanged:", len(report.Unchanged), pluralHost(len(report.Unchanged)))
+		b.WriteString(f.colorize(label, colorGreen))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(strings.Join(report.Unchanged, ", "), colorCyan))
+		b.WriteString("\n\n")
+	}
+
+	parts := []string{
+		fmt.Sprintf("%d changed", len(report.Changed)),
+		fmt.Sprintf("%d unchanged", len(report.Unchanged)),
+	}
+	if n := len(report.OnlyA); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d only in first", n))
+	}
+	if n := len(report.OnlyB); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d only in second", n))
+	}
+	b.WriteString(strings.Join(parts, ", "))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// describeErr formats an export's error field, which is empty on success.
+func describeErr(err string) string {
+	if err == "" {
+		return "none"
+	}
+	return fmt.Sprintf("%q", err)
+}
@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+)
+
+// export returns results as FormatJSON writes them.
+func export(t *testing.T, results ...*executor.HostResult) []byte {
+	t.Helper()
+	data, err := NewFormatter(true, false, false).FormatJSON(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCompareExports(t *testing.T) {
+	a := export(t,
+		&executor.HostResult{Host: "web-1", Stdout: []byte("nginx 1.24\n"), Duration: time.Second},
+		&executor.HostResult{Host: "web-2", Stdout: []byte("nginx 1.24\n")},
+		&executor.HostResult{Host: "web-3", Stdout: []byte("nginx 1.24\n")},
+		&executor.HostResult{Host: "db-1", Stdout: []byte("ok\n")},
+	)
+	b := export(t,
+		&executor.HostResult{Host: "web-3", Stdout: []byte("nginx 1.24\n")},
+		&executor.HostResult{Host: "web-1", Stdout: []byte("nginx 1.26\n"), Duration: 2 * time.Second},
+		&executor.HostResult{Host: "web-2", ExitCode: -1, Err: errors.New("connection refused")},
+		&executor.HostResult{Host: "web-4", Stdout: []byte("nginx 1.26\n")},
+	)
+
+	report, err := CompareExports(a, b)
+	if err != nil {
+		t.Fatalf("CompareExports: %v", err)
+	}
+	if len(report.Changed) != 2 || report.Changed[0].Host != "web-1" || report.Changed[1].Host != "web-2" {
+		t.Fatalf("changed = %+v, want web-1 and web-2", report.Changed)
+	}
+	if d := report.Changed[0].StdoutDiff; !strings.Contains(d, "-nginx 1.24") || !strings.Contains(d, "+nginx 1.26") {
+		t.Errorf("web-1 diff = %q", d)
+	}
+	if c := report.Changed[1]; c.ExitB != -1 || c.ErrB != "connection refused" {
+		t.Errorf("web-2 change = %+v", c)
+	}
+	if strings.Join(report.Unchanged, ",") != "web-3" {
+		t.Errorf("unchanged = %v, want [web-3] (durations are ignored)", report.Unchanged)
+	}
+	if strings.Join(report.OnlyA, ",") != "db-1" || strings.Join(report.OnlyB, ",") != "web-4" {
+		t.Errorf("only in a = %v, only in b = %v", report.OnlyA, report.OnlyB)
+	}
+}
+
+func TestCompareExportsErrors(t *testing.T) {
+	ok := export(t, &executor.HostResult{Host: "a"})
+	tests := []struct {
+		name    string
+		a, b    string
+		wantErr string
+	}{
+		{"not json", "uptime", string(ok), "first export: not a herd JSON export"},
+		{"object", string(ok), `{"host": "a"}`, "second export: not a herd JSON export"},
+		{"duplicate", `[{"host": "a"}, {"host": "a"}]`, string(ok), `host "a" appears more than once`},
+		{"no host", string(ok), `[{"stdout": "x"}]`, "result without a host"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CompareExports([]byte(tc.a), []byte(tc.b))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatCompare(t *testing.T) {
+	report := CompareReport{
+		Changed: []HostChange{
+			{Host: "web-1", StdoutDiff: "--- a/web-1\n+++ b/web-1\n-v1\n+v2\n"},
+			{Host: "web-2", ExitA: 0, ExitB: 1, ErrB: ""},
+		},
+		Unchanged: []string{"web-3"},
+		OnlyB:     []string{"web-4"},
+	}
+
+	output := NewFormatter(false, false, false).FormatCompare(report)
+	for _, want := range []string{
+		"1 host changed:",
+		"+++ b/web-1",
+		"exit code 0 -> 1",
+		"1 host only in the second export:",
+		"1 host unchanged:",
+		"2 changed, 1 unchanged, 1 only in second",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got:\n%s", want, output)
+		}
+	}
+
+	output = NewFormatter(false, true, false).FormatCompare(report)
+	if strings.Contains(output, "unchanged:") {
+		t.Errorf("errors-only output should omit unchanged hosts, got:\n%s", output)
+	}
+}
@@ -3,7 +3,10 @@ package exec
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/agent462/herd/internal/executor"
 	"github.com/agent462/herd/internal/grouper"
@@ -23,6 +26,8 @@ type Formatter struct {
 	JSON       bool
 	ErrorsOnly bool
 	Color      bool
+
+	summaryTmpl *template.Template // nil uses the built-in summary line
 }
 
 // NewFormatter creates a Formatter with the given options.
@@ -40,27 +45,31 @@ func (f *Formatter) Format(grouped *grouper.GroupedResults) string {
 
 	succeeded := 0
 	nonZero := 0
-	failed := len(grouped.Failed)
+	warned := 0
+	failedByClass := grouped.FailedByClass
+	if failedByClass == nil {
+		failedByClass = grouper.ClassifyFailed(grouped.Failed)
+	}
 	timedOut := len(grouped.TimedOut)
 
 	// Show groups (unless errors-only mode skips successful ones).
 	for _, g := range grouped.Groups {
-		if g.ExitCode != 0 {
+		switch {
+		case g.Failed():
 			nonZero += len(g.Hosts)
-		} else {
+		case g.Status == grouper.StatusWarn:
+			warned += len(g.Hosts)
+		default:
 			succeeded += len(g.Hosts)
 		}
-		if !f.ErrorsOnly || g.ExitCode != 0 {
+		if !f.ErrorsOnly || g.Failed() || g.Status == grouper.StatusWarn {
 			f.writeGroup(&b, &g, len(grouped.Groups))
 			b.WriteString("\n")
 		}
 	}
 
-	// Show failed hosts.
-	for _, r := range grouped.Failed {
-		f.writeFailed(&b, r)
-		b.WriteString("\n")
-	}
+	// Show failed hosts, grouped by failure class.
+	f.writeFailures(&b, failedByClass)
 
 	// Show timed out hosts.
 	for _, r := range grouped.TimedOut {
@@ -69,36 +78,319 @@ func (f *Formatter) Format(grouped *grouper.GroupedResults) string {
 	}
 
 	// Summary line.
-	b.WriteString(f.summaryLine(succeeded, nonZero, failed, timedOut))
+	b.WriteString(f.summaryLine(Summary{
+		Hosts:     succeeded + warned + nonZero + len(grouped.Failed) + timedOut,
+		Succeeded: succeeded,
+		Warn:      warned,
+		NonZero:   nonZero,
+		Failed:    len(grouped.Failed),
+		Timeout:   timedOut,
+		Groups:    len(grouped.Groups),
+		Elapsed:   grouped.Elapsed,
+	}, failedByClass))
 	b.WriteString("\n")
 
 	return b.String()
 }
 
-// FormatJSON serializes results as a JSON array.
-func (f *Formatter) FormatJSON(results []*executor.HostResult) ([]byte, error) {
-	type jsonResult struct {
-		Host     string `json:"host"`
-		Stdout   string `json:"stdout"`
-		Stderr   string `json:"stderr"`
-		ExitCode int    `json:"exit_code"`
-		Duration string `json:"duration"`
-		Error    string `json:"error,omitempty"`
+// FormatFlat renders results grouped with grouper.Options.Flat: each host
+// under its own name and exit code, in run order, with no norm and no diffs.
+// Failures, timeouts and the summary line are as in Format.
+func (f *Formatter) FormatFlat(grouped *grouper.GroupedResults) string {
+	var b strings.Builder
+
+	s := Summary{Groups: len(grouped.Groups), Elapsed: grouped.Elapsed}
+	for _, g := range grouped.Groups {
+		var label string
+		switch {
+		case g.Failed():
+			s.NonZero++
+			label = f.colorize(fmt.Sprintf(" %s exited with code %d:", strings.Join(g.Hosts, ", "), g.ExitCode), colorRed)
+		case g.Status == grouper.StatusWarn:
+			s.Warn++
+			label = f.colorize(fmt.Sprintf(" %s exited with code %d (warn):", strings.Join(g.Hosts, ", "), g.ExitCode), colorYellow)
+		default:
+			s.Succeeded++
+			if f.ErrorsOnly {
+				continue
+			}
+			label = " " + f.colorize(strings.Join(g.Hosts, ", "), colorCyan) + ":"
+		}
+		b.WriteString(label)
+		b.WriteString("\n")
+		f.writeOutput(&b, g.Stdout, g.Stderr)
+		b.WriteString("\n")
+	}
+
+	failedByClass := grouped.FailedByClass
+	if failedByClass == nil {
+		failedByClass = grouper.ClassifyFailed(grouped.Failed)
+	}
+	f.writeFailures(&b, failedByClass)
+	for _, r := range grouped.TimedOut {
+		f.writeTimedOut(&b, r)
+		b.WriteString("\n")
+	}
+
+	s.Failed = len(grouped.Failed)
+	s.Timeout = len(grouped.TimedOut)
+	s.Hosts = s.Succeeded + s.Warn + s.NonZero + s.Failed + s.Timeout
+	b.WriteString(f.summaryLine(s, failedByClass))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// FormatGolden renders a golden-file comparison report: passing hosts, then
+// mismatches with a diff against each host's golden output, then hosts with
+// no golden file and connection failures.
+func (f *Formatter) FormatGolden(report *grouper.GoldenReport) string {
+	var b strings.Builder
+
+	if len(report.Passed) > 0 && !f.ErrorsOnly {
+		hosts := make([]string, len(report.Passed))
+		for i, r := range report.Passed {
+			hosts[i] = r.Host
+		}
+		label := fmt.Sprintf(" %d %s matching golden:", len(hosts), pluralHost(len(hosts)))
+		b.WriteString(f.colorize(label, colorGreen))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(strings.Join(hosts, ", "), colorCyan))
+		b.WriteString("\n\n")
+	}
+
+	for _, r := range report.Mismatch {
+		b.WriteString(f.colorize(" 1 host differs from golden:", colorYellow))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(r.Host, colorCyan))
+		b.WriteString("\n\n")
+		f.writeDiff(&b, r.Diff)
+		b.WriteString("\n")
+	}
+
+	if len(report.Missing) > 0 {
+		label := fmt.Sprintf(" %d %s without golden file:", len(report.Missing), pluralHost(len(report.Missing)))
+		b.WriteString(f.colorize(label, colorYellow))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(strings.Join(report.Missing, ", "), colorCyan))
+		b.WriteString("\n\n")
+	}
+
+	failedByClass := grouper.ClassifyFailed(report.Failed)
+	f.writeFailures(&b, failedByClass)
+	for _, r := range report.TimedOut {
+		f.writeTimedOut(&b, r)
+		b.WriteString("\n")
+	}
+
+	parts := []string{fmt.Sprintf("%d passed", len(report.Passed))}
+	if n := len(report.Mismatch); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d mismatch", n))
+	}
+	if n := len(report.Missing); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing golden", n))
+	}
+	parts = append(parts, failureParts(failedByClass)...)
+	if n := len(report.TimedOut); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d timeout", n))
+	}
+	b.WriteString(strings.Join(parts, ", "))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// FormatVerify renders a verification report: hosts that met the
+// expectation, then each host that did not with its output and exit code,
+// then connection failures.
+func (f *Formatter) FormatVerify(report *grouper.VerifyReport) string {
+	var b strings.Builder
+
+	if len(report.Passed) > 0 && !f.ErrorsOnly {
+		expected := report.Expected.Value
+		if report.Expected.Pattern == nil {
+			expected = strconv.Quote(expected)
+		}
+		label := fmt.Sprintf(" %d %s matching %s:", len(report.Passed), pluralHost(len(report.Passed)), expected)
+		b.WriteString(f.colorize(label, colorGreen))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(strings.Join(report.Passed, ", "), colorCyan))
+		b.WriteString("\n\n")
+	}
+
+	for _, r := range report.Mismatch {
+		label := " 1 host does not match:"
+		if r.ExitCode != 0 {
+			label = fmt.Sprintf(" 1 host does not match (exit code %d):", r.ExitCode)
+		}
+		b.WriteString(f.colorize(label, colorYellow))
+		b.WriteString("\n")
+		b.WriteString("   " + f.colorize(r.Host, colorCyan))
+		b.WriteString("\n")
+		f.writeOutput(&b, r.Stdout, r.Stderr)
+		b.WriteString("\n")
+	}
+
+	failedByClass := grouper.ClassifyFailed(report.Failed)
+	f.writeFailures(&b, failedByClass)
+	for _, r := range report.TimedOut {
+		f.writeTimedOut(&b, r)
+		b.WriteString("\n")
+	}
+
+	parts := []string{fmt.Sprintf("%d passed", len(report.Passed))}
+	if n := len(report.Mismatch); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d mismatch", n))
+	}
+	parts = append(parts, failureParts(failedByClass)...)
+	if n := len(report.TimedOut); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d timeout", n))
+	}
+	b.WriteString(strings.Join(parts, ", "))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// jsonResult is one host's entry in a JSON export.
+type jsonResult struct {
+	Host     string `json:"host"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	Duration string `json:"duration"`
+	Connect  string `json:"connect_duration,omitempty"`
+	Run      string `json:"run_duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+	RunID    string `json:"run_id,omitempty"`
+}
+
+// newJSONResult converts a host result to its JSON export entry.
+func newJSONResult(r *executor.HostResult) jsonResult {
+	out := jsonResult{
+		Host:     r.Host,
+		Stdout:   string(r.Stdout),
+		Stderr:   string(r.Stderr),
+		ExitCode: r.ExitCode,
+		Duration: r.Duration.String(),
+		RunID:    r.RunID,
+	}
+	if r.ConnectDuration > 0 || r.RunDuration > 0 {
+		out.Connect = r.ConnectDuration.String()
+		out.Run = r.RunDuration.String()
 	}
+	if r.Err != nil {
+		out.Error = r.Err.Error()
+	}
+	return out
+}
 
+// FormatJSON serializes results as a JSON array.
+func (f *Formatter) FormatJSON(results []*executor.HostResult) ([]byte, error) {
 	out := make([]jsonResult, len(results))
 	for i, r := range results {
-		out[i] = jsonResult{
-			Host:     r.Host,
-			Stdout:   string(r.Stdout),
-			Stderr:   string(r.Stderr),
-			ExitCode: r.ExitCode,
-			Duration: r.Duration.String(),
+		out[i] = newJSONResult(r)
+	}
+
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// WriteJSONL writes results to w as JSON Lines: one compact object per host,
+// with the fields of FormatJSON, each on its own line. Each line is written
+// as soon as it is encoded, so a consumer such as jq -c can process hosts
+// one at a time.
+func (f *Formatter) WriteJSONL(w io.Writer, results []*executor.HostResult) error {
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		if err := enc.Encode(newJSONResult(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonGroup is one output group in the FormatGroupedJSON document.
+type jsonGroup struct {
+	Hosts    []string `json:"hosts"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+	Status   string   `json:"status"`
+	IsNorm   bool     `json:"is_norm"`
+	Diff     string   `json:"diff,omitempty"`
+}
+
+// jsonSummary holds the counts of the FormatGroupedJSON document; they are
+// those of Summary.
+type jsonSummary struct {
+	Hosts     int    `json:"hosts"`
+	Succeeded int    `json:"succeeded"`
+	Warn      int    `json:"warn"`
+	NonZero   int    `json:"nonzero"`
+	Failed    int    `json:"failed"`
+	Timeout   int    `json:"timeout"`
+	Groups    int    `json:"groups"`
+	Elapsed   string `json:"elapsed"`
+}
+
+// jsonGrouped is the document written by FormatGroupedJSON.
+type jsonGrouped struct {
+	Summary  jsonSummary  `json:"summary"`
+	Groups   []jsonGroup  `json:"groups"`
+	NonZero  []string     `json:"nonzero"`
+	Failed   []jsonResult `json:"failed"`
+	TimedOut []jsonResult `json:"timedout"`
+}
+
+// FormatGroupedJSON serializes grouped results as a JSON object, keeping the
+// grouper's analysis that FormatJSON drops: each output group with its hosts,
+// exit status, whether it is the norm and its diff against the norm. Hosts
+// in groups whose exit code means failure are also listed under "nonzero";
+// connection failures and timeouts appear under "failed" and "timedout" in
+// the per-host form of FormatJSON. A "summary" object holds the counts of
+// the summary line.
+func (f *Formatter) FormatGroupedJSON(grouped *grouper.GroupedResults) ([]byte, error) {
+	out := jsonGrouped{
+		Groups:   make([]jsonGroup, len(grouped.Groups)),
+		NonZero:  []string{},
+		Failed:   make([]jsonResult, len(grouped.Failed)),
+		TimedOut: make([]jsonResult, len(grouped.TimedOut)),
+	}
+	s := &out.Summary
+	for i, g := range grouped.Groups {
+		status := grouper.StatusOK
+		switch {
+		case g.Failed():
+			status = grouper.StatusFail
+			s.NonZero += len(g.Hosts)
+			out.NonZero = append(out.NonZero, g.Hosts...)
+		case g.Status == grouper.StatusWarn:
+			status = grouper.StatusWarn
+			s.Warn += len(g.Hosts)
+		default:
+			s.Succeeded += len(g.Hosts)
 		}
-		if r.Err != nil {
-			out[i].Error = r.Err.Error()
+		out.Groups[i] = jsonGroup{
+			Hosts:    g.Hosts,
+			Stdout:   string(g.Stdout),
+			Stderr:   string(g.Stderr),
+			ExitCode: g.ExitCode,
+			Status:   string(status),
+			IsNorm:   g.IsNorm,
+			Diff:     g.Diff,
 		}
 	}
+	for i, r := range grouped.Failed {
+		out.Failed[i] = newJSONResult(r)
+	}
+	for i, r := range grouped.TimedOut {
+		out.TimedOut[i] = newJSONResult(r)
+	}
+	s.Failed = len(grouped.Failed)
+	s.Timeout = len(grouped.TimedOut)
+	s.Hosts = s.Succeeded + s.Warn + s.NonZero + s.Failed + s.Timeout
+	s.Groups = len(grouped.Groups)
+	s.Elapsed = grouped.Elapsed.String()
 
 	return json.MarshalIndent(out, "", "  ")
 }
@@ -110,9 +402,12 @@ func (f *Formatter) writeGroup(b *strings.Builder, g *grouper.OutputGroup, total
 		hostWord = "host"
 	}
 
-	if g.ExitCode != 0 {
+	if g.Failed() {
 		label := fmt.Sprintf(" %d %s exited with code %d:", hostCount, hostWord, g.ExitCode)
 		b.WriteString(f.colorize(label, colorRed))
+	} else if g.Status == grouper.StatusWarn {
+		label := fmt.Sprintf(" %d %s exited with code %d (warn):", hostCount, hostWord, g.ExitCode)
+		b.WriteString(f.colorize(label, colorYellow))
 	} else if g.IsNorm {
 		var label string
 		if totalGroups == 1 && hostCount == 1 {
@@ -137,8 +432,19 @@ func (f *Formatter) writeGroup(b *strings.Builder, g *grouper.OutputGroup, total
 	b.WriteString(hostList)
 	b.WriteString("\n")
 
+	f.writeOutput(b, g.Stdout, g.Stderr)
+
+	// Diff for outlier groups.
+	if !g.IsNorm && g.Diff != "" {
+		b.WriteString("\n")
+		f.writeDiff(b, g.Diff)
+	}
+}
+
+// writeOutput writes stdout and stderr indented under a group's label.
+func (f *Formatter) writeOutput(b *strings.Builder, stdoutBytes, stderrBytes []byte) {
 	// Output (indented).
-	stdout := strings.TrimRight(string(g.Stdout), "\n")
+	stdout := strings.TrimRight(string(stdoutBytes), "\n")
 	if stdout != "" {
 		for _, line := range strings.Split(stdout, "\n") {
 			b.WriteString("   ")
@@ -148,7 +454,7 @@ func (f *Formatter) writeGroup(b *strings.Builder, g *grouper.OutputGroup, total
 	}
 
 	// Stderr (if any).
-	stderr := strings.TrimRight(string(g.Stderr), "\n")
+	stderr := strings.TrimRight(string(stderrBytes), "\n")
 	if stderr != "" {
 		for _, line := range strings.Split(stderr, "\n") {
 			b.WriteString("   ")
@@ -156,12 +462,6 @@ func (f *Formatter) writeGroup(b *strings.Builder, g *grouper.OutputGroup, total
 			b.WriteString("\n")
 		}
 	}
-
-	// Diff for outlier groups.
-	if !g.IsNorm && g.Diff != "" {
-		b.WriteString("\n")
-		f.writeDiff(b, g.Diff)
-	}
 }
 
 func (f *Formatter) writeDiff(b *strings.Builder, diff string) {
@@ -181,19 +481,73 @@ func (f *Formatter) writeDiff(b *strings.Builder, diff string) {
 	}
 }
 
-func (f *Formatter) writeFailed(b *strings.Builder, r *executor.HostResult) {
-	label := " 1 host failed:"
-	b.WriteString(f.colorize(label, colorRed))
-	b.WriteString("\n")
+// failureLabels and failureHints describe each grouper failure class.
+var (
+	failureLabels = map[grouper.FailClass]string{
+		grouper.FailAuth:        "authentication",
+		grouper.FailHostKey:     "host key",
+		grouper.FailRefused:     "connection refused",
+		grouper.FailUnreachable: "unreachable",
+	}
+	failureHints = map[grouper.FailClass]string{
+		grouper.FailAuth:        "the server rejected our credentials; check the SSH key, agent or password",
+		grouper.FailHostKey:     "the host key is unknown or changed; check known_hosts before reconnecting",
+		grouper.FailRefused:     "nothing is listening on the SSH port; check that sshd is running",
+		grouper.FailUnreachable: "the host could not be reached; check the hostname and that it is up",
+	}
+	// failureSummaries are the summary-line formats for each class.
+	failureSummaries = map[grouper.FailClass]string{
+		grouper.FailAuth:        "%d auth failed",
+		grouper.FailHostKey:     "%d host-key failed",
+		grouper.FailRefused:     "%d refused",
+		grouper.FailUnreachable: "%d unreachable",
+		grouper.FailOther:       "%d failed",
+	}
+)
 
-	errMsg := "unknown error"
-	if r.Err != nil {
-		errMsg = r.Err.Error()
+// writeFailures renders failed hosts grouped by failure class, each group
+// followed by a short explanation of the likely cause.
+func (f *Formatter) writeFailures(b *strings.Builder, byClass map[grouper.FailClass][]*executor.HostResult) {
+	for _, class := range grouper.FailClasses {
+		results := byClass[class]
+		if len(results) == 0 {
+			continue
+		}
+		label := fmt.Sprintf(" %d %s failed:", len(results), pluralHost(len(results)))
+		if l, ok := failureLabels[class]; ok {
+			label = fmt.Sprintf(" %d %s failed (%s):", len(results), pluralHost(len(results)), l)
+		}
+		b.WriteString(f.colorize(label, colorRed))
+		b.WriteString("\n")
+
+		for _, r := range results {
+			errMsg := "unknown error"
+			if r.Err != nil {
+				errMsg = r.Err.Error()
+			}
+			b.WriteString("   ")
+			b.WriteString(f.colorize(r.Host, colorCyan))
+			b.WriteString(fmt.Sprintf(" (%s)", errMsg))
+			b.WriteString("\n")
+		}
+		if hint, ok := failureHints[class]; ok {
+			b.WriteString("   " + f.colorize(hint, colorYellow))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
 	}
-	b.WriteString("   ")
-	b.WriteString(f.colorize(r.Host, colorCyan))
-	b.WriteString(fmt.Sprintf(" (%s)", errMsg))
-	b.WriteString("\n")
+}
+
+// failureParts returns summary-line parts such as "3 auth failed" and
+// "2 unreachable", one per failure class with hosts.
+func failureParts(byClass map[grouper.FailClass][]*executor.HostResult) []string {
+	var parts []string
+	for _, class := range grouper.FailClasses {
+		if n := len(byClass[class]); n > 0 {
+			parts = append(parts, fmt.Sprintf(failureSummaries[class], n))
+		}
+	}
+	return parts
 }
 
 func (f *Formatter) writeTimedOut(b *strings.Builder, r *executor.HostResult) {
@@ -211,22 +565,37 @@ func (f *Formatter) writeTimedOut(b *strings.Builder, r *executor.HostResult) {
 	b.WriteString("\n")
 }
 
-func (f *Formatter) summaryLine(succeeded, nonZero, failed, timedOut int) string {
+// summaryLine renders the summary template if one is set and renders
+// cleanly, and the built-in counts otherwise.
+func (f *Formatter) summaryLine(s Summary, failedByClass map[grouper.FailClass][]*executor.HostResult) string {
+	if f.summaryTmpl != nil {
+		if line, ok := f.renderSummary(s); ok {
+			return line
+		}
+	}
 	parts := []string{
-		fmt.Sprintf("%d succeeded", succeeded),
+		fmt.Sprintf("%d succeeded", s.Succeeded),
 	}
-	if nonZero > 0 {
-		parts = append(parts, fmt.Sprintf("%d non-zero exit", nonZero))
+	if s.Warn > 0 {
+		parts = append(parts, fmt.Sprintf("%d warn", s.Warn))
 	}
-	if failed > 0 {
-		parts = append(parts, fmt.Sprintf("%d failed", failed))
+	if s.NonZero > 0 {
+		parts = append(parts, fmt.Sprintf("%d non-zero exit", s.NonZero))
 	}
-	if timedOut > 0 {
-		parts = append(parts, fmt.Sprintf("%d timeout", timedOut))
+	parts = append(parts, failureParts(failedByClass)...)
+	if s.Timeout > 0 {
+		parts = append(parts, fmt.Sprintf("%d timeout", s.Timeout))
 	}
 	return strings.Join(parts, ", ")
 }
 
+func pluralHost(n int) string {
+	if n == 1 {
+		return "host"
+	}
+	return "hosts"
+}
+
 func (f *Formatter) colorize(text, color string) string {
 	if !f.Color {
 		return text
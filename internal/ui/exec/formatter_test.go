@@ -68,7 +68,7 @@ func TestFormatWithDiffs(t *testing.T) {
 func TestFormatJSON(t *testing.T) {
 	results := []*executor.HostResult{
 		{Host: "host-a", Stdout: []byte("ok\n"), ExitCode: 0, Duration: 2 * time.Second},
-		{Host: "host-b", Stdout: []byte("ok\n"), ExitCode: 0, Duration: time.Second},
+		{Host: "host-b", Stdout: []byte("ok\n"), ExitCode: 0, Duration: time.Second, ConnectDuration: 300 * time.Millisecond, RunDuration: 700 * time.Millisecond},
 		{Host: "host-c", Err: errors.New("connection refused"), Duration: 0},
 	}
 
@@ -95,6 +95,99 @@ func TestFormatJSON(t *testing.T) {
 	if _, ok := parsed[0]["error"]; ok {
 		t.Errorf("expected no error field for successful host, got %v", parsed[0]["error"])
 	}
+	// Phase timings appear only when the runner reported them.
+	if parsed[1]["connect_duration"] != "300ms" || parsed[1]["run_duration"] != "700ms" {
+		t.Errorf("expected phase timings 300ms/700ms, got %v/%v", parsed[1]["connect_duration"], parsed[1]["run_duration"])
+	}
+	if _, ok := parsed[0]["connect_duration"]; ok {
+		t.Errorf("expected no connect_duration without phase timings, got %v", parsed[0]["connect_duration"])
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("ok\n"), Duration: time.Second, RunID: "abc"},
+		{Host: "host-b", Stderr: []byte("boom\n"), ExitCode: 2},
+		{Host: "host-c", Err: errors.New("connection refused")},
+	}
+
+	var b strings.Builder
+	if err := NewFormatter(true, false, false).WriteJSONL(&b, results); err != nil {
+		t.Fatalf("WriteJSONL error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want one per host:\n%s", len(lines), b.String())
+	}
+	want := `{"host":"host-a","stdout":"ok\n","stderr":"","exit_code":0,"duration":"1s","run_id":"abc"}`
+	if lines[0] != want {
+		t.Errorf("line 1 = %s\nwant %s", lines[0], want)
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &parsed); err != nil || parsed["exit_code"] != float64(2) {
+		t.Errorf("line 2 = %s (err %v), want exit_code 2", lines[1], err)
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &parsed); err != nil || parsed["error"] != "connection refused" {
+		t.Errorf("line 3 = %s (err %v), want the error", lines[2], err)
+	}
+}
+
+func TestFormatGroupedJSON(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("v1\n")},
+		{Host: "host-b", Stdout: []byte("v1\n")},
+		{Host: "host-c", Stdout: []byte("v2\n"), ExitCode: 1},
+		{Host: "host-d", Err: errors.New("connection refused")},
+		{Host: "host-e", Err: context.DeadlineExceeded},
+	}
+	data, err := NewFormatter(true, false, false).FormatGroupedJSON(grouper.Group(results))
+	if err != nil {
+		t.Fatalf("FormatGroupedJSON error: %v", err)
+	}
+
+	var doc struct {
+		Summary map[string]any `json:"summary"`
+		Groups  []struct {
+			Hosts    []string `json:"hosts"`
+			ExitCode int      `json:"exit_code"`
+			Status   string   `json:"status"`
+			IsNorm   bool     `json:"is_norm"`
+			Diff     string   `json:"diff"`
+		} `json:"groups"`
+		NonZero  []string         `json:"nonzero"`
+		Failed   []map[string]any `json:"failed"`
+		TimedOut []map[string]any `json:"timedout"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+
+	if len(doc.Groups) != 2 {
+		t.Fatalf("got %d groups, want 2:\n%s", len(doc.Groups), data)
+	}
+	norm, outlier := doc.Groups[0], doc.Groups[1]
+	if !norm.IsNorm || len(norm.Hosts) != 2 || norm.Status != "ok" || norm.Diff != "" {
+		t.Errorf("norm group = %+v, want host-a and host-b, ok, no diff", norm)
+	}
+	if outlier.IsNorm || outlier.ExitCode != 1 || outlier.Status != "fail" || !strings.Contains(outlier.Diff, "+v2") {
+		t.Errorf("outlier group = %+v, want exit 1, fail, with a diff", outlier)
+	}
+	if len(doc.NonZero) != 1 || doc.NonZero[0] != "host-c" {
+		t.Errorf("nonzero = %v, want [host-c]", doc.NonZero)
+	}
+	if len(doc.Failed) != 1 || doc.Failed[0]["host"] != "host-d" {
+		t.Errorf("failed = %v, want host-d", doc.Failed)
+	}
+	if len(doc.TimedOut) != 1 || doc.TimedOut[0]["host"] != "host-e" {
+		t.Errorf("timedout = %v, want host-e", doc.TimedOut)
+	}
+	want := map[string]float64{"hosts": 5, "succeeded": 2, "nonzero": 1, "failed": 1, "timeout": 1, "groups": 2}
+	for k, v := range want {
+		if doc.Summary[k] != v {
+			t.Errorf("summary %s = %v, want %v", k, doc.Summary[k], v)
+		}
+	}
 }
 
 func TestFormatErrorsOnly(t *testing.T) {
@@ -151,6 +244,59 @@ func TestFormatSummaryLine(t *testing.T) {
 	}
 }
 
+func TestFormatExitMap(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("same\n"), ExitCode: 0},
+		{Host: "host-b", Stdout: []byte("changed\n"), ExitCode: 1},
+		{Host: "host-c", Stdout: []byte("stale\n"), ExitCode: 3},
+	}
+	grouped := grouper.Options{ExitMap: map[int]grouper.ExitStatus{1: grouper.StatusOK, 3: grouper.StatusWarn}}.Group(results)
+
+	output := NewFormatter(false, false, false).Format(grouped)
+	if !strings.Contains(output, "exited with code 3 (warn)") {
+		t.Errorf("expected warn label for exit 3, got:\n%s", output)
+	}
+	if !strings.Contains(output, "2 succeeded, 1 warn") {
+		t.Errorf("expected '2 succeeded, 1 warn' in summary, got:\n%s", output)
+	}
+	if strings.Contains(output, "non-zero") {
+		t.Errorf("mapped exit codes should not count as non-zero, got:\n%s", output)
+	}
+}
+
+func TestFormatSummaryTemplate(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("ok\n"), Duration: 1200 * time.Millisecond},
+		{Host: "host-b", Stdout: []byte("ok\n"), Duration: 1500 * time.Millisecond},
+		{Host: "host-c", Stdout: []byte("no\n"), ExitCode: 1},
+		{Host: "host-d", Err: errors.New("connection refused")},
+		{Host: "host-e", Err: context.DeadlineExceeded, Duration: 2345678 * time.Microsecond},
+	}
+	grouped := grouper.Group(results)
+
+	f := NewFormatter(false, false, false)
+	if err := f.SetSummaryTemplate("hosts={{.Hosts}} ok={{.Succeeded}} nonzero={{.NonZero}} failed={{.Failed}} timeout={{.Timeout}} groups={{.Groups}} elapsed={{.Elapsed}}"); err != nil {
+		t.Fatal(err)
+	}
+	want := "hosts=5 ok=2 nonzero=1 failed=1 timeout=1 groups=2 elapsed=2.346s\n"
+	if output := f.Format(grouped); !strings.HasSuffix(output, want) {
+		t.Errorf("expected summary %q, got:\n%s", want, output)
+	}
+
+	if err := f.SetSummaryTemplate(""); err != nil {
+		t.Fatal(err)
+	}
+	if output := f.Format(grouped); !strings.Contains(output, "2 succeeded, 1 non-zero exit") {
+		t.Errorf("expected default summary after reset, got:\n%s", output)
+	}
+
+	for _, text := range []string{"{{.Succeeded", "{{.Bogus}}"} {
+		if err := f.SetSummaryTemplate(text); err == nil {
+			t.Errorf("SetSummaryTemplate(%q): expected error", text)
+		}
+	}
+}
+
 func TestFormatWithColor(t *testing.T) {
 	results := []*executor.HostResult{
 		{Host: "host-a", Stdout: []byte("ok\n"), ExitCode: 0},
@@ -219,3 +365,110 @@ func TestFormatSingleHost(t *testing.T) {
 		t.Errorf("expected '1 succeeded', got:\n%s", output)
 	}
 }
+
+func TestFormatFlat(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "web-02", Stdout: []byte("web-02\n")},
+		{Host: "web-01", Stdout: []byte("web-01\n")},
+		{Host: "web-03", Stderr: []byte("boom\n"), ExitCode: 2},
+	}
+
+	output := NewFormatter(false, false, false).FormatFlat(grouper.Options{}.Flat(results))
+
+	want := " web-02:\n   web-02\n\n web-01:\n   web-01\n\n web-03 exited with code 2:\n   stderr: boom\n\n2 succeeded, 1 non-zero exit\n"
+	if output != want {
+		t.Errorf("got:\n%s\nwant:\n%s", output, want)
+	}
+	if strings.Contains(output, "identical") || strings.Contains(output, "differ") {
+		t.Errorf("flat output should not mention grouping:\n%s", output)
+	}
+
+	errorsOnly := NewFormatter(false, true, false).FormatFlat(grouper.Options{}.Flat(results))
+	if strings.Contains(errorsOnly, "web-01") || !strings.Contains(errorsOnly, "web-03") {
+		t.Errorf("errors-only should show only the failing host:\n%s", errorsOnly)
+	}
+}
+
+func TestFormatGolden(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("v1\n")},
+		{Host: "host-b", Stdout: []byte("v2\n")},
+		{Host: "host-c", Stdout: []byte("v1\n")},
+	}
+	golden := map[string][]byte{
+		"host-a": []byte("v1\n"),
+		"host-b": []byte("v1\n"),
+	}
+
+	f := NewFormatter(false, false, false)
+	output := f.FormatGolden(grouper.CompareGolden(results, golden))
+
+	for _, want := range []string{
+		"1 host matching golden:",
+		"1 host differs from golden:",
+		"+++ host-b",
+		"1 host without golden file:",
+		"1 passed, 1 mismatch, 1 missing golden",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatVerify(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Stdout: []byte("12\n")},
+		{Host: "host-b", Stdout: []byte("11\n")},
+		{Host: "host-c", Stdout: []byte("12\n")},
+		{Host: "host-d", Stderr: []byte("not found\n"), ExitCode: 127},
+	}
+	exp, err := grouper.ParseExpectation("12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFormatter(false, false, false)
+	output := f.FormatVerify(grouper.Verify(results, exp))
+
+	for _, want := range []string{
+		"2 hosts matching \"12\":\n   host-a, host-c",
+		"1 host does not match:\n   host-b\n   11",
+		"1 host does not match (exit code 127):\n   host-d\n   stderr: not found",
+		"2 passed, 2 mismatch",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q, got:\n%s", want, output)
+		}
+	}
+}
+
+type kindErr struct{ kind, msg string }
+
+func (e kindErr) Error() string       { return e.msg }
+func (e kindErr) FailureKind() string { return e.kind }
+
+func TestFormatFailuresGroupedByClass(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "host-a", Err: kindErr{"auth", "unable to authenticate"}},
+		{Host: "host-b", Err: kindErr{"auth", "unable to authenticate"}},
+		{Host: "host-c", Err: kindErr{"refused", "connection refused"}},
+		{Host: "host-d", Err: errors.New("boom")},
+	}
+
+	f := NewFormatter(false, false, false)
+	output := f.Format(grouper.Group(results))
+
+	if !strings.Contains(output, "2 hosts failed (authentication):") {
+		t.Errorf("expected grouped auth failures, got:\n%s", output)
+	}
+	if !strings.Contains(output, "check the SSH key") {
+		t.Errorf("expected auth explanation, got:\n%s", output)
+	}
+	if !strings.Contains(output, "1 host failed:\n   host-d (boom)") {
+		t.Errorf("expected uncategorized failure, got:\n%s", output)
+	}
+	if !strings.Contains(output, "0 succeeded, 2 auth failed, 1 refused, 1 failed") {
+		t.Errorf("expected per-class summary, got:\n%s", output)
+	}
+}
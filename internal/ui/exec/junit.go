@@ -0,0 +1,117 @@
+package exec
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+)
+
+// JUnit XML document structure, following the de facto schema understood
+// by CI systems (Jenkins, GitLab, GitHub Actions reporters).
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr,omitempty"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// FormatJUnit serializes grouped results as JUnit XML with one test case
+// per host, sorted by host name, in a suite named after command. Hosts whose
+// exit status is ok or warn pass; a failing exit is a failure whose body is
+// the host's stdout and stderr; connection failures and timeouts are errors
+// typed by failure class.
+func FormatJUnit(grouped *grouper.GroupedResults, command string) ([]byte, error) {
+	suite := junitSuite{
+		Name: command,
+		Time: junitSeconds(grouped.Elapsed),
+	}
+
+	for _, g := range grouped.Groups {
+		for _, h := range g.Hosts {
+			tc := junitCase{Name: h, ClassName: "herd"}
+			if g.Failed() {
+				tc.Failure = &junitProblem{
+					Message: fmt.Sprintf("exit code %d", g.ExitCode),
+					Type:    "exit",
+					Body:    junitOutput(g.Stdout, g.Stderr),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+	}
+
+	errored := func(r *executor.HostResult, kind string) {
+		msg := "timed out"
+		if r.Err != nil {
+			msg = r.Err.Error()
+		}
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      r.Host,
+			ClassName: "herd",
+			Time:      junitSeconds(r.Duration),
+			Error:     &junitProblem{Message: msg, Type: kind, Body: junitOutput(r.Stdout, r.Stderr)},
+		})
+		suite.Errors++
+	}
+	for _, r := range grouped.Failed {
+		errored(r, string(grouper.ClassifyFailure(r.Err)))
+	}
+	for _, r := range grouped.TimedOut {
+		errored(r, "timeout")
+	}
+
+	sort.Slice(suite.Cases, func(i, j int) bool { return suite.Cases[i].Name < suite.Cases[j].Name })
+	suite.Tests = len(suite.Cases)
+
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// junitSeconds formats d as fractional seconds, as JUnit time attributes
+// expect.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// junitOutput joins stdout and stderr into a failure body.
+func junitOutput(stdout, stderr []byte) string {
+	var b strings.Builder
+	b.Write(stdout)
+	if len(stderr) > 0 {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		b.Write(stderr)
+	}
+	return b.String()
+}
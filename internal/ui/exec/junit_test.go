@@ -0,0 +1,63 @@
+package exec
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+)
+
+func TestFormatJUnit(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "web-02", Stdout: []byte("ok\n"), Duration: time.Second},
+		{Host: "web-01", Stdout: []byte("ok\n"), Duration: 1500 * time.Millisecond},
+		{Host: "web-03", Stdout: []byte("disk <full>\n"), Stderr: []byte("warning\n"), ExitCode: 2},
+		{Host: "web-04", Err: errors.New("connection refused")},
+		{Host: "web-05", Err: context.DeadlineExceeded, Duration: 2 * time.Second},
+	}
+	data, err := FormatJUnit(grouper.Group(results), "df -h /")
+	if err != nil {
+		t.Fatalf("FormatJUnit: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("missing XML header:\n%s", data)
+	}
+
+	var doc junitSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, data)
+	}
+	if len(doc.Suites) != 1 {
+		t.Fatalf("expected 1 suite, got %d", len(doc.Suites))
+	}
+	s := doc.Suites[0]
+	if s.Name != "df -h /" || s.Tests != 5 || s.Failures != 1 || s.Errors != 2 || s.Time != "2.000" {
+		t.Errorf("suite = name %q tests %d failures %d errors %d time %s", s.Name, s.Tests, s.Failures, s.Errors, s.Time)
+	}
+
+	var names []string
+	for _, tc := range s.Cases {
+		names = append(names, tc.Name)
+	}
+	if got := strings.Join(names, ","); got != "web-01,web-02,web-03,web-04,web-05" {
+		t.Errorf("cases not sorted by host: %s", got)
+	}
+
+	if s.Cases[0].Failure != nil || s.Cases[0].Error != nil {
+		t.Errorf("web-01 should pass: %+v", s.Cases[0])
+	}
+	if f := s.Cases[2].Failure; f == nil || f.Message != "exit code 2" || f.Body != "disk <full>\nwarning\n" {
+		t.Errorf("web-03 failure = %+v", f)
+	}
+	if e := s.Cases[3].Error; e == nil || e.Type != "other" || e.Message != "connection refused" {
+		t.Errorf("web-04 error = %+v", e)
+	}
+	if e := s.Cases[4].Error; e == nil || e.Type != "timeout" {
+		t.Errorf("web-05 error = %+v", e)
+	}
+}
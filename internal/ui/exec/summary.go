@@ -0,0 +1,61 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Summary holds the counts available to a summary template.
+type Summary struct {
+	Hosts     int           // hosts in the run
+	Succeeded int           // hosts whose exit status is ok
+	Warn      int           // hosts whose exit code is mapped to warn
+	NonZero   int           // hosts whose exit code means failure
+	Failed    int           // hosts whose connection or command failed
+	Timeout   int           // hosts that timed out
+	Groups    int           // distinct outputs
+	Elapsed   time.Duration // duration of the slowest host
+}
+
+// ParseSummaryTemplate parses a text/template for the summary line, such
+// as "{{.Succeeded}} ok, {{.Failed}} failed in {{.Elapsed}}". Fields are
+// those of Summary; a reference to any other field is reported here rather
+// than when the template is first rendered.
+func ParseSummaryTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("summary").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("summary template: %w", err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, Summary{}); err != nil {
+		return nil, fmt.Errorf("summary template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// SetSummaryTemplate renders the summary line with the given template
+// instead of the built-in format. An empty text restores the default.
+func (f *Formatter) SetSummaryTemplate(text string) error {
+	if text == "" {
+		f.summaryTmpl = nil
+		return nil
+	}
+	tmpl, err := ParseSummaryTemplate(text)
+	if err != nil {
+		return err
+	}
+	f.summaryTmpl = tmpl
+	return nil
+}
+
+// renderSummary executes the summary template, reporting false if it fails
+// so the caller can fall back to the built-in format.
+func (f *Formatter) renderSummary(s Summary) (string, bool) {
+	s.Elapsed = s.Elapsed.Round(time.Millisecond)
+	var b strings.Builder
+	if err := f.summaryTmpl.Execute(&b, s); err != nil {
+		return "", false
+	}
+	return strings.TrimRight(b.String(), "\n"), true
+}
@@ -0,0 +1,140 @@
+// Package progress renders host-by-host progress for long operations, such
+// as connecting to or transferring files to many hosts, outside the
+// dashboard.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"golang.org/x/term"
+)
+
+// Bar is a single-line progress bar. On a terminal it redraws the line in
+// place; otherwise it writes a plain line at most once per interval, so logs
+// and CI output are not flooded. A Bar is safe for concurrent use.
+type Bar struct {
+	mu       sync.Mutex
+	w        io.Writer
+	label    string
+	tty      bool
+	width    int           // terminal columns; lines are cut to fit
+	interval time.Duration // minimum time between non-terminal lines
+
+	total    int
+	done     int
+	lastHost string
+	lastLine time.Time // when the last non-terminal line was written
+	written  int       // done count of the last line written, or -1
+}
+
+// Option configures a Bar.
+type Option func(*Bar)
+
+// WithTerminal overrides terminal detection and sets the line width.
+func WithTerminal(tty bool, width int) Option {
+	return func(b *Bar) {
+		b.tty = tty
+		b.width = width
+	}
+}
+
+// WithInterval sets how often a line is written when w is not a terminal.
+func WithInterval(d time.Duration) Option {
+	return func(b *Bar) {
+		b.interval = d
+	}
+}
+
+// New creates a Bar that writes to w, prefixing each line with label. If w
+// is a terminal the bar is redrawn in place.
+func New(w io.Writer, label string, opts ...Option) *Bar {
+	b := &Bar{w: w, label: label, interval: 2 * time.Second, width: 80}
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		b.tty = true
+		if cols, _, err := term.GetSize(int(f.Fd())); err == nil && cols > 0 {
+			b.width = cols
+		}
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Start begins an operation over total hosts.
+func (b *Bar) Start(total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.total = total
+	b.done = 0
+	b.lastHost = ""
+	b.lastLine = time.Time{}
+	b.written = -1
+	b.render(true)
+}
+
+// Increment records that host has finished.
+func (b *Bar) Increment(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done++
+	b.lastHost = host
+	b.render(false)
+}
+
+// Finish writes the final state and ends the line.
+func (b *Bar) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastHost = ""
+	if b.tty {
+		b.render(true)
+		fmt.Fprintln(b.w)
+		return
+	}
+	if b.written != b.done {
+		b.render(true)
+	}
+}
+
+// render draws the current state. Non-terminal output is rate limited
+// unless force is set or every host is done.
+func (b *Bar) render(force bool) {
+	if b.tty {
+		fmt.Fprint(b.w, "\r"+ansi.EraseLineRight+ansi.Truncate(b.line(true), b.width-1, ""))
+		b.written = b.done
+		return
+	}
+	now := time.Now()
+	if !force && b.done < b.total && now.Sub(b.lastLine) < b.interval {
+		return
+	}
+	fmt.Fprintln(b.w, b.line(false))
+	b.lastLine = now
+	b.written = b.done
+}
+
+// line formats the progress text, with a bar on terminals.
+func (b *Bar) line(bar bool) string {
+	var s strings.Builder
+	s.WriteString(b.label)
+	if bar {
+		const size = 20
+		filled := 0
+		if b.total > 0 {
+			filled = min(size, b.done*size/b.total)
+		}
+		s.WriteString(" [" + strings.Repeat("=", filled) + strings.Repeat(" ", size-filled) + "]")
+	}
+	fmt.Fprintf(&s, " %d/%d", b.done, b.total)
+	if b.lastHost != "" && b.done < b.total {
+		s.WriteString(" " + b.lastHost)
+	}
+	return s.String()
+}
@@ -0,0 +1,68 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBarTerminal(t *testing.T) {
+	var buf strings.Builder
+	b := New(&buf, "connecting", WithTerminal(true, 80))
+	b.Start(4)
+	b.Increment("web-01")
+	b.Increment("web-02")
+	b.Finish()
+
+	out := buf.String()
+	if !strings.Contains(out, "connecting [==========          ] 2/4 web-02") {
+		t.Errorf("expected half-full bar naming the last host, got %q", out)
+	}
+	if !strings.HasSuffix(out, "] 2/4\n") {
+		t.Errorf("expected final line to end the bar, got %q", out)
+	}
+	if strings.Count(out, "\r") != 4 {
+		t.Errorf("expected each update to redraw in place, got %q", out)
+	}
+}
+
+func TestBarTerminalTruncates(t *testing.T) {
+	var buf strings.Builder
+	b := New(&buf, "transferring", WithTerminal(true, 30))
+	b.Start(1)
+	b.Increment("a-very-long-host-name.example.com")
+
+	lines := strings.Split(buf.String(), "\r")
+	last := lines[len(lines)-1]
+	if strings.Contains(last, "example.com") {
+		t.Errorf("expected line cut to terminal width, got %q", last)
+	}
+}
+
+func TestBarNonTerminal(t *testing.T) {
+	var buf strings.Builder
+	b := New(&buf, "connecting", WithTerminal(false, 0), WithInterval(time.Hour))
+	b.Start(3)
+	b.Increment("web-01")
+	b.Increment("web-02")
+	b.Increment("web-03")
+	b.Finish()
+
+	want := "connecting 0/3\nconnecting 3/3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestBarNonTerminalFinishWritesFinalCount(t *testing.T) {
+	var buf strings.Builder
+	b := New(&buf, "connecting", WithTerminal(false, 0), WithInterval(time.Hour))
+	b.Start(3)
+	b.Increment("web-01")
+	b.Finish() // interrupted before every host finished
+
+	want := "connecting 0/3\nconnecting 1/3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/agent462/herd/internal/config"
+	"github.com/agent462/herd/internal/recipe"
+	hssh "github.com/agent462/herd/internal/ssh"
+)
+
+// editConfig opens the config file in $EDITOR, then reloads it and
+// re-resolves the session's hosts. If the edited config does not load, or no
+// longer resolves the current group, the previous config stays in use.
+func (r *REPL) editConfig() error {
+	path := r.configPath
+	if path == "" {
+		path = config.DefaultConfigPath()
+	}
+	if path == "" {
+		return errors.New("no config file path (home directory unknown)")
+	}
+	if err := runEditor(path); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(path)
+	if err == nil {
+		err = checkConfig(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("%w; keeping the previous config", err)
+	}
+	return r.reloadConfig(cfg)
+}
+
+// checkConfig runs the checks config.Validate leaves to the packages that
+// understand the settings: recipe step selectors and the dial proxy URL.
+func checkConfig(cfg *config.Config) error {
+	if err := recipe.Validate(cfg); err != nil {
+		return err
+	}
+	if cfg.Defaults.DialProxy != "" {
+		if _, err := hssh.ParseDialProxy(cfg.Defaults.DialProxy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runEditor opens path in $EDITOR, or vi if it is unset, and waits for it to
+// exit. $EDITOR may include arguments, e.g. "code --wait".
+func runEditor(path string) error {
+	editor := strings.TrimSpace(os.Getenv("EDITOR"))
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s: %w", editor, err)
+	}
+	return nil
+}
+
+// reloadConfig switches the session to cfg: the current group, or the hosts
+// given on the command line, are resolved again, settings the caller did not
+// choose explicitly are derived from cfg's defaults as in New, and the pool
+// and executor are rebuilt as on :group.
+func (r *REPL) reloadConfig(cfg *config.Config) error {
+	// Command-line overrides are not in the file; carry them over.
+	if r.cfg != nil {
+		cfg.IncludeIgnored, cfg.Dedup, cfg.Warn = r.cfg.IncludeIgnored, r.cfg.Dedup, r.cfg.Warn
+	}
+
+	var hosts []config.Host
+	var err error
+	if r.groupName != "" {
+		hosts, err = config.ResolveHosts(cfg, r.groupName, nil)
+	} else {
+		hosts, err = config.ResolveHosts(cfg, "", r.allHosts)
+	}
+	if err != nil {
+		return fmt.Errorf("%w; keeping the previous config", err)
+	}
+
+	r.cfg = cfg
+	settings := r.explicit.withDefaults(cfg)
+	r.baseSSHConf, r.timeout, r.concurrency = settings.ssh, settings.timeout, settings.concurrency
+	r.warnRes = CompileWarnPatterns(cfg.Defaults.WarnPatterns)
+	if err := r.formatter.SetSummaryTemplate(cfg.Defaults.SummaryTemplate); err != nil {
+		fmt.Fprintf(os.Stderr, "%v; using the default summary\n", err)
+	}
+	r.useHosts(hosts)
+
+	fmt.Fprintf(os.Stdout, "config reloaded (%d %s)\n", len(r.allHosts), plural("host", len(r.allHosts)))
+	return nil
+}
@@ -0,0 +1,142 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/agent462/herd/internal/config"
+	hssh "github.com/agent462/herd/internal/ssh"
+	execui "github.com/agent462/herd/internal/ui/exec"
+)
+
+// fakeEditor sets $EDITOR to a script that replaces the edited file with
+// content.
+func fakeEditor(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "new.yaml")
+	if err := os.WriteFile(src, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "editor.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncp '"+src+"' \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", script)
+}
+
+func TestEditConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("groups:\n  web:\n    hosts: [web-01]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &REPL{
+		pool:       hssh.NewPool(hssh.ClientConfig{}, nil),
+		cfg:        cfg,
+		configPath: path,
+		groupName:  "web",
+		allHosts:   []string{"web-01"},
+		formatter:  execui.NewFormatter(false, false, false),
+	}
+
+	fakeEditor(t, "groups:\n  web:\n    hosts: [web-01, web-02]\n    tags: [prod]\n")
+	if err := r.editConfig(); err != nil {
+		t.Fatalf("editConfig: %v", err)
+	}
+	if !slices.Equal(r.allHosts, []string{"web-01", "web-02"}) {
+		t.Errorf("hosts after reload = %v, want web-01 and web-02", r.allHosts)
+	}
+	if r.cfg == cfg {
+		t.Error("config was not replaced")
+	}
+
+	kept := r.cfg
+	fakeEditor(t, "groups:\n  web:\n    hosts: [web-01]\n    timeout: -1s\n")
+	if err := r.editConfig(); err == nil {
+		t.Error("expected an error for an invalid config")
+	}
+	if r.cfg != kept || len(r.allHosts) != 2 {
+		t.Errorf("invalid config should keep the previous one; hosts = %v", r.allHosts)
+	}
+
+	fakeEditor(t, "groups:\n  db:\n    hosts: [db-01]\n")
+	if err := r.editConfig(); err == nil {
+		t.Error("expected an error when the current group is gone")
+	}
+	if r.cfg != kept {
+		t.Error("config without the current group should not be loaded")
+	}
+}
+
+func TestReloadConfigSettings(t *testing.T) {
+	r := New(Config{
+		Pool:        hssh.NewPool(hssh.ClientConfig{}, nil),
+		HerdConfig:  &config.Config{Dedup: true, Defaults: config.Defaults{Shell: "/bin/sh", Concurrency: 5}},
+		GroupName:   "web",
+		BaseSSHConf: hssh.ClientConfig{KnownHostsFile: "/tmp/explicit_known_hosts"},
+		Timeout:     time.Minute,
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.Groups["web"] = config.Group{Hosts: []config.HostEntry{{Host: "web-01"}}}
+	cfg.Defaults.KnownHostsFile = "/tmp/config_known_hosts"
+	sshConfig := filepath.Join(t.TempDir(), "ssh_config")
+	if err := os.WriteFile(sshConfig, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Defaults.SSHConfig = sshConfig
+	cfg.Defaults.DialProxy = "socks5://proxy:1080"
+	cfg.Defaults.ConnectTimeout = config.Duration{Duration: 3 * time.Second}
+	cfg.Defaults.Timeout = config.Duration{Duration: 10 * time.Second}
+	cfg.Defaults.Concurrency = 8
+	if err := r.reloadConfig(cfg); err != nil {
+		t.Fatalf("reloadConfig: %v", err)
+	}
+
+	want := hssh.ClientConfig{
+		KnownHostsFile: "/tmp/explicit_known_hosts",
+		SSHConfigPath:  sshConfig,
+		DialProxy:      "socks5://proxy:1080",
+		ConnectTimeout: 3 * time.Second,
+	}
+	if got := r.baseSSHConf; got.KnownHostsFile != want.KnownHostsFile || got.SSHConfigPath != want.SSHConfigPath ||
+		got.DialProxy != want.DialProxy || got.ConnectTimeout != want.ConnectTimeout || got.Shell != "" {
+		t.Errorf("baseSSHConf = %+v, want the explicit known_hosts and the rest from the new config", got)
+	}
+	if r.timeout != time.Minute {
+		t.Errorf("timeout = %v, want the explicit 1m kept", r.timeout)
+	}
+	if r.concurrency != 8 {
+		t.Errorf("concurrency = %d, want 8 from the new config", r.concurrency)
+	}
+	if !r.cfg.Dedup {
+		t.Error("--dedup was not carried over to the reloaded config")
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Groups["web"] = config.Group{Hosts: []config.HostEntry{{Host: "web-01"}}}
+
+	cfg.Defaults.DialProxy = "socks5://proxy:1080"
+	if err := checkConfig(cfg); err != nil {
+		t.Errorf("valid dial_proxy rejected: %v", err)
+	}
+	cfg.Defaults.DialProxy = "ftp://proxy:21"
+	if err := checkConfig(cfg); err == nil {
+		t.Error("expected an error for an unsupported dial_proxy scheme")
+	}
+
+	cfg.Defaults.DialProxy = ""
+	cfg.Recipes = map[string]config.Recipe{"r": {Steps: config.PlainSteps("@web uptime")}}
+	if err := checkConfig(cfg); err == nil {
+		t.Error("expected an error for a recipe selecting a group by name")
+	}
+}
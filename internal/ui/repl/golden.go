@@ -0,0 +1,57 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/agent462/herd/internal/grouper"
+	"github.com/agent462/herd/internal/pathutil"
+	"github.com/agent462/herd/internal/selector"
+)
+
+// golden runs a command on the hosts its selector picks, every host by
+// default, and compares each host's output with its golden file,
+// <dir>/<host>.txt, for compliance checks. It returns an error when any
+// host does not match, has no golden file, fails to connect or times out.
+func (r *REPL) golden(args string) error {
+	dir, cmd, _ := strings.Cut(strings.TrimSpace(args), " ")
+	sel, cmd := selector.ParseInput(strings.TrimSpace(cmd))
+	if dir == "" || strings.TrimSpace(cmd) == "" {
+		return errors.New("usage: :golden <dir> [selector] <command>")
+	}
+	dir = pathutil.ExpandHome(dir)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	hosts, err := r.resolveSelector(ctx, sel)
+	if err != nil {
+		return err
+	}
+	golden, err := grouper.LoadGolden(dir, hosts)
+	if err != nil {
+		return err
+	}
+
+	// Like :verify, the comparison always reaches the hosts rather than
+	// trusting the cache.
+	results := r.exec.ExecuteNoCache(ctx, hosts, cmd)
+
+	report := grouper.CompareGolden(results, golden)
+	fmt.Fprint(os.Stdout, r.formatter.FormatGolden(report))
+
+	grouped := r.group(cmd, results)
+	r.lastResults = results
+	r.lastGrouped = grouped
+	r.lastCommand = cmd
+	r.logRun(":golden "+args, grouped)
+
+	if !report.OK() {
+		failed := len(results) - len(report.Passed)
+		return fmt.Errorf("%d of %d %s did not match golden", failed, len(results), plural("host", len(results)))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultHistorySize is the number of lines kept in the history file when
+// Config.HistorySize is 0.
+const DefaultHistorySize = 1000
+
+// historyFile persists REPL input lines across sessions, one per line,
+// oldest first, keeping at most size lines.
+type historyFile struct {
+	path  string
+	size  int
+	lines int // lines currently in the file
+}
+
+// loadHistory reads the last size lines of the history file at path. A
+// missing file is an empty history.
+func loadHistory(path string, size int) (*historyFile, []string, error) {
+	h := &historyFile{path: path, size: size}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil, nil
+	}
+	if err != nil {
+		return h, nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return h, nil, err
+	}
+	h.lines = len(lines)
+	if len(lines) > size {
+		lines = lines[len(lines)-size:]
+	}
+	return h, lines, nil
+}
+
+// append adds line to the file, creating it and its directory if needed,
+// then trims the file to its last size lines once it grows past size.
+func (h *historyFile) append(line string) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	h.lines++
+	if h.lines <= h.size {
+		return nil
+	}
+	return h.trim()
+}
+
+// trim rewrites the file with only its last size lines.
+func (h *historyFile) trim() error {
+	_, lines, err := loadHistory(h.path, h.size)
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	data := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	h.lines = len(lines)
+	return nil
+}
@@ -0,0 +1,84 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agent462/herd/internal/grouper"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "herd", "history")
+
+	h, lines, err := loadHistory(path, 3)
+	if err != nil || len(lines) != 0 {
+		t.Fatalf("missing file: lines = %v, err = %v; want empty", lines, err)
+	}
+	for _, line := range []string{"uptime", "@web-* df -h", "hostname", "whoami"} {
+		if err := h.append(line); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "@web-* df -h\nhostname\nwhoami\n"; got != want {
+		t.Errorf("file = %q, want the last 3 lines %q", got, want)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	_, lines, err = loadHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, "|") != "hostname|whoami" {
+		t.Errorf("loadHistory(size 2) = %q, want the last 2 lines", lines)
+	}
+}
+
+func TestRestoreHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("uptime\n\n@failed systemctl restart app\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &REPL{}
+	r.restoreHistory(path, 0)
+	if len(r.history) != 2 || !r.history[0].Restored || r.history[1].Input != "@failed systemctl restart app" {
+		t.Fatalf("history = %+v, want 2 restored entries", r.history)
+	}
+
+	r.addHistory("hostname", &grouper.GroupedResults{})
+	input, ok, err := ExpandHistoryRef("!3", r.history)
+	if !ok || err != nil || input != "hostname" {
+		t.Errorf("!3 = %q, %v, %v; want the session's command", input, ok, err)
+	}
+	input, _, _ = ExpandHistoryRef("!1", r.history)
+	if input != "uptime" {
+		t.Errorf("!1 = %q, want the restored command", input)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(data), "restart app\nhostname\n") {
+		t.Errorf("file = %q, want the session's command appended", data)
+	}
+	if s := SummarizeHistory(r.history); len(s) != 1 || s[0].Command != "hostname" {
+		t.Errorf("summary = %+v, want only this session's command", s)
+	}
+}
+
+func TestFormatHistoryEntryRestored(t *testing.T) {
+	got := FormatHistoryEntry(4, HistoryEntry{Input: "uptime", Restored: true})
+	if got != " 4    uptime" {
+		t.Errorf("FormatHistoryEntry = %q, want just the index and input", got)
+	}
+}
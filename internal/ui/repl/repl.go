@@ -2,14 +2,20 @@ package repl
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/term"
@@ -20,8 +26,11 @@ import (
 	"github.com/agent462/herd/internal/parser"
 	"github.com/agent462/herd/internal/recipe"
 	"github.com/agent462/herd/internal/selector"
+	"github.com/agent462/herd/internal/sessionlog"
 	hssh "github.com/agent462/herd/internal/ssh"
+	"github.com/agent462/herd/internal/transfer"
 	execui "github.com/agent462/herd/internal/ui/exec"
+	"github.com/agent462/herd/internal/ui/progress"
 )
 
 // HistoryEntry records a single command execution in the REPL.
@@ -31,74 +40,215 @@ type HistoryEntry struct {
 	OKCount   int
 	DiffCount int
 	FailCount int
+	Restored  bool // loaded from the history file; has no result counts
 }
 
 // Config holds the settings for creating a REPL session.
 type Config struct {
 	Pool         *hssh.Pool
 	AllHosts     []string
-	HostTags     map[string][]string // host name -> tags from config
+	HostTags     map[string][]string      // host name -> tags from config
+	HostTimeouts map[string]time.Duration // host name -> group timeout, see config.HostTimeouts
+	ConfigPath   string                   // config file opened by :edit; empty uses config.DefaultConfigPath
 	GroupName    string
 	HerdConfig   *config.Config
 	BaseSSHConf  hssh.ClientConfig
 	Timeout      time.Duration
 	Concurrency  int
-	Color        bool
-	SudoPassword string // initial sudo password set at startup
+	Output       string        // "grouped", "json", "jsonl" or "flat"; empty uses HerdConfig.Defaults.Output
+	Color        string        // "auto", "always", or "never"; empty uses HerdConfig.Defaults.Color
+	SudoPassword string        // initial sudo password set at startup
+	CacheTTL     time.Duration // cache identical host+command results for this long; 0 disables
+	LogFile      string        // append a record of every command run to this file; empty disables
+	LogOutput    bool          // include full output in LogFile records, not just summary counts
+	RunIDs       bool          // export a fresh HERD_RUN_ID correlation ID with every command
+	HistoryFile  string        // restore history from and save each command to this file; empty disables
+	HistorySize  int           // lines kept in HistoryFile; 0 uses DefaultHistorySize
+	// TimeoutMargin enables adaptive per-host timeouts: each host's recent
+	// latency plus this margin, with Timeout for hosts without history.
+	TimeoutMargin time.Duration
 }
 
 // REPL is an interactive session that executes commands across SSH hosts.
 type REPL struct {
-	pool        *hssh.Pool
-	exec        *executor.Executor
-	formatter   *execui.Formatter
-	allHosts    []string
-	hostTags    map[string][]string // host name -> tags
-	groupName   string
-	cfg         *config.Config
-	baseSSHConf hssh.ClientConfig
-	timeout     time.Duration
-	concurrency int
-	color       bool
+	pool         *hssh.Pool
+	exec         *executor.Executor
+	formatter    *execui.Formatter
+	allHosts     []string
+	hostTags     map[string][]string      // host name -> tags
+	hostTimeouts map[string]time.Duration // host name -> group timeout
+	configPath   string                   // config file for :edit
+	groupName    string
+	cfg          *config.Config
+	explicit     sessionSettings // caller's settings, before config defaults
+	baseSSHConf  hssh.ClientConfig
+	timeout      time.Duration
+	concurrency  int
+	cacheTTL     time.Duration
+	runIDs       bool
+	margin       time.Duration // adaptive timeout margin; 0 disables
+	color        bool
+	jsonOutput   bool               // "json" or "jsonl": print only the JSON
+	jsonLines    bool               // one compact object per host instead of an array
+	flatOutput   bool               // one section per host, no grouping; toggled by :flat
+	warnRes      []*regexp.Regexp   // commands matching these need confirmation
+	sessionLog   *sessionlog.Logger // nil unless Config.LogFile is set
+	histFile     *historyFile       // nil unless Config.HistoryFile is set
 
 	// Mutable state from last command.
 	lastResults  []*executor.HostResult
 	lastGrouped  *grouper.GroupedResults
+	lastCommand  string // command behind lastResults, without its selector
 	history      []HistoryEntry
 	sudoPassword string
+	sudoUser     string // sudo -u target; empty means root
 }
 
 // New creates a REPL with the given configuration.
 func New(c Config) *REPL {
+	output, colorMode := c.Output, c.Color
+	if c.HerdConfig != nil {
+		if output == "" {
+			output = c.HerdConfig.Defaults.Output
+		}
+		if colorMode == "" {
+			colorMode = c.HerdConfig.Defaults.Color
+		}
+	}
+	color := execui.ColorEnabled(colorMode, os.Stdout)
+
+	warnPatterns := config.DefaultWarnPatterns()
+	if c.HerdConfig != nil {
+		warnPatterns = c.HerdConfig.Defaults.WarnPatterns
+	}
+
+	explicit := sessionSettings{ssh: c.BaseSSHConf, timeout: c.Timeout, concurrency: c.Concurrency}
+	settings := explicit.withDefaults(c.HerdConfig)
+
 	r := &REPL{
 		pool:         c.Pool,
 		allHosts:     c.AllHosts,
 		hostTags:     c.HostTags,
+		hostTimeouts: c.HostTimeouts,
+		configPath:   c.ConfigPath,
 		groupName:    c.GroupName,
 		cfg:          c.HerdConfig,
-		baseSSHConf:  c.BaseSSHConf,
-		timeout:      c.Timeout,
-		concurrency:  c.Concurrency,
-		color:        c.Color,
+		explicit:     explicit,
+		baseSSHConf:  settings.ssh,
+		timeout:      settings.timeout,
+		concurrency:  settings.concurrency,
+		cacheTTL:     c.CacheTTL,
+		runIDs:       c.RunIDs,
+		margin:       c.TimeoutMargin,
+		color:        color,
+		jsonOutput:   output == "json" || output == "jsonl",
+		jsonLines:    output == "jsonl",
+		flatOutput:   output == "flat",
 		sudoPassword: c.SudoPassword,
-		formatter:    execui.NewFormatter(false, false, c.Color),
+		formatter:    execui.NewFormatter(output == "json", false, color),
+		warnRes:      CompileWarnPatterns(warnPatterns),
+	}
+	if c.LogFile != "" {
+		r.sessionLog = sessionlog.New(c.LogFile, c.LogOutput)
 	}
+	if c.HistoryFile != "" {
+		r.restoreHistory(c.HistoryFile, c.HistorySize)
+	}
+	if c.HerdConfig != nil {
+		if err := r.formatter.SetSummaryTemplate(c.HerdConfig.Defaults.SummaryTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, "%v; using the default summary\n", err)
+		}
+	}
+	r.applyFactProbes()
 	r.rebuildExecutor()
 	return r
 }
 
+// sessionSettings are the SSH, timeout and concurrency settings of a
+// session. Those the caller leaves zero come from the herd config's
+// defaults, and are derived again when :edit reloads the config.
+type sessionSettings struct {
+	ssh         hssh.ClientConfig
+	timeout     time.Duration
+	concurrency int
+}
+
+// withDefaults returns s with its zero settings taken from cfg's defaults:
+// pools verify host keys against the configured known_hosts file, read the
+// configured ssh_config file, dial through the configured proxy, read the
+// configured password file, and apply the configured connect timeout and
+// shell. cfg may be nil.
+func (s sessionSettings) withDefaults(cfg *config.Config) sessionSettings {
+	if cfg == nil {
+		return s
+	}
+	d := cfg.Defaults
+	if s.ssh.KnownHostsFile == "" {
+		s.ssh.KnownHostsFile = d.KnownHostsFile
+	}
+	if s.ssh.SSHConfigPath == "" {
+		s.ssh.SSHConfigPath = d.SSHConfig
+	}
+	if s.ssh.DialProxy == "" {
+		s.ssh.DialProxy = d.DialProxy
+	}
+	if s.ssh.PasswordFile == "" {
+		s.ssh.PasswordFile = d.PasswordFile
+	}
+	if s.ssh.ConnectTimeout == 0 {
+		s.ssh.ConnectTimeout = d.ConnectTimeout.Duration
+	}
+	if s.ssh.Shell == "" {
+		s.ssh.Shell = d.Shell
+	}
+	if s.timeout == 0 {
+		s.timeout = d.Timeout.Timeout()
+	}
+	if s.concurrency == 0 {
+		s.concurrency = d.Concurrency
+	}
+	return s
+}
+
 func (r *REPL) rebuildExecutor() {
-	r.exec = executor.New(r.pool,
-		executor.WithConcurrency(r.concurrency),
+	opts := append(r.executorOptions(r.concurrency), executor.WithResultCache(r.cacheTTL))
+	r.exec = executor.New(r.pool, opts...)
+}
+
+// executorOptions returns the session's executor settings with the given
+// concurrency, excluding the result cache.
+func (r *REPL) executorOptions(concurrency int) []executor.Option {
+	opts := []executor.Option{
+		executor.WithConcurrency(concurrency),
 		executor.WithTimeout(r.timeout),
-	)
+	}
+	if r.margin > 0 {
+		opts = append(opts, executor.WithAdaptiveTimeout(r.timeout, r.margin))
+	}
+	if len(r.hostTimeouts) > 0 {
+		opts = append(opts, executor.WithHostTimeouts(r.hostTimeouts))
+	}
+	if r.runIDs {
+		opts = append(opts, executor.WithRunID(""))
+	}
+	if rewrite := r.cfg.CommandRewriter(); rewrite != nil {
+		opts = append(opts, executor.WithRewrite(rewrite))
+	}
+	return opts
 }
 
-// Close closes the REPL's connection pool and any associated resources.
+// closeGracePeriod bounds how long Close waits for in-flight commands and
+// transfers before tearing down connections.
+const closeGracePeriod = 5 * time.Second
+
+// Close closes the REPL's connection pool and any associated resources,
+// giving in-flight operations up to closeGracePeriod to finish.
 func (r *REPL) Close() error {
 	hssh.CloseAgent()
 	if r.pool != nil {
-		return r.pool.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), closeGracePeriod)
+		defer cancel()
+		return r.pool.CloseGraceful(ctx)
 	}
 	return nil
 }
@@ -145,6 +295,41 @@ func (r *REPL) Run(ctx context.Context) error {
 			continue
 		}
 
+		// :retry reruns the last input as if typed again, so its selector
+		// is resolved against the current results.
+		if last, ok := ParseRetry(line, r.history); ok {
+			if last == "" {
+				fmt.Fprintln(os.Stderr, "no history to retry")
+				continue
+			}
+			fmt.Fprintln(os.Stdout, last)
+			line = last
+		}
+
+		// !N reruns history entry N, from this session or a restored one, and
+		// !! reruns the last entry.
+		if input, ok, err := ExpandHistoryRef(line, r.history); ok {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			fmt.Fprintln(os.Stdout, input)
+			line = input
+		}
+
+		// :nocache runs the rest of the line without consulting the result cache.
+		noCache := false
+		if rest, ok := strings.CutPrefix(line, ":nocache "); ok {
+			line, noCache = strings.TrimSpace(rest), true
+		}
+
+		// :multi splits the rest of the line on ";" into commands run one
+		// after another; without it the line goes to the remote shell whole.
+		multi := false
+		if rest, ok := strings.CutPrefix(line, ":multi "); ok {
+			line, multi = strings.TrimSpace(rest), true
+		}
+
 		// Colon-commands.
 		if strings.HasPrefix(line, ":") {
 			if quit := r.handleCommand(line); quit {
@@ -155,40 +340,139 @@ func (r *REPL) Run(ctx context.Context) error {
 
 		// Parse selector and command.
 		sel, cmd := selector.ParseInput(line)
-		if cmd == "" {
+		concurrency, cmd, err := selector.ParseConcurrency(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
+		}
+		// Under :multi, a line of several commands separated by ";" runs
+		// them in turn on the same hosts.
+		cmds := []string{cmd}
+		if multi {
+			cmds = SplitCommands(cmd)
+		} else if strings.TrimSpace(cmd) == "" {
+			cmds = nil
+		}
+		if len(cmds) == 0 {
 			fmt.Fprintln(os.Stderr, "no command specified")
 			continue
 		}
 
-		state := &selector.State{
-			AllHosts: r.allHosts,
-			Grouped:  r.lastGrouped,
-			HostTags: r.hostTags,
-		}
-		hosts, err := selector.Resolve(sel, state)
+		hosts, err := r.resolveSelector(ctx, sel)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "selector error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			continue
 		}
-		if len(hosts) == 0 {
-			fmt.Fprintln(os.Stderr, "no hosts match selector")
-			continue
+
+		// Destructive-looking commands need confirmation before fanning out.
+		if pat, destructive := matchAnyWarnPattern(cmds, r.warnRes); destructive && len(hosts) > 1 {
+			q := fmt.Sprintf("command matches warn pattern %s; run on %d hosts? [y/N] ", pat, len(hosts))
+			if !confirm(reader, q) {
+				fmt.Fprintln(os.Stderr, "aborted")
+				continue
+			}
 		}
 
-		// Execute with Ctrl-C cancellation via signal.NotifyContext.
-		// Each command gets its own context so Ctrl-C cancels only the
-		// current command, not the entire REPL session.
-		execCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
-		results := r.exec.Execute(execCtx, hosts, cmd)
-		stop()
+		var grouped *grouper.GroupedResults
+		for i, c := range cmds {
+			if len(cmds) > 1 {
+				fmt.Fprintf(os.Stdout, "\n=== Command %d/%d: %s ===\n", i+1, len(cmds), c)
+			}
+			var cancelled bool
+			grouped, cancelled = r.runCommand(ctx, hosts, c, concurrency, noCache)
+			if len(cmds) > 1 {
+				r.logRun(strings.TrimSpace(sel+" "+c), grouped)
+			}
+			if cancelled {
+				break
+			}
+		}
+		input := line
+		if multi {
+			input = ":multi " + line
+		}
+		r.addHistory(input, grouped)
+		if len(cmds) == 1 {
+			r.logRun(input, grouped)
+		}
+	}
+}
+
+// runCommand runs cmd on hosts, prints its results, and makes them the
+// last results for selectors. It reports whether the command was cancelled
+// with Ctrl-C.
+func (r *REPL) runCommand(ctx context.Context, hosts []string, cmd string, concurrency int, noCache bool) (*grouper.GroupedResults, bool) {
+	_, destructive := MatchWarnPattern(cmd, r.warnRes)
+
+	// Execute with Ctrl-C cancellation via signal.NotifyContext.
+	// Each command gets its own context so Ctrl-C cancels only the
+	// current command, not the entire REPL session. Destructive-looking
+	// commands always run rather than being answered from the cache.
+	// A %N override runs on a one-off executor without the result cache.
+	execCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	var results []*executor.HostResult
+	if concurrency > 0 {
+		results = executor.New(r.pool, r.executorOptions(concurrency)...).Execute(execCtx, hosts, cmd)
+	} else if noCache || destructive {
+		results = r.exec.ExecuteNoCache(execCtx, hosts, cmd)
+	} else {
+		results = r.exec.Execute(execCtx, hosts, cmd)
+	}
+	cancelled := execCtx.Err() != nil && ctx.Err() == nil
+	stop()
 
-		grouped := grouper.Group(results)
-		fmt.Fprint(os.Stdout, r.formatter.Format(grouped))
+	grouped := r.group(cmd, results)
+	r.printResults(results, grouped)
 
-		r.lastResults = results
-		r.lastGrouped = grouped
-		r.addHistory(line, grouped)
+	r.lastResults = results
+	r.lastGrouped = grouped
+	r.lastCommand = cmd
+	return grouped, cancelled
+}
+
+// logRun appends a record of input and its results to the session log, if
+// one is configured. Failures are reported but do not interrupt the session.
+func (r *REPL) logRun(input string, grouped *grouper.GroupedResults) {
+	if r.sessionLog == nil {
+		return
 	}
+	if err := r.sessionLog.Record(input, grouped); err != nil {
+		fmt.Fprintf(os.Stderr, "session log: %v\n", err)
+	}
+}
+
+// group groups the results of cmd with the configured options, or one group
+// per host in flat mode.
+func (r *REPL) group(cmd string, results []*executor.HostResult) *grouper.GroupedResults {
+	opts := recipe.GroupOptions(r.cfg, cmd)
+	if r.flatOutput {
+		return opts.Flat(results)
+	}
+	return opts.Group(results)
+}
+
+// printResults writes results to stdout in the session's output mode.
+func (r *REPL) printResults(results []*executor.HostResult, grouped *grouper.GroupedResults) {
+	if r.jsonLines {
+		if err := r.formatter.WriteJSONL(os.Stdout, results); err != nil {
+			fmt.Fprintf(os.Stderr, "format json: %v\n", err)
+		}
+		return
+	}
+	if r.jsonOutput {
+		data, err := r.formatter.FormatJSON(results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "format json: %v\n", err)
+			return
+		}
+		fmt.Fprintln(os.Stdout, string(data))
+		return
+	}
+	if r.flatOutput {
+		fmt.Fprint(os.Stdout, r.formatter.FormatFlat(grouped))
+		return
+	}
+	fmt.Fprint(os.Stdout, r.formatter.Format(grouped))
 }
 
 func (r *REPL) prompt() string {
@@ -207,7 +491,7 @@ func (r *REPL) addHistory(input string, grouped *grouper.GroupedResults) {
 
 	for _, g := range grouped.Groups {
 		entry.HostCount += len(g.Hosts)
-		if g.ExitCode != 0 {
+		if g.Failed() {
 			entry.FailCount += len(g.Hosts)
 		} else if g.IsNorm {
 			entry.OKCount += len(g.Hosts)
@@ -219,6 +503,27 @@ func (r *REPL) addHistory(input string, grouped *grouper.GroupedResults) {
 	entry.HostCount += len(grouped.Failed) + len(grouped.TimedOut)
 
 	r.history = append(r.history, entry)
+	if r.histFile != nil {
+		if err := r.histFile.append(input); err != nil {
+			fmt.Fprintf(os.Stderr, "history file: %v\n", err)
+		}
+	}
+}
+
+// restoreHistory loads earlier sessions' commands from path into the
+// history and saves this session's commands there.
+func (r *REPL) restoreHistory(path string, size int) {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	hf, lines, err := loadHistory(path, size)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history file: %v\n", err)
+	}
+	for _, line := range lines {
+		r.history = append(r.history, HistoryEntry{Input: line, Restored: true})
+	}
+	r.histFile = hf
 }
 
 // handleCommand processes a colon-prefixed REPL command.
@@ -235,9 +540,25 @@ func (r *REPL) handleCommand(line string) bool {
 	case ":history", ":h":
 		r.showHistory()
 
+	case ":summary":
+		r.showSummary()
+
 	case ":hosts":
 		r.showHosts()
 
+	case ":connect":
+		r.connectHosts()
+
+	case ":explain":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "usage: :explain <host>")
+			return false
+		}
+		_, trace := config.Explain(r.cfg, args[0])
+		for _, line := range trace {
+			fmt.Fprintf(os.Stdout, "  %s\n", line)
+		}
+
 	case ":group":
 		if len(args) == 0 {
 			fmt.Fprintln(os.Stderr, "usage: :group <name>")
@@ -249,7 +570,7 @@ func (r *REPL) handleCommand(line string) bool {
 
 	case ":timeout":
 		if len(args) == 0 {
-			fmt.Fprintf(os.Stdout, "current timeout: %s\n", r.timeout)
+			fmt.Fprintf(os.Stdout, "current timeout: %s\n", formatTimeout(r.timeout))
 			return false
 		}
 		d, err := time.ParseDuration(args[0])
@@ -257,9 +578,17 @@ func (r *REPL) handleCommand(line string) bool {
 			fmt.Fprintf(os.Stderr, "invalid duration: %v\n", err)
 			return false
 		}
+		if d == 0 {
+			d = executor.NoTimeout
+		}
 		r.timeout = d
 		r.rebuildExecutor()
-		fmt.Fprintf(os.Stdout, "timeout set to %s\n", d)
+		fmt.Fprintf(os.Stdout, "timeout set to %s\n", formatTimeout(d))
+
+	case ":edit":
+		if err := r.editConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		}
 
 	case ":diff":
 		r.showDiff()
@@ -267,12 +596,32 @@ func (r *REPL) handleCommand(line string) bool {
 	case ":last":
 		r.showLast()
 
+	case ":filter":
+		pattern := strings.TrimSpace(strings.TrimPrefix(line, cmd))
+		if pattern == "" {
+			fmt.Fprintln(os.Stderr, "usage: :filter /regex/")
+			return false
+		}
+		if err := r.filterLast(pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "filter: %v\n", err)
+		}
+
+	case ":retry":
+		// A bare :retry is handled in Run; :retry failed lands here.
+		if len(args) != 1 || args[0] != "failed" {
+			fmt.Fprintln(os.Stderr, "usage: :retry [failed]")
+			return false
+		}
+		if err := r.retryFailed(); err != nil {
+			fmt.Fprintf(os.Stderr, "retry: %v\n", err)
+		}
+
 	case ":export":
 		if len(args) == 0 {
 			fmt.Fprintln(os.Stderr, "usage: :export <file>")
 			return false
 		}
-		if err := r.exportJSON(args[0]); err != nil {
+		if err := r.export(args[0]); err != nil {
 			fmt.Fprintf(os.Stderr, "export: %v\n", err)
 		} else {
 			fmt.Fprintf(os.Stdout, "exported to %s\n", args[0])
@@ -281,27 +630,94 @@ func (r *REPL) handleCommand(line string) bool {
 	case ":recipe":
 		if len(args) == 0 {
 			r.listRecipes()
+		} else if len(args) == 2 && args[1] == "--dry-run" {
+			r.dryRunRecipe(args[0])
+		} else if len(args) == 2 && args[1] == "--timeline" {
+			r.runRecipe(args[0], true)
+		} else if len(args) > 1 {
+			r.runRecipeAcrossGroups(args[0], strings.Split(args[1], ","))
 		} else {
-			r.runRecipe(args[0])
+			r.runRecipe(args[0], false)
 		}
 
 	case ":parse":
 		if len(args) == 0 {
-			fmt.Fprintln(os.Stderr, "usage: :parse <name> (built-in: disk, free, uptime)")
+			fmt.Fprintln(os.Stderr, "usage: :parse <name> [sort-field] (built-in: disk, free, uptime, net, security-updates)")
 			return false
 		}
-		r.parseLastResults(args[0])
+		sortField := ""
+		if len(args) > 1 {
+			sortField = args[1]
+		}
+		r.parseLastResults(args[0], sortField)
+
+	case ":check":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: :check <parser> <field><op><limit>... (e.g. :check disk use_pct>90)")
+			return false
+		}
+		r.checkThresholds(args[0], args[1:])
+
+	case ":agg":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: :agg <parser> <field> (e.g. :agg free used)")
+			return false
+		}
+		r.aggregateField(args[0], args[1])
+
+	case ":verify":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: :verify <expected> [selector] <command> (expected is a value, \"quoted value\" or /regex/)")
+			return false
+		}
+		if err := r.verify(strings.TrimPrefix(line, cmd)); err != nil {
+			fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+		}
+
+	case ":golden":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "usage: :golden <dir> [selector] <command>")
+			return false
+		}
+		if err := r.golden(strings.TrimPrefix(line, cmd)); err != nil {
+			fmt.Fprintf(os.Stderr, "golden: %v\n", err)
+		}
 
 	case ":tags":
 		r.showTags()
 
+	case ":os":
+		r.showOS()
+
+	case ":facts":
+		r.showFacts(len(args) > 0 && args[0] == "refresh")
+
+	case ":nocache":
+		fmt.Fprintln(os.Stderr, "usage: :nocache [@selector] <command>")
+
+	case ":multi":
+		fmt.Fprintln(os.Stderr, "usage: :multi [@selector] <command> ; <command>...")
+
+	case ":flat":
+		r.flatOutput = !r.flatOutput
+		if r.flatOutput {
+			fmt.Fprintln(os.Stdout, "flat output enabled")
+		} else {
+			fmt.Fprintln(os.Stdout, "flat output disabled")
+		}
+
 	case ":sudo":
-		if r.sudoPassword != "" {
+		if r.sudoPassword != "" && len(args) == 0 {
 			// Toggle off: disable sudo mode.
 			r.sudoPassword = ""
+			r.sudoUser = ""
 			r.pool.SetSudo(false, "")
+			r.pool.SetSudoUser("")
+			r.exec.ClearCache()
 			fmt.Fprintln(os.Stdout, "sudo mode disabled")
-		} else {
+			return false
+		}
+		if r.sudoPassword == "" {
 			// Toggle on: prompt for password.
 			fmt.Fprint(os.Stderr, "BECOME password: ")
 			pw, err := term.ReadPassword(int(os.Stdin.Fd()))
@@ -312,11 +728,22 @@ func (r *REPL) handleCommand(line string) bool {
 			}
 			r.sudoPassword = string(pw)
 			r.pool.SetSudo(true, r.sudoPassword)
+		}
+		// :sudo <user> runs commands as that user (sudo -u).
+		r.sudoUser = ""
+		if len(args) > 0 {
+			r.sudoUser = args[0]
+		}
+		r.pool.SetSudoUser(r.sudoUser)
+		r.exec.ClearCache()
+		if r.sudoUser != "" {
+			fmt.Fprintf(os.Stdout, "sudo mode enabled (as %s)\n", r.sudoUser)
+		} else {
 			fmt.Fprintln(os.Stdout, "sudo mode enabled")
 		}
 
 	default:
-		fmt.Fprintf(os.Stderr, "unknown command %q (try :quit, :history, :hosts, :group, :tags, :timeout, :diff, :last, :export, :sudo, :recipe, :parse)\n", cmd)
+		fmt.Fprintf(os.Stderr, "unknown command %q (try :quit, :history, :hosts, :connect, :explain, :group, :edit, :tags, :os, :facts, :timeout, :diff, :last, :filter, :export, :sudo, :recipe, :parse, :check, :agg, :verify, :golden, :retry [failed], :summary, :nocache, :multi, :flat)\n", cmd)
 	}
 
 	return false
@@ -329,29 +756,63 @@ func (r *REPL) showHistory() {
 	}
 	for i, e := range r.history {
 		input := e.Input
+		if e.Restored {
+			fmt.Fprintf(os.Stdout, " %-4d %s\n", i+1, input)
+			continue
+		}
 		if len(input) > 40 {
 			input = input[:37] + "..."
 		}
 		fmt.Fprintf(os.Stdout, " %-4d %-42s (%d %s",
 			i+1, input, e.HostCount, plural("host", e.HostCount))
-
-		var parts []string
-		if e.OKCount > 0 {
-			parts = append(parts, fmt.Sprintf("%d ok", e.OKCount))
-		}
-		if e.DiffCount > 0 {
-			parts = append(parts, fmt.Sprintf("%d differs", e.DiffCount))
-		}
-		if e.FailCount > 0 {
-			parts = append(parts, fmt.Sprintf("%d failed", e.FailCount))
-		}
-		if len(parts) > 0 {
+		if parts := outcomeParts(e.OKCount, e.DiffCount, e.FailCount); len(parts) > 0 {
 			fmt.Fprintf(os.Stdout, ", %s", strings.Join(parts, ", "))
 		}
 		fmt.Fprintln(os.Stdout, ")")
 	}
 }
 
+// showSummary prints the session history rolled up by command, then how
+// many hosts of the last run reused a connection (warm) or dialed (cold).
+func (r *REPL) showSummary() {
+	summary := SummarizeHistory(r.history)
+	if len(summary) == 0 {
+		fmt.Fprintln(os.Stdout, "no history")
+		return
+	}
+	for _, c := range summary {
+		command := c.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+		fmt.Fprintf(os.Stdout, " %-42s %d %s, %d %s",
+			command, c.Runs, plural("run", c.Runs), c.HostCount, plural("host", c.HostCount))
+		if parts := outcomeParts(c.OKCount, c.DiffCount, c.FailCount); len(parts) > 0 {
+			fmt.Fprintf(os.Stdout, " (%s)", strings.Join(parts, ", "))
+		}
+		fmt.Fprintln(os.Stdout)
+	}
+	if r.lastResults != nil {
+		warm, cold := executor.ConnectionCounts(r.lastResults)
+		fmt.Fprintf(os.Stdout, "last run: %d warm, %d cold\n", warm, cold)
+	}
+}
+
+// outcomeParts describes non-zero host outcome counts, e.g. "3 ok".
+func outcomeParts(ok, diff, fail int) []string {
+	var parts []string
+	if ok > 0 {
+		parts = append(parts, fmt.Sprintf("%d ok", ok))
+	}
+	if diff > 0 {
+		parts = append(parts, fmt.Sprintf("%d differs", diff))
+	}
+	if fail > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", fail))
+	}
+	return parts
+}
+
 func (r *REPL) showHosts() {
 	for _, h := range r.allHosts {
 		status := "not connected"
@@ -362,6 +823,151 @@ func (r *REPL) showHosts() {
 	}
 }
 
+// connectHosts opens pooled connections to every host ahead of the first
+// command, showing progress as hosts connect, and reports any that fail.
+func (r *REPL) connectHosts() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	bar := progress.New(os.Stderr, "connecting")
+	bar.Start(len(r.allHosts))
+	errs := make([]error, len(r.allHosts))
+	limit := r.concurrency
+	if limit <= 0 {
+		limit = 20
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, h := range r.allHosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			hostCtx, cancel := ctx, context.CancelFunc(func() {})
+			if r.timeout > 0 {
+				hostCtx, cancel = context.WithTimeout(ctx, r.timeout)
+			}
+			defer cancel()
+			_, errs[i] = r.pool.GetClient(hostCtx, h)
+			bar.Increment(h)
+		}()
+	}
+	wg.Wait()
+	bar.Finish()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stdout, "  %-30s %v\n", r.allHosts[i], err)
+		}
+	}
+	fmt.Fprintf(os.Stdout, "%d connected, %d failed\n", len(r.allHosts)-failed, failed)
+}
+
+// hostFacts returns the cached facts of each host, or nil if none are known.
+func (r *REPL) hostFacts() map[string]map[string]string {
+	if r.pool == nil {
+		return nil
+	}
+	var facts map[string]map[string]string
+	for _, h := range r.allHosts {
+		if f := r.pool.Facts(h); f != nil {
+			if facts == nil {
+				facts = make(map[string]map[string]string)
+			}
+			facts[h] = f
+		}
+	}
+	return facts
+}
+
+// factOptions returns the executor settings for fact probes: the session's
+// concurrency and timeout.
+func (r *REPL) factOptions() []executor.Option {
+	return []executor.Option{
+		executor.WithConcurrency(r.concurrency),
+		executor.WithTimeout(r.timeout),
+	}
+}
+
+// applyFactProbes configures the pool's fact probes from the config file.
+func (r *REPL) applyFactProbes() {
+	if r.pool == nil || r.cfg == nil {
+		return
+	}
+	r.pool.SetFactProbes(hssh.MergeFactProbes(r.cfg.Defaults.Facts), r.cfg.Defaults.FactsTTL.Duration)
+}
+
+// showOS probes hosts for their facts (cached per host) and prints how many
+// run each OS.
+func (r *REPL) showOS() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	facts := r.pool.Probe(ctx, r.allHosts, r.factOptions()...)
+
+	counts := make(map[string]int)
+	for _, h := range r.allHosts {
+		osName := facts[h].Values["os"]
+		if osName == "" {
+			osName = "unknown"
+		}
+		counts[osName]++
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stdout, "  %-20s %d %s\n", name, counts[name], plural("host", counts[name]))
+	}
+}
+
+// showFacts prints a table of every host's facts, probing hosts whose facts
+// are missing or expired. With refresh, all hosts are probed again.
+func (r *REPL) showFacts(refresh bool) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if refresh {
+		r.pool.RefreshFacts(ctx, r.allHosts, r.factOptions()...)
+	}
+	facts := hssh.FactValues(r.pool.Probe(ctx, r.allHosts, r.factOptions()...))
+
+	nameSet := make(map[string]bool)
+	for _, f := range facts {
+		for name := range f {
+			nameSet[name] = true
+		}
+	}
+	if len(nameSet) == 0 {
+		fmt.Fprintln(os.Stdout, "no facts collected")
+		return
+	}
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parsed := make([]*parser.HostParsed, len(r.allHosts))
+	for i, h := range r.allHosts {
+		hp := &parser.HostParsed{Host: h}
+		for _, name := range names {
+			value, ok := facts[h][name]
+			if !ok {
+				value = "-"
+			}
+			hp.Fields = append(hp.Fields, parser.FieldValue{Field: name, Value: value})
+		}
+		parsed[i] = hp
+	}
+	fmt.Fprint(os.Stdout, parser.FormatTableWidth(parsed, r.color, terminalWidth()))
+}
+
 func (r *REPL) showTags() {
 	if len(r.hostTags) == 0 {
 		fmt.Fprintln(os.Stdout, "no tags defined")
@@ -398,29 +1004,56 @@ func (r *REPL) switchGroup(name string) error {
 		return err
 	}
 
+	r.useHosts(hosts)
+	r.groupName = name
+
+	fmt.Fprintf(os.Stdout, "switched to group %q (%d %s)\n",
+		name, len(r.allHosts), plural("host", len(r.allHosts)))
+	return nil
+}
+
+// resolveSelector resolves sel against the session's hosts, tags, facts and
+// last results, probing facts first if sel needs them. It reports an error
+// when sel is invalid or matches no host.
+func (r *REPL) resolveSelector(ctx context.Context, sel string) ([]string, error) {
+	// @os: and @fact: selectors need host facts; the pool caches them.
+	if (strings.Contains(sel, "@os:") || strings.Contains(sel, "@fact:")) && r.pool != nil {
+		r.pool.Probe(ctx, r.allHosts, r.factOptions()...)
+	}
+
+	state := &selector.State{
+		AllHosts:  r.allHosts,
+		Grouped:   r.lastGrouped,
+		HostTags:  r.hostTags,
+		HostFacts: r.hostFacts(),
+		Results:   r.lastResults,
+	}
+	hosts, err := selector.Resolve(sel, state)
+	if err != nil {
+		return nil, fmt.Errorf("selector error: %w", err)
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no hosts match selector")
+	}
+	return hosts, nil
+}
+
+// useHosts replaces the session's hosts with hosts, rebuilding the pool and
+// executor and forgetting the previous results.
+func (r *REPL) useHosts(hosts []config.Host) {
 	r.pool.Close()
 
-	hostConfs := make(map[string]hssh.HostConfig, len(hosts))
 	hostNames := make([]string, len(hosts))
 	for i, h := range hosts {
 		hostNames[i] = h.Name
-		hostConfs[h.Name] = hssh.HostConfig{
-			Hostname:     h.Hostname,
-			User:         h.User,
-			Port:         h.Port,
-			IdentityFile: h.IdentityFile,
-			ProxyJump:    h.ProxyJump,
-		}
 	}
 
-	r.pool = hssh.NewPool(r.baseSSHConf, hostConfs)
-	if r.sudoPassword != "" {
-		r.pool.SetSudo(true, r.sudoPassword)
-	}
+	r.pool = r.newPool(hosts)
+	r.applyFactProbes()
 	r.allHosts = hostNames
-	r.groupName = name
 	r.lastResults = nil
 	r.lastGrouped = nil
+	r.lastCommand = ""
 
 	// Rebuild tag map from resolved hosts.
 	hostTags := make(map[string][]string, len(hosts))
@@ -428,12 +1061,52 @@ func (r *REPL) switchGroup(name string) error {
 		hostTags[h.Name] = h.Tags
 	}
 	r.hostTags = hostTags
+	r.hostTimeouts = config.HostTimeouts(hosts)
 
 	r.rebuildExecutor()
+}
 
-	fmt.Fprintf(os.Stdout, "switched to group %q (%d %s)\n",
-		name, len(hostNames), plural("host", len(hostNames)))
-	return nil
+// jumpHosts converts a host's proxy_jump hops for the SSH client, returning
+// nil when there are none.
+func jumpHosts(hops []config.JumpHop) []hssh.JumpHost {
+	if len(hops) == 0 {
+		return nil
+	}
+	hosts := make([]hssh.JumpHost, len(hops))
+	for i, hop := range hops {
+		hosts[i] = hssh.JumpHost(hop)
+	}
+	return hosts
+}
+
+// newPool creates a connection pool for hosts using the session's SSH
+// settings and sudo password.
+func (r *REPL) newPool(hosts []config.Host) *hssh.Pool {
+	hostConfs := make(map[string]hssh.HostConfig, len(hosts))
+	for _, h := range hosts {
+		hostConfs[h.Name] = hssh.HostConfig{
+			Hostname:       h.Hostname,
+			User:           h.User,
+			Port:           h.Port,
+			IdentityFile:   h.IdentityFile,
+			ProxyJump:      h.ProxyJump,
+			JumpHosts:      jumpHosts(h.JumpHosts),
+			ConnectTimeout: h.ConnectTimeout,
+			Shell:          h.Shell,
+		}
+	}
+	var opts []hssh.PoolOption
+	if r.cfg != nil {
+		opts = append(opts,
+			hssh.WithKeepAlive(r.cfg.Defaults.KeepAlive.Duration),
+			hssh.WithDialConcurrency(r.cfg.Defaults.DialConcurrency))
+	}
+	pool := hssh.NewPool(r.baseSSHConf, hostConfs, opts...)
+	if r.sudoPassword != "" {
+		pool.SetSudo(true, r.sudoPassword)
+		pool.SetSudoUser(r.sudoUser)
+	}
+	return pool
 }
 
 func (r *REPL) showDiff() {
@@ -462,15 +1135,136 @@ func (r *REPL) showLast() {
 		fmt.Fprintln(os.Stderr, "no previous command results")
 		return
 	}
-	fmt.Fprint(os.Stdout, r.formatter.Format(r.lastGrouped))
+	r.printResults(r.lastResults, r.lastGrouped)
+}
+
+// retryFailed reruns the last command on the hosts it failed on, and merges
+// the new results into the last results so the summary covers every host
+// the command originally ran on.
+func (r *REPL) retryFailed() error {
+	if r.lastGrouped == nil || r.lastCommand == "" {
+		return fmt.Errorf("no previous command results")
+	}
+	hosts, err := selector.Resolve("@failed", &selector.State{Grouped: r.lastGrouped})
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		fmt.Fprintln(os.Stdout, "no failed hosts to retry")
+		return nil
+	}
+	cmd := r.lastCommand
+	fmt.Fprintf(os.Stdout, "retrying %q on %d failed %s\n", cmd, len(hosts), plural("host", len(hosts)))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	retried := r.exec.ExecuteNoCache(ctx, hosts, cmd)
+	stop()
+
+	results := MergeResults(r.lastResults, retried)
+	grouped := r.group(cmd, results)
+	r.printResults(results, grouped)
+
+	r.lastResults = results
+	r.lastGrouped = grouped
+	r.logRun(":retry failed "+cmd, grouped)
+	return nil
+}
+
+// MergeResults returns results with each host's entry replaced by its entry
+// in retried, keeping the original host order. Hosts only in retried are
+// appended.
+func MergeResults(results, retried []*executor.HostResult) []*executor.HostResult {
+	byHost := make(map[string]*executor.HostResult, len(retried))
+	for _, res := range retried {
+		byHost[res.Host] = res
+	}
+	merged := make([]*executor.HostResult, 0, len(results))
+	for _, res := range results {
+		if nr, ok := byHost[res.Host]; ok {
+			res = nr
+			delete(byHost, res.Host)
+		}
+		merged = append(merged, res)
+	}
+	for _, res := range retried {
+		if _, ok := byHost[res.Host]; ok {
+			merged = append(merged, res)
+		}
+	}
+	return merged
+}
+
+// filterLast shows the last results narrowed to the hosts whose stdout
+// matches pattern, without running the command again. The last results are
+// left as they were, so each :filter starts from every host.
+func (r *REPL) filterLast(pattern string) error {
+	if r.lastGrouped == nil {
+		return fmt.Errorf("no previous command results")
+	}
+	re, err := ParseFilter(pattern)
+	if err != nil {
+		return err
+	}
+	filtered := r.lastGrouped.Filter(func(_ string, out []byte) bool {
+		return re.Match(out)
+	})
+
+	matched := make(map[string]bool)
+	for _, g := range filtered.Groups {
+		for _, h := range g.Hosts {
+			matched[h] = true
+		}
+	}
+	for _, res := range filtered.Failed {
+		matched[res.Host] = true
+	}
+	for _, res := range filtered.TimedOut {
+		matched[res.Host] = true
+	}
+	if len(matched) == 0 {
+		fmt.Fprintln(os.Stdout, "no hosts matched")
+		return nil
+	}
+	var results []*executor.HostResult
+	for _, res := range r.lastResults {
+		if matched[res.Host] {
+			results = append(results, res)
+		}
+	}
+	r.printResults(results, filtered)
+	return nil
 }
 
-func (r *REPL) exportJSON(filename string) error {
+// ParseFilter compiles a :filter pattern, written as /regex/ like @match
+// (with an optional trailing i and \/ for a literal slash) or as a bare
+// regex.
+func ParseFilter(pattern string) (*regexp.Regexp, error) {
+	re, err := selector.ParseRegex(pattern)
+	if errors.Is(err, selector.ErrNotRegex) {
+		return regexp.Compile(pattern)
+	}
+	return re, err
+}
+
+// export writes the last results to filename: JUnit XML for a .xml file,
+// for CI test reports, JSON Lines for a .jsonl file, and JSON otherwise.
+func (r *REPL) export(filename string) error {
 	if r.lastResults == nil {
 		return fmt.Errorf("no results to export")
 	}
 
-	data, err := r.formatter.FormatJSON(r.lastResults)
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xml":
+		data, err = execui.FormatJUnit(r.lastGrouped, r.lastCommand)
+	case ".jsonl":
+		var b bytes.Buffer
+		err = r.formatter.WriteJSONL(&b, r.lastResults)
+		data = bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+	default:
+		data, err = r.formatter.FormatJSON(r.lastResults)
+	}
 	if err != nil {
 		return err
 	}
@@ -504,22 +1298,74 @@ func (r *REPL) listRecipes() {
 	}
 }
 
-func (r *REPL) runRecipe(name string) {
-	rec, _, ok := recipe.ResolveRecipe(name, r.cfg)
+// resolveRecipe looks up a recipe by name or unambiguous abbreviation,
+// returning its full name. Unknown and ambiguous names are reported.
+func (r *REPL) resolveRecipe(name string) (string, config.Recipe, bool) {
+	full, err := recipe.ExpandName(name, r.cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return name, config.Recipe{}, false
+	}
+	rec, _, ok := recipe.ResolveRecipe(full, r.cfg)
 	if !ok {
 		fmt.Fprintf(os.Stderr, "unknown recipe %q\n", name)
+		return name, config.Recipe{}, false
+	}
+	if full != name {
+		fmt.Fprintf(os.Stdout, "%q matches recipe %s\n", name, full)
+	}
+	return full, rec, true
+}
+
+// dryRunRecipe lists the hosts and command of each of the named recipe's
+// steps without running them.
+func (r *REPL) dryRunRecipe(name string) {
+	name, rec, ok := r.resolveRecipe(name)
+	if !ok {
 		return
 	}
 
-	steps := make([]recipe.Step, len(rec.Steps))
-	for i, raw := range rec.Steps {
-		steps[i] = recipe.ParseStep(raw)
+	steps := recipe.Steps(r.cfg, rec)
+	runner := recipe.New(r.exec, r.allHosts)
+	runner.SetHostTags(r.hostTags)
+	plans, err := runner.DryRun(context.Background(), steps)
+	for _, p := range plans {
+		fmt.Fprintf(os.Stdout, "\n=== Step %d/%d: %s ===\n", p.Index, len(steps), p.Step.Command)
+		if p.Step.Selector != "" {
+			fmt.Fprintf(os.Stdout, "    Selector: %s\n", p.Step.Selector)
+		}
+		if p.Unresolvable != "" {
+			fmt.Fprintf(os.Stdout, "    Hosts: unresolvable in dry-run: %s\n", p.Unresolvable)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "    Hosts: %d %s: %s\n", len(p.Hosts), plural("host", len(p.Hosts)), strings.Join(p.Hosts, ", "))
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "recipe error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stdout, "\ndry run: %d %s, nothing was run\n", len(plans), plural("step", len(plans)))
+}
+
+// runRecipe runs the named recipe on the session's hosts, printing each
+// step's results and, if timeline is true, how long each step took.
+func (r *REPL) runRecipe(name string, timeline bool) {
+	name, rec, ok := r.resolveRecipe(name)
+	if !ok {
+		return
+	}
+
+	steps := recipe.Steps(r.cfg, rec)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
 	runner := recipe.New(r.exec, r.allHosts)
+	runner.SetHostTags(r.hostTags)
+	if r.pool != nil {
+		runner.SetTransfer(transfer.New(r.pool, transfer.WithConcurrency(r.concurrency),
+			transfer.WithProgress(progress.New(os.Stderr, "transferring"))))
+	}
 	results, err := runner.Run(ctx, steps)
 
 	for i, sr := range results {
@@ -527,7 +1373,12 @@ func (r *REPL) runRecipe(name string) {
 		if sr.Step.Selector != "" {
 			fmt.Fprintf(os.Stdout, "    Selector: %s → %d %s\n", sr.Step.Selector, len(sr.Hosts), plural("host", len(sr.Hosts)))
 		}
-		fmt.Fprint(os.Stdout, r.formatter.Format(sr.Grouped))
+		printRetried(sr.Retried)
+		r.printResults(sr.Results, sr.Grouped)
+		r.logRun(fmt.Sprintf(":recipe %s [%d/%d] %s", name, i+1, len(steps), rec.Steps[i]), sr.Grouped)
+	}
+	if timeline && len(results) > 0 && !r.jsonOutput {
+		fmt.Fprintf(os.Stdout, "\n%s", recipe.FormatTimeline(results))
 	}
 
 	if err != nil {
@@ -540,34 +1391,179 @@ func (r *REPL) runRecipe(name string) {
 		last := results[len(results)-1]
 		r.lastResults = last.Results
 		r.lastGrouped = last.Grouped
+		r.lastCommand = last.Step.Command
+	}
+}
+
+// printRetried lists the hosts a recipe step reran on a transient exit code.
+func printRetried(retried map[string]int) {
+	if len(retried) == 0 {
+		return
+	}
+	hosts := make([]string, 0, len(retried))
+	for h := range retried {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	parts := make([]string, len(hosts))
+	for i, h := range hosts {
+		parts[i] = fmt.Sprintf("%s (%dx)", h, retried[h])
+	}
+	fmt.Fprintf(os.Stdout, "    Retried: %s\n", strings.Join(parts, ", "))
+}
+
+// runRecipeAcrossGroups runs a recipe against several groups at once, each
+// over its own connection pool, and prints each group's steps in turn. The
+// current group and last results are left unchanged.
+func (r *REPL) runRecipeAcrossGroups(name string, groups []string) {
+	name, rec, ok := r.resolveRecipe(name)
+	if !ok {
+		return
+	}
+	if r.cfg == nil {
+		fmt.Fprintln(os.Stderr, "no config loaded; groups are not available")
+		return
+	}
+
+	steps := recipe.Steps(r.cfg, rec)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var mu sync.Mutex
+	var pools []*hssh.Pool
+	defer func() {
+		for _, p := range pools {
+			p.Close()
+		}
+	}()
+	newExec := func(group string, hosts []config.Host, limiter *executor.Limiter) (*executor.Executor, recipe.Transferer, error) {
+		pool := r.newPool(hosts)
+		mu.Lock()
+		pools = append(pools, pool)
+		mu.Unlock()
+		opts := append(r.executorOptions(r.concurrency), executor.WithLimiter(limiter))
+		return executor.New(pool, opts...), transfer.New(pool, transfer.WithConcurrency(r.concurrency)), nil
+	}
+
+	for _, run := range recipe.RunAcrossGroups(ctx, r.cfg, groups, steps, r.concurrency, newExec) {
+		fmt.Fprintf(os.Stdout, "\n##### Group %s (%d %s) #####\n", run.Group, len(run.Hosts), plural("host", len(run.Hosts)))
+		for i, sr := range run.Results {
+			fmt.Fprintf(os.Stdout, "\n=== Step %d/%d: %s ===\n", i+1, len(steps), sr.Step.Command)
+			if sr.Step.Selector != "" {
+				fmt.Fprintf(os.Stdout, "    Selector: %s → %d %s\n", sr.Step.Selector, len(sr.Hosts), plural("host", len(sr.Hosts)))
+			}
+			printRetried(sr.Retried)
+			r.printResults(sr.Results, sr.Grouped)
+			r.logRun(fmt.Sprintf(":recipe %s %s [%d/%d] %s", name, run.Group, i+1, len(steps), rec.Steps[i]), sr.Grouped)
+		}
+		if run.Err != nil {
+			fmt.Fprintf(os.Stderr, "recipe error in group %s: %v\n", run.Group, run.Err)
+		}
 	}
 }
 
-func (r *REPL) parseLastResults(name string) {
+// parseLastResults prints the last results parsed with the named parser,
+// sorted by sortField (largest first, see parser.FormatTableSorted) when it
+// is not empty.
+func (r *REPL) parseLastResults(name, sortField string) {
 	if r.lastResults == nil {
 		fmt.Fprintln(os.Stderr, "no previous command results")
 		return
 	}
+	p, err := r.resolveParser(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
 
-	// Resolve parser: built-in first, then config.
-	builtins := parser.BuiltinParsers()
-	var p *parser.OutputParser
-	if bp, ok := builtins[name]; ok {
-		p = bp
-	} else if pcfg, ok := r.cfg.Parsers[name]; ok {
-		var err error
-		p, err = parser.New(pcfg.Extract)
+	parsed := p.ParseAll(r.lastResults)
+	if sortField == "" {
+		fmt.Fprint(os.Stdout, parser.FormatTableWidth(parsed, r.color, terminalWidth()))
+		return
+	}
+	if len(parsed) > 0 && !slices.ContainsFunc(parsed[0].Fields, func(fv parser.FieldValue) bool { return fv.Field == sortField }) {
+		fmt.Fprintf(os.Stderr, "parser %q has no field %q\n", name, sortField)
+		return
+	}
+	fmt.Fprint(os.Stdout, parser.FormatTableSorted(parsed, r.color, sortField, true))
+}
+
+// checkThresholds parses the last results with the named parser and lists
+// hosts whose fields breach any of the threshold expressions (e.g.
+// "use_pct>90").
+func (r *REPL) checkThresholds(name string, exprs []string) {
+	if r.lastResults == nil {
+		fmt.Fprintln(os.Stderr, "no previous command results")
+		return
+	}
+	p, err := r.resolveParser(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	thresholds := make(map[string]parser.Threshold, len(exprs))
+	for _, expr := range exprs {
+		field, t, err := parser.ParseThreshold(expr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "parser %q: %v\n", name, err)
+			fmt.Fprintln(os.Stderr, err)
 			return
 		}
-	} else {
-		fmt.Fprintf(os.Stderr, "unknown parser %q (built-in: disk, free, uptime)\n", name)
+		thresholds[field] = t
+	}
+
+	alerts := parser.CheckThresholds(p.ParseAll(r.lastResults), thresholds)
+	fmt.Fprint(os.Stdout, parser.FormatAlerts(alerts, r.color))
+}
+
+// aggregateField parses the last results with the named parser and prints
+// the sum, mean, min and max of field across hosts.
+func (r *REPL) aggregateField(name, field string) {
+	if r.lastResults == nil {
+		fmt.Fprintln(os.Stderr, "no previous command results")
+		return
+	}
+	p, err := r.resolveParser(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	out, err := parser.FormatAggregate(p.ParseAll(r.lastResults), field, r.color)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "parser %q: %v\n", name, err)
 		return
 	}
+	fmt.Fprint(os.Stdout, out)
+}
 
-	parsed := p.ParseAll(r.lastResults)
-	fmt.Fprint(os.Stdout, parser.FormatTable(parsed, r.color))
+// resolveParser returns the named parser, preferring built-ins over parsers
+// defined in the config.
+func (r *REPL) resolveParser(name string) (*parser.OutputParser, error) {
+	if bp, ok := parser.BuiltinParsers()[name]; ok {
+		return bp, nil
+	}
+	if r.cfg != nil {
+		if pcfg, ok := r.cfg.Parsers[name]; ok {
+			p, err := parser.New(pcfg.Extract)
+			if err != nil {
+				return nil, fmt.Errorf("parser %q: %w", name, err)
+			}
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown parser %q (built-in: disk, free, uptime, net, security-updates)", name)
+}
+
+// confirm prints question to stderr and reads a y/yes answer from reader.
+func confirm(reader *bufio.Reader, question string) bool {
+	fmt.Fprint(os.Stderr, question)
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
 func plural(word string, n int) string {
@@ -593,6 +1589,9 @@ func drainSignals(ch <-chan os.Signal) bool {
 // Exported for testing.
 func FormatHistoryEntry(index int, e HistoryEntry) string {
 	input := e.Input
+	if e.Restored {
+		return fmt.Sprintf(" %-4d %s", index, input)
+	}
 	if len(input) > 40 {
 		input = input[:37] + "..."
 	}
@@ -630,7 +1629,30 @@ func ParseColonCommand(line string) (cmd string, args []string) {
 
 // ValidCommands returns the list of valid colon-command names.
 func ValidCommands() []string {
-	return []string{":quit", ":q", ":history", ":h", ":hosts", ":group", ":tags", ":timeout", ":diff", ":last", ":export", ":sudo", ":recipe", ":parse"}
+	return []string{":quit", ":q", ":history", ":h", ":hosts", ":connect", ":explain", ":group", ":edit", ":tags", ":os", ":facts", ":timeout", ":diff", ":last", ":filter", ":export", ":sudo", ":recipe", ":parse", ":check", ":agg", ":verify", ":golden", ":retry", ":!!", ":summary", ":nocache", ":multi", ":flat"}
+}
+
+// terminalWidth returns the width of the terminal on stdout, or 0 when
+// stdout is not a terminal so tables are printed in full.
+func terminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	cols, _, err := term.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+	return cols
+}
+
+// formatTimeout renders a command timeout for display, with
+// executor.NoTimeout as "none".
+func formatTimeout(d time.Duration) string {
+	if d == executor.NoTimeout {
+		return "none"
+	}
+	return d.String()
 }
 
 // ParseTimeout parses a timeout duration string, exported for testing.
@@ -638,6 +1660,184 @@ func ParseTimeout(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
+// CompileWarnPatterns compiles warn pattern regexes, skipping any that
+// fail to compile (config validation reports those at load time).
+func CompileWarnPatterns(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		res = append(res, re)
+	}
+	return res
+}
+
+// MatchWarnPattern reports whether command matches any of the warn patterns,
+// returning the first matching pattern's source.
+func MatchWarnPattern(command string, patterns []*regexp.Regexp) (string, bool) {
+	for _, re := range patterns {
+		if re.MatchString(command) {
+			return re.String(), true
+		}
+	}
+	return "", false
+}
+
+// matchAnyWarnPattern reports the first warn pattern matched by any of cmds.
+func matchAnyWarnPattern(cmds []string, patterns []*regexp.Regexp) (string, bool) {
+	for _, c := range cmds {
+		if pat, ok := MatchWarnPattern(c, patterns); ok {
+			return pat, true
+		}
+	}
+	return "", false
+}
+
+// SplitCommands splits a :multi command line on ";" into commands run one
+// after another. Semicolons inside single or double quotes or backticks,
+// escaped with a backslash, inside (), $() or {}, or inside if/fi,
+// for/while/until/select ... done and case/esac blocks do not split, so
+// each piece is a complete shell command. Empty commands are dropped.
+func SplitCommands(line string) []string {
+	var cmds []string
+	var cur, word strings.Builder
+	var quote rune
+	escaped := false
+	depth, blocks := 0, 0
+	cmdStart := true // the next word is in command position
+
+	// endWord tracks compound-command keywords, which only count in
+	// command position.
+	endWord := func() {
+		w := word.String()
+		word.Reset()
+		if w == "" {
+			return
+		}
+		if cmdStart {
+			switch w {
+			case "if", "for", "while", "until", "case", "select":
+				blocks++
+			case "fi", "done", "esac":
+				blocks = max(blocks-1, 0)
+			}
+		}
+		switch w {
+		case "if", "then", "else", "elif", "do", "while", "until", "!", "time":
+			cmdStart = true
+		default:
+			cmdStart = false
+		}
+	}
+	flush := func() {
+		if c := strings.TrimSpace(cur.String()); c != "" {
+			cmds = append(cmds, c)
+		}
+		cur.Reset()
+	}
+	for _, ch := range line {
+		switch {
+		case escaped:
+			escaped = false
+			word.WriteRune(ch)
+		case ch == '\\' && quote != '\'':
+			escaped = true
+			word.WriteRune(ch)
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+			word.WriteRune(ch)
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			word.WriteRune(ch)
+		case ch == '(' || ch == '{':
+			depth++
+			if word.Len() == 0 || word.String() == "$" {
+				cmdStart = true
+			}
+			word.Reset()
+		case ch == ')' || ch == '}':
+			depth = max(depth-1, 0)
+			endWord()
+		case ch == ';':
+			endWord()
+			cmdStart = true
+			if depth == 0 && blocks == 0 {
+				flush()
+				continue
+			}
+		case ch == '&' || ch == '|' || ch == '\n':
+			endWord()
+			cmdStart = true
+		case ch == ' ' || ch == '\t':
+			endWord()
+		default:
+			word.WriteRune(ch)
+		}
+		cur.WriteRune(ch)
+	}
+	flush()
+	return cmds
+}
+
+// ParseRetry reports whether line is :retry or its :!! shorthand and, if
+// so, returns the last history input to rerun ("" when history is empty).
+func ParseRetry(line string, history []HistoryEntry) (string, bool) {
+	if line != ":retry" && line != ":!!" {
+		return "", false
+	}
+	if len(history) == 0 {
+		return "", true
+	}
+	return history[len(history)-1].Input, true
+}
+
+// CommandSummary rolls up the history entries that ran the same command.
+type CommandSummary struct {
+	Command   string // command text without selector or concurrency override
+	Runs      int
+	HostCount int // host outcomes summed over all runs
+	OKCount   int
+	DiffCount int
+	FailCount int
+}
+
+// SummarizeHistory groups history by command text, ignoring the selector,
+// any %N concurrency override and spacing, and totals each command's host
+// outcomes. Commands appear in the order they were first run. Entries
+// restored from earlier sessions are skipped.
+func SummarizeHistory(history []HistoryEntry) []CommandSummary {
+	var out []CommandSummary
+	index := make(map[string]int)
+	for _, e := range history {
+		if e.Restored {
+			continue
+		}
+		_, cmd := selector.ParseInput(e.Input)
+		if _, rest, err := selector.ParseConcurrency(cmd); err == nil {
+			cmd = rest
+		}
+		cmd = strings.Join(strings.Fields(cmd), " ")
+
+		i, ok := index[cmd]
+		if !ok {
+			i = len(out)
+			index[cmd] = i
+			out = append(out, CommandSummary{Command: cmd})
+		}
+		c := &out[i]
+		c.Runs++
+		c.HostCount += e.HostCount
+		c.OKCount += e.OKCount
+		c.DiffCount += e.DiffCount
+		c.FailCount += e.FailCount
+	}
+	return out
+}
+
 // ParseHistoryRef checks if a string is a history reference like "!3".
 // Returns the 1-based index and true if it is, or 0 and false otherwise.
 func ParseHistoryRef(s string) (int, bool) {
@@ -650,3 +1850,23 @@ func ParseHistoryRef(s string) (int, bool) {
 	}
 	return n, true
 }
+
+// ExpandHistoryRef checks if line is a history reference like "!3", or "!!"
+// for the last entry, and if so returns the input of that 1-based history
+// entry. It reports an error for a reference past the end of history.
+func ExpandHistoryRef(line string, history []HistoryEntry) (string, bool, error) {
+	if line == "!!" {
+		if len(history) == 0 {
+			return "", true, fmt.Errorf("!!: no history yet")
+		}
+		return history[len(history)-1].Input, true, nil
+	}
+	n, ok := ParseHistoryRef(line)
+	if !ok {
+		return "", false, nil
+	}
+	if n > len(history) {
+		return "", true, fmt.Errorf("%s: no such history entry (%d in history)", line, len(history))
+	}
+	return history[n-1].Input, true, nil
+}
@@ -1,8 +1,19 @@
 package repl
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/agent462/herd/internal/config"
+	"github.com/agent462/herd/internal/executor"
+	"github.com/agent462/herd/internal/grouper"
+	execui "github.com/agent462/herd/internal/ui/exec"
 )
 
 func TestFormatHistoryEntry(t *testing.T) {
@@ -133,6 +144,97 @@ func TestParseHistoryRef(t *testing.T) {
 	}
 }
 
+func TestExpandHistoryRef(t *testing.T) {
+	history := []HistoryEntry{{Input: "uptime"}, {Input: "@web-* df -h"}}
+	tests := []struct {
+		line    string
+		want    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{"!2", "@web-* df -h", true, false},
+		{"!1", "uptime", true, false},
+		{"!3", "", true, true},
+		{"!!", "@web-* df -h", true, false},
+		{"!0", "", false, false},
+		{"uptime", "", false, false},
+		{"!ls", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok, err := ExpandHistoryRef(tt.line, history)
+			if got != tt.want || ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Errorf("ExpandHistoryRef(%q) = (%q, %v, %v), want (%q, %v, err %v)", tt.line, got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpandHistoryRefEmpty(t *testing.T) {
+	for _, line := range []string{"!!", "!1"} {
+		_, ok, err := ExpandHistoryRef(line, nil)
+		if !ok || err == nil {
+			t.Errorf("ExpandHistoryRef(%q, nil) = %v, %v; want a reference with an error", line, ok, err)
+		}
+	}
+}
+
+func TestParseRetry(t *testing.T) {
+	history := []HistoryEntry{{Input: "uptime"}, {Input: "@failed systemctl restart app"}}
+	tests := []struct {
+		line    string
+		history []HistoryEntry
+		want    string
+		wantOK  bool
+	}{
+		{":retry", history, "@failed systemctl restart app", true},
+		{":!!", history, "@failed systemctl restart app", true},
+		{":retry", nil, "", true},
+		{"uptime", history, "", false},
+		{":retry now", history, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok := ParseRetry(tt.line, tt.history)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseRetry(%q) = (%q, %v), want (%q, %v)", tt.line, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"uptime", []string{"uptime"}},
+		{"systemctl stop nginx ; sleep 2 ; systemctl start nginx", []string{"systemctl stop nginx", "sleep 2", "systemctl start nginx"}},
+		{"echo 'a;b'; echo \"c;d\"", []string{"echo 'a;b'", "echo \"c;d\""}},
+		{`find . -exec rm {} \; ; ls`, []string{`find . -exec rm {} \;`, "ls"}},
+		{`echo 'it\'; date`, []string{`echo 'it\'`, "date"}},
+		{"uptime;; ;", []string{"uptime"}},
+		{" ; ", nil},
+		{"for i in 1 2; do echo $i; done", []string{"for i in 1 2; do echo $i; done"}},
+		{"(cd /tmp; ls); uptime", []string{"(cd /tmp; ls)", "uptime"}},
+		{"echo $(date; hostname)", []string{"echo $(date; hostname)"}},
+		{"echo `date; hostname`", []string{"echo `date; hostname`"}},
+		{"if true; then echo a; fi; echo b", []string{"if true; then echo a; fi", "echo b"}},
+		{"{ echo a; echo b; } > out; cat out", []string{"{ echo a; echo b; } > out", "cat out"}},
+		{"case $x in a) echo a;; *) echo b;; esac; ls", []string{"case $x in a) echo a;; *) echo b;; esac", "ls"}},
+		{"while true; do if x; then y; fi; done; z", []string{"while true; do if x; then y; fi; done", "z"}},
+		{"echo done; echo if; ls", []string{"echo done", "echo if", "ls"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got := SplitCommands(tt.line)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitCommands(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestValidCommands(t *testing.T) {
 	cmds := ValidCommands()
 	if len(cmds) == 0 {
@@ -141,8 +243,10 @@ func TestValidCommands(t *testing.T) {
 
 	required := map[string]bool{
 		":quit": false, ":q": false, ":history": false, ":h": false,
-		":hosts": false, ":group": false, ":tags": false, ":timeout": false,
-		":diff": false, ":last": false, ":export": false,
+		":hosts": false, ":connect": false, ":explain": false, ":group": false, ":tags": false, ":timeout": false,
+		":diff": false, ":last": false, ":filter": false, ":export": false,
+		":retry": false, ":!!": false, ":summary": false, ":flat": false,
+		":verify": false, ":agg": false,
 	}
 	for _, c := range cmds {
 		if _, ok := required[c]; ok {
@@ -156,6 +260,64 @@ func TestValidCommands(t *testing.T) {
 	}
 }
 
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   string
+		want    bool
+	}{
+		{"/nginx 1\\.2[0-9]/", "nginx 1.24", true},
+		{"/nginx/", "apache", false},
+		{"error|warn", "disk warn", true},
+		{"/", "a/b", true},
+		{"//", "", true},
+		{"/NGINX/i", "nginx 1.24", true},
+		{"/NGINX/", "nginx 1.24", false},
+		{`/usr\/sbin/`, "/usr/sbin/nginx", true},
+		{"/var/log", "/var/log/syslog", true},
+	}
+	for _, tt := range tests {
+		re, err := ParseFilter(tt.pattern)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := re.MatchString(tt.match); got != tt.want {
+			t.Errorf("ParseFilter(%q) match %q = %v, want %v", tt.pattern, tt.match, got, tt.want)
+		}
+	}
+
+	if _, err := ParseFilter("/[/"); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	history := []HistoryEntry{
+		{Input: "uptime", HostCount: 3, OKCount: 3},
+		{Input: "@web-* df -h", HostCount: 2, OKCount: 1, DiffCount: 1},
+		{Input: "@failed %2 uptime", HostCount: 1, FailCount: 1},
+		{Input: "df  -h", HostCount: 3, OKCount: 2, FailCount: 1},
+	}
+	got := SummarizeHistory(history)
+	want := []CommandSummary{
+		{Command: "uptime", Runs: 2, HostCount: 4, OKCount: 3, FailCount: 1},
+		{Command: "df -h", Runs: 2, HostCount: 5, OKCount: 3, DiffCount: 1, FailCount: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d summaries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("summary %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := SummarizeHistory(nil); len(got) != 0 {
+		t.Errorf("expected no summaries for empty history, got %+v", got)
+	}
+}
+
 func TestPlural(t *testing.T) {
 	if got := plural("host", 1); got != "host" {
 		t.Errorf("plural(host, 1) = %q, want %q", got, "host")
@@ -167,3 +329,195 @@ func TestPlural(t *testing.T) {
 		t.Errorf("plural(host, 5) = %q, want %q", got, "hosts")
 	}
 }
+
+func TestMatchWarnPattern(t *testing.T) {
+	patterns := CompileWarnPatterns(config.DefaultWarnPatterns())
+
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"rm -rf /tmp/cache", true},
+		{"sudo rm /etc/motd", true},
+		{"dd if=/dev/zero of=/dev/sdb", true},
+		{"mkfs.ext4 /dev/sdb1", true},
+		{"shutdown -h now", true},
+		{"systemctl reboot", true},
+		{"uptime", false},
+		{"ls /srv/firmware", false},
+		{"grep -r address /etc", false},
+		{"cat /var/log/dmesg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			_, got := MatchWarnPattern(tt.command, patterns)
+			if got != tt.want {
+				t.Errorf("MatchWarnPattern(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileWarnPatternsSkipsInvalid(t *testing.T) {
+	res := CompileWarnPatterns([]string{`\bhalt\b`, `([bad`})
+	if len(res) != 1 {
+		t.Fatalf("expected 1 compiled pattern, got %d", len(res))
+	}
+	if pat, ok := MatchWarnPattern("halt -p", res); !ok || pat != `\bhalt\b` {
+		t.Errorf("expected match on \\bhalt\\b, got %q, %v", pat, ok)
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("ok\n")},
+		{Host: "b", ExitCode: 1},
+		{Host: "c", ExitCode: -1, Err: errors.New("connection refused")},
+	}
+	retried := []*executor.HostResult{
+		{Host: "c", Stdout: []byte("ok\n")},
+		{Host: "b", Stdout: []byte("ok\n")},
+	}
+	merged := MergeResults(results, retried)
+	if len(merged) != 3 || merged[0] != results[0] || merged[1] != retried[1] || merged[2] != retried[0] {
+		t.Errorf("merged = %+v, want a unchanged and b, c replaced in order", merged)
+	}
+}
+
+// retryRunner succeeds on every host, recording the hosts it ran on.
+type retryRunner struct{ hosts []string }
+
+func (rr *retryRunner) Run(_ context.Context, host, _ string) *executor.HostResult {
+	rr.hosts = append(rr.hosts, host)
+	return &executor.HostResult{Host: host, Stdout: []byte("ok\n")}
+}
+
+func TestRetryFailed(t *testing.T) {
+	runner := &retryRunner{}
+	r := &REPL{
+		exec:      executor.New(runner, executor.WithConcurrency(1)),
+		formatter: execui.NewFormatter(false, false, false),
+	}
+	if err := r.retryFailed(); err == nil {
+		t.Error("expected an error without previous results")
+	}
+
+	r.lastCommand = "uptime"
+	r.lastResults = []*executor.HostResult{
+		{Host: "a", Stdout: []byte("ok\n")},
+		{Host: "b", ExitCode: 2},
+		{Host: "c", ExitCode: -1, Err: errors.New("connection refused")},
+	}
+	r.lastGrouped = grouper.Group(r.lastResults)
+	if err := r.retryFailed(); err != nil {
+		t.Fatalf("retryFailed: %v", err)
+	}
+
+	slices.Sort(runner.hosts)
+	if strings.Join(runner.hosts, ",") != "b,c" {
+		t.Errorf("retried hosts = %v, want only b and c", runner.hosts)
+	}
+	if len(r.lastResults) != 3 || len(r.lastGrouped.Groups) != 1 || len(r.lastGrouped.Groups[0].Hosts) != 3 {
+		t.Errorf("after retry, grouped = %+v, want all 3 hosts in one group", r.lastGrouped)
+	}
+}
+
+func TestVerifySelector(t *testing.T) {
+	runner := &retryRunner{}
+	r := &REPL{
+		exec:      executor.New(runner, executor.WithConcurrency(1)),
+		formatter: execui.NewFormatter(false, false, false),
+		allHosts:  []string{"web-01", "web-02", "db-01"},
+	}
+	if err := r.verify("ok @web-* uptime"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	slices.Sort(runner.hosts)
+	if strings.Join(runner.hosts, ",") != "web-01,web-02" {
+		t.Errorf("verified hosts = %v, want only the web hosts", runner.hosts)
+	}
+	if r.lastCommand != "uptime" {
+		t.Errorf("lastCommand = %q, want the command without its selector", r.lastCommand)
+	}
+
+	if err := r.verify("ok @nope-* uptime"); err == nil {
+		t.Error("expected an error when the selector matches no host")
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	for host, content := range map[string]string{"web-01": "ok\n", "web-02": "stale\n"} {
+		if err := os.WriteFile(filepath.Join(dir, host+".txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner := &retryRunner{}
+	r := &REPL{
+		exec:      executor.New(runner, executor.WithConcurrency(1)),
+		formatter: execui.NewFormatter(false, false, false),
+		allHosts:  []string{"web-01", "web-02", "db-01"},
+	}
+
+	if err := r.golden(dir + " @web-01 uptime"); err != nil {
+		t.Errorf("golden on a matching host: %v", err)
+	}
+	if strings.Join(runner.hosts, ",") != "web-01" || r.lastCommand != "uptime" {
+		t.Errorf("ran %q on %v, want uptime on web-01 only", r.lastCommand, runner.hosts)
+	}
+
+	err := r.golden(dir + " uptime")
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 hosts") {
+		t.Errorf("err = %v, want web-02 (mismatch) and db-01 (missing) reported", err)
+	}
+	if err := r.golden(dir); err == nil {
+		t.Error("expected a usage error without a command")
+	}
+}
+
+func TestFlatToggle(t *testing.T) {
+	r := &REPL{}
+	results := []*executor.HostResult{
+		{Host: "a", Stdout: []byte("a\n")},
+		{Host: "b", Stdout: []byte("a\n")},
+	}
+	if got := r.group("hostname", results); len(got.Groups) != 1 {
+		t.Errorf("grouped mode: got %d groups, want 1", len(got.Groups))
+	}
+
+	r.handleCommand(":flat")
+	if !r.flatOutput {
+		t.Fatal(":flat should enable flat output")
+	}
+	if got := r.group("hostname", results); len(got.Groups) != 2 {
+		t.Errorf("flat mode: got %d groups, want one per host", len(got.Groups))
+	}
+
+	r.handleCommand(":flat")
+	if r.flatOutput {
+		t.Error(":flat again should disable flat output")
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	r := &REPL{
+		formatter: execui.NewFormatter(false, false, false),
+		lastResults: []*executor.HostResult{
+			{Host: "a", Stdout: []byte("ok\n")},
+			{Host: "b", ExitCode: 1},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	if err := r.export(path); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"host":"a",`) || !strings.HasPrefix(lines[1], `{"host":"b",`) {
+		t.Errorf("export wrote:\n%s\nwant one JSON object per host", data)
+	}
+}
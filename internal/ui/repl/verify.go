@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/agent462/herd/internal/grouper"
+	"github.com/agent462/herd/internal/selector"
+)
+
+// verify runs a command on the hosts its selector picks, every host by
+// default, and checks each host's trimmed output against an expected value,
+// for post-deploy assertions. It returns an error when any host does not
+// match, fails to connect or times out.
+func (r *REPL) verify(args string) error {
+	expected, cmd, err := SplitVerifyArgs(args)
+	if err != nil {
+		return err
+	}
+	exp, err := grouper.ParseExpectation(expected)
+	if err != nil {
+		return fmt.Errorf("expected value: %w", err)
+	}
+
+	// The command may start with a selector, as on a normal command line.
+	sel, cmd := selector.ParseInput(cmd)
+	if strings.TrimSpace(cmd) == "" {
+		return errors.New("no command specified")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	hosts, err := r.resolveSelector(ctx, sel)
+	if err != nil {
+		return err
+	}
+
+	// Verification always reaches the hosts rather than trusting the cache.
+	results := r.exec.ExecuteNoCache(ctx, hosts, cmd)
+
+	report := grouper.Verify(results, exp)
+	fmt.Fprint(os.Stdout, r.formatter.FormatVerify(report))
+
+	grouped := r.group(cmd, results)
+	r.lastResults = results
+	r.lastGrouped = grouped
+	r.lastCommand = cmd
+	r.logRun(":verify "+args, grouped)
+
+	if !report.OK() {
+		failed := len(results) - len(report.Passed)
+		return fmt.Errorf("%d of %d %s did not verify", failed, len(results), plural("host", len(results)))
+	}
+	return nil
+}
+
+// SplitVerifyArgs splits the arguments of :verify into the expected value
+// and the command to run. The expected value is the first word, a /regex/
+// or a "quoted value", either of which may contain spaces; "" expects no
+// output.
+func SplitVerifyArgs(args string) (expected, command string, err error) {
+	args = strings.TrimSpace(args)
+	var rest string
+	quoted := strings.HasPrefix(args, `"`)
+	switch {
+	case strings.HasPrefix(args, "/"):
+		end := strings.Index(args[1:], "/ ")
+		if end < 0 {
+			return "", "", errors.New("unterminated /regex/")
+		}
+		expected, rest = args[:end+2], args[end+2:]
+	case quoted:
+		end := strings.Index(args[1:], `"`)
+		if end < 0 {
+			return "", "", errors.New("unterminated quoted value")
+		}
+		expected, rest = args[1:end+1], args[end+2:]
+	default:
+		expected, rest, _ = strings.Cut(args, " ")
+	}
+	command = strings.TrimSpace(rest)
+	if (expected == "" && !quoted) || command == "" {
+		return "", "", errors.New("usage: :verify <expected> <command>")
+	}
+	return expected, command, nil
+}
@@ -0,0 +1,33 @@
+package repl
+
+import "testing"
+
+func TestSplitVerifyArgs(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected string
+		command  string
+	}{
+		{"12 lsb_release -rs", "12", "lsb_release -rs"},
+		{`"Debian GNU/Linux 12" grep PRETTY /etc/os-release`, "Debian GNU/Linux 12", "grep PRETTY /etc/os-release"},
+		{`"" systemctl --failed --plain --no-legend`, "", "systemctl --failed --plain --no-legend"},
+		{"/^nginx\\/1\\.2[45] / nginx -v", "/^nginx\\/1\\.2[45] /", "nginx -v"},
+		{"  active   systemctl is-active nginx ", "active", "systemctl is-active nginx"},
+	}
+	for _, tt := range tests {
+		expected, command, err := SplitVerifyArgs(tt.args)
+		if err != nil {
+			t.Errorf("SplitVerifyArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if expected != tt.expected || command != tt.command {
+			t.Errorf("SplitVerifyArgs(%q) = %q, %q; want %q, %q", tt.args, expected, command, tt.expected, tt.command)
+		}
+	}
+
+	for _, args := range []string{"12", `"unterminated uptime`, "/unterminated uptime", `"value"`} {
+		if _, _, err := SplitVerifyArgs(args); err == nil {
+			t.Errorf("SplitVerifyArgs(%q): expected an error", args)
+		}
+	}
+}